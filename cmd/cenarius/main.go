@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	log "github.com/sirupsen/logrus"
@@ -75,10 +76,62 @@ func getServerEnv(conf *server.Config) *server.Config {
 	if ok {
 		conf.SecretFilePath = secretPath
 	}
+	sessionKey, ok := os.LookupEnv("CENARIUS_SESSION_KEY")
+	if ok {
+		conf.SessionKey = sessionKey
+	}
+	keyDir, ok := os.LookupEnv("CENARIUS_KEY_DIR")
+	if ok {
+		conf.KeyDir = keyDir
+	}
+	fileKey, ok := os.LookupEnv("CENARIUS_FILE_KEY")
+	if ok {
+		conf.FileKey = fileKey
+	}
+	blobStorage, ok := os.LookupEnv("CENARIUS_BLOB_STORAGE")
+	if ok {
+		conf.BlobStorage = blobStorage
+	}
+	s3Endpoint, ok := os.LookupEnv("CENARIUS_S3_ENDPOINT")
+	if ok {
+		conf.S3Endpoint = s3Endpoint
+	}
+	s3Region, ok := os.LookupEnv("CENARIUS_S3_REGION")
+	if ok {
+		conf.S3Region = s3Region
+	}
+	s3Bucket, ok := os.LookupEnv("CENARIUS_S3_BUCKET")
+	if ok {
+		conf.S3Bucket = s3Bucket
+	}
+	s3AccessKey, ok := os.LookupEnv("CENARIUS_S3_ACCESS_KEY")
+	if ok {
+		conf.S3AccessKey = s3AccessKey
+	}
+	s3SecretKey, ok := os.LookupEnv("CENARIUS_S3_SECRET_KEY")
+	if ok {
+		conf.S3SecretKey = s3SecretKey
+	}
 	migrationPath, ok := os.LookupEnv("CENARIUS_MIGRATION_PATH")
 	if ok {
 		conf.MigrationPath = migrationPath
 	}
+	trashRetention, ok := os.LookupEnv("CENARIUS_TRASH_RETENTION")
+	if ok {
+		d, err := time.ParseDuration(trashRetention)
+		if err != nil {
+			log.Fatalf("Bad CENARIUS_TRASH_RETENTION: %v", err)
+		}
+		conf.TrashRetention = d
+	}
+	uploadTTL, ok := os.LookupEnv("CENARIUS_UPLOAD_TTL")
+	if ok {
+		d, err := time.ParseDuration(uploadTTL)
+		if err != nil {
+			log.Fatalf("Bad CENARIUS_UPLOAD_TTL: %v", err)
+		}
+		conf.UploadTTL = d
+	}
 	return conf
 }
 
@@ -124,18 +177,34 @@ func getAgentEnv(conf *agent.Config) *agent.Config {
 	if ok {
 		conf.Password = password
 	}
+	sshAgentSocket, ok := os.LookupEnv("CENARIUS_SSH_AGENT_SOCKET")
+	if ok {
+		conf.SSHAgentSocket = sshAgentSocket
+	}
+	clientID, ok := os.LookupEnv("CENARIUS_CLIENT_ID")
+	if ok {
+		conf.ClientID = clientID
+	}
+	idleLock, ok := os.LookupEnv("CENARIUS_IDLE_LOCK")
+	if ok {
+		d, err := time.ParseDuration(idleLock)
+		if err != nil {
+			log.Fatalf("Bad CENARIUS_IDLE_LOCK: %v", err)
+		}
+		conf.IdleLock = d
+	}
 	return conf
 }
 
 func main() {
-	flag.StringVar(&flagsData.mode, "m", "", "server or agent")
+	flag.StringVar(&flagsData.mode, "m", "", "server, agent, tui or watch, agent runs a single command if one is given after flags")
 	flag.StringVar(&flagsData.conf, "conf", "conf/conf.toml", "path to toml conf")
 	flag.StringVar(&flagsData.logLevel, "logLevel", "", "LogLevel")
 	flag.StringVar(&flagsData.host, "host", "", "Server address")
 	flag.StringVar(&flagsData.databaseDSN, "databaseDSN", "", "Database DNS for server")
 	flag.StringVar(&flagsData.secretFilePath, "secretFilePath", "", "Storage path for secret files")
 	flag.StringVar(&flagsData.login, "login", "", "Login for agent")
-	flag.StringVar(&flagsData.login, "password", "", "Password for agent")
+	flag.StringVar(&flagsData.password, "password", "", "Password for agent")
 	flag.Parse()
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, os.Interrupt)
@@ -149,14 +218,23 @@ func main() {
 		conf = getServerEnv(conf)
 		log.Debugf("Conf after env variables: %v", conf)
 		worker = server.NewServer(conf)
-	case "agent":
+	case "agent", "tui", "watch":
 		conf := getAgentConfig(agent.NewConfig())
 		log.Debugf("Conf after file configuration: %v", conf)
 		conf = getAgentFlags(conf)
 		log.Debugf("Conf after flags: %v", conf)
 		conf = getAgentEnv(conf)
 		log.Debugf("Conf after env variables: %v", conf)
-		worker = agent.NewAgent(conf)
+		switch {
+		case flagsData.mode == "tui":
+			worker = agent.NewTUI(conf)
+		case flagsData.mode == "watch":
+			worker = agent.NewWatcher(conf)
+		case flag.NArg() > 0:
+			os.Exit(agent.NewAgent(conf).Run(flag.Args()))
+		default:
+			worker = agent.NewAgent(conf)
+		}
 	default:
 		flag.Usage()
 		log.Fatalf("Unknown mode %v", flagsData.mode)
@@ -5,6 +5,7 @@ import (
 	"cenarius/internal/cache"
 	"cenarius/internal/cache/filecache"
 	"cenarius/internal/cache/mcache"
+	"cenarius/internal/encrypt"
 	"cenarius/internal/model"
 	"cenarius/internal/server"
 	"cenarius/internal/userinput"
@@ -16,16 +17,31 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
-var errBadHTTPStatusCode = errors.New("bad http status code")
+var (
+	errBadHTTPStatusCode = errors.New("bad http status code")
+	errSecretNotFound    = errors.New("secret not found in cache")
+	errIncorrectPassword = errors.New("incorrect current password")
+	errPendingChanges    = errors.New("offline changes are not sent yet, sync or discard them first")
+	errSessionExpired    = errors.New("session expired and login failed")
+	errShortPassword     = fmt.Errorf("master password must be at least %d characters long", minPasswordLength)
+)
+
+// minPasswordLength is the shortest master password, the server never sees it to check it
+const minPasswordLength = 8
+
+// sessionRefreshWindow is how long before expiration the session token gets refreshed
+const sessionRefreshWindow = time.Minute
 
 type agent struct {
 	client     http.Client
@@ -33,7 +49,19 @@ type agent struct {
 	logger     *logrus.Logger
 	cache      cache.StoreCache
 	store      cache.StoreCache
+	session    *model.Session
+	key        []byte
 	onlineMode bool
+	// input reads commands of the interactive session
+	input *lineReader
+	// journal holds changes made offline, kdfSalt is saved with it to open the vault offline
+	journal       []*journalOp
+	kdfSalt       string
+	lastReconnect time.Time
+	// lockMu guards the lock state and the ssh-agent, the vault is locked by the session goroutine only
+	lockMu       sync.Mutex
+	locked       bool
+	stopSSHAgent context.CancelFunc
 }
 
 // NewServer returns new server object
@@ -52,11 +80,21 @@ func NewAgent(config *Config) *agent {
 // Start starts the agent
 func (a *agent) Start() error {
 	ctx := context.Background()
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+	a.resumeUploads(ctx)
+	a.repl(ctx)
+	a.close()
+	return nil
+}
+
+// connect reads the local cache, logs in and refreshes the cache from the server
+func (a *agent) connect(ctx context.Context) error {
 	a.logger.Info("Configuring logger")
 	if err := a.configureLogger(); err != nil {
 		return err
 	}
-	a.setKeyAndIV()
 	a.logger.Info("Configuring store")
 	if err := a.configureStore(); err != nil {
 		return err
@@ -65,31 +103,54 @@ func (a *agent) Start() error {
 	if err := a.readCache(); err != nil {
 		return err
 	}
-	a.logger.Info("Checking the server availability")
-	statusCode, err := a.ping(ctx)
+	a.logger.Info("Logging in")
+	statusCode, err := a.login(ctx)
+	if isOffline(err) {
+		a.logger.Warnf("Server is unavailable, working offline: %s", err.Error())
+		return a.openOffline()
+	}
 	if err != nil {
 		return err
 	}
-	if statusCode < 0 {
-		a.onlineMode = false
-	}
 	if statusCode == http.StatusUnauthorized {
 		a.register(ctx)
+		if statusCode, err = a.login(ctx); err != nil {
+			return err
+		}
 	}
-	if err := a.updateCache(ctx); err != nil {
+	if statusCode != http.StatusOK {
+		a.logger.Errorf("Unable to login: %d", statusCode)
+		return errBadHTTPStatusCode
+	}
+	a.setVaultKey()
+	if err := a.loadJournal(); err != nil {
 		return err
 	}
-	a.userInput()
-	return nil
+	a.logger.Info("Checking the server availability")
+	statusCode, err = a.ping(ctx)
+	if err != nil {
+		return err
+	}
+	a.onlineMode = statusCode == http.StatusOK
+	a.replayJournal(ctx, failConflict)
+	return a.updateCache(ctx)
 }
 
 // Stop stops the agent
 func (a *agent) Shutdown() {
+	if a.input != nil {
+		a.input.restore()
+	}
+	a.close()
+	os.Exit(0)
+}
+
+// close saves the cache to the store and closes it
+func (a *agent) close() {
 	if err := a.saveCache(); err != nil {
 		a.logger.Errorf("Unable to save cache: %s", err.Error())
 	}
 	a.store.Cache().Close()
-	os.Exit(0)
 }
 
 // configureLogger configures logger
@@ -112,20 +173,30 @@ func (a *agent) configureStore() error {
 	return nil
 }
 
-func (a *agent) setKeyAndIV() {
-	a.logger.Debug("agent.getKeyAndIV is working")
-	key := a.config.Login + a.config.Password
-	a.logger.Debug("agent.getKeyAndIV key: ", key)
-	if len(key) < 32 {
-		for i := 1; len(key) < 32; i++ {
-			key += strconv.Itoa(i)
+// setVaultKey derives the key secrets are encrypted with from the master password and user's salt.
+// The key never leaves the agent, server stores only encrypted payloads.
+func (a *agent) setVaultKey() {
+	a.logger.Debug("agent.setVaultKey is working")
+	a.key = encrypt.DeriveKey(a.config.Password, a.session.KDFSalt)
+}
+
+// seal derives generated fields, validates plain secret and encrypts its payload with the vault key
+func (a *agent) seal(m model.Secret) error {
+	if d, ok := m.(model.Deriver); ok {
+		if err := d.Derive(); err != nil {
+			a.logger.Errorf("Secret is malformed: %s", err.Error())
+			return err
 		}
 	}
-	key = key[0:32]
-	iv := key[0:16]
-	a.logger.Debug("agent.getKeyAndIV Key, vector: ", key, iv)
-	a.config.SecretKey = key
-	a.config.SecretIV = iv
+	if err := m.Validate(); err != nil {
+		a.logger.Errorf("Secret validation failed: %s", err.Error())
+		return err
+	}
+	if err := m.Encrypt(a.key); err != nil {
+		a.logger.Errorf("agent.seal failed to encrypt: %s", err.Error())
+		return err
+	}
+	return nil
 }
 func (a *agent) readCache() error {
 	c, err := a.store.Cache().Get()
@@ -141,16 +212,22 @@ func (a *agent) readCache() error {
 	return nil
 }
 
+// getSecretsWrapper gets the uri into v. Lists paged by the server are walked to the last page
+// and their items are joined, so v gets the whole list.
 func (a *agent) getSecretsWrapper(ctx context.Context, uri string, v any) error {
-	data, s, err := a.sendRequest2(ctx, uri, http.MethodGet, nil)
+	data, next, err := a.getPage(ctx, uri)
 	if err != nil {
 		return err
 	}
-	if s != http.StatusOK && s != http.StatusCreated {
-		a.logger.Errorf("Faliled to get %s: %d", uri, s)
-		return errBadHTTPStatusCode
+	for next != "" {
+		var page []byte
+		if page, next, err = a.getPage(ctx, withCursor(uri, next)); err != nil {
+			return err
+		}
+		if data, err = joinArrays(data, page); err != nil {
+			return err
+		}
 	}
-	a.logger.Debugf("Got from %s: %v", uri, string(data))
 	if err := json.Unmarshal(data, &v); err != nil {
 		a.logger.Errorf("agent.getSecrets unmarshal json failed %v: %v", string(data), err)
 		return err
@@ -158,31 +235,75 @@ func (a *agent) getSecretsWrapper(ctx context.Context, uri string, v any) error
 	return nil
 }
 
-func (a *agent) getSecrets(ctx context.Context) (*model.SecretCache, error) {
-	var cache = &model.SecretCache{}
-	if err := a.getSecretsWrapper(ctx, logingWithPasswordGetURI, &cache.LoginWithPasswords); err != nil {
-		return nil, err
+// getPage returns the body of the response and the cursor of the next page, which is empty on the last page
+func (a *agent) getPage(ctx context.Context, uri string) ([]byte, string, error) {
+	data, s, header, err := a.exchange(ctx, uri, http.MethodGet, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if s != http.StatusOK && s != http.StatusCreated {
+		a.logger.Errorf("Faliled to get %s: %d", uri, s)
+		return nil, "", errBadHTTPStatusCode
 	}
-	if err := a.getSecretsWrapper(ctx, creditCardGetURI, &cache.CreditCards); err != nil {
+	a.logger.Debugf("Got from %s: %v", uri, string(data))
+	return data, header.Get(server.NextCursorHeader), nil
+}
+
+// withCursor returns the uri of the page of the cursor
+func withCursor(uri, cursor string) string {
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+	return uri + sep + "cursor=" + url.QueryEscape(cursor)
+}
+
+// joinArrays returns the json array of items of both json arrays
+func joinArrays(a, b []byte) ([]byte, error) {
+	var items, more []json.RawMessage
+	if err := json.Unmarshal(a, &items); err != nil {
 		return nil, err
 	}
-	if err := a.getSecretsWrapper(ctx, secretTextGetURI, &cache.SecretTexts); err != nil {
+	if err := json.Unmarshal(b, &more); err != nil {
 		return nil, err
 	}
-	if err := a.getSecretsWrapper(ctx, secretFileGetURI, &cache.SecretFiles); err != nil {
+	return json.Marshal(append(items, more...))
+}
+
+// getChanges returns changes of secrets after the revision, zero revision gets every secret
+func (a *agent) getChanges(ctx context.Context, since int64) (*model.SyncChanges, error) {
+	changes := &model.SyncChanges{}
+	uri := fmt.Sprintf("%s?since=%d", syncURI, since)
+	if err := a.getSecretsWrapper(ctx, uri, changes); err != nil {
 		return nil, err
 	}
-	a.logger.Debugf("Got new cache from server: %v", cache)
-	return cache, nil
+	if changes.Secrets == nil {
+		changes.Secrets = &model.SecretCache{}
+	}
+	a.logger.Debugf("Got changes since %d from server: %v", since, changes.Secrets)
+	return changes, nil
 }
 
+// updateCache patches the cache with changes of the server since the revision of the cache,
+// a cache without revision is replaced. Offline the cache is kept.
 func (a *agent) updateCache(ctx context.Context) error {
-	cache, err := a.getSecrets(ctx)
+	if !a.onlineMode {
+		a.logger.Debug("agent.updateCache is offline, the cache is kept")
+		return nil
+	}
+	cache, err := a.cache.Cache().Get()
 	if err != nil {
 		return err
 	}
-	a.logger.Debugf("Got secret: %v", cache)
-	if err := cache.Encrypt(a.config.SecretKey, a.config.SecretIV); err != nil {
+	if cache == nil || cache.Revision == 0 {
+		cache = &model.SecretCache{}
+	}
+	changes, err := a.getChanges(ctx, cache.Revision)
+	if err != nil {
+		return err
+	}
+	cache.Apply(changes)
+	if err := a.cache.Cache().Save(cache); err != nil {
 		return err
 	}
 	if err := a.store.Cache().Save(cache); err != nil {
@@ -191,12 +312,20 @@ func (a *agent) updateCache(ctx context.Context) error {
 	return nil
 }
 
-func (a *agent) saveCache() error {
+// resetCache drops the revision of the cache, so the next sync replaces it. Re-encrypting the vault
+// keeps revisions of secrets, a cache sealed with the key of the previous password is refreshed only this way.
+func (a *agent) resetCache() error {
 	c, err := a.cache.Cache().Get()
-	if err != nil {
+	if err != nil || c == nil {
 		return err
 	}
-	if err := c.Encrypt(a.config.SecretKey, a.config.SecretIV); err != nil {
+	c.Revision = 0
+	return a.cache.Cache().Save(c)
+}
+
+func (a *agent) saveCache() error {
+	c, err := a.cache.Cache().Get()
+	if err != nil {
 		return err
 	}
 	if err := a.store.Cache().Save(c); err != nil {
@@ -205,17 +334,29 @@ func (a *agent) saveCache() error {
 	return nil
 }
 
-func (a *agent) printEncryptedSecret(i model.Encrypter) error {
-	if err := i.Decrypt(a.config.SecretKey, a.config.SecretIV); err != nil {
-		a.logger.Errorf("agent.printEncryptedSecret failed to decrypt %v : %v", i, err.Error())
-		return err
+// findSecret returns decrypted copy of the cached secret
+func (a *agent) findSecret(kind model.Kind, id int) (model.Secret, error) {
+	cache, err := a.cache.Cache().Get()
+	if err != nil {
+		return nil, err
 	}
-	fmt.Println(i)
-	if err := i.Encrypt(a.config.SecretKey, a.config.SecretIV); err != nil {
-		a.logger.Errorf("agent.printEncryptedSecret failed to encrypt %v : %v", i, err.Error())
-		return err
+	cached, ok := cache.Find(kind, id)
+	if !ok {
+		return nil, errSecretNotFound
 	}
-	return nil
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return nil, err
+	}
+	m := kind.New()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if err := m.Decrypt(a.key); err != nil {
+		a.logger.Errorf("agent.findSecret failed to decrypt %v : %v", cached, err.Error())
+		return nil, err
+	}
+	return m, nil
 }
 
 // write2Buffer writes jsonData to buf
@@ -244,10 +385,6 @@ func (a *agent) write2Buffer(buf *bytes.Buffer, v any) {
 	}
 }
 
-func (a *agent) encodeAuth() string {
-	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s %s", a.config.Login, a.config.Password)))
-}
-
 func (a *agent) getRequest(ctx context.Context, method string, endpoint string, buf *bytes.Buffer) (*http.Request, error) {
 	var req *http.Request
 	var err error
@@ -259,7 +396,18 @@ func (a *agent) getRequest(ctx context.Context, method string, endpoint string,
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set(server.AuthHeader, a.encodeAuth())
+	if a.session != nil {
+		if err := a.refreshSession(ctx); err != nil {
+			return nil, err
+		}
+		if a.session == nil {
+			return nil, errSessionExpired
+		}
+		req.Header.Set(server.AuthHeader, a.session.Token)
+	}
+	if a.config.ClientID != "" {
+		req.Header.Set(server.ClientHeader, a.config.ClientID)
+	}
 	if a.config.GZip {
 		req.Header.Set("Content-Encoding", "gzip")
 	}
@@ -304,6 +452,17 @@ func (a *agent) sendRequest(ctx context.Context, path string, method string, v a
 
 // sendRequest send http request
 func (a *agent) sendRequest2(ctx context.Context, path string, method string, v any) ([]byte, int, error) {
+	return a.sendRequestWithHeader(ctx, path, method, v, nil)
+}
+
+// sendRequestWithHeader sends http request with additional headers
+func (a *agent) sendRequestWithHeader(ctx context.Context, path string, method string, v any, header http.Header) ([]byte, int, error) {
+	data, s, _, err := a.exchange(ctx, path, method, v, header)
+	return data, s, err
+}
+
+// exchange sends http request with additional headers and returns headers of the response too
+func (a *agent) exchange(ctx context.Context, path string, method string, v any, header http.Header) ([]byte, int, http.Header, error) {
 	endpoint := a.geHTTPtURL(path)
 	var buf bytes.Buffer
 	a.logger.Debug("sendRequest endpoint: ", endpoint)
@@ -313,13 +472,16 @@ func (a *agent) sendRequest2(ctx context.Context, path string, method string, v
 	req, err := a.getRequest(ctx, method, endpoint, &buf)
 	if err != nil {
 		a.logger.Errorf("agent.sendRequest req err: %s", err.Error())
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for k, vv := range header {
+		req.Header[k] = vv
+	}
 	resp, err := a.client.Do(req)
 	if err != nil {
 		a.logger.Errorf("agent.sendRequest resp err: %s", err.Error())
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 	defer resp.Body.Close()
 	a.logger.Debugf("sendRequest response code: %d", resp.StatusCode)
@@ -327,388 +489,582 @@ func (a *agent) sendRequest2(ctx context.Context, path string, method string, v
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		a.logger.Errorf("sendRequest err: %s", err.Error())
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
-	return bodyBytes, resp.StatusCode, nil
+	return bodyBytes, resp.StatusCode, resp.Header, nil
 }
 
+// register creates the account. The salt of the vault key is made by the agent, the server gets
+// the auth key derived from the vault key instead of the master password.
 func (a *agent) register(ctx context.Context) {
-	m := &model.User{Login: a.config.Login, Password: a.config.Password}
+	if len(a.config.Password) < minPasswordLength {
+		a.logger.Errorf("Master password must be at least %d characters long", minPasswordLength)
+		return
+	}
+	salt, err := encrypt.NewSalt()
+	if err != nil {
+		a.logger.Errorf("agent.register unable to make salt: %s", err.Error())
+		return
+	}
+	authKey, err := encrypt.AuthKey(encrypt.DeriveKey(a.config.Password, salt))
+	if err != nil {
+		a.logger.Errorf("agent.register unable to derive auth key: %s", err.Error())
+		return
+	}
+	m := &model.User{Login: a.config.Login, Password: authKey, KDFSalt: salt}
 	a.sendRequest(ctx, registerURI, http.MethodPost, m, false)
 }
 
-func (a *agent) ping(ctx context.Context) (int, error) {
-	m := &model.User{Login: a.config.Login, Password: a.config.Password}
-	_, s, err := a.sendRequest2(ctx, pingURI, http.MethodGet, m)
+// prelogin returns the salt of the vault key of the login
+func (a *agent) prelogin(ctx context.Context) (*model.Prelogin, int, error) {
+	data, s, err := a.sendRequest2(ctx, preloginURI, http.MethodPost, &model.User{Login: a.config.Login})
+	if err != nil || s != http.StatusOK {
+		return nil, s, err
+	}
+	p := &model.Prelogin{}
+	if err := json.Unmarshal(data, p); err != nil {
+		a.logger.Errorf("agent.prelogin unmarshal json failed %v: %v", string(data), err)
+		return nil, 0, err
+	}
+	return p, s, nil
+}
+
+// login exchanges login and the auth key for the session token. Accounts made before auth keys
+// send the master password for the last time and upgradeVault replaces it with the auth key.
+func (a *agent) login(ctx context.Context) (int, error) {
+	a.session = nil
+	p, s, err := a.prelogin(ctx)
+	if err != nil {
+		a.logger.Errorf("agent.login error: %s", err.Error())
+		return 0, err
+	}
+	if s != http.StatusOK {
+		return s, nil
+	}
+	key := encrypt.DeriveKey(a.config.Password, p.KDFSalt)
+	authKey, err := encrypt.AuthKey(key)
 	if err != nil {
-		a.logger.Errorf("agent.ping error: %s", err.Error())
 		return 0, err
 	}
+	m := &model.User{Login: a.config.Login, Password: authKey}
+	if p.LegacyAuth {
+		m.Password = a.config.Password
+	}
+	data, s, err := a.sendRequest2(ctx, loginURI, http.MethodPost, m)
+	if err != nil {
+		a.logger.Errorf("agent.login error: %s", err.Error())
+		return 0, err
+	}
+	if s != http.StatusOK {
+		return s, nil
+	}
+	session := &model.Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		a.logger.Errorf("agent.login unmarshal json failed %v: %v", string(data), err)
+		return 0, err
+	}
+	a.session = session
+	a.logger.Debugf("agent.login got session: %v", session)
+	if p.LegacyAuth {
+		if err := a.upgradeVault(ctx, key, authKey); err != nil {
+			a.session = nil
+			return 0, err
+		}
+	}
 	return s, nil
 }
 
-func (a *agent) listLogingWithPassword(ctx context.Context) {
-	fmt.Println("You Logins and Passwords: ")
-	cache, err := a.cache.Cache().Get()
+// upgradeVault reseals secrets the server sealed before vault keys with the vault key,
+// the server replaces the master password with the auth key in the same request
+func (a *agent) upgradeVault(ctx context.Context, key []byte, authKey string) error {
+	data, s, err := a.sendRequest2(ctx, legacyURI, http.MethodGet, nil)
 	if err != nil {
-		a.logger.Error(err.Error())
-		return
+		return err
+	}
+	if s != http.StatusOK {
+		a.logger.Errorf("agent.upgradeVault failed to get legacy secrets %d: %s", s, string(data))
+		return errBadHTTPStatusCode
 	}
-	l := make([]*model.LoginWithPassword, len(cache.LoginWithPasswords))
-	copy(l, cache.LoginWithPasswords)
-	for _, i := range l {
-		fmt.Println(i)
+	v := &model.LegacyVault{}
+	if err := json.Unmarshal(data, v); err != nil {
+		a.logger.Errorf("agent.upgradeVault unmarshal json failed %v: %v", string(data), err)
+		return err
+	}
+	for _, c := range []*model.SecretCache{v.Secrets, v.Trash, v.History} {
+		if c == nil {
+			continue
+		}
+		if err := c.Encrypt(key); err != nil {
+			a.logger.Errorf("agent.upgradeVault failed to encrypt vault: %s", err.Error())
+			return err
+		}
+	}
+	v.AuthKey = authKey
+	data, s, err = a.sendRequest2(ctx, legacyURI, http.MethodPut, v)
+	if err != nil {
+		return err
+	}
+	if s != http.StatusOK {
+		a.logger.Errorf("agent.upgradeVault failed %d: %s", s, string(data))
+		return errBadHTTPStatusCode
+	}
+	session := &model.Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		a.logger.Errorf("agent.upgradeVault unmarshal json failed %v: %v", string(data), err)
+		return err
 	}
+	a.session = session
+	a.logger.Info("Secrets are sealed with the vault key from now on")
+	// Resealed secrets keep their revisions, so they are only fetched by a full sync
+	return a.resetCache()
 }
 
-func (a *agent) getLogingWithPassword(ctx context.Context, id int) {
-	fmt.Printf("You Login and Password with id: %d\n", id)
-	cache, err := a.cache.Cache().Get()
+// refreshSession renews the session token when it is about to expire. An expired session is replaced by
+// a new login, an error is returned if it fails. Failed renewal of a session which is still valid is logged only.
+func (a *agent) refreshSession(ctx context.Context) error {
+	if time.Until(a.session.ExpiresAt) > sessionRefreshWindow {
+		return nil
+	}
+	if a.session.Expired() {
+		s, err := a.login(ctx)
+		if err != nil {
+			a.logger.Errorf("agent.refreshSession login failed: %s", err.Error())
+			return fmt.Errorf("%w: %s", errSessionExpired, err.Error())
+		}
+		if a.session == nil {
+			return fmt.Errorf("%w: login failed with status %d", errSessionExpired, s)
+		}
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.geHTTPtURL(refreshURI), nil)
 	if err != nil {
-		a.logger.Error(err.Error())
-		return
+		a.logger.Errorf("agent.refreshSession req err: %s", err.Error())
+		return nil
 	}
-	for _, i := range cache.LoginWithPasswords {
-		if i.ID == id {
-			if err := a.printEncryptedSecret(i); err != nil {
-				return
-			}
-		}
+	req.Header.Set(server.AuthHeader, a.session.Token)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		a.logger.Errorf("agent.refreshSession resp err: %s", err.Error())
+		return nil
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		a.logger.Errorf("agent.refreshSession bad status: %s", resp.Status)
+		return nil
+	}
+	session := &model.Session{}
+	if err := json.NewDecoder(resp.Body).Decode(session); err != nil {
+		a.logger.Errorf("agent.refreshSession unable to parse body: %v", err)
+		return nil
+	}
+	a.session = session
+	return nil
 }
 
-func (a *agent) addLogingWithPassword(ctx context.Context, m *model.LoginWithPassword) {
-	a.sendRequest(ctx, logingWithPasswordBodyURI, http.MethodPost, m, true)
+// changePassword re-encrypts the whole vault and the trash with the key derived from the new password
+// and replaces them on the server in one request
+func (a *agent) changePassword(ctx context.Context, oldPassword, newPassword string) error {
+	if oldPassword != a.config.Password {
+		return errIncorrectPassword
+	}
+	if len(newPassword) < minPasswordLength {
+		return errShortPassword
+	}
+	// Journaled secrets are sealed with the old key
+	if len(a.journal) > 0 {
+		return errPendingChanges
+	}
+	if err := a.updateCache(ctx); err != nil {
+		return err
+	}
+	c, err := a.cache.Cache().Get()
+	if err != nil {
+		return err
+	}
+	secrets, err := c.Copy()
+	if err != nil {
+		return err
+	}
+	if err := secrets.Decrypt(a.key); err != nil {
+		a.logger.Errorf("agent.changePassword failed to decrypt vault: %s", err.Error())
+		return err
+	}
+	trash, err := a.getTrash(ctx)
+	if err != nil {
+		return err
+	}
+	if err := trash.Decrypt(a.key); err != nil {
+		a.logger.Errorf("agent.changePassword failed to decrypt trash: %s", err.Error())
+		return err
+	}
+	history, err := a.getAllHistory(ctx)
+	if err != nil {
+		return err
+	}
+	if err := history.Decrypt(a.key); err != nil {
+		a.logger.Errorf("agent.changePassword failed to decrypt history: %s", err.Error())
+		return err
+	}
+	salt, err := encrypt.NewSalt()
+	if err != nil {
+		return err
+	}
+	key := encrypt.DeriveKey(newPassword, salt)
+	if err := secrets.Encrypt(key); err != nil {
+		a.logger.Errorf("agent.changePassword failed to encrypt vault: %s", err.Error())
+		return err
+	}
+	if err := trash.Encrypt(key); err != nil {
+		a.logger.Errorf("agent.changePassword failed to encrypt trash: %s", err.Error())
+		return err
+	}
+	if err := history.Encrypt(key); err != nil {
+		a.logger.Errorf("agent.changePassword failed to encrypt history: %s", err.Error())
+		return err
+	}
+	// The server checks auth keys, passwords never leave the agent
+	oldAuthKey, err := encrypt.AuthKey(a.key)
+	if err != nil {
+		return err
+	}
+	newAuthKey, err := encrypt.AuthKey(key)
+	if err != nil {
+		return err
+	}
+	m := &model.PasswordChange{OldPassword: oldAuthKey, Password: newAuthKey, KDFSalt: salt, Secrets: secrets, Trash: trash, History: history}
+	data, s, err := a.sendRequest2(ctx, passwordURI, http.MethodPut, m)
+	if err != nil {
+		return err
+	}
+	if s != http.StatusOK {
+		a.logger.Errorf("agent.changePassword failed %d: %s", s, string(data))
+		return errBadHTTPStatusCode
+	}
+	session := &model.Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		a.logger.Errorf("agent.changePassword unmarshal json failed %v: %v", string(data), err)
+		return err
+	}
+	a.config.Password = newPassword
+	a.session = session
+	a.key = key
+	a.kdfSalt = salt
+	if err := a.saveJournal(); err != nil {
+		return err
+	}
+	if err := a.resetCache(); err != nil {
+		return err
+	}
+	return a.updateCache(ctx)
 }
 
-func (a *agent) updateLogingWithPassword(ctx context.Context, m *model.LoginWithPassword) {
-	a.sendRequest(ctx, logingWithPasswordBodyURI, http.MethodPut, m, true)
+func (a *agent) passwd(ctx context.Context) {
+	oldPassword := userinput.InputPassword("current password")
+	newPassword := userinput.InputPassword("new password")
+	if newPassword != userinput.InputPassword("new password again") {
+		a.logger.Error("Passwords don't match")
+		return
+	}
+	if err := a.changePassword(ctx, oldPassword, newPassword); err != nil {
+		a.logger.Errorf("Password change failed: %s", err.Error())
+		return
+	}
+	fmt.Println("Password changed")
 }
 
-func (a *agent) deleteLogingWithPassword(ctx context.Context, id int) {
-	uri := fmt.Sprintf("%s/%s", logingWithPasswordBodyURI, strconv.Itoa(id))
-	a.sendRequest(ctx, uri, http.MethodDelete, nil, true)
+func (a *agent) ping(ctx context.Context) (int, error) {
+	_, s, err := a.sendRequest2(ctx, pingURI, http.MethodGet, nil)
+	if err != nil {
+		a.logger.Errorf("agent.ping error: %s", err.Error())
+		return 0, err
+	}
+	return s, nil
 }
 
-func (a *agent) listCreditCard(ctx context.Context) {
-	fmt.Println("You Credit Cards: ")
+func (a *agent) list(ctx context.Context, kind model.Kind) {
+	fmt.Printf("You %s: \n", kind.Plural())
 	cache, err := a.cache.Cache().Get()
 	if err != nil {
 		a.logger.Error(err.Error())
 		return
 	}
-	for _, i := range cache.CreditCards {
-		fmt.Println(i)
-	}
+	printByFolder(cache.Get(kind))
 }
 
-func (a *agent) getCreditCard(ctx context.Context, id int) {
-	fmt.Printf("You Credit Cards with id: %d\n", id)
-	cache, err := a.cache.Cache().Get()
-	if err != nil {
-		a.logger.Error(err.Error())
+func (a *agent) get(ctx context.Context, kind model.Kind) {
+	a.list(ctx, kind)
+	id := userinput.InputID()
+	if kind.Blob() {
+		a.getSecretFile(ctx, kind, id)
 		return
 	}
-	for _, i := range cache.CreditCards {
-		if i.ID == id {
-			if err := a.printEncryptedSecret(i); err != nil {
-				return
-			}
-		}
+	fmt.Printf("You %s with id: %d\n", kind.Name(), id)
+	m, err := a.findSecret(kind, id)
+	if err != nil {
+		a.logger.Errorf("agent.get unable to find %s %d: %s", kind.Name(), id, err.Error())
+		return
 	}
+	fmt.Println(m)
 }
 
-func (a *agent) addCreditCard(ctx context.Context, m *model.CreditCard) {
-	a.sendRequest(ctx, creditCardBodyURI, http.MethodPost, m, true)
-}
-
-func (a *agent) updateCreditCard(ctx context.Context, m *model.CreditCard) {
-	a.sendRequest(ctx, creditCardBodyURI, http.MethodPut, m, true)
-}
-
-func (a *agent) deleteCreditCard(ctx context.Context, id int) {
-	uri := fmt.Sprintf("%s/%s", creditCardBodyURI, strconv.Itoa(id))
-	a.sendRequest(ctx, uri, http.MethodDelete, nil, true)
+func (a *agent) add(ctx context.Context, kind model.Kind) {
+	m := kind.New()
+	if !userinput.InputSecret(m, true) {
+		return
+	}
+	if err := a.addSecret(ctx, kind, m); err != nil {
+		a.logger.Errorf("Unable to add %s: %s", kind.Name(), err.Error())
+		return
+	}
+	fmt.Printf("Saved %s %d\n", kind.Name(), m.Data().ID)
 }
 
-func (a *agent) listSecretText(ctx context.Context) {
-	fmt.Println("You Secret Texts: ")
-	cache, err := a.cache.Cache().Get()
+// update replaces the secret with entered values, generated fields are kept
+func (a *agent) update(ctx context.Context, kind model.Kind) {
+	a.list(ctx, kind)
+	id := userinput.InputID()
+	m, err := a.findSecret(kind, id)
 	if err != nil {
-		a.logger.Error(err.Error())
+		a.logger.Errorf("agent.update unable to find %s %d: %s", kind.Name(), id, err.Error())
 		return
 	}
-	for _, i := range cache.SecretTexts {
-		if err := i.Encrypt(a.config.SecretKey, a.config.SecretIV); err != nil {
-			return
-		}
-		fmt.Println(i)
-		if err := i.Decrypt(a.config.SecretKey, a.config.SecretIV); err != nil {
-			return
-		}
+	if !userinput.InputSecret(m, false) {
+		return
+	}
+	if _, err := a.updateResolved(ctx, kind, m, promptConflict); err != nil {
+		a.logger.Errorf("Unable to update %s %d: %s", kind.Name(), id, err.Error())
+		return
 	}
+	fmt.Printf("Saved %s %d\n", kind.Name(), id)
 }
 
-func (a *agent) getSecretText(ctx context.Context, id int) {
-	fmt.Printf("You Secret Texts with id: %d\n", id)
-	cache, err := a.cache.Cache().Get()
-	if err != nil {
-		a.logger.Error(err.Error())
+func (a *agent) delete(ctx context.Context, kind model.Kind) {
+	a.list(ctx, kind)
+	id := userinput.InputID()
+	if err := a.deleteSecret(ctx, kind, id); err != nil {
+		a.logger.Errorf("Unable to delete %s %d: %s", kind.Name(), id, err.Error())
 		return
 	}
-	for _, i := range cache.SecretTexts {
-		if i.ID == id {
-			if err := a.printEncryptedSecret(i); err != nil {
-				return
-			}
+	fmt.Printf("Deleted %s %d\n", kind.Name(), id)
+}
+
+// addSecret seals the secret and creates it on the server, content of files is uploaded separately.
+// Offline the secret is journaled.
+func (a *agent) addSecret(ctx context.Context, kind model.Kind, m model.Secret) error {
+	if f, ok := m.(*model.SecretFile); ok {
+		if !a.onlineMode {
+			return errOfflineFile
 		}
+		return a.uploadSecretFile(ctx, f)
 	}
+	if err := a.seal(m); err != nil {
+		return err
+	}
+	return a.saveChange(ctx, opAdd, kind, m)
 }
 
-func (a *agent) addSecretText(ctx context.Context, m *model.SecretText) {
-	a.sendRequest(ctx, secretTextBodyURI, http.MethodPost, m, true)
+func (a *agent) updateSecret(ctx context.Context, kind model.Kind, m model.Secret) error {
+	if err := a.seal(m); err != nil {
+		return err
+	}
+	return a.saveChange(ctx, opUpdate, kind, m)
 }
 
-func (a *agent) deleteSecretText(ctx context.Context, id int) {
-	uri := fmt.Sprintf("%s/%s", secretTextBodyURI, strconv.Itoa(id))
-	a.sendRequest(ctx, uri, http.MethodDelete, nil, true)
-}
-func (a *agent) updateSecretText(ctx context.Context, m *model.SecretText) {
-	a.sendRequest(ctx, secretTextBodyURI, http.MethodPut, m, true)
+// saveSecret sends the sealed secret to the server and reads the stored secret back into m.
+// Updates require the version of m, a newer version on the server is returned as conflictError.
+func (a *agent) saveSecret(ctx context.Context, kind model.Kind, method string, m model.Secret) error {
+	header := http.Header{}
+	if v := m.Data().Version; method == http.MethodPut && v > 0 {
+		header.Set("If-Match", `"`+strconv.Itoa(v)+`"`)
+	}
+	data, s, err := a.sendRequestWithHeader(ctx, secretURI(kind), method, m, header)
+	if err != nil {
+		return err
+	}
+	if s == http.StatusConflict && method == http.MethodPut {
+		return newConflictError(kind, data)
+	}
+	if s != http.StatusOK {
+		a.logger.Errorf("agent.saveSecret %s %s failed %d: %s", method, kind.Name(), s, string(data))
+		return fmt.Errorf("%w %d: %s", errBadHTTPStatusCode, s, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, m)
 }
 
-func (a *agent) listSecretFile(ctx context.Context) {
-	fmt.Println("You Secret Files: ")
+// deleteSecret deletes the cached secret, offline the deletion is journaled
+func (a *agent) deleteSecret(ctx context.Context, kind model.Kind, id int) error {
 	cache, err := a.cache.Cache().Get()
 	if err != nil {
-		a.logger.Error(err.Error())
-		return
+		return err
 	}
-	for _, i := range cache.SecretFiles {
-		fmt.Println(i)
+	m, ok := cache.Find(kind, id)
+	if !ok {
+		return errSecretNotFound
 	}
+	return a.saveChange(ctx, opDelete, kind, m)
 }
 
-func (a *agent) getSecretFile(ctx context.Context, id string) {
-	uri := fmt.Sprintf("%s/%s", secretFileBodyURI, id)
-	endpoint := a.geHTTPtURL(uri)
-	req, err := a.getRequest(ctx, http.MethodGet, endpoint, nil)
+func (a *agent) deleteRequest(ctx context.Context, kind model.Kind, id int) error {
+	uri := fmt.Sprintf("%s/%s", secretURI(kind), strconv.Itoa(id))
+	data, s, err := a.sendRequest2(ctx, uri, http.MethodDelete, nil)
 	if err != nil {
-		a.logger.Errorf("agent.getSecretFile req err: %s", err.Error())
-		return
+		return err
 	}
-	resp, err := a.client.Do(req)
-	if err != nil {
-		a.logger.Errorf("agent.getSecretFile resp err: %s", err.Error())
-		return
+	if s != http.StatusOK {
+		a.logger.Errorf("agent.deleteSecret %s %d failed %d: %s", kind.Name(), id, s, string(data))
+		return fmt.Errorf("%w %d: %s", errBadHTTPStatusCode, s, strings.TrimSpace(string(data)))
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		a.logger.Errorf("bad status: %s", resp.Status)
+	return nil
+}
+
+// getSecretFile downloads the file to SecretFile_<id>
+func (a *agent) getSecretFile(ctx context.Context, kind model.Kind, id int) {
+	name := fmt.Sprintf("SecretFile_%d", id)
+	if err := a.saveSecretFile(ctx, kind, id, name); err != nil {
+		a.logger.Errorf("Unable to get file %d: %s", id, err.Error())
 		return
 	}
-	out, err := os.Create("SecretFile_" + id)
+	fmt.Printf("Saved to %s\n", name)
+}
+
+// saveSecretFile downloads the file to dst. The encrypted content is downloaded to
+// <dst>.part first, an interrupted download is resumed from where it stopped.
+func (a *agent) saveSecretFile(ctx context.Context, kind model.Kind, id int, dst string) error {
+	s, err := a.findSecret(kind, id)
 	if err != nil {
-		a.logger.Errorf("Can't create local file: %s", err.Error())
-		return
+		return err
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
+	m := s.(*model.SecretFile)
+	key, err := base64.StdEncoding.DecodeString(m.Key)
 	if err != nil {
-		a.logger.Errorf("Can't copy reposnse to local file: %s", err.Error())
-		return
+		return fmt.Errorf("bad file key: %w", err)
+	}
+	part := dst + ".part"
+	if err := a.downloadSecretFile(ctx, kind, id, part); err != nil {
+		a.logger.Errorf("Download of file %d is interrupted, get it again to resume", id)
+		return err
+	}
+	// Files uploaded before vault keys have no file key, their content is stored as uploaded
+	if len(key) == 0 {
+		return os.Rename(part, dst)
 	}
+	if err := decryptFile(part, dst, key); err != nil {
+		return err
+	}
+	if err := os.Remove(part); err != nil {
+		a.logger.Errorf("Unable to remove %s: %s", part, err.Error())
+	}
+	return nil
 }
 
-func (a *agent) uploadSecretFile(ctx context.Context, m *model.SecretFile) {
-	file, err := os.Open(m.Path)
+// downloadSecretFile appends the rest of the encrypted file to part
+func (a *agent) downloadSecretFile(ctx context.Context, kind model.Kind, id int, part string) error {
+	out, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0600)
 	if err != nil {
-		a.logger.Errorf("The file doesn't exist: %s", m.Path)
+		return err
 	}
-	defer file.Close()
-
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("secretFile", filepath.Base(file.Name()))
+	defer out.Close()
+	offset, err := out.Seek(0, io.SeekEnd)
 	if err != nil {
-		a.logger.Errorf("agent.addSecretFile CreateFormFile %s: %s", file.Name(), err.Error())
-		return
-	}
-	if _, err := io.Copy(part, file); err != nil {
-		a.logger.Errorf("Failed copy part of file: %s", err.Error())
-		return
+		return err
 	}
-	writer.Close()
-	endpoint := a.geHTTPtURL(secretFileBodyURI)
-	req, err := a.getRequest(ctx, http.MethodPost, endpoint, body)
+	endpoint := a.geHTTPtURL(fmt.Sprintf("%s/%d", secretURI(kind), id))
+	req, err := a.getRequest(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		a.logger.Errorf("agent.addSecretFile req err: %s", err.Error())
-		return
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
-	req.Header.Add("Content-Type", writer.FormDataContentType())
 	resp, err := a.client.Do(req)
 	if err != nil {
-		a.logger.Errorf("agent.addSecretFile resp err: %s", err.Error())
-		return
+		return err
 	}
 	defer resp.Body.Close()
-	responseM := &model.SecretFile{}
-	if err := json.NewDecoder(resp.Body).Decode(responseM); err != nil {
-		a.logger.Errorf("Unable to parse resp body in uploadSecretFile: %v", err)
-		return
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		a.logger.Infof("Resuming download of file %d from %d bytes", id, offset)
+	case http.StatusOK:
+		if err := out.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := out.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Everything has been downloaded already
+		return nil
+	default:
+		a.logger.Errorf("bad status: %s", resp.Status)
+		return errBadHTTPStatusCode
 	}
-	a.logger.Infof("agent.uploadSecretFile uploaded: %v", responseM)
-	m.ID = responseM.ID
-	m.UserID = responseM.ID
-	a.updateSecretFile(ctx, m)
-}
-
-func (a *agent) updateSecretFile(ctx context.Context, m *model.SecretFile) {
-	a.sendRequest(ctx, secretFileBodyURI, http.MethodPut, m, true)
-}
-
-func (a *agent) deleteSecretFile(ctx context.Context, id int) {
-	uri := fmt.Sprintf("%s/%s", secretFileBodyURI, strconv.Itoa(id))
-	a.sendRequest(ctx, uri, http.MethodDelete, nil, true)
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return err
+	}
+	return out.Sync()
 }
 
-func (a *agent) list(ctx context.Context, target string) {
-	switch target {
-	case "l", "login", "password", "lp":
-		a.listLogingWithPassword(ctx)
-	case "c", "credit", "card", "cc", "creditcard":
-		a.listCreditCard(ctx)
-	case "t", "text", "secrettext":
-		a.listSecretText(ctx)
-	case "f", "file", "secretfile":
-		a.listSecretFile(ctx)
-	default:
-		a.logger.Errorf("Unknown target: %s", target)
-	}
-}
-
-func (a *agent) get(ctx context.Context, target string) {
-	switch target {
-	case "l", "login", "password", "lp":
-		a.listLogingWithPassword(ctx)
-		id := userinput.InputID()
-		a.getLogingWithPassword(ctx, id)
-	case "c", "credit", "card", "cc", "creditcard":
-		a.listCreditCard(ctx)
-		id := userinput.InputID()
-		a.getCreditCard(ctx, id)
-	case "t", "text", "secrettext":
-		a.listSecretText(ctx)
-		id := userinput.InputID()
-		a.getSecretText(ctx, id)
-	case "f", "file", "secretfile":
-		a.listSecretFile(ctx)
-		id := userinput.InputID()
-		a.getSecretFile(ctx, strconv.Itoa(id))
-	default:
-		a.logger.Errorf("Unknown target: %s", target)
-	}
-}
-
-func (a *agent) add(ctx context.Context, target string) {
-	switch target {
-	case "l", "login", "password", "lp":
-		m := userinput.InputLoginWithPassword()
-		a.addLogingWithPassword(ctx, m)
-	case "c", "credit", "card", "cc", "creditcard":
-		m := userinput.InputCreditCard()
-		a.addCreditCard(ctx, m)
-	case "t", "text", "secrettext":
-		m := userinput.InputSecretText()
-		a.addSecretText(ctx, m)
-	case "f", "file", "secretfile":
-		m := userinput.InputSecretFile(true)
-		a.uploadSecretFile(ctx, m)
-	default:
-		a.logger.Errorf("Unknown target: %s", target)
-	}
-}
-
-func (a *agent) delete(ctx context.Context, target string) {
-	switch target {
-	case "l", "login", "password", "lp":
-		a.listLogingWithPassword(ctx)
-		id := userinput.InputID()
-		a.deleteLogingWithPassword(ctx, id)
-	case "c", "credit", "card", "cc", "creditcard":
-		a.listCreditCard(ctx)
-		id := userinput.InputID()
-		a.deleteCreditCard(ctx, id)
-	case "t", "text", "secrettext":
-		a.listSecretText(ctx)
-		id := userinput.InputID()
-		a.deleteSecretText(ctx, id)
-	case "f", "file", "secretfile":
-		a.listSecretFile(ctx)
-		id := userinput.InputID()
-		a.deleteSecretFile(ctx, id)
-	default:
-		a.logger.Errorf("Unknown target: %s", target)
-	}
-}
-
-func (a *agent) update(ctx context.Context, target string) {
-	switch target {
-	case "l", "login", "password", "lp":
-		a.listLogingWithPassword(ctx)
-		id := userinput.InputID()
-		m := userinput.InputLoginWithPassword()
-		m.ID = id
-		a.updateLogingWithPassword(ctx, m)
-	case "c", "credit", "card", "cc", "creditcard":
-		a.listCreditCard(ctx)
-		id := userinput.InputID()
-		m := userinput.InputCreditCard()
-		m.ID = id
-		a.updateCreditCard(ctx, m)
-	case "t", "text", "secrettext":
-		a.listSecretText(ctx)
-		id := userinput.InputID()
-		m := userinput.InputSecretText()
-		m.ID = id
-		a.updateSecretText(ctx, m)
-	case "f", "file", "secretfile":
-		a.listSecretFile(ctx)
-		id := userinput.InputID()
-		m := userinput.InputSecretFile(false)
-		m.ID = id
-		a.updateSecretFile(ctx, m)
-	default:
-		a.logger.Errorf("Unknown target: %s", target)
+// decryptFile decrypts downloaded file with the file key, files uploaded in a single
+// request by previous versions are sealed as a whole
+func decryptFile(src, dst string, key []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	var content io.Reader
+	content, err = encrypt.NewStreamReader(in, stat.Size(), key)
+	if errors.Is(err, encrypt.ErrNotStream) {
+		sealed, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		data, err := encrypt.Open(string(sealed), key)
+		if err != nil {
+			return err
+		}
+		content = strings.NewReader(data)
+	} else if err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, content); err != nil {
+		os.Remove(dst)
+		return err
 	}
+	return nil
 }
 
-func (a *agent) userInput() {
-	ctx := context.Background()
-	action := userinput.Input("Action: (r|register) (l|list) (g|get) (a|add) (d|delete) (u|update)")
-	a.logger.Infof("agent.userInput action: %s", action)
-	if action == "register" || action == "r" {
-		a.register(ctx)
+// otp prints the current one-time code of the OTP secret, the seed is never shown
+func (a *agent) otp(ctx context.Context) {
+	a.list(ctx, model.OTPSecretKind)
+	id := userinput.InputID()
+	m, err := a.findSecret(model.OTPSecretKind, id)
+	if err != nil {
+		a.logger.Errorf("agent.otp unable to find OTP secret %d: %s", id, err.Error())
 		return
 	}
-	target := userinput.Input("Type of secret you want to operate: (l|login|password|lp) (c|credit|card|cc|creditcard) (t|text|secrettext) (f|file|secretfile)")
-	switch action {
-	case "list", "l":
-		a.list(ctx, target)
-	case "get", "g":
-		a.get(ctx, target)
-	case "add", "a":
-		a.add(ctx, target)
-	case "delete", "d":
-		a.delete(ctx, target)
-	case "update", "u":
-		a.update(ctx, target)
-	default:
-		a.logger.Errorf("Unknown action: %s", action)
+	code, valid, err := m.(*model.OTPSecret).Code(time.Now())
+	if err != nil {
+		a.logger.Errorf("Unable to generate code: %s", err.Error())
+		return
 	}
-	if err := a.updateCache(ctx); err != nil {
-		a.logger.Error(err)
+	fmt.Printf("Code: %s, valid for %s\n", code, valid.Round(time.Second))
+}
+
+// kindsHelp lists registered kinds with their aliases
+func kindsHelp() string {
+	help := make([]string, 0, len(model.Kinds()))
+	for _, kind := range model.Kinds() {
+		help = append(help, "("+strings.Join(append(kind.Aliases(), kind.Name()), "|")+")")
 	}
-	a.logger.Info("Done")
+	return strings.Join(help, " ")
 }
@@ -0,0 +1,185 @@
+package agent
+
+import (
+	"cenarius/internal/cache/filecache"
+	"cenarius/internal/cache/mcache"
+	"cenarius/internal/encrypt"
+	"cenarius/internal/model"
+	"cenarius/internal/server"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// testOnlineAgent returns agent of the TLS test server with the cache file in a temporary directory
+func testOnlineAgent(t *testing.T, srv *httptest.Server) *agent {
+	f, err := os.OpenFile(filepath.Join(t.TempDir(), "cache"), os.O_RDWR|os.O_CREATE, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return &agent{
+		config:     &Config{Host: strings.TrimPrefix(srv.URL, "https://")},
+		logger:     logrus.New(),
+		client:     *srv.Client(),
+		store:      filecache.New(f),
+		cache:      mcache.New(),
+		onlineMode: true,
+	}
+}
+
+func Test_agent_updateCache(t *testing.T) {
+	var since []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since = append(since, r.URL.Query().Get("since"))
+		changes := model.NewSyncChanges(10)
+		changes.Add(model.SecretTextKind,
+			[]model.Secret{&model.SecretText{SecretData: model.SecretData{ID: 2, Name: "changed", Revision: 11}}},
+			[]model.Tombstone{{ID: 1, Revision: 12}},
+		)
+		_ = json.NewEncoder(w).Encode(changes)
+	}))
+	defer srv.Close()
+
+	a := testOnlineAgent(t, srv)
+	c := &model.SecretCache{Revision: 10}
+	c.Set(model.SecretTextKind, []model.Secret{
+		&model.SecretText{SecretData: model.SecretData{ID: 1, Name: "deleted", Revision: 3}},
+		&model.SecretText{SecretData: model.SecretData{ID: 2, Name: "old", Revision: 4}},
+		&model.SecretText{SecretData: model.SecretData{ID: 3, Name: "kept", Revision: 5}},
+	})
+	if err := a.cache.Cache().Save(c); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, a.updateCache(context.Background()))
+	c, err := a.store.Cache().Get()
+	if !assert.NoError(t, err) {
+		return
+	}
+	var names []string
+	for _, m := range c.Get(model.SecretTextKind) {
+		names = append(names, m.Data().Name)
+	}
+	assert.Equal(t, []string{"kept", "changed"}, names)
+	assert.Equal(t, int64(12), c.Revision)
+
+	// Local changes drop the revision, so the next sync gets every secret
+	if c, err = a.cache.Cache().Get(); err != nil {
+		t.Fatal(err)
+	}
+	applyOp(c, model.SecretTextKind, opDelete, &model.SecretText{SecretData: model.SecretData{ID: 3}})
+	assert.NoError(t, a.updateCache(context.Background()))
+	assert.Equal(t, []string{"10", "0"}, since)
+}
+
+func Test_agent_getSecretsWrapper(t *testing.T) {
+	var queries []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Header().Set(server.NextCursorHeader, "p2")
+			_ = json.NewEncoder(w).Encode([]*model.SecretText{{SecretData: model.SecretData{ID: 1}}})
+		case "p2":
+			w.Header().Set(server.NextCursorHeader, "p3")
+			_ = json.NewEncoder(w).Encode([]*model.SecretText{{SecretData: model.SecretData{ID: 2}}})
+		default:
+			_ = json.NewEncoder(w).Encode([]*model.SecretText{})
+		}
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+
+	secrets := make([]*model.SecretText, 0)
+	if assert.NoError(t, a.getSecretsWrapper(context.Background(), "api/v1/private/secrettexts?sort=name", &secrets)) && assert.Len(t, secrets, 2) {
+		assert.Equal(t, 2, secrets[1].ID)
+	}
+	assert.Equal(t, []string{"sort=name", "sort=name&cursor=p2", "sort=name&cursor=p3"}, queries)
+}
+
+func Test_agent_getRequest_expiredSession(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The password was changed on another device
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+	a.session = &model.Session{Token: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+	_, err := a.getRequest(context.Background(), http.MethodGet, a.geHTTPtURL(syncURI), nil)
+	assert.ErrorIs(t, err, errSessionExpired)
+	assert.Nil(t, a.session)
+
+	// A server which is down fails the request too
+	srv.Close()
+	a.session = &model.Session{Token: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+	_, err = a.getRequest(context.Background(), http.MethodGet, a.geHTTPtURL(syncURI), nil)
+	assert.ErrorIs(t, err, errSessionExpired)
+}
+
+func Test_agent_login(t *testing.T) {
+	legacy := false
+	var sent *model.User
+	var upgraded *model.LegacyVault
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/user/prelogin":
+			_ = json.NewEncoder(w).Encode(&model.Prelogin{Login: "alice", KDFSalt: "salt", LegacyAuth: legacy})
+		case "/api/v1/user/login":
+			sent = &model.User{}
+			_ = json.NewDecoder(r.Body).Decode(sent)
+			_ = json.NewEncoder(w).Encode(&model.Session{Token: "token", ExpiresAt: time.Now().Add(time.Hour), KDFSalt: "salt"})
+		case "/api/v1/private/user/legacy":
+			if r.Method == http.MethodGet {
+				secrets := &model.SecretCache{}
+				secrets.Set(model.SecretTextKind, []model.Secret{&model.SecretText{SecretData: model.SecretData{ID: 1, Version: 1}, Text: "legacy"}})
+				_ = json.NewEncoder(w).Encode(&model.LegacyVault{Secrets: secrets})
+				return
+			}
+			upgraded = &model.LegacyVault{}
+			_ = json.NewDecoder(r.Body).Decode(upgraded)
+			_ = json.NewEncoder(w).Encode(&model.Session{Token: "upgraded", ExpiresAt: time.Now().Add(time.Hour), KDFSalt: "salt"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+	a.config.Login, a.config.Password = "alice", "master password"
+	key := encrypt.DeriveKey("master password", "salt")
+	authKey, err := encrypt.AuthKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := a.login(context.Background())
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, s) {
+		assert.Equal(t, authKey, sent.Password)
+		assert.Nil(t, upgraded)
+		assert.Equal(t, "token", a.session.Token)
+	}
+
+	// An account made before auth keys sends the master password for the last time,
+	// its secrets come back resealed with the vault key together with the auth key
+	legacy = true
+	_, err = a.login(context.Background())
+	if assert.NoError(t, err) && assert.NotNil(t, upgraded) {
+		assert.Equal(t, "master password", sent.Password)
+		assert.Equal(t, authKey, upgraded.AuthKey)
+		assert.Equal(t, "upgraded", a.session.Token)
+		resealed := upgraded.Secrets.Get(model.SecretTextKind)
+		if assert.Len(t, resealed, 1) && assert.NoError(t, resealed[0].Decrypt(key)) {
+			assert.Equal(t, "legacy", resealed[0].(*model.SecretText).Text)
+			assert.Equal(t, 1, resealed[0].Data().Version)
+		}
+	}
+}
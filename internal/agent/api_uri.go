@@ -1,18 +1,39 @@
 package agent
 
+import (
+	"cenarius/internal/model"
+	"strconv"
+)
+
 const (
 	registerURI = "api/v1/user/register"
+	loginURI    = "api/v1/user/login"
+	preloginURI = "api/v1/user/prelogin"
+	refreshURI  = "api/v1/private/user/refresh"
+	passwordURI = "api/v1/private/user/password"
+	legacyURI   = "api/v1/private/user/legacy"
 	pingURI     = "api/v1/private/ping"
+	privateURI  = "api/v1/private/"
+	uploadURI   = "api/v1/private/secretfile/upload"
+	syncURI     = "api/v1/private/sync"
+	eventsURI   = "api/v1/private/events"
+	trashURI    = "api/v1/private/trash"
+	versionsURI = "api/v1/private/history"
+	searchURI   = "api/v1/private/search"
+	batchURI    = "api/v1/private/batch"
+)
 
-	logingWithPasswordGetURI  = "api/v1/private/loginwithpasswords"
-	logingWithPasswordBodyURI = "api/v1/private/loginwithpassword"
-
-	creditCardGetURI  = "api/v1/private/creditcards"
-	creditCardBodyURI = "api/v1/private/creditcard"
+// secretURI addresses a single secret of the kind
+func secretURI(kind model.Kind) string {
+	return privateURI + kind.Name()
+}
 
-	secretTextGetURI  = "api/v1/private/secrettexts"
-	secretTextBodyURI = "api/v1/private/secrettext"
+// historyURI addresses prior versions of the secret
+func historyURI(kind model.Kind, id int) string {
+	return secretURI(kind) + "/" + strconv.Itoa(id) + "/history"
+}
 
-	secretFileGetURI  = "api/v1/private/secretfiles"
-	secretFileBodyURI = "api/v1/private/secretfile"
-)
+// trashedURI addresses the secret in the trash
+func trashedURI(kind model.Kind, id int) string {
+	return trashURI + "/" + kind.Name() + "/" + strconv.Itoa(id)
+}
@@ -0,0 +1,267 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	errBatchOffline    = errors.New("batch is applied by the server and is unavailable offline")
+	errBatchNotApplied = errors.New("batch was not applied")
+)
+
+// batchEntry is an operation of a batch file. Secrets to update or delete are selected by id or name,
+// fields of the plain secret replace fields of the selected one on update.
+type batchEntry struct {
+	Op     string          `json:"op"`
+	Kind   string          `json:"kind"`
+	ID     int             `json:"id"`
+	Name   string          `json:"name"`
+	Secret json.RawMessage `json:"secret"`
+}
+
+// readBatch reads entries of the batch file, - reads stdin
+func readBatch(path string) ([]batchEntry, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	entries := make([]batchEntry, 0)
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("batch file must be a JSON array of operations: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("batch file has no operations")
+	}
+	return entries, nil
+}
+
+// prepareBatch turns entries into operations of the server, secrets are sealed and selected by name
+// from the cache. Nothing is sent if any entry is malformed.
+func (a *agent) prepareBatch(entries []batchEntry) ([]*model.BatchOp, error) {
+	ops := make([]*model.BatchOp, 0, len(entries))
+	for i, e := range entries {
+		op, err := a.prepareBatchOp(e)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i+1, err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func (a *agent) prepareBatchOp(e batchEntry) (*model.BatchOp, error) {
+	kind, ok := model.KindByName(e.Kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown kind %q, one of: %s", e.Kind, kindsHelp())
+	}
+	op := &model.BatchOp{Op: e.Op, Kind: kind.Name()}
+	hasSecret := len(e.Secret) > 0 && string(e.Secret) != "null"
+	switch e.Op {
+	case model.BatchCreate:
+		if kind.Blob() {
+			return nil, fmt.Errorf("%s are added by add, not in a batch", kind.Plural())
+		}
+		if !hasSecret {
+			return nil, fmt.Errorf("create needs the secret")
+		}
+		m := kind.New()
+		if err := json.Unmarshal(e.Secret, m); err != nil {
+			return nil, err
+		}
+		m.Data().ID, m.Data().Version = 0, 0
+		if err := a.seal(m); err != nil {
+			return nil, err
+		}
+		op.Secret = m
+	case model.BatchUpdate:
+		if !hasSecret {
+			return nil, fmt.Errorf("update needs the secret")
+		}
+		m, err := a.selectBatchSecret(kind, e)
+		if err != nil {
+			return nil, err
+		}
+		// The cached version is kept, so changes made on another device are not overwritten
+		id, version := m.Data().ID, m.Data().Version
+		if err := json.Unmarshal(e.Secret, m); err != nil {
+			return nil, err
+		}
+		m.Data().ID, m.Data().Version = id, version
+		if err := a.seal(m); err != nil {
+			return nil, err
+		}
+		op.Secret = m
+	case model.BatchDelete:
+		m, err := a.selectBatchSecret(kind, e)
+		if err != nil {
+			return nil, err
+		}
+		op.ID = m.Data().ID
+	default:
+		return nil, fmt.Errorf("unknown op %q, one of: create, update, delete", e.Op)
+	}
+	return op, nil
+}
+
+// selectBatchSecret returns decrypted cached secret the entry selects by id or name
+func (a *agent) selectBatchSecret(kind model.Kind, e batchEntry) (model.Secret, error) {
+	opts := &cliOptions{id: e.ID, name: e.Name, set: map[string]bool{"id": e.ID != 0, "name": e.Name != ""}}
+	if !opts.set["id"] && !opts.set["name"] {
+		return nil, fmt.Errorf("%s needs the id or the name of the secret", e.Op)
+	}
+	return a.lookupSecret(kind, opts)
+}
+
+// applyBatch sends operations to the server which applies all of them or none in one transaction,
+// results are returned either way. The cache is updated once the batch is applied.
+func (a *agent) applyBatch(ctx context.Context, ops []*model.BatchOp) (*model.BatchResults, error) {
+	if !a.onlineMode {
+		return nil, errBatchOffline
+	}
+	data, s, err := a.sendRequest2(ctx, batchURI, http.MethodPost, ops)
+	if err != nil {
+		return nil, err
+	}
+	results := &model.BatchResults{}
+	if err := json.Unmarshal(data, results); err != nil || len(results.Results) != len(ops) {
+		return nil, fmt.Errorf("%w %d: %s", errBadHTTPStatusCode, s, strings.TrimSpace(string(data)))
+	}
+	if results.Applied {
+		if err := a.updateCache(ctx); err != nil {
+			a.logger.Errorf("Unable to update cache: %s", err.Error())
+		}
+	}
+	return results, nil
+}
+
+// batchError returns why the batch was not applied, conflicts and missing secrets are told apart
+func batchError(results *model.BatchResults) error {
+	if results.Applied {
+		return nil
+	}
+	for _, r := range results.Results {
+		switch r.Status {
+		case http.StatusConflict:
+			return fmt.Errorf("batch was not applied: %w", errConflict)
+		case http.StatusNotFound:
+			return fmt.Errorf("batch was not applied: %w", errSecretNotFound)
+		}
+	}
+	return errBatchNotApplied
+}
+
+func printBatch(ops []*model.BatchOp, results *model.BatchResults, output string) error {
+	if output == outputJSON {
+		return printJSON(results)
+	}
+	for i, r := range results.Results {
+		fmt.Printf("%d\t%s\t%s\t%d\t%d\t%s\n", i+1, ops[i].Op, ops[i].Kind, r.ID, r.Status, r.Error)
+	}
+	if !results.Applied {
+		fmt.Println("Nothing was applied")
+	}
+	return nil
+}
+
+// parseBatchOptions returns options of the batch command and the path of the batch file
+func parseBatchOptions(args []string) (*cliOptions, string, error) {
+	opts := &cliOptions{set: make(map[string]bool), fields: make(map[string]*string)}
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	fs.StringVar(&opts.output, "o", outputText, "Output format: text or json")
+	paths := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, "", err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(paths) != 1 {
+		fmt.Fprintln(os.Stderr, "A single batch file is required, - reads stdin")
+		return nil, "", errUsage
+	}
+	if opts.output != outputText && opts.output != outputJSON {
+		fmt.Fprintf(os.Stderr, "Unknown output format %s\n", opts.output)
+		return nil, "", errUsage
+	}
+	return opts, paths[0], nil
+}
+
+// cliBatch applies operations of the batch file and prints results of every operation
+func (a *agent) cliBatch(ctx context.Context, path string, opts *cliOptions) error {
+	entries, err := readBatch(path)
+	if err != nil {
+		return err
+	}
+	ops, err := a.prepareBatch(entries)
+	if err != nil {
+		return err
+	}
+	results, err := a.applyBatch(ctx, ops)
+	if err != nil {
+		return err
+	}
+	if err := printBatch(ops, results, opts.output); err != nil {
+		return err
+	}
+	return batchError(results)
+}
+
+// runBatch executes the batch command of the command line mode and returns the exit code
+func (a *agent) runBatch(args []string) int {
+	opts, path, err := parseBatchOptions(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		return ExitUsage
+	}
+	ctx := context.Background()
+	if err := a.connect(ctx); err != nil {
+		a.logger.Errorf("Unable to connect: %s", err.Error())
+		return ExitError
+	}
+	err = a.cliBatch(ctx, path, opts)
+	a.close()
+	if err != nil {
+		a.logger.Errorf("batch failed: %s", err.Error())
+	}
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, errSecretNotFound):
+		return ExitNotFound
+	case errors.Is(err, errConflict):
+		return ExitConflict
+	default:
+		return ExitError
+	}
+}
+
+// batch runs the batch command of the interactive session
+func (a *agent) batch(ctx context.Context, args []string) {
+	opts, path, err := parseBatchOptions(args)
+	if err != nil {
+		return
+	}
+	if err := a.cliBatch(ctx, path, opts); err != nil {
+		a.logger.Errorf("batch failed: %s", err.Error())
+	}
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_agent_cliBatch(t *testing.T) {
+	ctx := context.Background()
+	key := make([]byte, 32)
+	var sent []*model.BatchOp
+	conflict := false
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/private/batch":
+			sent = make([]*model.BatchOp, 0)
+			_ = json.NewDecoder(r.Body).Decode(&sent)
+			results := &model.BatchResults{Applied: !conflict, Results: make([]model.BatchResult, len(sent))}
+			for i := range sent {
+				results.Results[i] = model.BatchResult{Status: http.StatusOK, ID: i + 1}
+				if conflict {
+					results.Results[i] = model.BatchResult{Status: http.StatusFailedDependency, Error: "not applied"}
+				}
+			}
+			status := http.StatusOK
+			if conflict {
+				status = http.StatusConflict
+				results.Results[1] = model.BatchResult{Status: http.StatusConflict, ID: 4, Error: "conflict"}
+			}
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(results)
+		case "/api/v1/private/sync":
+			_ = json.NewEncoder(w).Encode(model.NewSyncChanges(1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+	a.key = key
+	login := &model.LoginWithPassword{SecretData: model.SecretData{ID: 4, Name: "github", Version: 2}, Login: "me", Password: "old"}
+	text := &model.SecretText{SecretData: model.SecretData{ID: 7, Name: "note", Version: 1}, Text: "text"}
+	for _, m := range []model.Secret{login, text} {
+		if err := m.Encrypt(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &model.SecretCache{Revision: 1}
+	c.Set(model.LoginWithPasswordKind, []model.Secret{login})
+	c.Set(model.SecretTextKind, []model.Secret{text})
+	if err := a.cache.Cache().Save(c); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "batch.json")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`[
+		{"op": "create", "kind": "text", "secret": {"name": "new note", "text": "hello"}},
+		{"op": "update", "kind": "login", "name": "github", "secret": {"password": "new"}},
+		{"op": "delete", "kind": "texts", "id": 7}
+	]`)
+	opts := &cliOptions{output: outputText}
+
+	assert.NoError(t, a.cliBatch(ctx, path, opts))
+	if assert.Len(t, sent, 3) {
+		assert.Equal(t, model.SecretTextKind.Name(), sent[0].Kind)
+		assert.Equal(t, 0, sent[0].Secret.Data().ID)
+		updated := sent[1].Secret
+		assert.Equal(t, 4, updated.Data().ID)
+		assert.Equal(t, 2, updated.Data().Version)
+		if assert.NoError(t, updated.Decrypt(key)) {
+			assert.Equal(t, "me", updated.(*model.LoginWithPassword).Login)
+			assert.Equal(t, "new", updated.(*model.LoginWithPassword).Password)
+		}
+		assert.Equal(t, model.BatchDelete, sent[2].Op)
+		assert.Equal(t, 7, sent[2].ID)
+	}
+
+	// A conflict of any operation fails the whole batch
+	conflict = true
+	assert.ErrorIs(t, a.cliBatch(ctx, path, opts), errConflict)
+
+	// Malformed entries are not sent
+	sent = nil
+	write(`[{"op": "delete", "kind": "text", "name": "missing"}]`)
+	assert.ErrorIs(t, a.cliBatch(ctx, path, opts), errSecretNotFound)
+	write(`[{"op": "create", "kind": "file", "secret": {"name": "photo"}}]`)
+	assert.Error(t, a.cliBatch(ctx, path, opts))
+	assert.Nil(t, sent)
+
+	a.onlineMode = false
+	write(`[{"op": "delete", "kind": "text", "id": 7}]`)
+	assert.ErrorIs(t, a.cliBatch(ctx, path, opts), errBatchOffline)
+}
+
+func Test_parseBatchOptions(t *testing.T) {
+	opts, path, err := parseBatchOptions([]string{"ops.json", "-o", "json"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "ops.json", path)
+		assert.Equal(t, outputJSON, opts.output)
+	}
+	_, _, err = parseBatchOptions([]string{"-o", "json"})
+	assert.ErrorIs(t, err, errUsage)
+	_, _, err = parseBatchOptions([]string{"a.json", "b.json"})
+	assert.ErrorIs(t, err, errUsage)
+}
@@ -0,0 +1,730 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/userinput"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Exit codes of the command line mode
+const (
+	ExitOK       = 0
+	ExitError    = 1
+	ExitUsage    = 2
+	ExitNotFound = 3
+	ExitConflict = 4
+)
+
+// Output formats of the command line mode
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var (
+	errUsage         = errors.New("usage")
+	errAmbiguousName = errors.New("more than one secret has the name, use --id")
+	errUnknownField  = errors.New("unknown field")
+)
+
+const cliUsage = `Usage: cenarius [flags] <command> <kind> [name] [options]
+       cenarius [flags] search <text> [options]
+       cenarius [flags] batch <file> [options]
+       cenarius [flags] import <file> [options]
+       cenarius [flags] export <file> [options]
+       cenarius [flags] restore --archive <file> [options]
+
+Commands:
+  list    lists id, name, meta, folder, tags and favorite mark of secrets grouped by folder,
+          --folder, --tags and --favorite list only secrets in the folder, with every tag or favorite ones
+  get     prints the secret selected by --id or --name, --field prints a single field
+  add     adds a secret, fields are taken from --<field> options, --folder, --tags and --favorite label it
+  update  updates the secret selected by --id or --name, --rename changes its name,
+          --folder, --tags and --favorite replace its labels, --force overwrites changes made on another device
+  delete  deletes the secret selected by --id or --name
+  otp     prints the current one-time code of the OTP secret selected by --id or --name
+  trash   lists secrets in the trash, delete moves secrets there
+  undelete restores the secret selected by --id or --name from the trash
+  purge   removes the secret selected by --id or --name from the trash for good, --all empties the trash of the kind
+  history lists versions of the secret selected by --id or --name, --version prints a prior version,
+          --id selects deleted secrets too
+  restore saves the prior --version of the secret selected by --id or --name as its next version,
+          a deleted secret selected by --id is added again
+  search  searches secrets of every kind by words of their names, meta and tags, best matches first,
+          --limit caps the number of secrets found, offline the cache is searched
+  batch   applies create, update and delete operations of the JSON file, - reads stdin, all of them or none,
+          and prints the result of every operation
+  import  imports a Bitwarden JSON, KeePass KDBX, 1Password 1PUX or CSV export, --dry-run only prints
+          what is added and skipped, secrets named like existing ones are skipped unless --duplicates is set
+  export  writes every secret with content of files to an archive encrypted with a passphrase,
+          --stdin reads the passphrase from stdin
+  restore --archive adds secrets of the archive to an empty account on any server, --force to one with secrets
+
+Kinds: `
+
+// secretSummary is a secret without its payload, it is printed by list and by commands changing secrets
+type secretSummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Meta string `json:"meta"`
+	model.Labels
+}
+
+func summary(m model.Secret) secretSummary {
+	d := m.Data()
+	return secretSummary{ID: d.ID, Name: d.Name, Meta: d.Meta, Labels: d.Labels}
+}
+
+// cliOptions are options of a command. Fields holds values of --<column> options which are set.
+type cliOptions struct {
+	id     int
+	name   string
+	rename string
+	meta   string
+	output string
+	field  string
+	uri    string
+	file   string
+	out    string
+	stdin  bool
+	force  bool
+	// version selects a prior version of the secret
+	version int
+	all     bool
+	// folder, tags and favorite label secrets or filter listed ones, tags are comma separated
+	folder   string
+	tags     string
+	favorite bool
+	// limit is the largest number of secrets found by search
+	limit int
+	// format, dryRun and duplicates are options of import
+	format     string
+	dryRun     bool
+	duplicates bool
+	// archive is the file restore --archive reads
+	archive string
+	set     map[string]bool
+	fields  map[string]*string
+}
+
+// Run executes a single command without prompting for anything given in args and returns the exit code
+func (a *agent) Run(args []string) int {
+	if len(args) > 0 && args[0] == "search" {
+		return a.runSearch(args[1:])
+	}
+	if len(args) > 0 && args[0] == "batch" {
+		return a.runBatch(args[1:])
+	}
+	if len(args) > 0 && args[0] == "import" {
+		return a.runImport(args[1:])
+	}
+	if len(args) > 0 && args[0] == "export" {
+		return a.runArchive("export", args[1:])
+	}
+	if isArchiveRestore(args) {
+		return a.runArchive("restore", args[1:])
+	}
+	if len(args) < 2 {
+		fmt.Fprint(os.Stderr, cliUsage, kindsHelp(), "\n")
+		return ExitUsage
+	}
+	command := args[0]
+	kind, ok := model.KindByName(args[1])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown kind %s, one of: %s\n", args[1], kindsHelp())
+		return ExitUsage
+	}
+	opts, err := parseCLIOptions(command, kind, args[2:])
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		return ExitUsage
+	}
+	ctx := context.Background()
+	if err := a.connect(ctx); err != nil {
+		a.logger.Errorf("Unable to connect: %s", err.Error())
+		return ExitError
+	}
+	a.resumeUploads(ctx)
+	err = a.runCommand(ctx, command, kind, opts)
+	if err == nil && mutates(command) {
+		if err := a.updateCache(ctx); err != nil {
+			a.logger.Errorf("Unable to update cache: %s", err.Error())
+		}
+	}
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(os.Stderr, cliUsage, kindsHelp(), "\n")
+		return ExitUsage
+	}
+	if err != nil {
+		a.logger.Errorf("%s %s failed: %s", command, kind.Name(), err.Error())
+	}
+	a.close()
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, errSecretNotFound), errors.Is(err, errVersionNotFound):
+		return ExitNotFound
+	case errors.Is(err, errConflict):
+		return ExitConflict
+	default:
+		return ExitError
+	}
+}
+
+// parseCLIOptions parses options of the command
+func parseCLIOptions(command string, kind model.Kind, args []string) (*cliOptions, error) {
+	opts := &cliOptions{set: make(map[string]bool), fields: make(map[string]*string)}
+	fs := newCLIFlagSet(command, kind, opts)
+	if fs == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %s\n", command)
+		fmt.Fprint(os.Stderr, cliUsage, kindsHelp(), "\n")
+		return nil, errUsage
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	// A single positional argument of commands selecting a secret is its name, options may follow it
+	if fs.NArg() > 0 && command != "list" && command != "add" && command != "trash" && !isSet(fs, "name") && !isSet(fs, "id") {
+		opts.name = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return nil, err
+		}
+		opts.set["name"] = true
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return nil, errUsage
+	}
+	if opts.output != outputText && opts.output != outputJSON {
+		fmt.Fprintf(os.Stderr, "Unknown output format %s\n", opts.output)
+		return nil, errUsage
+	}
+	fs.Visit(func(f *flag.Flag) {
+		opts.set[f.Name] = true
+	})
+	return opts, nil
+}
+
+// newCLIFlagSet returns options of the command bound to opts, every payload field of the kind
+// which is not generated gets its --<column> option. Nil is returned for unknown commands.
+func newCLIFlagSet(command string, kind model.Kind, opts *cliOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(command+" "+kind.Name(), flag.ContinueOnError)
+	fs.IntVar(&opts.id, "id", 0, "Id of the secret")
+	fs.StringVar(&opts.name, "name", "", "Name of the secret")
+	fs.StringVar(&opts.output, "o", outputText, "Output format: text or json")
+	switch command {
+	case "list":
+		fs.StringVar(&opts.folder, "folder", "", "List only secrets in the folder or its subfolders")
+		fs.StringVar(&opts.tags, "tags", "", "List only secrets with every tag of the comma separated list")
+		fs.BoolVar(&opts.favorite, "favorite", false, "List only favorite secrets")
+	case "delete", "otp", "trash", "undelete":
+	case "purge":
+		fs.BoolVar(&opts.all, "all", false, "Purge every secret of the kind in the trash")
+	case "history", "restore":
+		fs.IntVar(&opts.version, "version", 0, "Prior version of the secret")
+		if command == "restore" {
+			fs.BoolVar(&opts.force, "force", false, "Overwrite the secret if it was changed on another device")
+		}
+	case "get":
+		fs.StringVar(&opts.field, "field", "", "Print only the field, e.g. password")
+		fs.StringVar(&opts.out, "out", "", "Path the file is saved to")
+	case "add", "update":
+		fs.StringVar(&opts.meta, "meta", "", "Meta of the secret")
+		fs.StringVar(&opts.folder, "folder", "", "Folder of the secret, subfolders are separated by /")
+		fs.StringVar(&opts.tags, "tags", "", "Comma separated tags of the secret")
+		fs.BoolVar(&opts.favorite, "favorite", false, "Mark the secret as favorite, --favorite=false unmarks it")
+		fs.BoolVar(&opts.stdin, "stdin", false, "Read the first field which is not given from stdin")
+		if command == "update" {
+			fs.StringVar(&opts.rename, "rename", "", "New name of the secret")
+			fs.BoolVar(&opts.force, "force", false, "Overwrite the secret if it was changed on another device")
+		}
+		if _, ok := kind.New().(model.URIImporter); ok {
+			fs.StringVar(&opts.uri, "uri", "", "URI the secret is imported from")
+		}
+		if kind.Blob() && command == "add" {
+			fs.StringVar(&opts.file, "file", "", "Path of the file to upload")
+		}
+		for _, f := range kind.New().Fields() {
+			if f.Generated {
+				continue
+			}
+			v := new(string)
+			opts.fields[f.Column] = v
+			usage := f.Label
+			if f.File {
+				usage += ", path of the file"
+			}
+			fs.StringVar(v, f.Column, "", usage)
+		}
+	default:
+		return nil
+	}
+	return fs
+}
+
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// mutates reports whether the command changes secrets, the cache is refreshed after such commands
+func mutates(command string) bool {
+	return command == "add" || command == "update" || command == "delete" || command == "restore" || command == "undelete"
+}
+
+func (a *agent) runCommand(ctx context.Context, command string, kind model.Kind, opts *cliOptions) error {
+	switch command {
+	case "list":
+		return a.cliList(kind, opts)
+	case "get":
+		return a.cliGet(ctx, kind, opts)
+	case "add":
+		return a.cliAdd(ctx, kind, opts)
+	case "update":
+		return a.cliUpdate(ctx, kind, opts)
+	case "delete":
+		return a.cliDelete(ctx, kind, opts)
+	case "otp":
+		return a.cliOTP(kind, opts)
+	case "trash":
+		return a.cliTrash(ctx, kind, opts)
+	case "undelete":
+		return a.cliUndelete(ctx, kind, opts)
+	case "purge":
+		return a.cliPurge(ctx, kind, opts)
+	case "history":
+		return a.cliHistory(ctx, kind, opts)
+	case "restore":
+		return a.cliRestore(ctx, kind, opts)
+	}
+	return errUsage
+}
+
+// cliList lists secrets matching the label filter sorted by folder, so secrets of a folder follow each other
+func (a *agent) cliList(kind model.Kind, opts *cliOptions) error {
+	cache, err := a.cache.Cache().Get()
+	if err != nil {
+		return err
+	}
+	secrets := filterLabels(cache.Get(kind), labelFilterOf(opts))
+	model.SortByFolder(secrets)
+	list := make([]secretSummary, 0, len(secrets))
+	for _, m := range secrets {
+		list = append(list, summary(m))
+	}
+	if opts.output == outputJSON {
+		return printJSON(list)
+	}
+	for _, s := range list {
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.Name, s.Meta, s.Folder, strings.Join(s.Tags, ","), favoriteMark(s.Favorite))
+	}
+	return nil
+}
+
+// labelFilterOf returns the filter of --folder, --tags and --favorite options
+func labelFilterOf(opts *cliOptions) model.LabelFilter {
+	return model.LabelFilter{Folder: model.CleanFolder(opts.folder), Tags: model.ParseTags(opts.tags), Favorite: opts.favorite}
+}
+
+// setLabels changes labels given in options, every label is set when a secret is added
+func setLabels(m model.Secret, opts *cliOptions, all bool) {
+	d := m.Data()
+	if all || opts.set["folder"] {
+		d.Folder = model.CleanFolder(opts.folder)
+	}
+	if all || opts.set["tags"] {
+		d.Tags = model.ParseTags(opts.tags)
+	}
+	if all || opts.set["favorite"] {
+		d.Favorite = opts.favorite
+	}
+}
+
+func (a *agent) cliGet(ctx context.Context, kind model.Kind, opts *cliOptions) error {
+	m, err := a.lookupSecret(kind, opts)
+	if err != nil {
+		return err
+	}
+	if opts.field != "" {
+		v, err := fieldValue(m, opts.field)
+		if err != nil {
+			return err
+		}
+		fmt.Println(v)
+		return nil
+	}
+	if kind.Blob() {
+		dst := opts.out
+		if dst == "" {
+			dst = fmt.Sprintf("SecretFile_%d", m.Data().ID)
+		}
+		if err := a.saveSecretFile(ctx, kind, m.Data().ID, dst); err != nil {
+			return err
+		}
+		if opts.output == outputJSON {
+			return printJSON(struct {
+				secretSummary
+				Out string `json:"out"`
+			}{summary(m), dst})
+		}
+		fmt.Println(dst)
+		return nil
+	}
+	if opts.output == outputJSON {
+		return printJSON(m)
+	}
+	fmt.Println(m)
+	return nil
+}
+
+func (a *agent) cliAdd(ctx context.Context, kind model.Kind, opts *cliOptions) error {
+	m := kind.New()
+	m.Data().Name = opts.name
+	m.Data().Meta = opts.meta
+	setLabels(m, opts, true)
+	if b, ok := m.(model.BlobSecret); ok {
+		if opts.file == "" {
+			fmt.Fprintln(os.Stderr, "--file is required")
+			return errUsage
+		}
+		*b.BlobName() = opts.file
+	}
+	if opts.uri != "" {
+		if err := m.(model.URIImporter).ParseURI(opts.uri); err != nil {
+			return err
+		}
+	} else if err := fillFields(m, opts); err != nil {
+		return err
+	}
+	if err := a.addSecret(ctx, kind, m); err != nil {
+		return err
+	}
+	return printSaved(kind, m, opts, "Saved")
+}
+
+func (a *agent) cliUpdate(ctx context.Context, kind model.Kind, opts *cliOptions) error {
+	m, err := a.lookupSecret(kind, opts)
+	if err != nil {
+		return err
+	}
+	if opts.rename != "" {
+		m.Data().Name = opts.rename
+	}
+	if opts.set["meta"] {
+		m.Data().Meta = opts.meta
+	}
+	setLabels(m, opts, false)
+	if opts.uri != "" {
+		if err := m.(model.URIImporter).ParseURI(opts.uri); err != nil {
+			return err
+		}
+	} else if err := setFields(m, opts); err != nil {
+		return err
+	}
+	m, err = a.updateResolved(ctx, kind, m, conflictResolverOf(opts))
+	if err != nil {
+		return err
+	}
+	return printSaved(kind, m, opts, "Saved")
+}
+
+func (a *agent) cliDelete(ctx context.Context, kind model.Kind, opts *cliOptions) error {
+	m, err := a.lookupSecret(kind, opts)
+	if err != nil {
+		return err
+	}
+	if err := a.deleteSecret(ctx, kind, m.Data().ID); err != nil {
+		return err
+	}
+	return printSaved(kind, m, opts, "Deleted")
+}
+
+func (a *agent) cliOTP(kind model.Kind, opts *cliOptions) error {
+	m, err := a.lookupSecret(kind, opts)
+	if err != nil {
+		return err
+	}
+	s, ok := m.(*model.OTPSecret)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "otp needs an OTP secret, not %s\n", kind.Name())
+		return errUsage
+	}
+	code, valid, err := s.Code(time.Now())
+	if err != nil {
+		return err
+	}
+	if opts.output == outputJSON {
+		return printJSON(struct {
+			Code  string `json:"code"`
+			Valid int    `json:"valid"`
+		}{code, int(valid.Round(time.Second) / time.Second)})
+	}
+	fmt.Println(code)
+	return nil
+}
+
+func (a *agent) cliTrash(ctx context.Context, kind model.Kind, opts *cliOptions) error {
+	trash, err := a.getTrash(ctx)
+	if err != nil {
+		return err
+	}
+	list := trashList(trash, []model.Kind{kind})
+	if opts.output == outputJSON {
+		return printJSON(list)
+	}
+	printTrash(list)
+	return nil
+}
+
+func (a *agent) cliUndelete(ctx context.Context, kind model.Kind, opts *cliOptions) error {
+	m, err := a.lookupTrashed(ctx, kind, opts)
+	if err != nil {
+		return err
+	}
+	if _, err := a.undeleteSecret(ctx, kind, m.Data().ID); err != nil {
+		return err
+	}
+	return printSaved(kind, m, opts, "Restored")
+}
+
+// cliPurge purges the selected secret or with --all every secret of the kind in the trash
+func (a *agent) cliPurge(ctx context.Context, kind model.Kind, opts *cliOptions) error {
+	if !opts.all {
+		m, err := a.lookupTrashed(ctx, kind, opts)
+		if err != nil {
+			return err
+		}
+		if err := a.purgeSecret(ctx, kind, m.Data().ID); err != nil {
+			return err
+		}
+		return printSaved(kind, m, opts, "Purged")
+	}
+	trash, err := a.getTrash(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range trash.Get(kind) {
+		if err := a.purgeSecret(ctx, kind, m.Data().ID); err != nil {
+			return err
+		}
+		if err := printSaved(kind, m, opts, "Purged"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *agent) cliHistory(ctx context.Context, kind model.Kind, opts *cliOptions) error {
+	id, err := a.historyID(kind, opts)
+	if err != nil {
+		return err
+	}
+	if opts.version > 0 {
+		m, err := a.secretVersion(ctx, kind, id, opts.version)
+		if err != nil {
+			return err
+		}
+		if opts.output == outputJSON {
+			return printJSON(m)
+		}
+		fmt.Println(m)
+		return nil
+	}
+	versions, err := a.versionsOf(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	if opts.output == outputJSON {
+		return printJSON(versions)
+	}
+	printVersions(versions)
+	return nil
+}
+
+func (a *agent) cliRestore(ctx context.Context, kind model.Kind, opts *cliOptions) error {
+	if opts.version <= 0 {
+		fmt.Fprintln(os.Stderr, "--version is required")
+		return errUsage
+	}
+	id, err := a.historyID(kind, opts)
+	if err != nil {
+		return err
+	}
+	m, err := a.restoreSecret(ctx, kind, id, opts.version, conflictResolverOf(opts))
+	if err != nil {
+		return err
+	}
+	return printSaved(kind, m, opts, "Restored")
+}
+
+// historyID returns the id of the secret selected by --id, which may be deleted already, or by --name
+func (a *agent) historyID(kind model.Kind, opts *cliOptions) (int, error) {
+	if opts.set["id"] {
+		return opts.id, nil
+	}
+	m, err := a.lookupSecret(kind, opts)
+	if err != nil {
+		return 0, err
+	}
+	return m.Data().ID, nil
+}
+
+// printSaved prints the changed secret
+func printSaved(kind model.Kind, m model.Secret, opts *cliOptions, action string) error {
+	if opts.output == outputJSON {
+		return printJSON(summary(m))
+	}
+	fmt.Printf("%s %s %d\n", action, kind.Name(), m.Data().ID)
+	return nil
+}
+
+// lookupSecret returns decrypted secret selected by --id or --name
+func (a *agent) lookupSecret(kind model.Kind, opts *cliOptions) (model.Secret, error) {
+	if opts.set["id"] {
+		return a.findSecret(kind, opts.id)
+	}
+	if !opts.set["name"] {
+		fmt.Fprintln(os.Stderr, "--id or --name is required")
+		return nil, errUsage
+	}
+	cache, err := a.cache.Cache().Get()
+	if err != nil {
+		return nil, err
+	}
+	id := 0
+	for _, m := range cache.Get(kind) {
+		if m.Data().Name != opts.name {
+			continue
+		}
+		if id != 0 {
+			return nil, errAmbiguousName
+		}
+		id = m.Data().ID
+	}
+	if id == 0 {
+		return nil, errSecretNotFound
+	}
+	return a.findSecret(kind, id)
+}
+
+// conflictResolverOf returns how the update resolves changes made on another device: --force
+// overwrites them, on a terminal the user picks or merges versions, otherwise the update fails
+func conflictResolverOf(opts *cliOptions) conflictResolver {
+	switch {
+	case opts.force:
+		return keepLocal
+	case !opts.stdin && term.IsTerminal(int(os.Stdin.Fd())):
+		return promptConflict
+	}
+	return failConflict
+}
+
+// fillFields sets fields of the new secret from options. The first field which is not given
+// is read from stdin if --stdin is set, others are prompted for only when stdin is a terminal.
+func fillFields(m model.Secret, opts *cliOptions) error {
+	interactive := !opts.stdin && term.IsTerminal(int(os.Stdin.Fd()))
+	for _, f := range m.Fields() {
+		if f.Generated {
+			continue
+		}
+		var err error
+		switch {
+		case opts.set[f.Column]:
+			*f.Value, err = optionValue(f, *opts.fields[f.Column])
+		case opts.stdin:
+			opts.stdin = false
+			*f.Value, err = readStdin()
+		case interactive && f.Hidden:
+			*f.Value = userinput.InputPassword(f.Label)
+		case interactive:
+			*f.Value, err = optionValue(f, userinput.Input(f.Label))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setFields changes fields of the existing secret given in options, --stdin replaces the first field which is not given
+func setFields(m model.Secret, opts *cliOptions) error {
+	for _, f := range m.Fields() {
+		if f.Generated {
+			continue
+		}
+		var err error
+		switch {
+		case opts.set[f.Column]:
+			*f.Value, err = optionValue(f, *opts.fields[f.Column])
+		case opts.stdin:
+			opts.stdin = false
+			*f.Value, err = readStdin()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// optionValue returns value of the field given by the user, file fields are given by a path
+func optionValue(f model.Field, v string) (string, error) {
+	if !f.File {
+		return v, nil
+	}
+	content, err := os.ReadFile(v)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// readStdin reads stdin to the end, a single trailing newline is dropped
+func readStdin() (string, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// fieldValue returns the decrypted field by its column, name, meta, folder and tags are fields too
+func fieldValue(m model.Secret, column string) (string, error) {
+	switch column {
+	case "name":
+		return m.Data().Name, nil
+	case "meta":
+		return m.Data().Meta, nil
+	case "folder":
+		return m.Data().Folder, nil
+	case "tags":
+		return strings.Join(m.Data().Tags, ","), nil
+	}
+	for _, f := range m.Fields() {
+		if f.Column == column {
+			return *f.Value, nil
+		}
+	}
+	return "", fmt.Errorf("%w %s", errUnknownField, column)
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseCLIOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		kind    model.Kind
+		args    []string
+		wantErr bool
+		check   func(t *testing.T, opts *cliOptions)
+	}{
+		{
+			name:    "get field",
+			command: "get",
+			kind:    model.LoginWithPasswordKind,
+			args:    []string{"--name", "github", "--field", "password"},
+			check: func(t *testing.T, opts *cliOptions) {
+				assert.Equal(t, "github", opts.name)
+				assert.Equal(t, "password", opts.field)
+				assert.True(t, opts.set["name"])
+				assert.False(t, opts.set["id"])
+			},
+		},
+		{
+			name:    "positional name",
+			command: "get",
+			kind:    model.LoginWithPasswordKind,
+			args:    []string{"my bank", "--field", "password"},
+			check: func(t *testing.T, opts *cliOptions) {
+				assert.Equal(t, "my bank", opts.name)
+				assert.Equal(t, "password", opts.field)
+				assert.True(t, opts.set["name"])
+			},
+		},
+		{
+			name:    "add fields",
+			command: "add",
+			kind:    model.LoginWithPasswordKind,
+			args:    []string{"--name", "github", "--login", "me", "--stdin", "-o", "json"},
+			check: func(t *testing.T, opts *cliOptions) {
+				assert.Equal(t, "me", *opts.fields["login"])
+				assert.True(t, opts.set["login"])
+				assert.False(t, opts.set["password"])
+				assert.True(t, opts.stdin)
+				assert.Equal(t, outputJSON, opts.output)
+			},
+		},
+		{
+			name:    "generated fields are not options",
+			command: "add",
+			kind:    model.KeyPairKind,
+			args:    []string{"--fingerprint", "x"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown command",
+			command: "show",
+			kind:    model.SecretTextKind,
+			wantErr: true,
+		},
+		{
+			name:    "unknown output",
+			command: "list",
+			kind:    model.CreditCardKind,
+			args:    []string{"-o", "yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "positional arguments",
+			command: "delete",
+			kind:    model.CreditCardKind,
+			args:    []string{"--id", "1", "2"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseCLIOptions(tt.command, tt.kind, tt.args)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) && tt.check != nil {
+				tt.check(t, opts)
+			}
+		})
+	}
+}
+
+func Test_fieldValue(t *testing.T) {
+	m := &model.LoginWithPassword{Login: "me", Password: "secret"}
+	m.Name = "github"
+	v, err := fieldValue(m, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+	v, err = fieldValue(m, "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "github", v)
+	_, err = fieldValue(m, "cvc")
+	assert.ErrorIs(t, err, errUnknownField)
+}
@@ -1,14 +1,21 @@
 package agent
 
+import (
+	"os"
+	"time"
+)
+
 type Config struct {
-	Host      string `json:"host" toml:"host,omitempty"`
-	LogLevel  string `json:"log_level" toml:"log_level,omitempty"`
-	GZip      bool   `json:"gzip" toml:"gzip,omitempty"`
-	Login     string `json:"login" toml:"login,omitempty"`
-	Password  string `json:"password" toml:"password,omitempty"`
-	CacheFile string `json:"cache_file"`
-	SecretKey string `json:"secret_key"`
-	SecretIV  string `json:"secret_iv"`
+	Host           string        `json:"host" toml:"host,omitempty"`
+	LogLevel       string        `json:"log_level" toml:"log_level,omitempty"`
+	GZip           bool          `json:"gzip" toml:"gzip,omitempty"`
+	Login          string        `json:"login" toml:"login,omitempty"`
+	Password       string        `json:"password" toml:"password,omitempty"`
+	CacheFile      string        `json:"cache_file"`
+	SSHAgentSocket string        `json:"ssh_agent_socket" toml:"ssh_agent_socket,omitempty"`
+	IdleLock       time.Duration `json:"idle_lock" toml:"idle_lock,omitempty"`
+	// ClientID is recorded by the server with every version of a secret the agent saves
+	ClientID string `json:"client_id" toml:"client_id,omitempty"`
 }
 
 func NewConfig() *Config {
@@ -19,5 +26,16 @@ func NewConfig() *Config {
 		Login:     "AgentUser",
 		Password:  "AgentPassword",
 		CacheFile: "/tmp/cenarius.cache",
+		IdleLock:  5 * time.Minute,
+		ClientID:  defaultClientID(),
+	}
+}
+
+// defaultClientID names the agent by the host it runs on
+func defaultClientID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return ""
 	}
+	return host
 }
@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/userinput"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// conflictValueWidth limits values of both versions printed side by side
+const conflictValueWidth = 60
+
+var errConflict = errors.New("secret was changed on another device")
+
+// conflictError is returned by an update of a stale version, current is the sealed secret stored on the server
+type conflictError struct {
+	current model.Secret
+}
+
+func (e *conflictError) Error() string {
+	return fmt.Sprintf("%s, the server has version %d", errConflict.Error(), e.current.Data().Version)
+}
+
+func (e *conflictError) Unwrap() error {
+	return errConflict
+}
+
+// newConflictError reads the stored secret from the body of the 409 response
+func newConflictError(kind model.Kind, data []byte) error {
+	body := struct {
+		Current json.RawMessage `json:"current"`
+	}{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+	m := kind.New()
+	if err := json.Unmarshal(body.Current, m); err != nil {
+		return err
+	}
+	return &conflictError{current: m}
+}
+
+// conflictField is a field which differs between versions, key matches keys of form inputs
+type conflictField struct {
+	key    string
+	label  string
+	local  string
+	server string
+	hidden bool
+}
+
+// conflictFields compares decrypted versions, generated fields follow entered ones and are skipped
+func conflictFields(local, server model.Secret) []conflictField {
+	fields := make([]conflictField, 0)
+	if l, s := local.Data().Name, server.Data().Name; l != s {
+		fields = append(fields, conflictField{key: formName, label: "Name", local: l, server: s})
+	}
+	serverFields := server.Fields()
+	for i, f := range local.Fields() {
+		if f.Generated || *f.Value == *serverFields[i].Value {
+			continue
+		}
+		fields = append(fields, conflictField{
+			key: f.Column, label: f.Label, local: *f.Value, server: *serverFields[i].Value, hidden: f.Hidden,
+		})
+	}
+	if l, s := local.Data().Meta, server.Data().Meta; l != s {
+		fields = append(fields, conflictField{key: formMeta, label: "Meta", local: l, server: s})
+	}
+	if l, s := local.Data().Folder, server.Data().Folder; l != s {
+		fields = append(fields, conflictField{key: formFolder, label: "Folder", local: l, server: s})
+	}
+	if l, s := strings.Join(local.Data().Tags, ", "), strings.Join(server.Data().Tags, ", "); l != s {
+		fields = append(fields, conflictField{key: formTags, label: "Tags", local: l, server: s})
+	}
+	return fields
+}
+
+// setConflictField sets the field of m by its key
+func setConflictField(m model.Secret, key, value string) {
+	switch key {
+	case formName:
+		m.Data().Name = value
+	case formMeta:
+		m.Data().Meta = value
+	case formFolder:
+		m.Data().Folder = model.CleanFolder(value)
+	case formTags:
+		m.Data().Tags = model.ParseTags(value)
+	default:
+		for _, f := range m.Fields() {
+			if f.Column == key {
+				*f.Value = value
+			}
+		}
+	}
+}
+
+// conflictResolver returns the secret to save from decrypted local and server versions,
+// nil keeps the server version
+type conflictResolver func(kind model.Kind, local, server model.Secret) (model.Secret, error)
+
+// updateResolved updates the secret, conflicts with newer versions on the server are resolved
+// until the update succeeds. It returns the stored secret.
+func (a *agent) updateResolved(ctx context.Context, kind model.Kind, m model.Secret, resolve conflictResolver) (model.Secret, error) {
+	if err := a.seal(m); err != nil {
+		return nil, err
+	}
+	return a.resolveConflicts(kind, m, resolve, func(m model.Secret) error {
+		return a.saveChange(ctx, opUpdate, kind, m)
+	})
+}
+
+// resolveConflicts sends the sealed secret until send succeeds or fails not because of a conflict,
+// versions are decrypted to be resolved and the resolved secret is sealed again with the server version
+func (a *agent) resolveConflicts(kind model.Kind, m model.Secret, resolve conflictResolver, send func(model.Secret) error) (model.Secret, error) {
+	for {
+		err := send(m)
+		var conflict *conflictError
+		if !errors.As(err, &conflict) {
+			return m, err
+		}
+		current := conflict.current
+		if err := m.Decrypt(a.key); err != nil {
+			return nil, err
+		}
+		if err := current.Decrypt(a.key); err != nil {
+			return nil, err
+		}
+		resolved, err := resolve(kind, m, current)
+		if err != nil {
+			return nil, err
+		}
+		if resolved == nil {
+			return current, nil
+		}
+		resolved.Data().Version = current.Data().Version
+		if err := a.seal(resolved); err != nil {
+			return nil, err
+		}
+		m = resolved
+	}
+}
+
+// keepLocal overwrites the server version
+func keepLocal(_ model.Kind, local, _ model.Secret) (model.Secret, error) {
+	return local, nil
+}
+
+// failConflict leaves the conflict to the user and names the fields which differ
+func failConflict(_ model.Kind, local, server model.Secret) (model.Secret, error) {
+	labels := make([]string, 0)
+	for _, f := range conflictFields(local, server) {
+		labels = append(labels, f.label)
+	}
+	return nil, fmt.Errorf("%w: version %d differs in %s", errConflict, server.Data().Version, strings.Join(labels, ", "))
+}
+
+// promptConflict shows both versions and asks which one to keep, merge picks every differing field
+func promptConflict(kind model.Kind, local, server model.Secret) (model.Secret, error) {
+	fields := conflictFields(local, server)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	fmt.Printf("%s %s was changed on another device, version %d:\n", kind.Name(), server.Data().Name, server.Data().Version)
+	for _, f := range fields {
+		fmt.Printf("  %s\n    local:  %s\n    server: %s\n", f.label, conflictValue(f.local), conflictValue(f.server))
+	}
+	switch strings.ToLower(strings.TrimSpace(userinput.Input("[l]ocal, [s]erver or [m]erge"))) {
+	case "l", "local":
+		return local, nil
+	case "s", "server":
+		return nil, nil
+	case "m", "merge":
+		return mergeConflict(fields, server), nil
+	}
+	return nil, fmt.Errorf("%w: not resolved", errConflict)
+}
+
+// mergeConflict takes every differing field from the local or the server version or a new value
+func mergeConflict(fields []conflictField, server model.Secret) model.Secret {
+	for _, f := range fields {
+		switch strings.ToLower(strings.TrimSpace(userinput.Input(f.label + " [l]ocal, [s]erver or [n]ew value"))) {
+		case "l", "local":
+			setConflictField(server, f.key, f.local)
+		case "n", "new":
+			if f.hidden {
+				setConflictField(server, f.key, userinput.InputPassword(f.label))
+			} else {
+				setConflictField(server, f.key, userinput.Input(f.label))
+			}
+		}
+	}
+	return server
+}
+
+func conflictValue(v string) string {
+	return strings.TrimSpace(fit(v, conflictValueWidth))
+}
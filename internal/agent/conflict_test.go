@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"cenarius/internal/cache/mcache"
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_conflictFields(t *testing.T) {
+	local := &model.LoginWithPassword{SecretData: model.SecretData{Name: "github", Meta: "work"}, Login: "me", Password: "local"}
+	server := &model.LoginWithPassword{SecretData: model.SecretData{Name: "github", Meta: "home"}, Login: "me", Password: "server"}
+	fields := conflictFields(local, server)
+	if assert.Len(t, fields, 2) {
+		assert.Equal(t, conflictField{key: "password", label: "Password", local: "local", server: "server", hidden: true}, fields[0])
+		assert.Equal(t, formMeta, fields[1].key)
+	}
+
+	setConflictField(server, "password", "merged")
+	setConflictField(server, formMeta, "both")
+	assert.Equal(t, "merged", server.Password)
+	assert.Equal(t, "both", server.Meta)
+}
+
+func Test_agent_updateResolved(t *testing.T) {
+	key := make([]byte, 32)
+	current := &model.LoginWithPassword{SecretData: model.SecretData{ID: 1, Name: "github", Version: 3}, Login: "me", Password: "server"}
+	if err := current.Encrypt(key); err != nil {
+		t.Fatal(err)
+	}
+	var ifMatch []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifMatch = append(ifMatch, r.Header.Get("If-Match"))
+		if r.Header.Get("If-Match") != `"3"` {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": "conflict", "current": current})
+			return
+		}
+		m := &model.LoginWithPassword{}
+		_ = json.NewDecoder(r.Body).Decode(m)
+		m.Version = 4
+		_ = json.NewEncoder(w).Encode(m)
+	}))
+	defer srv.Close()
+	a := &agent{
+		config:     &Config{Host: strings.TrimPrefix(srv.URL, "https://")},
+		logger:     logrus.New(),
+		client:     *srv.Client(),
+		cache:      mcache.New(),
+		key:        key,
+		onlineMode: true,
+	}
+	local := func() model.Secret {
+		return &model.LoginWithPassword{SecretData: model.SecretData{ID: 1, Name: "github", Version: 2}, Login: "me", Password: "local"}
+	}
+
+	_, err := a.updateResolved(context.Background(), model.LoginWithPasswordKind, local(), failConflict)
+	assert.ErrorIs(t, err, errConflict)
+	assert.Contains(t, err.Error(), "Password")
+
+	ifMatch = nil
+	m, err := a.updateResolved(context.Background(), model.LoginWithPasswordKind, local(), keepLocal)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 4, m.Data().Version)
+		assert.NoError(t, m.Decrypt(key))
+		assert.Equal(t, "local", m.(*model.LoginWithPassword).Password)
+	}
+	assert.Equal(t, []string{`"2"`, `"3"`}, ifMatch)
+
+	m, err = a.updateResolved(context.Background(), model.LoginWithPasswordKind, local(), func(_ model.Kind, _, _ model.Secret) (model.Secret, error) {
+		return nil, nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "server", m.(*model.LoginWithPassword).Password)
+	}
+}
@@ -0,0 +1,353 @@
+package agent
+
+import (
+	"cenarius/internal/archive"
+	"cenarius/internal/importer"
+	"cenarius/internal/model"
+	"cenarius/internal/userinput"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// minPassphraseLength is the shortest passphrase an archive is protected with
+const minPassphraseLength = 8
+
+var (
+	errExportOffline      = errors.New("content of files is downloaded from the server, it is unavailable offline")
+	errRestoreOffline     = errors.New("secrets are restored to the server, it is unavailable offline")
+	errArchiveExists      = errors.New("archive exists, choose another file")
+	errShortPassphrase    = fmt.Errorf("passphrase must be at least %d characters long", minPassphraseLength)
+	errPassphraseMatch    = errors.New("passphrases don't match")
+	errAccountNotEmpty    = errors.New("account has secrets, restore to an empty account or use --force to add secrets of the archive to them")
+	errUnknownArchiveFile = errors.New("archive holds content of an unknown file")
+)
+
+// archiveSummary is printed by export and restore, Restored is the number of secrets added by restore
+type archiveSummary struct {
+	Path string `json:"path"`
+	archive.Manifest
+	Restored int `json:"restored,omitempty"`
+}
+
+// readPassphrase reads the passphrase of the archive from stdin with --stdin or prompts for it,
+// a new passphrase is prompted for twice
+func readPassphrase(opts *cliOptions, confirm bool) (string, error) {
+	if opts.stdin {
+		return readStdin()
+	}
+	passphrase := userinput.InputPassword("archive passphrase")
+	if confirm && passphrase != userinput.InputPassword("archive passphrase again") {
+		return "", errPassphraseMatch
+	}
+	return passphrase, nil
+}
+
+// parseArchiveOptions returns options of the export command and of restore --archive and the path of the archive
+func parseArchiveOptions(command string, args []string) (*cliOptions, string, error) {
+	opts := &cliOptions{set: make(map[string]bool), fields: make(map[string]*string)}
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.BoolVar(&opts.stdin, "stdin", false, "Read the passphrase of the archive from stdin")
+	fs.StringVar(&opts.output, "o", outputText, "Output format: text or json")
+	if command == "restore" {
+		fs.StringVar(&opts.archive, "archive", "", "Archive made by export")
+		fs.BoolVar(&opts.force, "force", false, "Restore to an account which has secrets, they are kept")
+	}
+	paths := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, "", err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if command == "restore" && opts.archive != "" && len(paths) == 0 {
+		paths = append(paths, opts.archive)
+	}
+	if len(paths) != 1 {
+		fmt.Fprintln(os.Stderr, "A single archive file is required")
+		return nil, "", errUsage
+	}
+	if opts.output != outputText && opts.output != outputJSON {
+		fmt.Fprintf(os.Stderr, "Unknown output format %s\n", opts.output)
+		return nil, "", errUsage
+	}
+	return opts, paths[0], nil
+}
+
+// isArchiveRestore tells restore --archive from restore of a prior version of a secret
+func isArchiveRestore(args []string) bool {
+	return len(args) > 1 && args[0] == "restore" && strings.HasPrefix(args[1], "-") &&
+		strings.HasPrefix(strings.TrimLeft(args[1], "-"), "archive")
+}
+
+// printArchiveSummary prints numbers of secrets of every kind in the archive
+func printArchiveSummary(s *archiveSummary, output string) error {
+	if output == outputJSON {
+		return printJSON(s)
+	}
+	fmt.Printf("Archive %s of %s:\n", s.Path, s.CreatedAt.Local().Format("2006-01-02 15:04"))
+	for _, kind := range model.Kinds() {
+		if n := s.Secrets[kind.Name()]; n > 0 {
+			fmt.Printf("  %s\t%d\n", kind.Plural(), n)
+		}
+	}
+	return nil
+}
+
+// cliExport writes every secret of the account with content of files to the archive protected by a passphrase.
+// The archive is written next to the path and renamed once it is complete, an existing file is never replaced.
+func (a *agent) cliExport(ctx context.Context, path string, opts *cliOptions) error {
+	if _, err := os.Stat(path); err == nil {
+		return errArchiveExists
+	}
+	passphrase, err := readPassphrase(opts, true)
+	if err != nil {
+		return err
+	}
+	if len(passphrase) < minPassphraseLength {
+		return errShortPassphrase
+	}
+	if err := a.updateCache(ctx); err != nil {
+		return err
+	}
+	items, err := a.exportItems()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w, err := a.writeArchive(ctx, f, passphrase, items)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return printArchiveSummary(&archiveSummary{Path: path, Manifest: w.Manifest}, opts.output)
+}
+
+// exportItems returns decrypted copies of every cached secret, offline files can't be exported
+func (a *agent) exportItems() ([]archive.Item, error) {
+	cache, err := a.cache.Cache().Get()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]archive.Item, 0)
+	for _, kind := range model.Kinds() {
+		for _, cached := range cache.Get(kind) {
+			if kind.Blob() && !a.onlineMode {
+				return nil, errExportOffline
+			}
+			m, err := a.findSecret(kind, cached.Data().ID)
+			if err != nil {
+				return nil, fmt.Errorf("%s %q: %w", kind.Name(), cached.Data().Name, err)
+			}
+			items = append(items, archive.Item{Kind: kind, Secret: m})
+		}
+	}
+	return items, nil
+}
+
+// writeArchive writes the items to w, content of files is downloaded to temporary files next to the cache
+func (a *agent) writeArchive(ctx context.Context, w io.Writer, passphrase string, items []archive.Item) (*archive.Writer, error) {
+	aw, err := archive.NewWriter(w, passphrase, items)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.File == "" {
+			continue
+		}
+		if err := a.addArchiveFile(ctx, aw, item); err != nil {
+			return nil, fmt.Errorf("%s %q: %w", item.Kind.Name(), item.Secret.Data().Name, err)
+		}
+	}
+	return aw, aw.Close()
+}
+
+func (a *agent) addArchiveFile(ctx context.Context, aw *archive.Writer, item archive.Item) error {
+	tmp, err := os.CreateTemp(filepath.Dir(a.config.CacheFile), "cenarius-export-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := a.saveSecretFile(ctx, item.Kind, item.Secret.Data().ID, tmp.Name()); err != nil {
+		os.Remove(tmp.Name() + ".part")
+		return err
+	}
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return aw.AddFile(item.File, stat.Size(), f)
+}
+
+// cliRestoreArchive adds secrets and files of the archive to the account, which must be empty unless --force is set
+func (a *agent) cliRestoreArchive(ctx context.Context, path string, opts *cliOptions) error {
+	if !a.onlineMode {
+		return errRestoreOffline
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase(opts, false)
+	if err != nil {
+		return err
+	}
+	r, err := archive.Open(f, stat.Size(), passphrase)
+	if err != nil {
+		return err
+	}
+	if err := a.updateCache(ctx); err != nil {
+		return err
+	}
+	if !opts.force {
+		empty, err := a.accountEmpty()
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return errAccountNotEmpty
+		}
+	}
+	plan := make([]*importedSecret, 0, len(r.Items))
+	files := make(map[string]*model.SecretFile)
+	for _, item := range r.Items {
+		if item.File != "" {
+			files[item.File] = item.Secret.(*model.SecretFile)
+			continue
+		}
+		d := item.Secret.Data()
+		plan = append(plan, &importedSecret{Kind: item.Kind.Name(), Name: d.Name, Source: path, Folder: d.Folder,
+			Status: importAdd, item: importer.Item{Kind: item.Kind, Secret: item.Secret, Source: path}})
+	}
+	restored, err := a.importSecrets(ctx, plan)
+	if err == nil {
+		restored, err = a.restoreFiles(ctx, r, files, restored)
+	}
+	summary := &archiveSummary{Path: path, Manifest: r.Manifest, Restored: restored}
+	if printErr := printArchiveSummary(summary, opts.output); err == nil {
+		err = printErr
+	}
+	if opts.output == outputText {
+		fmt.Printf("Restored %d of %d secrets\n", restored, len(r.Items))
+	}
+	return err
+}
+
+// restoreFiles uploads content of files following secrets in the archive, restored counts added secrets
+func (a *agent) restoreFiles(ctx context.Context, r *archive.Reader, files map[string]*model.SecretFile, restored int) (int, error) {
+	if len(files) == 0 {
+		return restored, nil
+	}
+	defer func() {
+		if err := a.updateCache(ctx); err != nil {
+			a.logger.Errorf("Unable to update cache: %s", err.Error())
+		}
+	}()
+	for {
+		name, content, err := r.NextFile()
+		if errors.Is(err, io.EOF) {
+			return restored, nil
+		}
+		if err != nil {
+			return restored, err
+		}
+		m, ok := files[name]
+		if !ok {
+			return restored, fmt.Errorf("%w: %s", errUnknownArchiveFile, name)
+		}
+		if err := a.uploadFileContent(ctx, m, content); err != nil {
+			return restored, fmt.Errorf("%s %q: %w", model.SecretFileKind.Name(), m.Name, err)
+		}
+		restored++
+	}
+}
+
+// accountEmpty reports whether the cache has no secrets of any kind
+func (a *agent) accountEmpty() (bool, error) {
+	cache, err := a.cache.Cache().Get()
+	if err != nil {
+		return false, err
+	}
+	for _, kind := range model.Kinds() {
+		if len(cache.Get(kind)) > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// runArchive executes export or restore --archive of the command line mode and returns the exit code
+func (a *agent) runArchive(command string, args []string) int {
+	opts, path, err := parseArchiveOptions(command, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		return ExitUsage
+	}
+	ctx := context.Background()
+	if err := a.connect(ctx); err != nil {
+		a.logger.Errorf("Unable to connect: %s", err.Error())
+		return ExitError
+	}
+	if command == "export" {
+		err = a.cliExport(ctx, path, opts)
+	} else {
+		err = a.cliRestoreArchive(ctx, path, opts)
+	}
+	a.close()
+	if err != nil {
+		a.logger.Errorf("%s failed: %s", command, err.Error())
+		return ExitError
+	}
+	return ExitOK
+}
+
+// archiveCommand runs export or restore --archive of the interactive session
+func (a *agent) archiveCommand(ctx context.Context, command string, args []string) {
+	opts, path, err := parseArchiveOptions(command, args)
+	if err != nil {
+		return
+	}
+	if command == "export" {
+		err = a.cliExport(ctx, path, opts)
+	} else {
+		err = a.cliRestoreArchive(ctx, path, opts)
+	}
+	if err != nil {
+		a.logger.Errorf("%s failed: %s", command, err.Error())
+	}
+}
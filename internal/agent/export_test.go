@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"cenarius/internal/archive"
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setStdin makes the content stdin of the test
+func setStdin(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func Test_agent_cliExport(t *testing.T) {
+	ctx := context.Background()
+	key := make([]byte, 32)
+	var sent []*model.BatchOp
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/private/batch":
+			_ = json.NewDecoder(r.Body).Decode(&sent)
+			results := &model.BatchResults{Applied: true, Results: make([]model.BatchResult, len(sent))}
+			for i := range sent {
+				results.Results[i] = model.BatchResult{Status: http.StatusCreated, ID: i + 1, Version: 1}
+			}
+			_ = json.NewEncoder(w).Encode(results)
+		case "/api/v1/private/sync":
+			_ = json.NewEncoder(w).Encode(model.NewSyncChanges(1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+	a.key = key
+	login := &model.LoginWithPassword{SecretData: model.SecretData{ID: 3, UserID: 1, Name: "GitHub"}, Login: "octocat", Password: "hunter2"}
+	text := &model.SecretText{SecretData: model.SecretData{ID: 4, UserID: 1, Name: "Wifi", Labels: model.Labels{Folder: "Home"}}, Text: "guest"}
+	for _, m := range []model.Secret{login, text} {
+		if err := m.Encrypt(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &model.SecretCache{Revision: 1}
+	c.Set(model.LoginWithPasswordKind, []model.Secret{login})
+	c.Set(model.SecretTextKind, []model.Secret{text})
+	if err := a.cache.Cache().Save(c); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "vault.cnra")
+	opts, file, err := parseArchiveOptions("export", []string{path, "--stdin"})
+	if !assert.NoError(t, err) || !assert.Equal(t, path, file) {
+		return
+	}
+	setStdin(t, "short\n")
+	assert.ErrorIs(t, a.cliExport(ctx, path, opts), errShortPassphrase)
+	setStdin(t, "correct horse\n")
+	if !assert.NoError(t, a.cliExport(ctx, path, opts)) {
+		return
+	}
+	setStdin(t, "correct horse\n")
+	assert.ErrorIs(t, a.cliExport(ctx, path, opts), errArchiveExists)
+
+	// The account has secrets, the archive is added to them with --force only
+	opts, file, err = parseArchiveOptions("restore", []string{"--archive", path, "--stdin"})
+	if !assert.NoError(t, err) || !assert.Equal(t, path, file) {
+		return
+	}
+	setStdin(t, "wrong horse\n")
+	assert.ErrorIs(t, a.cliRestoreArchive(ctx, path, opts), archive.ErrWrongPassphrase)
+	setStdin(t, "correct horse\n")
+	assert.ErrorIs(t, a.cliRestoreArchive(ctx, path, opts), errAccountNotEmpty)
+	assert.Nil(t, sent)
+
+	// An empty account on another server
+	b := testOnlineAgent(t, srv)
+	b.key = make([]byte, 32)
+	b.key[0] = 1
+	if err := b.cache.Cache().Save(&model.SecretCache{Revision: 1}); err != nil {
+		t.Fatal(err)
+	}
+	setStdin(t, "correct horse\n")
+	assert.NoError(t, b.cliRestoreArchive(ctx, path, opts))
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, model.LoginWithPasswordKind.Name(), sent[0].Kind)
+		restored := sent[0].Secret
+		assert.Zero(t, restored.Data().ID)
+		if assert.NoError(t, restored.Decrypt(b.key)) {
+			assert.Equal(t, "hunter2", restored.(*model.LoginWithPassword).Password)
+		}
+		assert.Equal(t, "Home", sent[1].Secret.Data().Folder)
+	}
+
+	b.onlineMode = false
+	assert.ErrorIs(t, b.cliRestoreArchive(ctx, path, opts), errRestoreOffline)
+}
+
+func Test_isArchiveRestore(t *testing.T) {
+	assert.True(t, isArchiveRestore([]string{"restore", "--archive", "vault.cnra"}))
+	assert.True(t, isArchiveRestore([]string{"restore", "-archive=vault.cnra"}))
+	assert.False(t, isArchiveRestore([]string{"restore", "login", "--version", "2"}))
+	assert.False(t, isArchiveRestore([]string{"export", "--archive"}))
+	_, _, err := parseArchiveOptions("restore", []string{"--stdin"})
+	assert.ErrorIs(t, err, errUsage)
+}
@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/userinput"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionCurrent marks the current version of the secret in the printed history
+const versionCurrent = "current"
+
+var (
+	errHistoryOffline  = errors.New("history is kept on the server and is unavailable offline")
+	errVersionNotFound = errors.New("version not found in the history")
+	errRestoreFile     = errors.New("content of a purged file is not kept, the file can't be restored")
+)
+
+// secretHistory returns prior versions of the secret kept by the server, newest first
+func (a *agent) secretHistory(ctx context.Context, kind model.Kind, id int) ([]model.SecretVersion, error) {
+	if !a.onlineMode {
+		return nil, errHistoryOffline
+	}
+	versions := make([]model.SecretVersion, 0)
+	if err := a.getSecretsWrapper(ctx, historyURI(kind, id), &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// getAllHistory returns sealed prior versions of every secret kept by the server
+func (a *agent) getAllHistory(ctx context.Context) (*model.SecretCache, error) {
+	if !a.onlineMode {
+		return nil, errHistoryOffline
+	}
+	history := &model.SecretCache{}
+	if err := a.getSecretsWrapper(ctx, versionsURI, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// secretVersion returns the decrypted prior version of the secret
+func (a *agent) secretVersion(ctx context.Context, kind model.Kind, id, version int) (model.Secret, error) {
+	if !a.onlineMode {
+		return nil, errHistoryOffline
+	}
+	data, s, err := a.sendRequest2(ctx, fmt.Sprintf("%s/%d", historyURI(kind, id), version), http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s == http.StatusNotFound {
+		return nil, errVersionNotFound
+	}
+	if s != http.StatusOK {
+		return nil, fmt.Errorf("%w %d: %s", errBadHTTPStatusCode, s, strings.TrimSpace(string(data)))
+	}
+	m := kind.New()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if err := m.Decrypt(a.key); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// restoreSecret saves the prior version as the next version of the secret, so the current one stays
+// in the history. A secret in the trash must be undeleted first. A purged secret is added again
+// with a new id, purged files can't be restored as their content is removed with them.
+func (a *agent) restoreSecret(ctx context.Context, kind model.Kind, id, version int, resolve conflictResolver) (model.Secret, error) {
+	m, err := a.secretVersion(ctx, kind, id, version)
+	if err != nil {
+		return nil, err
+	}
+	current, err := a.findSecret(kind, id)
+	if errors.Is(err, errSecretNotFound) {
+		trashed, err := a.inTrash(ctx, kind, id)
+		if err != nil {
+			return nil, err
+		}
+		if trashed {
+			return nil, errInTrash
+		}
+		if kind.Blob() {
+			return nil, errRestoreFile
+		}
+		m.Data().ID, m.Data().Version = 0, 0
+		if err := a.addSecret(ctx, kind, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Labels are not versioned, the restored version keeps the current ones
+	m.Data().Version, m.Data().Labels = current.Data().Version, current.Data().Labels
+	return a.updateResolved(ctx, kind, m, resolve)
+}
+
+// versionsOf returns the history of the secret preceded by its cached current version,
+// the current version is missing if the secret is deleted
+func (a *agent) versionsOf(ctx context.Context, kind model.Kind, id int) ([]model.SecretVersion, error) {
+	history, err := a.secretHistory(ctx, kind, id)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]model.SecretVersion, 0, len(history)+1)
+	cache, err := a.cache.Cache().Get()
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := cache.Find(kind, id); ok {
+		d := m.Data()
+		versions = append(versions, model.SecretVersion{
+			Version: d.Version, Name: d.Name, ClientID: d.ClientID, UpdatedAt: d.UpdatedAt, Op: versionCurrent,
+		})
+	}
+	return append(versions, history...), nil
+}
+
+func printVersions(versions []model.SecretVersion) {
+	if len(versions) == 0 {
+		fmt.Println("No versions")
+		return
+	}
+	for _, v := range versions {
+		client := v.ClientID
+		if client == "" {
+			client = "-"
+		}
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\n", v.Version, v.UpdatedAt.Local().Format("2006-01-02 15:04:05"), client, v.Op, v.Name)
+	}
+}
+
+// history prints versions of the secret entered by id
+func (a *agent) history(ctx context.Context, kind model.Kind) {
+	a.list(ctx, kind)
+	id := userinput.InputID()
+	versions, err := a.versionsOf(ctx, kind, id)
+	if err != nil {
+		a.logger.Errorf("Unable to get history of %s %d: %s", kind.Name(), id, err.Error())
+		return
+	}
+	printVersions(versions)
+}
+
+// restore prints versions of the secret entered by id and restores the entered version
+func (a *agent) restore(ctx context.Context, kind model.Kind) {
+	a.list(ctx, kind)
+	id := userinput.InputID()
+	versions, err := a.versionsOf(ctx, kind, id)
+	if err != nil {
+		a.logger.Errorf("Unable to get history of %s %d: %s", kind.Name(), id, err.Error())
+		return
+	}
+	printVersions(versions)
+	version, err := strconv.Atoi(userinput.Input("Version to restore"))
+	if err != nil {
+		a.logger.Errorf("Wrong version: %s", err.Error())
+		return
+	}
+	m, err := a.restoreSecret(ctx, kind, id, version, promptConflict)
+	if err != nil {
+		a.logger.Errorf("Unable to restore %s %d: %s", kind.Name(), id, err.Error())
+		return
+	}
+	fmt.Printf("Restored %s %d from version %d\n", kind.Name(), m.Data().ID, version)
+}
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/server"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_agent_restoreSecret(t *testing.T) {
+	ctx := context.Background()
+	key := make([]byte, 32)
+	old := &model.SecretText{SecretData: model.SecretData{ID: 5, Name: "note", Version: 1, ClientID: "laptop"}, Text: "old text"}
+	if err := old.Encrypt(key); err != nil {
+		t.Fatal(err)
+	}
+	var requests, clients []string
+	var saved *model.SecretText
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("If-Match"))
+		clients = append(clients, r.Header.Get(server.ClientHeader))
+		switch r.URL.Path {
+		case "/api/v1/private/secrettext/5/history":
+			_ = json.NewEncoder(w).Encode([]model.SecretVersion{{Version: 1, Name: "note", ClientID: "laptop", Op: model.EventUpdate}})
+		case "/api/v1/private/secrettext/5/history/1":
+			_ = json.NewEncoder(w).Encode(old)
+		case "/api/v1/private/trash":
+			_ = json.NewEncoder(w).Encode(&model.SecretCache{})
+		case "/api/v1/private/secrettext":
+			saved = &model.SecretText{}
+			_ = json.NewDecoder(r.Body).Decode(saved)
+			if saved.ID == 0 {
+				saved.ID = 9
+			}
+			saved.Version++
+			_ = json.NewEncoder(w).Encode(saved)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+	a.key = key
+	a.config.ClientID = "desktop"
+	current := &model.SecretText{SecretData: model.SecretData{ID: 5, Name: "note", Version: 2}, Text: "new text"}
+	if err := current.Encrypt(key); err != nil {
+		t.Fatal(err)
+	}
+	c := &model.SecretCache{}
+	c.Set(model.SecretTextKind, []model.Secret{current})
+	if err := a.cache.Cache().Save(c); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := a.versionsOf(ctx, model.SecretTextKind, 5)
+	if assert.NoError(t, err) && assert.Len(t, versions, 2) {
+		assert.Equal(t, versionCurrent, versions[0].Op)
+		assert.Equal(t, 2, versions[0].Version)
+		assert.Equal(t, "laptop", versions[1].ClientID)
+	}
+
+	m, err := a.restoreSecret(ctx, model.SecretTextKind, 5, 1, failConflict)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 5, m.Data().ID)
+		assert.Equal(t, 3, m.Data().Version)
+	}
+	if assert.NotNil(t, saved) && assert.NoError(t, saved.Decrypt(key)) {
+		assert.Equal(t, "old text", saved.Text)
+	}
+
+	// A purged secret is added again
+	c.Set(model.SecretTextKind, nil)
+	if err := a.cache.Cache().Save(c); err != nil {
+		t.Fatal(err)
+	}
+	m, err = a.restoreSecret(ctx, model.SecretTextKind, 5, 1, failConflict)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 9, m.Data().ID)
+	}
+	_, err = a.restoreSecret(ctx, model.SecretTextKind, 5, 7, failConflict)
+	assert.ErrorIs(t, err, errVersionNotFound)
+
+	assert.Equal(t, []string{
+		"GET /api/v1/private/secrettext/5/history ",
+		"GET /api/v1/private/secrettext/5/history/1 ",
+		`PUT /api/v1/private/secrettext "2"`,
+		"GET /api/v1/private/secrettext/5/history/1 ",
+		"GET /api/v1/private/trash ",
+		"POST /api/v1/private/secrettext ",
+		"GET /api/v1/private/secrettext/5/history/7 ",
+	}, requests)
+	for _, client := range clients {
+		assert.Equal(t, "desktop", client)
+	}
+
+	a.onlineMode = false
+	_, err = a.secretHistory(ctx, model.SecretTextKind, 5)
+	assert.ErrorIs(t, err, errHistoryOffline)
+}
@@ -0,0 +1,306 @@
+package agent
+
+import (
+	"bytes"
+	"cenarius/internal/importer"
+	"cenarius/internal/model"
+	"cenarius/internal/userinput"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importBatchSize is the largest batch the server applies, larger imports are sent in several batches
+const importBatchSize = 1000
+
+// Statuses of imported secrets
+const (
+	importAdd     = "add"
+	importExists  = "exists"
+	importInvalid = "invalid"
+)
+
+var errImportOffline = errors.New("secrets are imported to the server, it is unavailable offline")
+
+// importedSecret is a secret of the export and what the import does with it
+type importedSecret struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Folder string `json:"folder,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	item   importer.Item
+}
+
+// readExport reads plain secrets of the export, the format is detected unless it is given.
+// KeePass databases are opened with the password read from stdin with --stdin or prompted for.
+func readExport(path string, opts *cliOptions) (string, []importer.Item, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	format := opts.format
+	if format == "" {
+		if format, err = importer.Detect(path, data); err != nil {
+			return "", nil, err
+		}
+	}
+	password := ""
+	if importer.NeedsPassword(format) {
+		if opts.stdin {
+			password, err = readStdin()
+		} else {
+			password = userinput.InputPassword("password of " + filepath.Base(path))
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	items, err := importer.Read(format, data, password)
+	return format, items, err
+}
+
+// planImport tells which secrets are added. Secrets failing validation are skipped, so are secrets
+// of the same kind and name as a cached one, unless duplicates are allowed.
+func (a *agent) planImport(items []importer.Item, duplicates bool) ([]*importedSecret, error) {
+	cache, err := a.cache.Cache().Get()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for _, kind := range model.Kinds() {
+		for _, m := range cache.Get(kind) {
+			existing[kind.Name()+"/"+m.Data().Name] = true
+		}
+	}
+	plan := make([]*importedSecret, 0, len(items))
+	for _, item := range items {
+		d := item.Secret.Data()
+		s := &importedSecret{Kind: item.Kind.Name(), Name: d.Name, Source: item.Source, Folder: d.Folder, Status: importAdd, item: item}
+		switch {
+		case !duplicates && existing[s.Kind+"/"+s.Name]:
+			s.Status = importExists
+		case item.Kind.Blob():
+			// Files are validated once they are uploaded
+		default:
+			if err := item.Secret.Validate(); err != nil {
+				s.Status, s.Error = importInvalid, err.Error()
+			}
+		}
+		plan = append(plan, s)
+	}
+	return plan, nil
+}
+
+// printImportPlan prints numbers of secrets of every kind the import adds or skips and skipped secrets
+func printImportPlan(format, path string, plan []*importedSecret, output string) error {
+	if output == outputJSON {
+		return printJSON(plan)
+	}
+	fmt.Printf("%s export %s:\n", format, path)
+	for _, kind := range model.Kinds() {
+		counts := make(map[string]int)
+		for _, s := range plan {
+			if s.Kind == kind.Name() {
+				counts[s.Status]++
+			}
+		}
+		if len(counts) > 0 {
+			fmt.Printf("  %s\t%d to add, %d exist, %d invalid\n", kind.Plural(), counts[importAdd], counts[importExists], counts[importInvalid])
+		}
+	}
+	for _, s := range plan {
+		switch s.Status {
+		case importExists:
+			fmt.Printf("Skipped %s %q of %q: a secret of the name exists\n", s.Kind, s.Name, s.Source)
+		case importInvalid:
+			fmt.Printf("Skipped %s %q of %q: %s\n", s.Kind, s.Name, s.Source, s.Error)
+		}
+	}
+	return nil
+}
+
+// importSecrets adds planned secrets to the server: secrets in batches of importBatchSize, each batch is
+// applied all or none, and files one by one. The number of added secrets is returned.
+func (a *agent) importSecrets(ctx context.Context, plan []*importedSecret) (int, error) {
+	if !a.onlineMode {
+		return 0, errImportOffline
+	}
+	added := 0
+	ops := make([]*model.BatchOp, 0, importBatchSize)
+	send := func() error {
+		if len(ops) == 0 {
+			return nil
+		}
+		results, err := a.applyBatch(ctx, ops)
+		if err != nil {
+			return err
+		}
+		if err := batchError(results); err != nil {
+			_ = printBatch(ops, results, outputText)
+			return err
+		}
+		added += len(ops)
+		ops = ops[:0]
+		return nil
+	}
+	files := make([]*importedSecret, 0)
+	for _, s := range plan {
+		if s.Status != importAdd {
+			continue
+		}
+		if s.item.Kind.Blob() {
+			files = append(files, s)
+			continue
+		}
+		if err := a.seal(s.item.Secret); err != nil {
+			return added, fmt.Errorf("%s %q: %w", s.Kind, s.Name, err)
+		}
+		ops = append(ops, &model.BatchOp{Op: model.BatchCreate, Kind: s.Kind, Secret: s.item.Secret})
+		if len(ops) == importBatchSize {
+			if err := send(); err != nil {
+				return added, err
+			}
+		}
+	}
+	if err := send(); err != nil {
+		return added, err
+	}
+	for _, s := range files {
+		if err := a.uploadFileContent(ctx, s.item.Secret.(*model.SecretFile), bytes.NewReader(s.item.Content)); err != nil {
+			return added, fmt.Errorf("%s %q: %w", s.Kind, s.Name, err)
+		}
+		added++
+	}
+	if len(files) > 0 {
+		if err := a.updateCache(ctx); err != nil {
+			a.logger.Errorf("Unable to update cache: %s", err.Error())
+		}
+	}
+	return added, nil
+}
+
+// uploadFileContent uploads the content as the file from a temporary copy next to the cache
+func (a *agent) uploadFileContent(ctx context.Context, m *model.SecretFile, content io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(a.config.CacheFile), "cenarius-import-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	m.Path = tmp.Name()
+	return a.uploadSecretFile(ctx, m)
+}
+
+// parseImportOptions returns options of the import command and the path of the export
+func parseImportOptions(args []string) (*cliOptions, string, error) {
+	opts := &cliOptions{set: make(map[string]bool), fields: make(map[string]*string)}
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.StringVar(&opts.format, "format", "", "Format of the export: "+strings.Join(importer.Formats(), ", ")+", detected if not given")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Print what would be imported without importing anything")
+	fs.BoolVar(&opts.duplicates, "duplicates", false, "Import secrets named like existing secrets of the kind too")
+	fs.BoolVar(&opts.stdin, "stdin", false, "Read the password of a KeePass database from stdin")
+	fs.StringVar(&opts.output, "o", outputText, "Output format: text or json")
+	paths := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, "", err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(paths) != 1 {
+		fmt.Fprintln(os.Stderr, "A single export file is required")
+		return nil, "", errUsage
+	}
+	if opts.format != "" && !contains(importer.Formats(), opts.format) {
+		fmt.Fprintf(os.Stderr, "Unknown format %s, one of: %s\n", opts.format, strings.Join(importer.Formats(), ", "))
+		return nil, "", errUsage
+	}
+	if opts.output != outputText && opts.output != outputJSON {
+		fmt.Fprintf(os.Stderr, "Unknown output format %s\n", opts.output)
+		return nil, "", errUsage
+	}
+	return opts, paths[0], nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// cliImport prints what the import of the export does and adds its secrets unless --dry-run is set
+func (a *agent) cliImport(ctx context.Context, path string, opts *cliOptions) error {
+	format, items, err := readExport(path, opts)
+	if err != nil {
+		return err
+	}
+	plan, err := a.planImport(items, opts.duplicates)
+	if err != nil {
+		return err
+	}
+	if err := printImportPlan(format, path, plan, opts.output); err != nil {
+		return err
+	}
+	if opts.dryRun {
+		return nil
+	}
+	added, err := a.importSecrets(ctx, plan)
+	if opts.output == outputText {
+		fmt.Printf("Imported %d secrets\n", added)
+	}
+	return err
+}
+
+// runImport executes the import command of the command line mode and returns the exit code
+func (a *agent) runImport(args []string) int {
+	opts, path, err := parseImportOptions(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		return ExitUsage
+	}
+	ctx := context.Background()
+	if err := a.connect(ctx); err != nil {
+		a.logger.Errorf("Unable to connect: %s", err.Error())
+		return ExitError
+	}
+	err = a.cliImport(ctx, path, opts)
+	a.close()
+	if err != nil {
+		a.logger.Errorf("import failed: %s", err.Error())
+		return ExitError
+	}
+	return ExitOK
+}
+
+// importExport runs the import command of the interactive session
+func (a *agent) importExport(ctx context.Context, args []string) {
+	opts, path, err := parseImportOptions(args)
+	if err != nil {
+		return
+	}
+	if err := a.cliImport(ctx, path, opts); err != nil {
+		a.logger.Errorf("import failed: %s", err.Error())
+	}
+}
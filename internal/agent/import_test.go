@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_agent_cliImport(t *testing.T) {
+	ctx := context.Background()
+	key := make([]byte, 32)
+	var sent []*model.BatchOp
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/private/batch":
+			_ = json.NewDecoder(r.Body).Decode(&sent)
+			results := &model.BatchResults{Applied: true, Results: make([]model.BatchResult, len(sent))}
+			for i := range sent {
+				results.Results[i] = model.BatchResult{Status: http.StatusCreated, ID: i + 1, Version: 1}
+			}
+			_ = json.NewEncoder(w).Encode(results)
+		case "/api/v1/private/sync":
+			_ = json.NewEncoder(w).Encode(model.NewSyncChanges(1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+	a.key = key
+	c := &model.SecretCache{Revision: 1}
+	c.Set(model.SecretTextKind, []model.Secret{&model.SecretText{SecretData: model.SecretData{ID: 3, Name: "Wifi"}}})
+	if err := a.cache.Cache().Save(c); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bitwarden.json")
+	export := `{"encrypted": false, "items": [
+		{"type": 1, "name": "GitHub", "login": {"username": "octocat", "password": "hunter2"}},
+		{"type": 1, "name": "Bank", "login": {"username": "", "password": "secret"}},
+		{"type": 2, "name": "Wifi", "notes": "guest network"}
+	]}`
+	if err := os.WriteFile(path, []byte(export), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run sends nothing
+	opts, _, err := parseImportOptions([]string{path, "--dry-run"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, a.cliImport(ctx, path, opts))
+	assert.Nil(t, sent)
+
+	opts.dryRun = false
+	assert.NoError(t, a.cliImport(ctx, path, opts))
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, model.BatchCreate, sent[0].Op)
+		login := sent[0].Secret
+		assert.Equal(t, "GitHub", login.Data().Name)
+		if assert.NoError(t, login.Decrypt(key)) {
+			assert.Equal(t, "hunter2", login.(*model.LoginWithPassword).Password)
+		}
+	}
+
+	// Duplicates are imported on request
+	opts.duplicates = true
+	assert.NoError(t, a.cliImport(ctx, path, opts))
+	assert.Len(t, sent, 2)
+
+	a.onlineMode = false
+	assert.ErrorIs(t, a.cliImport(ctx, path, opts), errImportOffline)
+}
+
+func Test_parseImportOptions(t *testing.T) {
+	opts, path, err := parseImportOptions([]string{"vault.kdbx", "--format", "keepass", "--stdin", "--dry-run"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "vault.kdbx", path)
+		assert.Equal(t, "keepass", opts.format)
+		assert.True(t, opts.stdin)
+		assert.True(t, opts.dryRun)
+	}
+	_, _, err = parseImportOptions([]string{"vault.kdbx", "--format", "lastpass"})
+	assert.ErrorIs(t, err, errUsage)
+	_, _, err = parseImportOptions(nil)
+	assert.ErrorIs(t, err, errUsage)
+}
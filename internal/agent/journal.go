@@ -0,0 +1,372 @@
+package agent
+
+import (
+	"cenarius/internal/encrypt"
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Operations of the journal
+const (
+	opAdd    = "add"
+	opUpdate = "update"
+	opDelete = "delete"
+)
+
+// Statuses of journal operations, done operations are dropped after they are reported
+const (
+	opPending = "pending"
+	opDone    = "done"
+	opFailed  = "failed"
+)
+
+var (
+	errNoOfflineData = errors.New("server is unavailable and there is no offline data, log in online once")
+	errOfflineFile   = errors.New("files can't be added offline")
+)
+
+// journalFile is the journal as it is saved next to the cache. The KDF salt is kept in plain,
+// so the vault key is derived without the server, operations are sealed with the vault key.
+type journalFile struct {
+	KDFSalt string `json:"kdf_salt"`
+	Ops     string `json:"ops"`
+}
+
+// journalOp is a change made offline. Secret is sealed like it is sent to the server,
+// secrets added offline get temporary negative id -Seq.
+type journalOp struct {
+	Seq    int             `json:"seq"`
+	Op     string          `json:"op"`
+	Kind   string          `json:"kind"`
+	Name   string          `json:"name"`
+	Secret json.RawMessage `json:"secret"`
+	Status string          `json:"status"`
+	Error  string          `json:"error,omitempty"`
+	Time   time.Time       `json:"time"`
+}
+
+func (op *journalOp) String() string {
+	s := fmt.Sprintf("#%d %s %s %s %s: %s", op.Seq, op.Time.Format("2006-01-02 15:04:05"), op.Op, op.Kind, op.Name, op.Status)
+	if op.Error != "" {
+		s += " (" + op.Error + ")"
+	}
+	return s
+}
+
+// isOffline reports whether the request failed because the server can't be reached
+func isOffline(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func (a *agent) journalFile() string {
+	return a.config.CacheFile + ".journal"
+}
+
+// readJournal reads the journal file, the salt is empty if there is none
+func (a *agent) readJournal() (*journalFile, error) {
+	j := &journalFile{}
+	data, err := os.ReadFile(a.journalFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// openJournal reads operations sealed with the current vault key
+func (a *agent) openJournal(j *journalFile) error {
+	a.journal = make([]*journalOp, 0)
+	if j.Ops == "" {
+		return nil
+	}
+	data, err := encrypt.Open(j.Ops, a.key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), &a.journal)
+}
+
+func (a *agent) saveJournal() error {
+	data, err := json.Marshal(a.journal)
+	if err != nil {
+		return err
+	}
+	ops, err := encrypt.Seal(string(data), a.key)
+	if err != nil {
+		return err
+	}
+	data, err = json.Marshal(&journalFile{KDFSalt: a.kdfSalt, Ops: ops})
+	if err != nil {
+		return err
+	}
+	tmp := a.journalFile() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.journalFile())
+}
+
+// loadJournal opens the journal after logging in, the salt of the session is saved for offline use.
+// Operations sealed with a key of the previous password can't be replayed and are dropped,
+// the cache sealed with that key is fully synced again.
+func (a *agent) loadJournal() error {
+	j, err := a.readJournal()
+	if err != nil {
+		return err
+	}
+	a.kdfSalt = a.session.KDFSalt
+	if j.KDFSalt != "" && j.KDFSalt != a.kdfSalt {
+		a.logger.Errorf("Offline changes were made before the password was changed and are dropped")
+		j.Ops = ""
+		if err := a.resetCache(); err != nil {
+			return err
+		}
+	}
+	if err := a.openJournal(j); err != nil {
+		return err
+	}
+	return a.saveJournal()
+}
+
+// openOffline derives the vault key with the salt of the last login and applies pending
+// operations to the cache. Wrong password fails to open the journal.
+func (a *agent) openOffline() error {
+	j, err := a.readJournal()
+	if err != nil {
+		return err
+	}
+	if j.KDFSalt == "" {
+		return errNoOfflineData
+	}
+	a.kdfSalt = j.KDFSalt
+	a.key = encrypt.DeriveKey(a.config.Password, a.kdfSalt)
+	if err := a.openJournal(j); err != nil {
+		a.key = nil
+		return errIncorrectPassword
+	}
+	a.onlineMode = false
+	c, err := a.cache.Cache().Get()
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		c = &model.SecretCache{}
+	}
+	for _, op := range a.journal {
+		if op.Status != opPending {
+			continue
+		}
+		kind, m, err := op.secret()
+		if err != nil {
+			return err
+		}
+		applyOp(c, kind, op.Op, m)
+	}
+	return a.cache.Cache().Save(c)
+}
+
+func (op *journalOp) secret() (model.Kind, model.Secret, error) {
+	kind, ok := model.KindByName(op.Kind)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown kind %s", op.Kind)
+	}
+	m := kind.New()
+	if err := json.Unmarshal(op.Secret, m); err != nil {
+		return nil, nil, err
+	}
+	return kind, m, nil
+}
+
+// applyOp applies the change to secrets of the cache, applying it again changes nothing.
+// The cache no longer matches its revision, so the next sync replaces it.
+func applyOp(c *model.SecretCache, kind model.Kind, op string, m model.Secret) {
+	c.Revision = 0
+	secrets := c.Get(kind)
+	changed := make([]model.Secret, 0, len(secrets)+1)
+	found := false
+	for _, s := range secrets {
+		if s.Data().ID != m.Data().ID {
+			changed = append(changed, s)
+			continue
+		}
+		found = true
+		if op != opDelete {
+			changed = append(changed, m)
+		}
+	}
+	if !found && op != opDelete {
+		changed = append(changed, m)
+	}
+	c.Set(kind, changed)
+}
+
+// saveChange sends the sealed secret to the server, the change is journaled if the server can't be reached
+func (a *agent) saveChange(ctx context.Context, op string, kind model.Kind, m model.Secret) error {
+	if a.onlineMode {
+		err := a.sendChange(ctx, op, kind, m)
+		if !isOffline(err) {
+			return err
+		}
+		a.logger.Warnf("Server is unavailable, working offline: %s", err.Error())
+		a.onlineMode = false
+	}
+	return a.journalChange(op, kind, m)
+}
+
+func (a *agent) sendChange(ctx context.Context, op string, kind model.Kind, m model.Secret) error {
+	switch op {
+	case opAdd:
+		m.Data().ID = 0
+		return a.saveSecret(ctx, kind, http.MethodPost, m)
+	case opUpdate:
+		return a.saveSecret(ctx, kind, http.MethodPut, m)
+	}
+	return a.deleteRequest(ctx, kind, m.Data().ID)
+}
+
+// journalChange records the change and applies it to the cache. Changes of secrets added offline
+// are folded into their add operation.
+func (a *agent) journalChange(op string, kind model.Kind, m model.Secret) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if id := m.Data().ID; id < 0 {
+		for i, o := range a.journal {
+			if o.Op != opAdd || o.Kind != kind.Name() || -o.Seq != id || o.Status != opPending {
+				continue
+			}
+			if op == opDelete {
+				a.journal = append(a.journal[:i], a.journal[i+1:]...)
+			} else {
+				o.Name, o.Secret, o.Time = m.Data().Name, data, time.Now()
+			}
+			break
+		}
+	} else {
+		seq := 1
+		for _, o := range a.journal {
+			seq = maxInt(seq, o.Seq+1)
+		}
+		if op == opAdd {
+			m.Data().ID = -seq
+			if data, err = json.Marshal(m); err != nil {
+				return err
+			}
+		}
+		a.journal = append(a.journal, &journalOp{
+			Seq: seq, Op: op, Kind: kind.Name(), Name: m.Data().Name, Secret: data, Status: opPending, Time: time.Now(),
+		})
+	}
+	if err := a.saveJournal(); err != nil {
+		return err
+	}
+	c, err := a.cache.Cache().Get()
+	if err != nil {
+		return err
+	}
+	applyOp(c, kind, op, m)
+	return a.cache.Cache().Save(c)
+}
+
+// replayJournal sends pending changes in order and reports status of each. Replay stops
+// when the server can't be reached, the rest stays pending. Updates of secrets changed on the server
+// meanwhile are resolved by resolve.
+func (a *agent) replayJournal(ctx context.Context, resolve conflictResolver) {
+	left := make([]*journalOp, 0, len(a.journal))
+	for i, op := range a.journal {
+		if op.Status != opPending || !a.onlineMode {
+			left = append(left, op)
+			continue
+		}
+		kind, m, err := op.secret()
+		if err == nil && op.Op == opUpdate {
+			_, err = a.resolveConflicts(kind, m, resolve, func(m model.Secret) error {
+				return a.saveSecret(ctx, kind, http.MethodPut, m)
+			})
+		} else if err == nil {
+			err = a.sendChange(ctx, op.Op, kind, m)
+		}
+		if isOffline(err) {
+			a.onlineMode = false
+			left = append(left, a.journal[i:]...)
+			break
+		}
+		op.Status, op.Error = opDone, ""
+		if err != nil {
+			op.Status, op.Error = opFailed, err.Error()
+			left = append(left, op)
+		}
+		fmt.Printf("Offline change %s\n", op)
+	}
+	a.journal = left
+	if err := a.saveJournal(); err != nil {
+		a.logger.Errorf("Unable to save journal: %s", err.Error())
+	}
+}
+
+// reconnect logs in again, replays the journal and refreshes the cache
+func (a *agent) reconnect(ctx context.Context) bool {
+	s, err := a.login(ctx)
+	if err != nil || s != http.StatusOK {
+		return false
+	}
+	a.onlineMode = true
+	a.logger.Info("Server is available again")
+	a.replayJournal(ctx, failConflict)
+	if err := a.updateCache(ctx); err != nil {
+		a.logger.Errorf("Unable to update cache: %s", err.Error())
+	}
+	return a.onlineMode
+}
+
+// printJournal prints changes which are not sent yet
+func (a *agent) printJournal() {
+	if len(a.journal) == 0 {
+		fmt.Println("No offline changes")
+		return
+	}
+	for _, op := range a.journal {
+		fmt.Println(op)
+	}
+}
+
+// retryJournal makes failed changes pending again and sends them, conflicts are resolved by the user.
+// Discard drops failed changes instead.
+func (a *agent) retryJournal(ctx context.Context, discard bool) {
+	left := make([]*journalOp, 0, len(a.journal))
+	for _, op := range a.journal {
+		if op.Status == opFailed {
+			if discard {
+				continue
+			}
+			op.Status, op.Error = opPending, ""
+		}
+		left = append(left, op)
+	}
+	a.journal = left
+	if discard || !a.onlineMode {
+		if err := a.saveJournal(); err != nil {
+			a.logger.Errorf("Unable to save journal: %s", err.Error())
+		}
+		return
+	}
+	a.replayJournal(ctx, promptConflict)
+	if err := a.updateCache(ctx); err != nil {
+		a.logger.Errorf("Unable to update cache: %s", err.Error())
+	}
+}
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"cenarius/internal/cache/mcache"
+	"cenarius/internal/encrypt"
+	"cenarius/internal/model"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyOp(t *testing.T) {
+	c := &model.SecretCache{}
+	c.Set(model.SecretTextKind, []model.Secret{
+		&model.SecretText{SecretData: model.SecretData{ID: 1, Name: "a"}},
+		&model.SecretText{SecretData: model.SecretData{ID: 2, Name: "b"}},
+	})
+	added := &model.SecretText{SecretData: model.SecretData{ID: -1, Name: "c"}}
+	for i := 0; i < 2; i++ {
+		applyOp(c, model.SecretTextKind, opAdd, added)
+		applyOp(c, model.SecretTextKind, opUpdate, &model.SecretText{SecretData: model.SecretData{ID: 1, Name: "a2"}})
+		applyOp(c, model.SecretTextKind, opDelete, &model.SecretText{SecretData: model.SecretData{ID: 2}})
+	}
+	var names []string
+	for _, m := range c.Get(model.SecretTextKind) {
+		names = append(names, m.Data().Name)
+	}
+	assert.Equal(t, []string{"a2", "c"}, names)
+}
+
+func testOfflineAgent(cacheFile, password string) *agent {
+	return &agent{
+		config: &Config{CacheFile: cacheFile, Password: password},
+		logger: logrus.New(),
+		cache:  mcache.New(),
+	}
+}
+
+func Test_agent_journal(t *testing.T) {
+	ctx := context.Background()
+	cacheFile := filepath.Join(t.TempDir(), "cache")
+	a := testOfflineAgent(cacheFile, "password")
+	a.kdfSalt = "salt"
+	a.key = encrypt.DeriveKey("password", a.kdfSalt)
+	stored := &model.SecretText{SecretData: model.SecretData{ID: 5, Name: "old"}, Text: "text"}
+	if err := stored.Encrypt(a.key); err != nil {
+		t.Fatal(err)
+	}
+	c := &model.SecretCache{}
+	c.Set(model.SecretTextKind, []model.Secret{stored})
+	if err := a.cache.Cache().Save(c); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &model.LoginWithPassword{SecretData: model.SecretData{Name: "github"}, Login: "me", Password: "secret"}
+	assert.NoError(t, a.addSecret(ctx, model.LoginWithPasswordKind, m))
+	assert.Equal(t, -1, m.ID)
+	added, err := a.findSecret(model.LoginWithPasswordKind, -1)
+	if assert.NoError(t, err) {
+		added.(*model.LoginWithPassword).Password = "changed"
+		assert.NoError(t, a.updateSecret(ctx, model.LoginWithPasswordKind, added))
+	}
+	assert.NoError(t, a.deleteSecret(ctx, model.SecretTextKind, 5))
+	assert.ErrorIs(t, a.addSecret(ctx, model.SecretFileKind, &model.SecretFile{}), errOfflineFile)
+	if assert.Len(t, a.journal, 2) {
+		assert.Equal(t, opAdd, a.journal[0].Op)
+		assert.Equal(t, opDelete, a.journal[1].Op)
+	}
+
+	wrong := testOfflineAgent(cacheFile, "wrong")
+	assert.ErrorIs(t, wrong.openOffline(), errIncorrectPassword)
+
+	b := testOfflineAgent(cacheFile, "password")
+	if !assert.NoError(t, b.openOffline()) {
+		return
+	}
+	assert.Len(t, b.journal, 2)
+	reopened, err := b.findSecret(model.LoginWithPasswordKind, -1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "changed", reopened.(*model.LoginWithPassword).Password)
+	}
+
+	var requests []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodDelete {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 10}`))
+	}))
+	defer srv.Close()
+	b.client = *srv.Client()
+	b.config.Host = strings.TrimPrefix(srv.URL, "https://")
+	b.onlineMode = true
+	b.replayJournal(ctx, failConflict)
+	assert.Equal(t, []string{
+		"POST /api/v1/private/loginwithpassword",
+		"DELETE /api/v1/private/secrettext/5",
+	}, requests)
+	if assert.Len(t, b.journal, 1) {
+		assert.Equal(t, opFailed, b.journal[0].Status)
+		assert.Contains(t, b.journal[0].Error, "not found")
+	}
+
+	srv.Close()
+	b.retryJournal(ctx, false)
+	assert.False(t, b.onlineMode)
+	if assert.Len(t, b.journal, 1) {
+		assert.Equal(t, opPending, b.journal[0].Status)
+	}
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// filterLabels returns a new slice of secrets the filter selects
+func filterLabels(secrets []model.Secret, filter model.LabelFilter) []model.Secret {
+	filtered := make([]model.Secret, 0, len(secrets))
+	for _, m := range secrets {
+		if filter.Match(m) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+func favoriteMark(favorite bool) string {
+	if favorite {
+		return "*"
+	}
+	return ""
+}
+
+// printByFolder prints secrets grouped under their folders, secrets without a folder go first.
+// Favorites are marked with * and tags follow in brackets.
+func printByFolder(secrets []model.Secret) {
+	sorted := filterLabels(secrets, model.LabelFilter{})
+	model.SortByFolder(sorted)
+	folder := ""
+	for _, m := range sorted {
+		d := m.Data()
+		if d.Folder != folder {
+			folder = d.Folder
+			fmt.Println(folder + model.FolderSeparator)
+		}
+		line := m.String()
+		if len(d.Tags) > 0 {
+			line += " [" + strings.Join(d.Tags, ", ") + "]"
+		}
+		if d.Favorite {
+			line = "* " + line
+		}
+		if folder != "" {
+			line = "  " + line
+		}
+		fmt.Println(line)
+	}
+}
+
+// cachedLabels returns sorted folders and tags used by secrets of the kind in the cache,
+// parents of used folders are folders too
+func cachedLabels(cache *model.SecretCache, kind model.Kind) (folders, tags []string) {
+	seenFolders, seenTags := make(map[string]bool), make(map[string]bool)
+	for _, m := range cache.Get(kind) {
+		d := m.Data()
+		parts := strings.Split(d.Folder, model.FolderSeparator)
+		for i := range parts {
+			if f := strings.Join(parts[:i+1], model.FolderSeparator); f != "" && !seenFolders[f] {
+				seenFolders[f] = true
+				folders = append(folders, f)
+			}
+		}
+		for _, t := range d.Tags {
+			if !seenTags[t] {
+				seenTags[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(folders)
+	sort.Strings(tags)
+	return folders, tags
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cachedLabels(t *testing.T) {
+	c := &model.SecretCache{}
+	c.Set(model.SecretTextKind, []model.Secret{
+		&model.SecretText{SecretData: model.SecretData{ID: 1, Labels: model.Labels{Folder: "work/db", Tags: []string{"prod"}}}},
+		&model.SecretText{SecretData: model.SecretData{ID: 2, Labels: model.Labels{Folder: "home", Tags: []string{"aws", "prod"}}}},
+		&model.SecretText{SecretData: model.SecretData{ID: 3}},
+	})
+	folders, tags := cachedLabels(c, model.SecretTextKind)
+	assert.Equal(t, []string{"home", "work", "work/db"}, folders)
+	assert.Equal(t, []string{"aws", "prod"}, tags)
+
+	opts, err := parseCLIOptions("list", model.SecretTextKind, []string{"--folder", "work/", "--tags", "prod"})
+	if assert.NoError(t, err) {
+		filtered := filterLabels(c.Get(model.SecretTextKind), labelFilterOf(opts))
+		if assert.Len(t, filtered, 1) {
+			assert.Equal(t, 1, filtered[0].Data().ID)
+		}
+	}
+}
+
+func Test_setLabels(t *testing.T) {
+	m := &model.SecretText{SecretData: model.SecretData{Labels: model.Labels{Folder: "work", Tags: []string{"prod"}, Favorite: true}}}
+	opts, err := parseCLIOptions("update", model.SecretTextKind, []string{"--id", "1", "--tags", "aws, dev", "--favorite=false"})
+	if assert.NoError(t, err) {
+		setLabels(m, opts, false)
+		assert.Equal(t, "work", m.Folder)
+		assert.Equal(t, []string{"aws", "dev"}, m.Tags)
+		assert.False(t, m.Favorite)
+	}
+}
@@ -0,0 +1,656 @@
+package agent
+
+import (
+	"cenarius/internal/importer"
+	"cenarius/internal/model"
+	"cenarius/internal/userinput"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	replPrompt        = "cenarius> "
+	replOfflinePrompt = "cenarius (offline)> "
+	replLockedPrompt  = "cenarius (locked)> "
+)
+
+// reconnectInterval is how often the offline session tries to reach the server before a command
+const reconnectInterval = 30 * time.Second
+
+var errUnterminatedQuote = errors.New("unterminated quote")
+
+// replCommand is a command of the interactive session
+type replCommand struct {
+	name  string
+	alias string
+	args  string
+	help  string
+	// kind is set if the first argument is a kind of secrets
+	kind bool
+}
+
+var replCommands = []replCommand{
+	{name: "list", alias: "l", args: "<kind> [options]", help: "lists secrets grouped by folder, --folder, --tags and --favorite filter them", kind: true},
+	{name: "get", alias: "g", args: "<kind> [name] [options]", help: "prints the secret, asks for its id if no name is given", kind: true},
+	{name: "add", alias: "a", args: "<kind> [options]", help: "adds a secret, asks for everything if no options are given", kind: true},
+	{name: "update", alias: "u", args: "<kind> [name] [options]", help: "updates the secret", kind: true},
+	{name: "delete", alias: "d", args: "<kind> [name] [options]", help: "deletes the secret", kind: true},
+	{name: "undelete", args: "<kind> [name] [options]", help: "restores the secret from the trash", kind: true},
+	{name: "purge", args: "<kind> [name] [options]", help: "removes the secret from the trash for good, --all empties the trash of the kind", kind: true},
+	{name: "history", args: "<kind> [name] [options]", help: "lists versions of the secret, --version prints a prior one", kind: true},
+	{name: "restore", args: "<kind> [name] --version N", help: "saves a prior version as the next one, --archive <file> restores an export", kind: true},
+	{name: "otp", alias: "o", args: "[name] [options]", help: "prints the current one-time code"},
+	{name: "passwd", alias: "p", help: "changes the master password"},
+	{name: "ssh-agent", alias: "s", args: "[stop]", help: "serves SSH keys over the ssh-agent socket in background"},
+	{name: "search", alias: "f", args: "<text> [options]", help: "searches secrets of every kind by name, meta and tags, --limit caps the results"},
+	{name: "batch", args: "<file> [options]", help: "applies operations of the JSON file, all of them or none"},
+	{name: "import", args: "<file> [options]", help: "imports an export of another password manager, --dry-run only prints it"},
+	{name: "export", args: "<file> [options]", help: "writes every secret and file to an archive encrypted with a passphrase"},
+	{name: "trash", alias: "t", args: "[kind|empty]", help: "lists secrets in the trash, empty removes them for good"},
+	{name: "sync", help: "refreshes the cache from the server, sends offline changes when the server is back"},
+	{name: "journal", alias: "j", args: "[retry|discard]", help: "shows offline changes, failed ones are retried or discarded"},
+	{name: "lock", help: "locks the vault, the master password is asked for by the next command"},
+	{name: "help", alias: "h", help: "shows this help"},
+	{name: "exit", alias: "q", help: "leaves the session"},
+}
+
+func findREPLCommand(name string) (replCommand, bool) {
+	for _, c := range replCommands {
+		if c.name == name || c.alias != "" && c.alias == name {
+			return c, true
+		}
+	}
+	return replCommand{}, false
+}
+
+func printREPLHelp() {
+	for _, c := range replCommands {
+		name := c.name
+		if c.alias != "" {
+			name += "|" + c.alias
+		}
+		fmt.Printf("  %-38s %s\n", name+" "+c.args, c.help)
+	}
+	fmt.Println("Kinds:", kindsHelp())
+	fmt.Println("Options are the ones of the command line mode, e.g. get login github --field password")
+}
+
+// repl runs commands until exit. Kinds, names of secrets and options are completed with Tab,
+// the vault is locked after IdleLock without input.
+func (a *agent) repl(ctx context.Context) {
+	a.input = newLineReader(a.completeLine)
+	fmt.Println("Type help for the list of commands")
+	for {
+		prompt := replPrompt
+		switch {
+		case a.isLocked():
+			prompt = replLockedPrompt
+		case !a.onlineMode:
+			prompt = replOfflinePrompt
+		}
+		line, err := a.readCommand(prompt)
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			return
+		}
+		if err != nil {
+			a.logger.Errorf("Unable to read command: %s", err.Error())
+			return
+		}
+		args, err := splitArgs(line)
+		if err != nil {
+			a.logger.Error(err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if a.execREPL(ctx, args) {
+			return
+		}
+	}
+}
+
+// readCommand reads the next command of the session. The line is read on another goroutine, so the vault
+// is locked on the goroutine of the session when IdleLock passes without input and commands never
+// run concurrently with the lock.
+func (a *agent) readCommand(prompt string) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	lines := make(chan result, 1)
+	go func() {
+		line, err := a.input.readLine(prompt, a)
+		lines <- result{line, err}
+	}()
+	if a.config.IdleLock > 0 {
+		idle := time.NewTimer(a.config.IdleLock)
+		defer idle.Stop()
+		select {
+		case r := <-lines:
+			return r.line, r.err
+		case <-idle.C:
+			a.lock()
+		}
+	}
+	r := <-lines
+	return r.line, r.err
+}
+
+// execREPL runs the command of the session and reports whether the session is over
+func (a *agent) execREPL(ctx context.Context, args []string) bool {
+	c, ok := findREPLCommand(args[0])
+	if !ok {
+		a.logger.Errorf("Unknown command %s, type help for the list of commands", args[0])
+		return false
+	}
+	switch c.name {
+	case "exit":
+		return true
+	case "help":
+		printREPLHelp()
+		return false
+	case "lock":
+		a.lock()
+		return false
+	}
+	if !a.unlock(ctx) {
+		return false
+	}
+	if !a.onlineMode && c.name != "sync" && time.Since(a.lastReconnect) > reconnectInterval {
+		a.lastReconnect = time.Now()
+		a.reconnect(ctx)
+	}
+	// restore --archive restores an export, restore <kind> a prior version of a secret
+	if c.name == "restore" && isArchiveRestore(args) {
+		a.archiveCommand(ctx, "restore", args[1:])
+		return false
+	}
+	switch c.name {
+	case "passwd":
+		a.passwd(ctx)
+	case "journal":
+		switch {
+		case len(args) > 1 && args[1] == "retry":
+			a.retryJournal(ctx, false)
+		case len(args) > 1 && args[1] == "discard":
+			a.retryJournal(ctx, true)
+		}
+		a.printJournal()
+	case "ssh-agent":
+		a.backgroundSSHAgent(args[1:])
+	case "trash":
+		a.trash(ctx, args[1:])
+	case "search":
+		a.search(ctx, args[1:])
+	case "batch":
+		a.batch(ctx, args[1:])
+	case "import":
+		a.importExport(ctx, args[1:])
+	case "export":
+		a.archiveCommand(ctx, "export", args[1:])
+	case "sync":
+		if !a.onlineMode {
+			a.lastReconnect = time.Now()
+			if !a.reconnect(ctx) {
+				a.logger.Error("Server is still unavailable")
+			}
+			return false
+		}
+		if err := a.updateCache(ctx); err != nil {
+			a.logger.Errorf("Unable to update cache: %s", err.Error())
+		}
+	case "otp":
+		if len(args) == 1 {
+			a.otp(ctx)
+			return false
+		}
+		a.execKindCommand(ctx, c, model.OTPSecretKind, args[1:])
+	default:
+		if len(args) < 2 {
+			a.logger.Errorf("Usage: %s %s", c.name, c.args)
+			return false
+		}
+		kind, ok := model.KindByName(args[1])
+		if !ok {
+			a.logger.Errorf("Unknown kind %s, one of: %s", args[1], kindsHelp())
+			return false
+		}
+		a.execKindCommand(ctx, c, kind, args[2:])
+	}
+	return false
+}
+
+// execKindCommand runs the command on secrets of the kind, the user is asked for everything if there are no args
+func (a *agent) execKindCommand(ctx context.Context, c replCommand, kind model.Kind, args []string) {
+	if len(args) == 0 && c.name != "list" {
+		switch c.name {
+		case "get":
+			a.get(ctx, kind)
+		case "add":
+			a.add(ctx, kind)
+		case "update":
+			a.update(ctx, kind)
+		case "delete":
+			a.delete(ctx, kind)
+		case "undelete":
+			a.undelete(ctx, kind)
+		case "purge":
+			a.purge(ctx, kind)
+		case "history":
+			a.history(ctx, kind)
+		case "restore":
+			a.restore(ctx, kind)
+		}
+	} else {
+		opts, err := parseCLIOptions(c.name, kind, args)
+		if err != nil {
+			return
+		}
+		err = a.runCommand(ctx, c.name, kind, opts)
+		if errors.Is(err, errUsage) {
+			a.logger.Errorf("Usage: %s %s", c.name, c.args)
+			return
+		}
+		if err != nil {
+			a.logger.Errorf("%s %s failed: %s", c.name, kind.Name(), err.Error())
+			return
+		}
+	}
+	if mutates(c.name) {
+		if err := a.updateCache(ctx); err != nil {
+			a.logger.Errorf("Unable to update cache: %s", err.Error())
+		}
+	}
+}
+
+func (a *agent) isLocked() bool {
+	a.lockMu.Lock()
+	defer a.lockMu.Unlock()
+	return a.locked
+}
+
+// lock drops the vault key, the master password and the session. The ssh-agent is stopped
+// as it holds decrypted keys. Only encrypted secrets are left in memory.
+func (a *agent) lock() {
+	a.lockMu.Lock()
+	defer a.lockMu.Unlock()
+	if a.locked {
+		return
+	}
+	if a.stopSSHAgent != nil {
+		a.stopSSHAgent()
+		a.stopSSHAgent = nil
+	}
+	for i := range a.key {
+		a.key[i] = 0
+	}
+	a.key = nil
+	a.config.Password = ""
+	a.session = nil
+	a.locked = true
+	a.logger.Info("Vault is locked")
+}
+
+// unlock asks for the master password and logs in with it if the vault is locked,
+// offline the password opens the journal
+func (a *agent) unlock(ctx context.Context) bool {
+	if !a.isLocked() {
+		return true
+	}
+	a.config.Password = userinput.InputPassword("master password to unlock")
+	s, err := a.login(ctx)
+	if isOffline(err) {
+		if err := a.openOffline(); err != nil {
+			a.config.Password = ""
+			a.logger.Errorf("Unable to unlock: %s", err.Error())
+			return false
+		}
+		a.lockMu.Lock()
+		a.locked = false
+		a.lockMu.Unlock()
+		return true
+	}
+	if err != nil || s != http.StatusOK {
+		a.config.Password = ""
+		a.session = nil
+		a.logger.Error("Unable to unlock: wrong password or the server is unavailable")
+		return false
+	}
+	a.setVaultKey()
+	a.lockMu.Lock()
+	a.locked = false
+	a.lockMu.Unlock()
+	return true
+}
+
+// backgroundSSHAgent starts the ssh-agent which serves keys until it is stopped or the vault is locked
+func (a *agent) backgroundSSHAgent(args []string) {
+	a.lockMu.Lock()
+	defer a.lockMu.Unlock()
+	if len(args) > 0 && args[0] == "stop" {
+		if a.stopSSHAgent == nil {
+			fmt.Println("SSH agent is not running")
+			return
+		}
+		a.stopSSHAgent()
+		a.stopSSHAgent = nil
+		fmt.Println("SSH agent is stopped")
+		return
+	}
+	if a.stopSSHAgent != nil {
+		fmt.Println("SSH agent is already running")
+		return
+	}
+	// Keys are decrypted here, the vault key is not used by the background goroutine
+	keyring, err := a.sshKeyring()
+	if err != nil {
+		a.logger.Errorf("Unable to load SSH keys: %s", err.Error())
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopSSHAgent = cancel
+	go a.sshAgent(ctx, keyring)
+}
+
+// completeLine is called by the terminal on every key, Tab completes the word before the cursor
+func (a *agent) completeLine(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	cache, err := a.cache.Cache().Get()
+	if err != nil || cache == nil {
+		cache = &model.SecretCache{}
+	}
+	prefix, candidates := completeWord(line[:pos], cache)
+	if len(candidates) > 1 {
+		fmt.Fprintln(a.input.terminal, strings.Join(candidates, "  "))
+	}
+	return prefix + line[pos:], len(prefix), true
+}
+
+// completeWord completes the last word of prefix with the longest common prefix of candidates,
+// candidates are returned too when there are several of them
+func completeWord(prefix string, cache *model.SecretCache) (string, []string) {
+	start := strings.LastIndexAny(prefix, " \t") + 1
+	word := prefix[start:]
+	args, err := splitArgs(prefix[:start])
+	if err != nil {
+		return prefix, nil
+	}
+	typed := strings.TrimPrefix(word, `"`)
+	candidates := make([]string, 0)
+	for _, c := range replCandidates(args, cache) {
+		if strings.HasPrefix(c, typed) {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Strings(candidates)
+	switch len(candidates) {
+	case 0:
+		return prefix, nil
+	case 1:
+		return prefix[:start] + quoteArg(candidates[0]) + " ", nil
+	}
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if strings.HasPrefix(word, `"`) || strings.ContainsAny(common, " \t") {
+		common = `"` + common
+	}
+	if len(common) < len(word) {
+		common = word
+	}
+	return prefix[:start] + common, candidates
+}
+
+// replCandidates returns every possible next argument of the command
+func replCandidates(args []string, cache *model.SecretCache) []string {
+	candidates := make([]string, 0)
+	if len(args) == 0 {
+		for _, c := range replCommands {
+			candidates = append(candidates, c.name)
+		}
+		return candidates
+	}
+	c, ok := findREPLCommand(args[0])
+	if !ok {
+		return nil
+	}
+	var kind model.Kind
+	var rest []string
+	switch {
+	case c.name == "otp":
+		kind, rest = model.OTPSecretKind, args[1:]
+	case c.name == "ssh-agent" && len(args) == 1:
+		return []string{"stop"}
+	case c.name == "search":
+		if args[len(args)-1] == "-o" || args[len(args)-1] == "--o" {
+			return []string{outputText, outputJSON}
+		}
+		return []string{"--limit", "-o"}
+	case c.name == "batch" && len(args) > 1:
+		if args[len(args)-1] == "-o" || args[len(args)-1] == "--o" {
+			return []string{outputText, outputJSON}
+		}
+		return []string{"-o"}
+	case c.name == "import" && len(args) > 1:
+		switch args[len(args)-1] {
+		case "-o", "--o":
+			return []string{outputText, outputJSON}
+		case "--format", "-format":
+			return importer.Formats()
+		}
+		return []string{"--format", "--dry-run", "--duplicates", "--stdin", "-o"}
+	case c.name == "trash" && len(args) == 1:
+		candidates = append(candidates, "empty")
+		for _, k := range model.Kinds() {
+			candidates = append(candidates, k.Name())
+		}
+		return candidates
+	case c.name == "export" && len(args) > 1, c.name == "restore" && isArchiveRestore(args) && len(args) > 2:
+		if args[len(args)-1] == "-o" || args[len(args)-1] == "--o" {
+			return []string{outputText, outputJSON}
+		}
+		if c.name == "restore" {
+			return []string{"--stdin", "--force", "-o"}
+		}
+		return []string{"--stdin", "-o"}
+	case c.kind && len(args) == 1:
+		if c.name == "restore" {
+			candidates = append(candidates, "--archive")
+		}
+		for _, k := range model.Kinds() {
+			candidates = append(candidates, k.Name())
+			candidates = append(candidates, k.Aliases()...)
+		}
+		return candidates
+	case c.kind:
+		if kind, ok = model.KindByName(args[1]); !ok {
+			return nil
+		}
+		rest = args[2:]
+	default:
+		return nil
+	}
+	names := make([]string, 0)
+	for _, m := range cache.Get(kind) {
+		names = append(names, m.Data().Name)
+	}
+	opts := &cliOptions{fields: make(map[string]*string)}
+	fs := newCLIFlagSet(c.name, kind, opts)
+	if fs == nil {
+		return nil
+	}
+	last := ""
+	if len(rest) > 0 {
+		last = rest[len(rest)-1]
+	}
+	switch last {
+	case "--name", "-name":
+		return names
+	case "--folder", "-folder":
+		folders, _ := cachedLabels(cache, kind)
+		return folders
+	case "--tags", "-tags":
+		_, tags := cachedLabels(cache, kind)
+		return tags
+	case "-o", "--o":
+		return []string{outputText, outputJSON}
+	case "--field", "-field":
+		candidates = append(candidates, "name", "meta", "folder", "tags")
+		for _, f := range kind.New().Fields() {
+			candidates = append(candidates, f.Column)
+		}
+		return candidates
+	}
+	if len(rest) == 0 && c.name != "list" && c.name != "add" {
+		candidates = append(candidates, names...)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if len(f.Name) == 1 {
+			candidates = append(candidates, "-"+f.Name)
+			return
+		}
+		candidates = append(candidates, "--"+f.Name)
+	})
+	return candidates
+}
+
+// splitArgs splits the line into arguments by spaces, arguments may be quoted with ' or ",
+// a backslash escapes the next character outside of single quotes
+func splitArgs(line string) ([]string, error) {
+	args := make([]string, 0)
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errUnterminatedQuote
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// quoteArg quotes the argument if splitArgs would split or unescape it
+func quoteArg(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\"'\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// lineReader reads commands of the session. On a terminal lines are edited in raw mode
+// with history and completion, otherwise they are read as is, so commands may be piped.
+type lineReader struct {
+	fd       int
+	terminal *term.Terminal
+	state    *term.State
+}
+
+func newLineReader(complete func(line string, pos int, key rune) (string, int, bool)) *lineReader {
+	r := &lineReader{fd: int(os.Stdin.Fd())}
+	if !term.IsTerminal(r.fd) {
+		return r
+	}
+	r.terminal = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, replPrompt)
+	r.terminal.AutoCompleteCallback = complete
+	return r
+}
+
+// readLine reads a command, the terminal is in raw mode only while the line is edited,
+// so prompts of commands work as usual. Logs are written through the terminal meanwhile.
+func (r *lineReader) readLine(prompt string, a *agent) (string, error) {
+	if r.terminal == nil {
+		fmt.Print(prompt)
+		return readRawLine(os.Stdin)
+	}
+	if width, height, err := term.GetSize(r.fd); err == nil {
+		_ = r.terminal.SetSize(width, height)
+	}
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	r.state = state
+	a.logger.SetOutput(r.terminal)
+	defer a.logger.SetOutput(os.Stderr)
+	defer r.restore()
+	r.terminal.SetPrompt(prompt)
+	return r.terminal.ReadLine()
+}
+
+// restore leaves raw mode
+func (r *lineReader) restore() {
+	if r.state != nil {
+		_ = term.Restore(r.fd, r.state)
+		r.state = nil
+	}
+}
+
+// readRawLine reads a line byte by byte, so nothing after it is consumed from the reader
+func readRawLine(reader io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := reader.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				return strings.TrimSuffix(string(line), "\r"), nil
+			}
+			line = append(line, b[0])
+		}
+		if errors.Is(err, io.EOF) && len(line) > 0 {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_splitArgs(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{line: "  get login  github ", want: []string{"get", "login", "github"}},
+		{line: `get login "my bank" --field password`, want: []string{"get", "login", "my bank", "--field", "password"}},
+		{line: `add text --text 'say "hi"'`, want: []string{"add", "text", "--text", `say "hi"`}},
+		{line: `get login my\ bank`, want: []string{"get", "login", "my bank"}},
+		{line: `add text --text ""`, want: []string{"add", "text", "--text", ""}},
+		{line: `get login "my bank`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := splitArgs(tt.line)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_completeWord(t *testing.T) {
+	cache := &model.SecretCache{}
+	cache.Set(model.LoginWithPasswordKind, []model.Secret{
+		&model.LoginWithPassword{SecretData: model.SecretData{ID: 1, Name: "github"}},
+		&model.LoginWithPassword{SecretData: model.SecretData{ID: 2, Name: "gitlab"}},
+		&model.LoginWithPassword{SecretData: model.SecretData{ID: 3, Name: "my bank"}},
+	})
+	tests := []struct {
+		prefix         string
+		want           string
+		wantCandidates []string
+	}{
+		{prefix: "upd", want: "update "},
+		{prefix: "get tex", want: "get text "},
+		{prefix: "get log", want: "get login", wantCandidates: []string{"login", "loginwithpassword"}},
+		{prefix: "get login gi", want: "get login git", wantCandidates: []string{"github", "gitlab"}},
+		{prefix: "get login gith", want: "get login github "},
+		{prefix: "get login m", want: `get login "my bank" `},
+		{prefix: "get login github --fi", want: "get login github --field "},
+		{prefix: "get login github --field pa", want: "get login github --field password "},
+		{prefix: "get login --name gitl", want: "get login --name gitlab "},
+		{prefix: "list cards -o j", want: "list cards -o json "},
+		{prefix: "get unknown x", want: "get unknown x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			got, candidates := completeWord(tt.prefix, cache)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantCandidates, candidates)
+		})
+	}
+}
+
+func Test_readRawLine(t *testing.T) {
+	r := strings.NewReader("list login\r\nexit")
+	line, err := readRawLine(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "list login", line)
+	line, err = readRawLine(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "exit", line)
+	_, err = readRawLine(r)
+	assert.Error(t, err)
+}
+
+func Test_agent_readCommand(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+		w.Close()
+	})
+	a := &agent{config: NewConfig(), logger: logrus.New(), key: []byte("vault key"), session: &model.Session{Token: "token"}}
+	a.config.Password = "master password"
+	a.config.IdleLock = 10 * time.Millisecond
+	a.input = newLineReader(nil)
+	// The command is typed only after the vault is locked for being idle
+	go func() {
+		for !a.isLocked() {
+			time.Sleep(time.Millisecond)
+		}
+		_, _ = w.WriteString("list login\n")
+	}()
+	line, err := a.readCommand(replPrompt)
+	assert.NoError(t, err)
+	assert.Equal(t, "list login", line)
+	assert.True(t, a.isLocked())
+	assert.Nil(t, a.key)
+	assert.Nil(t, a.session)
+	assert.Empty(t, a.config.Password)
+}
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Numbers of search hits
+const (
+	defaultSearchLimit = 20
+	// searchPageSize is the largest page the server returns
+	searchPageSize = 100
+)
+
+var errEmptySearch = errors.New("nothing to search for, the text has no words")
+
+// searchSecrets returns up to limit secrets of every kind matching the text, best first. The server ranks
+// secrets by words of their names, meta and tags and its pages are walked until limit hits are got.
+// Offline the cache is searched like the filter of the TUI does.
+func (a *agent) searchSecrets(ctx context.Context, text string, limit int) ([]model.SearchHit, error) {
+	if len(model.SearchWords(text)) == 0 {
+		return nil, errEmptySearch
+	}
+	if !a.onlineMode {
+		cache, err := a.cache.Cache().Get()
+		if err != nil {
+			return nil, err
+		}
+		return searchCache(cache, text, limit), nil
+	}
+	hits := make([]model.SearchHit, 0)
+	cursor := ""
+	for len(hits) < limit {
+		page, err := a.searchPage(ctx, text, minInt(limit-len(hits), searchPageSize), cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range page.Hits {
+			// Secrets of kinds unknown to this agent are skipped
+			if h.Secret != nil {
+				hits = append(hits, h)
+			}
+		}
+		if page.NextCursor == "" || len(page.Hits) == 0 {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	return hits, nil
+}
+
+func (a *agent) searchPage(ctx context.Context, text string, limit int, cursor string) (*model.SearchResults, error) {
+	query := url.Values{"q": {text}, "limit": {strconv.Itoa(limit)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	data, s, err := a.sendRequest2(ctx, searchURI+"?"+query.Encode(), http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s != http.StatusOK {
+		return nil, fmt.Errorf("%w %d: %s", errBadHTTPStatusCode, s, strings.TrimSpace(string(data)))
+	}
+	page := &model.SearchResults{}
+	if err := json.Unmarshal(data, page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// searchCache returns up to limit cached secrets of every kind fuzzy matching the text, best first
+func searchCache(cache *model.SecretCache, text string, limit int) []model.SearchHit {
+	hits := make([]model.SearchHit, 0)
+	for _, kind := range model.Kinds() {
+		for _, m := range cache.Get(kind) {
+			if score, ok := fuzzyScore(text, fuzzyText(m)); ok {
+				hits = append(hits, model.SearchHit{Kind: kind.Name(), Rank: float64(score), Secret: m})
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Rank > hits[j].Rank
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// searchResult is a search hit without the payload of the secret
+type searchResult struct {
+	Kind string  `json:"kind"`
+	Rank float64 `json:"rank"`
+	secretSummary
+}
+
+func printSearch(hits []model.SearchHit, output string) error {
+	list := make([]searchResult, 0, len(hits))
+	for _, h := range hits {
+		list = append(list, searchResult{Kind: h.Kind, Rank: h.Rank, secretSummary: summary(h.Secret)})
+	}
+	if output == outputJSON {
+		return printJSON(list)
+	}
+	if len(list) == 0 {
+		fmt.Println("Nothing found")
+		return nil
+	}
+	for _, s := range list {
+		fmt.Printf("%s\t%d\t%s\t%s\t%s\t%s\t%s\n", s.Kind, s.ID, s.Name, s.Meta, s.Folder, strings.Join(s.Tags, ","), favoriteMark(s.Favorite))
+	}
+	return nil
+}
+
+// parseSearchOptions returns options of the search command and the text to search for,
+// which is every positional argument. Options may follow words of the text.
+func parseSearchOptions(args []string) (*cliOptions, string, error) {
+	opts := &cliOptions{set: make(map[string]bool), fields: make(map[string]*string)}
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.IntVar(&opts.limit, "limit", defaultSearchLimit, "Largest number of secrets found")
+	fs.StringVar(&opts.output, "o", outputText, "Output format: text or json")
+	words := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, "", err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		words = append(words, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(words) == 0 {
+		fmt.Fprintln(os.Stderr, "Text to search for is required")
+		return nil, "", errUsage
+	}
+	if opts.limit < 1 {
+		fmt.Fprintln(os.Stderr, "--limit must be positive")
+		return nil, "", errUsage
+	}
+	if opts.output != outputText && opts.output != outputJSON {
+		fmt.Fprintf(os.Stderr, "Unknown output format %s\n", opts.output)
+		return nil, "", errUsage
+	}
+	return opts, strings.Join(words, " "), nil
+}
+
+// cliSearch prints secrets of every kind matching the text, errSecretNotFound is returned if nothing matches
+func (a *agent) cliSearch(ctx context.Context, text string, opts *cliOptions) error {
+	hits, err := a.searchSecrets(ctx, text, opts.limit)
+	if err != nil {
+		return err
+	}
+	if err := printSearch(hits, opts.output); err != nil {
+		return err
+	}
+	if len(hits) == 0 {
+		return errSecretNotFound
+	}
+	return nil
+}
+
+// runSearch executes the search command of the command line mode and returns the exit code
+func (a *agent) runSearch(args []string) int {
+	opts, text, err := parseSearchOptions(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		return ExitUsage
+	}
+	ctx := context.Background()
+	if err := a.connect(ctx); err != nil {
+		a.logger.Errorf("Unable to connect: %s", err.Error())
+		return ExitError
+	}
+	err = a.cliSearch(ctx, text, opts)
+	a.close()
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, errSecretNotFound):
+		return ExitNotFound
+	case errors.Is(err, errEmptySearch):
+		a.logger.Errorf("search failed: %s", err.Error())
+		return ExitUsage
+	default:
+		a.logger.Errorf("search failed: %s", err.Error())
+		return ExitError
+	}
+}
+
+// search runs the search command of the interactive session
+func (a *agent) search(ctx context.Context, args []string) {
+	opts, text, err := parseSearchOptions(args)
+	if err != nil {
+		return
+	}
+	if err := a.cliSearch(ctx, text, opts); err != nil && !errors.Is(err, errSecretNotFound) {
+		a.logger.Errorf("search failed: %s", err.Error())
+	}
+}
@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_agent_searchSecrets(t *testing.T) {
+	ctx := context.Background()
+	var queries []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/private/search" {
+			http.NotFound(w, r)
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		page := &model.SearchResults{}
+		if r.URL.Query().Get("cursor") == "" {
+			page.Hits = []model.SearchHit{
+				{Kind: model.LoginWithPasswordKind.Name(), Rank: 2, Secret: &model.LoginWithPassword{SecretData: model.SecretData{ID: 1, Name: "github"}}},
+				{Kind: "unknown", Rank: 1.5},
+			}
+			page.NextCursor = "next"
+		} else {
+			page.Hits = []model.SearchHit{
+				{Kind: model.SecretTextKind.Name(), Rank: 1, Secret: &model.SecretText{SecretData: model.SecretData{ID: 2, Name: "github recovery"}}},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+
+	hits, err := a.searchSecrets(ctx, "git hub", 5)
+	if assert.NoError(t, err) && assert.Len(t, hits, 2) {
+		assert.Equal(t, model.LoginWithPasswordKind.Name(), hits[0].Kind)
+		assert.Equal(t, "github recovery", hits[1].Secret.Data().Name)
+	}
+	assert.Equal(t, []string{"limit=5&q=git+hub", "cursor=next&limit=4&q=git+hub"}, queries)
+
+	_, err = a.searchSecrets(ctx, " - ", 5)
+	assert.ErrorIs(t, err, errEmptySearch)
+
+	// Offline the cache is searched
+	c := &model.SecretCache{}
+	c.Set(model.SecretTextKind, []model.Secret{
+		&model.SecretText{SecretData: model.SecretData{ID: 3, Name: "gitlab"}},
+		&model.SecretText{SecretData: model.SecretData{ID: 4, Name: "bank"}},
+	})
+	c.Set(model.LoginWithPasswordKind, []model.Secret{
+		&model.LoginWithPassword{SecretData: model.SecretData{ID: 5, Name: "mail", Labels: model.Labels{Tags: []string{"git"}}}},
+	})
+	if err := a.cache.Cache().Save(c); err != nil {
+		t.Fatal(err)
+	}
+	a.onlineMode = false
+	hits, err = a.searchSecrets(ctx, "git", 1)
+	if assert.NoError(t, err) && assert.Len(t, hits, 1) {
+		assert.Equal(t, 3, hits[0].Secret.Data().ID)
+	}
+	hits, err = a.searchSecrets(ctx, "git", 5)
+	if assert.NoError(t, err) {
+		assert.Len(t, hits, 2)
+	}
+}
+
+func Test_parseSearchOptions(t *testing.T) {
+	opts, text, err := parseSearchOptions([]string{"work", "mail", "--limit", "3", "server", "-o", "json"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "work mail server", text)
+		assert.Equal(t, 3, opts.limit)
+		assert.Equal(t, outputJSON, opts.output)
+	}
+	_, _, err = parseSearchOptions([]string{"--limit", "3"})
+	assert.ErrorIs(t, err, errUsage)
+	_, _, err = parseSearchOptions([]string{"mail", "--limit", "0"})
+	assert.ErrorIs(t, err, errUsage)
+}
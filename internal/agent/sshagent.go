@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+
+	sshagent "golang.org/x/crypto/ssh/agent"
+)
+
+// sshAgentSocketName is the name of the socket in the private directory created for it
+const sshAgentSocketName = "agent.sock"
+
+var errSocketDirNotPrivate = errors.New("directory of the ssh-agent socket must be accessible only by the user")
+
+// sshAgent serves SSH key pairs of the vault over an ssh-agent compatible Unix socket.
+// Keys are decrypted into memory only and are gone when the agent stops.
+func (a *agent) sshAgent(ctx context.Context, keyring sshagent.Agent) {
+	l, cleanup, err := a.listenSSHAgent()
+	if err != nil {
+		a.logger.Errorf("Unable to listen ssh-agent socket: %s", err.Error())
+		return
+	}
+	defer cleanup()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	fmt.Printf("SSH agent is running, stop it with ssh-agent stop\nexport SSH_AUTH_SOCK=%s\n", l.Addr().String())
+	if err := a.serveSSHAgent(l, keyring); err != nil && ctx.Err() == nil {
+		a.logger.Errorf("SSH agent stopped: %s", err.Error())
+	}
+}
+
+// listenSSHAgent listens the ssh-agent socket, cleanup removes it. The socket is created in a directory
+// only the user can access, so no one else can connect before its permissions could be restricted.
+// Without a configured socket a new private directory is created in the temporary directory.
+func (a *agent) listenSSHAgent() (net.Listener, func(), error) {
+	path := a.config.SSHAgentSocket
+	if path == "" {
+		dir, err := os.MkdirTemp("", "cenarius-ssh-")
+		if err != nil {
+			return nil, nil, err
+		}
+		path = filepath.Join(dir, sshAgentSocketName)
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			os.Remove(dir)
+			return nil, nil, err
+		}
+		return l, func() { os.RemoveAll(dir) }, nil
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.IsDir() || info.Mode().Perm()&0077 != 0 {
+		return nil, nil, fmt.Errorf("%w: %s", errSocketDirNotPrivate, dir)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, func() { os.Remove(path) }, nil
+}
+
+// sshKeyring returns in-memory keyring with every SSH key pair of the cache
+func (a *agent) sshKeyring() (sshagent.Agent, error) {
+	cache, err := a.cache.Cache().Get()
+	if err != nil {
+		return nil, err
+	}
+	keyring := sshagent.NewKeyring()
+	for _, cached := range cache.Get(model.KeyPairKind) {
+		s, err := a.findSecret(model.KeyPairKind, cached.Data().ID)
+		if err != nil {
+			return nil, err
+		}
+		m := s.(*model.KeyPair)
+		if m.Type != model.KeyPairSSH {
+			continue
+		}
+		key, err := m.SSHKey()
+		if err != nil {
+			a.logger.Errorf("Skipping key pair %d: %s", m.ID, err.Error())
+			continue
+		}
+		if err := keyring.Add(sshagent.AddedKey{PrivateKey: key, Comment: m.Name}); err != nil {
+			a.logger.Errorf("Skipping key pair %d: %s", m.ID, err.Error())
+			continue
+		}
+		a.logger.Infof("SSH key %s loaded: %s", m.Name, m.Fingerprint)
+	}
+	return keyring, nil
+}
+
+// serveSSHAgent serves every connection of l with the keyring until l is closed
+func (a *agent) serveSSHAgent(l net.Listener, keyring sshagent.Agent) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := sshagent.ServeAgent(keyring, conn); err != nil && !errors.Is(err, io.EOF) {
+				a.logger.Debugf("ssh-agent connection closed: %s", err.Error())
+			}
+		}()
+	}
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	sshagent "golang.org/x/crypto/ssh/agent"
+)
+
+func Test_agent_serveSSHAgent(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := sshagent.NewKeyring()
+	if err := keyring.Add(sshagent.AddedKey{PrivateKey: priv, Comment: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	a := &agent{logger: logrus.New()}
+	go func() {
+		_ = a.serveSSHAgent(l, keyring)
+	}()
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := sshagent.NewClient(conn)
+	keys, err := client.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, "test", keys[0].Comment)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("challenge")
+	sig, err := client.Sign(signer.PublicKey(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, signer.PublicKey().Verify(data, sig))
+}
+
+func Test_agent_listenSSHAgent(t *testing.T) {
+	a := &agent{config: NewConfig(), logger: logrus.New()}
+	l, cleanup, err := a.listenSSHAgent()
+	if !assert.NoError(t, err) {
+		return
+	}
+	dir := filepath.Dir(l.Addr().String())
+	info, err := os.Stat(dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	}
+	l.Close()
+	cleanup()
+	assert.NoDirExists(t, dir)
+
+	a.config.SSHAgentSocket = filepath.Join(t.TempDir(), "private", "agent.sock")
+	l, cleanup, err = a.listenSSHAgent()
+	if assert.NoError(t, err) {
+		assert.Equal(t, a.config.SSHAgentSocket, l.Addr().String())
+		l.Close()
+		cleanup()
+	}
+
+	shared := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+	a.config.SSHAgentSocket = filepath.Join(shared, "agent.sock")
+	_, _, err = a.listenSSHAgent()
+	assert.ErrorIs(t, err, errSocketDirNotPrivate)
+}
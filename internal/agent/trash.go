@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/userinput"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	errTrashOffline = errors.New("trash is kept on the server and is unavailable offline")
+	errInTrash      = errors.New("secret is in the trash, undelete it first")
+)
+
+// getTrash returns secrets in the trash of every kind, sealed like they are stored
+func (a *agent) getTrash(ctx context.Context) (*model.SecretCache, error) {
+	if !a.onlineMode {
+		return nil, errTrashOffline
+	}
+	trash := &model.SecretCache{}
+	if err := a.getSecretsWrapper(ctx, trashURI, trash); err != nil {
+		return nil, err
+	}
+	return trash, nil
+}
+
+// undeleteSecret takes the secret out of the trash, the cache gets it back on the next sync
+func (a *agent) undeleteSecret(ctx context.Context, kind model.Kind, id int) (model.Secret, error) {
+	data, err := a.trashRequest(ctx, http.MethodPost, trashedURI(kind, id)+"/restore")
+	if err != nil {
+		return nil, err
+	}
+	m := kind.New()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// purgeSecret removes the secret from the trash for good
+func (a *agent) purgeSecret(ctx context.Context, kind model.Kind, id int) error {
+	_, err := a.trashRequest(ctx, http.MethodDelete, trashedURI(kind, id))
+	return err
+}
+
+// emptyTrash removes every secret in the trash for good and returns how many were removed
+func (a *agent) emptyTrash(ctx context.Context) (int, error) {
+	data, err := a.trashRequest(ctx, http.MethodDelete, trashURI)
+	if err != nil {
+		return 0, err
+	}
+	body := struct {
+		Purged int `json:"purged"`
+	}{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return 0, err
+	}
+	return body.Purged, nil
+}
+
+func (a *agent) trashRequest(ctx context.Context, method, uri string) ([]byte, error) {
+	if !a.onlineMode {
+		return nil, errTrashOffline
+	}
+	data, s, err := a.sendRequest2(ctx, uri, method, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s == http.StatusNotFound {
+		return nil, errSecretNotFound
+	}
+	if s != http.StatusOK {
+		a.logger.Errorf("agent.trashRequest %s %s failed %d: %s", method, uri, s, string(data))
+		return nil, fmt.Errorf("%w %d: %s", errBadHTTPStatusCode, s, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// lookupTrashed returns the secret in the trash selected by --id or --name
+func (a *agent) lookupTrashed(ctx context.Context, kind model.Kind, opts *cliOptions) (model.Secret, error) {
+	if !opts.set["id"] && !opts.set["name"] {
+		fmt.Fprintln(os.Stderr, "--id or --name is required")
+		return nil, errUsage
+	}
+	trash, err := a.getTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var found model.Secret
+	for _, m := range trash.Get(kind) {
+		if opts.set["id"] && m.Data().ID != opts.id || !opts.set["id"] && m.Data().Name != opts.name {
+			continue
+		}
+		if found != nil {
+			return nil, errAmbiguousName
+		}
+		found = m
+	}
+	if found == nil {
+		return nil, errSecretNotFound
+	}
+	return found, nil
+}
+
+// inTrash reports whether the secret is in the trash
+func (a *agent) inTrash(ctx context.Context, kind model.Kind, id int) (bool, error) {
+	trash, err := a.getTrash(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, ok := trash.Find(kind, id)
+	return ok, nil
+}
+
+// trashedSummary is a secret in the trash without its payload
+type trashedSummary struct {
+	secretSummary
+	Kind      string `json:"kind"`
+	DeletedAt string `json:"deleted_at"`
+}
+
+// trashList returns summaries of secrets in the trash of the kinds
+func trashList(trash *model.SecretCache, kinds []model.Kind) []trashedSummary {
+	list := make([]trashedSummary, 0)
+	for _, kind := range kinds {
+		for _, m := range trash.Get(kind) {
+			s := trashedSummary{secretSummary: summary(m), Kind: kind.Name()}
+			if d := m.Data().DeletedAt; d != nil {
+				s.DeletedAt = d.Local().Format("2006-01-02 15:04:05")
+			}
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func printTrash(list []trashedSummary) {
+	if len(list) == 0 {
+		fmt.Println("Trash is empty")
+		return
+	}
+	for _, s := range list {
+		fmt.Printf("%s\t%d\t%s\t%s\n", s.Kind, s.ID, s.Name, s.DeletedAt)
+	}
+}
+
+// trash lists the trash of every kind or of the kind given in args, trash empty purges it after confirmation
+func (a *agent) trash(ctx context.Context, args []string) {
+	if len(args) > 0 && args[0] == "empty" {
+		if strings.ToLower(strings.TrimSpace(userinput.Input("Delete everything in the trash for good? (y/n)"))) != "y" {
+			return
+		}
+		count, err := a.emptyTrash(ctx)
+		if err != nil {
+			a.logger.Errorf("Unable to empty trash: %s", err.Error())
+			return
+		}
+		fmt.Printf("Purged %d secrets\n", count)
+		return
+	}
+	kinds := model.Kinds()
+	if len(args) > 0 {
+		kind, ok := model.KindByName(args[0])
+		if !ok {
+			a.logger.Errorf("Unknown kind %s, one of: %s", args[0], kindsHelp())
+			return
+		}
+		kinds = []model.Kind{kind}
+	}
+	trash, err := a.getTrash(ctx)
+	if err != nil {
+		a.logger.Errorf("Unable to get trash: %s", err.Error())
+		return
+	}
+	printTrash(trashList(trash, kinds))
+}
+
+// undelete restores the secret of the trash entered by id
+func (a *agent) undelete(ctx context.Context, kind model.Kind) {
+	a.trash(ctx, []string{kind.Name()})
+	id := userinput.InputID()
+	if _, err := a.undeleteSecret(ctx, kind, id); err != nil {
+		a.logger.Errorf("Unable to undelete %s %d: %s", kind.Name(), id, err.Error())
+		return
+	}
+	fmt.Printf("Restored %s %d\n", kind.Name(), id)
+}
+
+// purge removes the secret of the trash entered by id for good
+func (a *agent) purge(ctx context.Context, kind model.Kind) {
+	a.trash(ctx, []string{kind.Name()})
+	id := userinput.InputID()
+	if err := a.purgeSecret(ctx, kind, id); err != nil {
+		a.logger.Errorf("Unable to purge %s %d: %s", kind.Name(), id, err.Error())
+		return
+	}
+	fmt.Printf("Purged %s %d\n", kind.Name(), id)
+}
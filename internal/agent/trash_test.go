@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_agent_trash(t *testing.T) {
+	ctx := context.Background()
+	trash := &model.SecretCache{}
+	trash.Set(model.SecretTextKind, []model.Secret{
+		&model.SecretText{SecretData: model.SecretData{ID: 5, Name: "note"}},
+		&model.SecretText{SecretData: model.SecretData{ID: 6, Name: "twin"}},
+		&model.SecretText{SecretData: model.SecretData{ID: 7, Name: "twin"}},
+	})
+	var requests []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/private/trash":
+			_ = json.NewEncoder(w).Encode(trash)
+		case "DELETE /api/v1/private/trash":
+			_ = json.NewEncoder(w).Encode(map[string]int{"purged": 3})
+		case "POST /api/v1/private/trash/secrettext/5/restore":
+			_ = json.NewEncoder(w).Encode(&model.SecretText{SecretData: model.SecretData{ID: 5, Name: "note", Version: 1}})
+		case "DELETE /api/v1/private/trash/secrettext/5":
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+
+	m, err := a.lookupTrashed(ctx, model.SecretTextKind, &cliOptions{name: "note", set: map[string]bool{"name": true}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 5, m.Data().ID)
+	}
+	_, err = a.lookupTrashed(ctx, model.SecretTextKind, &cliOptions{name: "twin", set: map[string]bool{"name": true}})
+	assert.ErrorIs(t, err, errAmbiguousName)
+	_, err = a.lookupTrashed(ctx, model.SecretTextKind, &cliOptions{id: 9, set: map[string]bool{"id": true}})
+	assert.ErrorIs(t, err, errSecretNotFound)
+
+	m, err = a.undeleteSecret(ctx, model.SecretTextKind, 5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "note", m.Data().Name)
+	}
+	_, err = a.undeleteSecret(ctx, model.SecretTextKind, 9)
+	assert.ErrorIs(t, err, errSecretNotFound)
+	assert.NoError(t, a.purgeSecret(ctx, model.SecretTextKind, 5))
+	count, err := a.emptyTrash(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, count)
+	}
+	assert.Len(t, trashList(trash, model.Kinds()), 3)
+
+	a.onlineMode = false
+	_, err = a.getTrash(ctx)
+	assert.ErrorIs(t, err, errTrashOffline)
+}
@@ -0,0 +1,672 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// Escape sequences of the terminal
+const (
+	escAltScreenOn  = "\x1b[?1049h"
+	escAltScreenOff = "\x1b[?1049l"
+	escHideCursor   = "\x1b[?25l"
+	escShowCursor   = "\x1b[?25h"
+	escHome         = "\x1b[H"
+	escClearLine    = "\x1b[K"
+	escReverse      = "\x1b[7m"
+	escBold         = "\x1b[1m"
+	escReset        = "\x1b[0m"
+)
+
+const (
+	maskedValue = "••••••••"
+	tuiHelp     = "q quit  ←/→ kind  ↑/↓ select  / filter  tab details  r reveal  c copy  a add  e edit  f favorite  d delete  s save file  R sync"
+)
+
+var errNotTerminal = errors.New("tui needs a terminal")
+
+// tui is a full-screen terminal interface of the agent. Secret fields are masked until revealed,
+// secrets are added and edited in forms checked by the rules of their kind.
+type tui struct {
+	*agent
+	fd        int
+	termState *term.State
+	width     int
+	height    int
+
+	kindIdx   int
+	selected  int
+	offset    int
+	filter    string
+	filtering bool
+	// details is set when the details pane has focus, field is the selected row of it
+	details bool
+	field   int
+	reveal  bool
+	form    *tuiForm
+	// confirm is a question in the status bar, onConfirm runs when it is answered with y
+	confirm   string
+	onConfirm func()
+	status    string
+	quit      bool
+}
+
+// NewTUI returns the agent with the terminal interface
+func NewTUI(config *Config) *tui {
+	return &tui{agent: NewAgent(config), fd: int(os.Stdin.Fd()), width: 80, height: 24}
+}
+
+// Start runs the interface until it is closed
+func (t *tui) Start() error {
+	ctx := context.Background()
+	if !term.IsTerminal(t.fd) {
+		return errNotTerminal
+	}
+	if err := t.connect(ctx); err != nil {
+		return err
+	}
+	t.resumeUploads(ctx)
+	// Logs would break the screen, errors are shown in the status bar instead
+	t.logger.SetOutput(io.Discard)
+	t.logger.AddHook(statusHook{t})
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return err
+	}
+	t.termState = state
+	fmt.Print(escAltScreenOn + escHideCursor)
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for !t.quit {
+		t.render()
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				t.quit = true
+				continue
+			}
+			t.handleKey(ctx, key)
+		case <-ticker.C:
+			// Redraw for resizes and codes of OTP secrets
+		}
+	}
+	t.restore()
+	t.close()
+	return nil
+}
+
+// Shutdown restores the terminal and stops the agent
+func (t *tui) Shutdown() {
+	t.restore()
+	t.agent.Shutdown()
+}
+
+func (t *tui) restore() {
+	if t.termState == nil {
+		return
+	}
+	fmt.Print(escShowCursor + escAltScreenOff)
+	_ = term.Restore(t.fd, t.termState)
+	t.termState = nil
+}
+
+// statusHook shows errors logged by the agent in the status bar
+type statusHook struct {
+	t *tui
+}
+
+func (h statusHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+func (h statusHook) Fire(e *logrus.Entry) error {
+	h.t.status = e.Message
+	return nil
+}
+
+// fail shows the error unless a more detailed one is already logged
+func (t *tui) fail(err error) {
+	if t.status == "" {
+		t.status = err.Error()
+	}
+}
+
+func (t *tui) kind() model.Kind {
+	return model.Kinds()[t.kindIdx]
+}
+
+// entries returns secrets of the current kind grouped by folder, with a filter best matches go first
+func (t *tui) entries() []model.Secret {
+	cache, err := t.cache.Cache().Get()
+	if err != nil || cache == nil {
+		return nil
+	}
+	secrets := filterLabels(cache.Get(t.kind()), model.LabelFilter{})
+	model.SortByFolder(secrets)
+	return filterSecrets(secrets, t.filter)
+}
+
+// current returns decrypted selected secret
+func (t *tui) current() (model.Secret, error) {
+	entries := t.entries()
+	if t.selected >= len(entries) {
+		return nil, errSecretNotFound
+	}
+	return t.findSecret(t.kind(), entries[t.selected].Data().ID)
+}
+
+func (t *tui) handleKey(ctx context.Context, key string) {
+	t.status = ""
+	switch {
+	case key == "ctrl+c":
+		t.quit = true
+	case t.confirm != "":
+		if key == "y" || key == "Y" {
+			t.onConfirm()
+		}
+		t.confirm, t.onConfirm = "", nil
+	case t.form != nil:
+		t.handleFormKey(ctx, key)
+	case t.filtering:
+		t.handleFilterKey(key)
+	default:
+		t.handleListKey(ctx, key)
+	}
+}
+
+func (t *tui) handleFilterKey(key string) {
+	switch key {
+	case "esc":
+		t.filter, t.filtering = "", false
+	case "enter", "tab", "down":
+		t.filtering = false
+	case "backspace":
+		if r := []rune(t.filter); len(r) > 0 {
+			t.filter = string(r[:len(r)-1])
+		}
+	case "ctrl+u":
+		t.filter = ""
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			t.filter += key
+		}
+	}
+	t.selected, t.offset = 0, 0
+}
+
+func (t *tui) handleListKey(ctx context.Context, key string) {
+	kinds := model.Kinds()
+	switch key {
+	case "q":
+		t.quit = true
+	case "left", "h":
+		t.kindIdx = (t.kindIdx + len(kinds) - 1) % len(kinds)
+		t.selected, t.offset, t.field, t.details = 0, 0, 0, false
+	case "right", "l":
+		t.kindIdx = (t.kindIdx + 1) % len(kinds)
+		t.selected, t.offset, t.field, t.details = 0, 0, 0, false
+	case "up", "k":
+		if t.details {
+			t.field = maxInt(t.field-1, 0)
+		} else if t.selected > 0 {
+			t.selected--
+			t.field = 0
+		}
+	case "down", "j":
+		if t.details {
+			t.field++
+		} else if t.selected < len(t.entries())-1 {
+			t.selected++
+			t.field = 0
+		}
+	case "/":
+		t.filtering, t.details = true, false
+	case "esc":
+		t.filter, t.details = "", false
+	case "tab", "enter":
+		t.details = !t.details
+	case "r":
+		t.reveal = !t.reveal
+	case "c":
+		t.copyField()
+	case "a":
+		t.form = newTUIForm(t.kind(), t.kind().New(), false)
+	case "e":
+		m, err := t.current()
+		if err != nil {
+			t.fail(err)
+			return
+		}
+		t.form = newTUIForm(t.kind(), m, true)
+	case "d":
+		m, err := t.current()
+		if err != nil {
+			t.fail(err)
+			return
+		}
+		t.confirm = fmt.Sprintf("Delete %s %s? (y/n)", t.kind().Name(), m.Data().Name)
+		t.onConfirm = func() {
+			if err := t.deleteSecret(ctx, t.kind(), m.Data().ID); err != nil {
+				t.fail(err)
+				return
+			}
+			t.sync(ctx)
+			t.status = "Moved to trash " + m.Data().Name
+		}
+	case "f":
+		t.toggleFavorite(ctx)
+	case "s":
+		t.saveFile(ctx)
+	case "R":
+		t.sync(ctx)
+	}
+}
+
+// toggleFavorite marks the selected secret as favorite or unmarks it, a change made on another device
+// is not overwritten
+func (t *tui) toggleFavorite(ctx context.Context) {
+	m, err := t.current()
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	m.Data().Favorite = !m.Data().Favorite
+	if _, err := t.updateResolved(ctx, t.kind(), m, failConflict); err != nil {
+		t.fail(err)
+		return
+	}
+	t.sync(ctx)
+	if m.Data().Favorite {
+		t.status = "Marked as favorite " + m.Data().Name
+	} else {
+		t.status = "Unmarked as favorite " + m.Data().Name
+	}
+}
+
+func (t *tui) sync(ctx context.Context) {
+	if err := t.updateCache(ctx); err != nil {
+		t.fail(err)
+		return
+	}
+	if n := len(t.entries()); t.selected >= n {
+		t.selected = maxInt(n-1, 0)
+	}
+}
+
+// copyField copies the selected row of the details, or the first masked one, to the clipboard
+// with OSC 52 escape sequence, so it works over SSH too
+func (t *tui) copyField() {
+	m, err := t.current()
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	rows := detailRows(m, time.Now())
+	row := rows[minInt(t.field, len(rows)-1)]
+	if !t.details {
+		for _, r := range rows {
+			if r.masked {
+				row = r
+				break
+			}
+		}
+	}
+	fmt.Printf("\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(row.value)))
+	t.status = fmt.Sprintf("Copied %s to the clipboard", row.label)
+}
+
+// saveFile downloads the selected blob secret to SecretFile_<id>
+func (t *tui) saveFile(ctx context.Context) {
+	if !t.kind().Blob() {
+		return
+	}
+	m, err := t.current()
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	name := fmt.Sprintf("SecretFile_%d", m.Data().ID)
+	if err := t.saveSecretFile(ctx, t.kind(), m.Data().ID, name); err != nil {
+		t.fail(err)
+		return
+	}
+	t.status = "Saved to " + name
+}
+
+// render draws the whole screen
+func (t *tui) render() {
+	if width, height, err := term.GetSize(t.fd); err == nil {
+		t.width, t.height = width, height
+	}
+	lines := t.view()
+	var b strings.Builder
+	b.WriteString(escHome)
+	for i, line := range lines {
+		b.WriteString(line)
+		b.WriteString(escClearLine)
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	fmt.Print(b.String())
+}
+
+// view returns lines of the screen
+func (t *tui) view() []string {
+	lines := make([]string, 0, t.height)
+	lines = append(lines, t.tabsLine())
+	body := maxInt(t.height-3, 1)
+	if t.form != nil {
+		lines = append(lines, escBold+fit(t.form.title(), t.width)+escReset)
+		lines = append(lines, t.form.view(t.width, body, t.reveal)...)
+	} else {
+		lines = append(lines, t.filterLine())
+		lines = append(lines, t.panes(body)...)
+	}
+	status := t.status
+	switch {
+	case t.confirm != "":
+		status = t.confirm
+	case status == "" && t.form != nil:
+		status = formHelp
+	case status == "":
+		status = tuiHelp
+	}
+	return append(lines, escReverse+fit(status, t.width)+escReset)
+}
+
+func (t *tui) tabsLine() string {
+	var b strings.Builder
+	width := 0
+	for i, k := range model.Kinds() {
+		tab := " " + k.Table() + " "
+		width += utf8.RuneCountInString(tab)
+		if width > t.width {
+			break
+		}
+		if i == t.kindIdx {
+			tab = escReverse + tab + escReset
+		}
+		b.WriteString(tab)
+	}
+	return b.String()
+}
+
+func (t *tui) filterLine() string {
+	switch {
+	case t.filtering:
+		return fit("/"+t.filter+"_", t.width)
+	case t.filter != "":
+		return fit("/"+t.filter+"  (esc clears)", t.width)
+	}
+	return ""
+}
+
+// panes returns lines of the list and the details side by side
+func (t *tui) panes(height int) []string {
+	listWidth := minInt(maxInt(t.width/3, 20), t.width)
+	detailsWidth := maxInt(t.width-listWidth-3, 0)
+	entries := t.entries()
+	if t.selected >= len(entries) {
+		t.selected = maxInt(len(entries)-1, 0)
+	}
+	if t.selected < t.offset {
+		t.offset = t.selected
+	}
+	if t.selected >= t.offset+height {
+		t.offset = t.selected - height + 1
+	}
+	details := t.detailLines(detailsWidth)
+	lines := make([]string, height)
+	for i := range lines {
+		item := strings.Repeat(" ", listWidth)
+		if n := t.offset + i; n < len(entries) {
+			item = fit(entryTitle(entries[n])+"  "+entries[n].Data().Meta, listWidth)
+			if n == t.selected {
+				item = escReverse + item + escReset
+			}
+		}
+		detail := ""
+		if i < len(details) {
+			detail = details[i]
+		}
+		lines[i] = item + " │ " + detail
+	}
+	if len(entries) == 0 {
+		lines[0] = fit("No "+t.kind().Plural()+", press a to add", listWidth) + " │ "
+	}
+	return lines
+}
+
+// entryTitle returns the name of the secret in the list prefixed with its folder, favorites are starred
+func entryTitle(m model.Secret) string {
+	d := m.Data()
+	title := d.Name
+	if d.Folder != "" {
+		title = d.Folder + model.FolderSeparator + title
+	}
+	if d.Favorite {
+		title = "★ " + title
+	}
+	return title
+}
+
+// detailLines returns lines of the selected secret, multi-line values are indented
+func (t *tui) detailLines(width int) []string {
+	if len(t.entries()) == 0 {
+		return nil
+	}
+	m, err := t.current()
+	if err != nil {
+		return []string{fit(err.Error(), width)}
+	}
+	rows := detailRows(m, time.Now())
+	if t.field >= len(rows) {
+		t.field = len(rows) - 1
+	}
+	lines := make([]string, 0, len(rows))
+	for i, r := range rows {
+		value := r.value
+		if r.masked && !t.reveal && value != "" {
+			value = maskedValue
+		}
+		marker := "  "
+		if t.details && i == t.field {
+			marker = "> "
+		}
+		values := strings.Split(strings.TrimRight(value, "\n"), "\n")
+		lines = append(lines, fit(marker+r.label+": "+values[0], width))
+		for _, v := range values[1:] {
+			lines = append(lines, fit("    "+v, width))
+		}
+	}
+	return lines
+}
+
+// detailRow is a row of the details pane, masked rows are shown only when revealed
+type detailRow struct {
+	label  string
+	value  string
+	masked bool
+}
+
+// detailRows returns rows of the decrypted secret, OTP secrets get the current code
+func detailRows(m model.Secret, now time.Time) []detailRow {
+	d := m.Data()
+	rows := []detailRow{{label: "ID", value: strconv.Itoa(d.ID)}, {label: "Name", value: d.Name}}
+	if s, ok := m.(*model.OTPSecret); ok {
+		code, valid, err := s.Code(now)
+		if err != nil {
+			code = err.Error()
+		}
+		rows = append(rows, detailRow{label: "Code", value: fmt.Sprintf("%s (%s)", code, valid.Round(time.Second))})
+	}
+	for _, f := range m.Fields() {
+		rows = append(rows, detailRow{label: f.Label, value: *f.Value, masked: f.Hidden || f.File})
+	}
+	return append(rows,
+		detailRow{label: "Meta", value: d.Meta},
+		detailRow{label: "Folder", value: d.Folder},
+		detailRow{label: "Tags", value: strings.Join(d.Tags, ", ")},
+	)
+}
+
+// filterSecrets returns secrets which name, meta, folder or tags fuzzy match the pattern, best matches first
+func filterSecrets(secrets []model.Secret, pattern string) []model.Secret {
+	if pattern == "" {
+		return secrets
+	}
+	type match struct {
+		m     model.Secret
+		score int
+	}
+	matches := make([]match, 0, len(secrets))
+	for _, m := range secrets {
+		if score, ok := fuzzyScore(pattern, fuzzyText(m)); ok {
+			matches = append(matches, match{m, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	filtered := make([]model.Secret, 0, len(matches))
+	for _, m := range matches {
+		filtered = append(filtered, m.m)
+	}
+	return filtered
+}
+
+// fuzzyText returns the text of the secret fuzzy matched by filters, its name, meta, folder and tags
+func fuzzyText(m model.Secret) string {
+	d := m.Data()
+	return d.Name + " " + d.Meta + " " + d.Folder + " " + strings.Join(d.Tags, " ")
+}
+
+// fuzzyScore matches characters of the pattern in order anywhere in the text ignoring case.
+// Consecutive characters and characters at starts of words score more, earlier matches win ties.
+func fuzzyScore(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	s := []rune(strings.ToLower(text))
+	score, pi, prev, first := 0, 0, -2, 0
+	for i, r := range s {
+		if pi == len(p) {
+			break
+		}
+		if r != p[pi] {
+			continue
+		}
+		if pi == 0 {
+			first = i
+		}
+		score++
+		if prev == i-1 {
+			score += 2
+		}
+		if i == 0 || !unicode.IsLetter(s[i-1]) && !unicode.IsDigit(s[i-1]) {
+			score += 3
+		}
+		prev = i
+		pi++
+	}
+	if pi < len(p) {
+		return 0, false
+	}
+	return score*1000 - first, true
+}
+
+// fit cuts or pads the text to exactly width runes, control characters are replaced
+func fit(text string, width int) string {
+	r := []rune(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text))
+	if len(r) > width {
+		if width > 0 {
+			return string(r[:width-1]) + "…"
+		}
+		return ""
+	}
+	return string(r) + strings.Repeat(" ", width-len(r))
+}
+
+// readKeys sends keys read from r until it fails, then closes keys
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+var escapeKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left", "Z": "backtab",
+	"H": "home", "F": "end", "3~": "delete", "5~": "pgup", "6~": "pgdown",
+}
+
+// parseKeys splits input of the terminal in raw mode into keys. Printable keys are the characters,
+// others are named, e.g. up, enter, ctrl+s.
+func parseKeys(b []byte) []string {
+	keys := make([]string, 0, len(b))
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b && len(b) > 2 && (b[1] == '[' || b[1] == 'O'):
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			if end == len(b) {
+				keys = append(keys, "esc")
+				b = b[1:]
+				continue
+			}
+			if name, ok := escapeKeys[string(b[2:end+1])]; ok {
+				keys = append(keys, name)
+			}
+			b = b[end+1:]
+			continue
+		case c == 0x1b:
+			keys = append(keys, "esc")
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+		case c == '\t':
+			keys = append(keys, "tab")
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+		case c < 0x20:
+			keys = append(keys, "ctrl+"+string(rune('a'+c-1)))
+		default:
+			r, size := utf8.DecodeRune(b)
+			if r != utf8.RuneError {
+				keys = append(keys, string(r))
+			}
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
@@ -0,0 +1,305 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+const formHelp = "tab/↓ next  shift+tab/↑ previous  enter next or save  ctrl+s save  ctrl+r reveal  ctrl+u clear  esc cancel"
+
+// Keys of form inputs which are not payload fields, payload fields are keyed by their columns
+const (
+	formName   = "name"
+	formMeta   = "meta"
+	formFolder = "folder"
+	formTags   = "tags"
+	formURI    = "uri"
+	formFile   = "file"
+)
+
+// formInput is an editable line of the form, key matches keys of validation errors
+type formInput struct {
+	key    string
+	label  string
+	value  string
+	hidden bool
+	// file inputs hold a path, the content of the file is the value
+	file bool
+}
+
+// tuiForm adds a secret or edits the one with id, inputs are checked by Validate of the kind.
+// Version is the version of the edited secret the form is saved over.
+type tuiForm struct {
+	kind    model.Kind
+	id      int
+	version int
+	name    string
+	inputs  []formInput
+	focus   int
+	errors  map[string]string
+}
+
+// newTUIForm returns form filled with the decrypted secret m, file fields of existing secrets
+// are left empty which keeps them
+func newTUIForm(kind model.Kind, m model.Secret, update bool) *tuiForm {
+	d := m.Data()
+	f := &tuiForm{kind: kind, name: d.Name}
+	if update {
+		f.id, f.version = d.ID, d.Version
+	}
+	f.inputs = append(f.inputs, formInput{key: formName, label: "Name", value: d.Name})
+	if _, ok := m.(model.URIImporter); ok && !update {
+		f.inputs = append(f.inputs, formInput{key: formURI, label: "URI (empty to enter fields)"})
+	}
+	for _, field := range m.Fields() {
+		if field.Generated {
+			continue
+		}
+		in := formInput{key: field.Column, label: field.Label, value: *field.Value, hidden: field.Hidden}
+		if field.File {
+			in.file, in.value = true, ""
+			if update {
+				in.label += " (empty to keep)"
+			}
+		}
+		f.inputs = append(f.inputs, in)
+	}
+	if kind.Blob() && !update {
+		f.inputs = append(f.inputs, formInput{key: formFile, label: "File path", file: true})
+	}
+	f.inputs = append(f.inputs,
+		formInput{key: formMeta, label: "Meta", value: d.Meta},
+		formInput{key: formFolder, label: "Folder", value: d.Folder},
+		formInput{key: formTags, label: "Tags (comma separated)", value: strings.Join(d.Tags, ", ")},
+	)
+	return f
+}
+
+func (f *tuiForm) title() string {
+	if f.id == 0 {
+		return "Add " + f.kind.Name()
+	}
+	return fmt.Sprintf("Edit %s %s", f.kind.Name(), f.name)
+}
+
+// fill sets inputs to the secret, fields are overwritten by the URI if it is given
+func (f *tuiForm) fill(m model.Secret) error {
+	uri := ""
+	fields := make(map[string]model.Field)
+	for _, field := range m.Fields() {
+		fields[field.Column] = field
+	}
+	for _, in := range f.inputs {
+		switch in.key {
+		case formName:
+			m.Data().Name = in.value
+		case formMeta:
+			m.Data().Meta = in.value
+		case formFolder:
+			m.Data().Folder = model.CleanFolder(in.value)
+		case formTags:
+			m.Data().Tags = model.ParseTags(in.value)
+		case formURI:
+			uri = in.value
+		case formFile:
+			if _, err := os.Stat(in.value); err != nil {
+				return err
+			}
+			*m.(model.BlobSecret).BlobName() = in.value
+		default:
+			field := fields[in.key]
+			if !in.file {
+				*field.Value = in.value
+				continue
+			}
+			if in.value == "" && f.id != 0 {
+				continue
+			}
+			content, err := os.ReadFile(in.value)
+			if err != nil {
+				return err
+			}
+			*field.Value = string(content)
+		}
+	}
+	if uri != "" {
+		return m.(model.URIImporter).ParseURI(uri)
+	}
+	return nil
+}
+
+// validate checks the secret with rules of its kind, errors of fields are shown next to inputs.
+// New blob secrets are checked by the server when their content is uploaded.
+func (f *tuiForm) validate(m model.Secret) error {
+	f.errors = nil
+	if f.kind.Blob() && f.id == 0 {
+		return nil
+	}
+	if d, ok := m.(model.Deriver); ok {
+		if err := d.Derive(); err != nil {
+			return err
+		}
+	}
+	err := m.Validate()
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		f.errors = make(map[string]string, len(errs))
+		for key, e := range errs {
+			f.errors[key] = e.Error()
+		}
+	}
+	return err
+}
+
+// view returns lines of the form, the focused input has a cursor
+func (f *tuiForm) view(width, height int, reveal bool) []string {
+	labelWidth := 0
+	for _, in := range f.inputs {
+		labelWidth = maxInt(labelWidth, utf8.RuneCountInString(in.label))
+	}
+	labelWidth = minInt(labelWidth, width/2)
+	lines := make([]string, 0, height)
+	for i, in := range f.inputs {
+		value := in.value
+		if in.hidden && !reveal {
+			value = strings.Repeat("•", utf8.RuneCountInString(value))
+		}
+		value = strings.ReplaceAll(value, "\n", "↵")
+		label := fit(in.label, labelWidth)
+		if i == f.focus {
+			label = escReverse + label + escReset
+			value += "_"
+		}
+		// Long values are scrolled to show their end, where the cursor is
+		valueWidth := maxInt(width-labelWidth-2, 0)
+		if r := []rune(value); len(r) > valueWidth && valueWidth > 0 {
+			value = "…" + string(r[len(r)-valueWidth+1:])
+		}
+		lines = append(lines, label+": "+fit(value, valueWidth))
+		if e, ok := f.errors[in.key]; ok {
+			lines = append(lines, fit(strings.Repeat(" ", labelWidth+2)+"! "+e, width))
+		}
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines[:height]
+}
+
+func (t *tui) handleFormKey(ctx context.Context, key string) {
+	f := t.form
+	in := &f.inputs[f.focus]
+	switch key {
+	case "esc":
+		t.form = nil
+	case "tab", "down":
+		f.focus = (f.focus + 1) % len(f.inputs)
+	case "backtab", "up":
+		f.focus = (f.focus + len(f.inputs) - 1) % len(f.inputs)
+	case "enter":
+		if f.focus < len(f.inputs)-1 {
+			f.focus++
+			return
+		}
+		t.saveForm(ctx)
+	case "ctrl+s":
+		t.saveForm(ctx)
+	case "ctrl+r":
+		t.reveal = !t.reveal
+	case "ctrl+u":
+		in.value = ""
+	case "backspace":
+		if r := []rune(in.value); len(r) > 0 {
+			in.value = string(r[:len(r)-1])
+		}
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			in.value += key
+		}
+	}
+}
+
+// saveForm fills a fresh copy of the secret, so a failed attempt leaves nothing half encrypted
+func (t *tui) saveForm(ctx context.Context) {
+	f := t.form
+	m := f.kind.New()
+	if f.id != 0 {
+		var err error
+		if m, err = t.findSecret(f.kind, f.id); err != nil {
+			t.fail(err)
+			return
+		}
+		m.Data().Version = f.version
+	}
+	if err := f.fill(m); err != nil {
+		t.fail(err)
+		return
+	}
+	if err := f.validate(m); err != nil {
+		t.fail(err)
+		return
+	}
+	var err error
+	if f.id == 0 {
+		err = t.addSecret(ctx, f.kind, m)
+	} else {
+		err = t.updateSecret(ctx, f.kind, m)
+	}
+	var conflict *conflictError
+	if errors.As(err, &conflict) {
+		t.showConflict(m, conflict.current)
+		return
+	}
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	t.form = nil
+	t.sync(ctx)
+	t.status = "Saved " + m.Data().Name
+}
+
+// showConflict shows values of the server version under inputs which differ, saving the form
+// again overwrites the server version
+func (t *tui) showConflict(local, current model.Secret) {
+	if err := local.Decrypt(t.key); err != nil {
+		t.fail(err)
+		return
+	}
+	if err := current.Decrypt(t.key); err != nil {
+		t.fail(err)
+		return
+	}
+	f := t.form
+	f.version = current.Data().Version
+	f.errors = make(map[string]string)
+	for _, c := range conflictFields(local, current) {
+		value := c.server
+		if c.hidden && !t.reveal {
+			value = maskedValue
+		}
+		f.errors[c.key] = "server: " + value
+	}
+	t.status = "Changed on another device: ctrl+s saves yours, esc keeps the server version"
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"cenarius/internal/cache/mcache"
+	"cenarius/internal/model"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_fuzzyScore(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		want    bool
+	}{
+		{pattern: "gh", text: "github", want: true},
+		{pattern: "GHB", text: "github", want: true},
+		{pattern: "wrk", text: "gitlab work", want: true},
+		{pattern: "hg", text: "github", want: false},
+		{pattern: "x", text: "github", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.text, func(t *testing.T) {
+			_, ok := fuzzyScore(tt.pattern, tt.text)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+	prefix, _ := fuzzyScore("git", "github")
+	scattered, _ := fuzzyScore("git", "go international travel")
+	assert.Greater(t, prefix, scattered)
+}
+
+func Test_filterSecrets(t *testing.T) {
+	secrets := []model.Secret{
+		&model.SecretText{SecretData: model.SecretData{ID: 1, Name: "notes", Meta: "gift ideas"}},
+		&model.SecretText{SecretData: model.SecretData{ID: 2, Name: "gift card"}},
+		&model.SecretText{SecretData: model.SecretData{ID: 3, Name: "wifi"}},
+	}
+	var ids []int
+	for _, m := range filterSecrets(secrets, "gift") {
+		ids = append(ids, m.Data().ID)
+	}
+	assert.Equal(t, []int{2, 1}, ids)
+	assert.Len(t, filterSecrets(secrets, ""), 3)
+}
+
+func Test_parseKeys(t *testing.T) {
+	keys := parseKeys([]byte("a\x1b[A\x1b[B\r\t\x1b[Z\x7f\x13ё\x1b"))
+	assert.Equal(t, []string{"a", "up", "down", "enter", "tab", "backtab", "backspace", "ctrl+s", "ё", "esc"}, keys)
+}
+
+func Test_fit(t *testing.T) {
+	assert.Equal(t, "ab  ", fit("ab", 4))
+	assert.Equal(t, "abc…", fit("abcdef", 4))
+	assert.Equal(t, "a b ", fit("a\nb", 4))
+}
+
+func testTUI(t *testing.T, secrets ...model.Secret) *tui {
+	key := make([]byte, 32)
+	for _, m := range secrets {
+		if err := m.Encrypt(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &model.SecretCache{}
+	c.Set(model.LoginWithPasswordKind, secrets)
+	a := &agent{logger: logrus.New(), cache: mcache.New(), key: key}
+	if err := a.cache.Cache().Save(c); err != nil {
+		t.Fatal(err)
+	}
+	return &tui{agent: a, width: 100, height: 10}
+}
+
+func Test_tui_view(t *testing.T) {
+	m := &model.LoginWithPassword{SecretData: model.SecretData{ID: 7, Name: "github"}, Login: "octocat", Password: "hunter2"}
+	tu := testTUI(t, m)
+	screen := strings.Join(tu.view(), "\n")
+	assert.Contains(t, screen, "octocat")
+	assert.NotContains(t, screen, "hunter2")
+	assert.Contains(t, screen, maskedValue)
+
+	tu.handleListKey(context.Background(), "r")
+	screen = strings.Join(tu.view(), "\n")
+	assert.Contains(t, screen, "hunter2")
+}
+
+func Test_tuiForm_validate(t *testing.T) {
+	f := newTUIForm(model.CreditCardKind, model.CreditCardKind.New(), false)
+	for i := range f.inputs {
+		switch f.inputs[i].key {
+		case "number":
+			f.inputs[i].value = "4111111111111111"
+		case "cvc":
+			f.inputs[i].value = "12a"
+		}
+	}
+	m := model.CreditCardKind.New()
+	assert.NoError(t, f.fill(m))
+	assert.Error(t, f.validate(m))
+	assert.Contains(t, f.errors, "cvc")
+	assert.NotContains(t, f.errors, "number")
+	assert.Contains(t, strings.Join(f.view(100, 20, false), "\n"), "! ")
+}
@@ -0,0 +1,272 @@
+package agent
+
+import (
+	"bytes"
+	"cenarius/internal/encrypt"
+	"cenarius/internal/model"
+	"cenarius/internal/server"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var errUploadNotFound = errors.New("upload not found on the server")
+
+// pendingUpload is a chunked upload saved locally, so it is resumed after a failure or restart.
+// Encrypted is a local copy of the file encrypted with the file key, it is uploaded chunk by chunk.
+type pendingUpload struct {
+	UploadID  string `json:"upload_id"`
+	Source    string `json:"source"`
+	Encrypted string `json:"encrypted"`
+}
+
+func (a *agent) uploadsFile() string {
+	return a.config.CacheFile + ".uploads"
+}
+
+func (a *agent) readPendingUploads() ([]*pendingUpload, error) {
+	uploads := make([]*pendingUpload, 0)
+	data, err := os.ReadFile(a.uploadsFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return uploads, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &uploads); err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+func (a *agent) savePendingUploads(uploads []*pendingUpload) error {
+	data, err := json.Marshal(uploads)
+	if err != nil {
+		return err
+	}
+	tmp := a.uploadsFile() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.uploadsFile())
+}
+
+func (a *agent) addPendingUpload(p *pendingUpload) error {
+	uploads, err := a.readPendingUploads()
+	if err != nil {
+		return err
+	}
+	return a.savePendingUploads(append(uploads, p))
+}
+
+// finishPendingUpload forgets the upload and removes its encrypted copy
+func (a *agent) finishPendingUpload(p *pendingUpload) error {
+	uploads, err := a.readPendingUploads()
+	if err != nil {
+		return err
+	}
+	left := make([]*pendingUpload, 0, len(uploads))
+	for _, u := range uploads {
+		if u.UploadID != p.UploadID {
+			left = append(left, u)
+		}
+	}
+	if err := os.Remove(p.Encrypted); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		a.logger.Errorf("Unable to remove %s: %s", p.Encrypted, err.Error())
+	}
+	return a.savePendingUploads(left)
+}
+
+// resumeUploads continues uploads interrupted in previous runs
+func (a *agent) resumeUploads(ctx context.Context) {
+	uploads, err := a.readPendingUploads()
+	if err != nil {
+		a.logger.Errorf("Unable to read pending uploads: %s", err.Error())
+		return
+	}
+	for _, p := range uploads {
+		a.logger.Infof("Resuming upload of %s", p.Source)
+		m, err := a.resumeUpload(ctx, p)
+		if errors.Is(err, errUploadNotFound) {
+			a.logger.Errorf("Upload of %s is gone from the server, upload the file again", p.Source)
+			if err := a.finishPendingUpload(p); err != nil {
+				a.logger.Error(err)
+			}
+			continue
+		}
+		if err != nil {
+			a.logger.Errorf("Unable to resume upload of %s: %s", p.Source, err.Error())
+			continue
+		}
+		a.logger.Infof("agent.resumeUploads uploaded: %v", m)
+	}
+}
+
+// encryptFile writes the file encrypted with a new random file key to a temporary file
+// next to the cache and returns its name and the key
+func (a *agent) encryptFile(source string) (string, string, error) {
+	key, err := encrypt.RandomString(encrypt.KeySize)
+	if err != nil {
+		return "", "", err
+	}
+	rawKey, _ := base64.StdEncoding.DecodeString(key)
+	src, err := os.Open(source)
+	if err != nil {
+		return "", "", err
+	}
+	defer src.Close()
+	dst, err := os.CreateTemp(filepath.Dir(a.config.CacheFile), "cenarius-upload-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer dst.Close()
+	w, err := encrypt.NewStreamWriter(dst, rawKey)
+	if err == nil {
+		_, err = io.Copy(w, src)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return "", "", err
+	}
+	return dst.Name(), key, nil
+}
+
+// uploadSecretFile encrypts the file with its own key and uploads it in chunks
+func (a *agent) uploadSecretFile(ctx context.Context, m *model.SecretFile) error {
+	encrypted, key, err := a.encryptFile(m.Path)
+	if err != nil {
+		return err
+	}
+	// The file key is stored on the server encrypted with the vault key
+	m.Key = key
+	if err := m.Encrypt(a.key); err != nil {
+		os.Remove(encrypted)
+		return err
+	}
+	stat, err := os.Stat(encrypted)
+	if err != nil {
+		os.Remove(encrypted)
+		return err
+	}
+	upload := &model.Upload{Name: m.Name, Meta: m.Meta, Key: m.Key, Size: stat.Size(), Labels: m.Labels}
+	data, s, err := a.sendRequest2(ctx, uploadURI, http.MethodPost, upload)
+	if err == nil && s != http.StatusCreated {
+		a.logger.Errorf("agent.uploadSecretFile init failed %d: %s", s, string(data))
+		err = errBadHTTPStatusCode
+	}
+	if err == nil {
+		err = json.Unmarshal(data, upload)
+	}
+	if err != nil {
+		os.Remove(encrypted)
+		return err
+	}
+	p := &pendingUpload{UploadID: upload.ID, Source: m.Path, Encrypted: encrypted}
+	if err := a.addPendingUpload(p); err != nil {
+		a.logger.Errorf("Unable to save upload state: %s", err.Error())
+	}
+	responseM, err := a.resumeUpload(ctx, p)
+	if err != nil {
+		a.logger.Errorf("Upload of %s is interrupted, it will be resumed on the next start", m.Path)
+		return err
+	}
+	a.logger.Infof("agent.uploadSecretFile uploaded: %v", responseM)
+	m.ID = responseM.ID
+	m.UserID = responseM.UserID
+	return nil
+}
+
+// resumeUpload sends chunks the server has not received yet and completes the upload
+func (a *agent) resumeUpload(ctx context.Context, p *pendingUpload) (*model.SecretFile, error) {
+	uri := fmt.Sprintf("%s/%s", uploadURI, p.UploadID)
+	data, s, err := a.sendRequest2(ctx, uri, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s == http.StatusNotFound {
+		return nil, errUploadNotFound
+	}
+	if s != http.StatusOK {
+		return nil, errBadHTTPStatusCode
+	}
+	upload := &model.Upload{}
+	if err := json.Unmarshal(data, upload); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p.Encrypted)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	received := make(map[int]bool, len(upload.Received))
+	for _, index := range upload.Received {
+		received[index] = true
+	}
+	buf := make([]byte, upload.ChunkSize)
+	for index := 0; index < upload.Chunks(); index++ {
+		if received[index] {
+			continue
+		}
+		chunk := buf[:upload.ChunkLen(index)]
+		if _, err := f.ReadAt(chunk, int64(index)*int64(upload.ChunkSize)); err != nil {
+			return nil, err
+		}
+		if err := a.putChunk(ctx, uri, index, chunk); err != nil {
+			return nil, err
+		}
+		a.logger.Debugf("Chunk %d/%d of %s uploaded", index+1, upload.Chunks(), p.Source)
+	}
+	data, s, err = a.sendRequest2(ctx, uri+"/complete", http.MethodPost, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s != http.StatusCreated {
+		a.logger.Errorf("agent.resumeUpload complete failed %d: %s", s, string(data))
+		return nil, errBadHTTPStatusCode
+	}
+	m := &model.SecretFile{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if err := a.finishPendingUpload(p); err != nil {
+		a.logger.Errorf("Unable to save upload state: %s", err.Error())
+	}
+	return m, nil
+}
+
+func (a *agent) putChunk(ctx context.Context, uri string, index int, chunk []byte) error {
+	req, err := a.getRequest(ctx, http.MethodPut, a.geHTTPtURL(fmt.Sprintf("%s/%d", uri, index)), bytes.NewBuffer(chunk))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(chunk)
+	req.Header.Set(server.ChunkChecksumHeader, hex.EncodeToString(sum[:]))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Del("Content-Encoding")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		a.logger.Errorf("agent.putChunk %d failed %s: %s", index, resp.Status, string(body))
+		return errBadHTTPStatusCode
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"bufio"
+	"cenarius/internal/model"
+	"cenarius/internal/server"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// watchRetry is how long the watcher waits before it opens a broken event stream again
+const watchRetry = 5 * time.Second
+
+var errPasswordChanged = errors.New("master password was changed on another device, restart the agent")
+
+// watcher is the long-running mode of the agent, it keeps the cache current from change events of the server
+type watcher struct {
+	*agent
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewWatcher(config *Config) *watcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{agent: NewAgent(config), ctx: ctx, cancel: cancel}
+}
+
+// Start watches changes until Shutdown
+func (w *watcher) Start() error {
+	if err := w.connect(w.ctx); err != nil {
+		return err
+	}
+	defer w.close()
+	return w.watch(w.ctx)
+}
+
+// Shutdown stops watching, the cache is saved by Start
+func (w *watcher) Shutdown() {
+	w.cancel()
+}
+
+// watch applies change events of the server to the cache until ctx is done. A broken stream is
+// opened again, the cache catches up on changes missed meanwhile when it is open.
+func (a *agent) watch(ctx context.Context) error {
+	for {
+		if a.onlineMode || a.reconnect(ctx) {
+			err := a.streamEvents(ctx)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, errPasswordChanged) {
+				return err
+			}
+			if err != nil {
+				a.logger.Warnf("Event stream is broken: %s", err.Error())
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchRetry):
+		}
+	}
+}
+
+// streamEvents reads the event stream of the server, the cache is synced when the stream is open
+// and on every change
+func (a *agent) streamEvents(ctx context.Context) error {
+	req, err := a.getRequest(ctx, http.MethodGet, a.geHTTPtURL(eventsURI), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		if isOffline(err) {
+			a.onlineMode = false
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// The session is renewed by logging in again
+		a.onlineMode = false
+		return fmt.Errorf("%w %d", errBadHTTPStatusCode, resp.StatusCode)
+	}
+	a.logger.Info("Watching changes")
+	a.syncCache(ctx)
+	lines := bufio.NewScanner(resp.Body)
+	event, data := "", ""
+	for lines.Scan() {
+		line := lines.Text()
+		switch {
+		case line == "":
+			if event == server.EventChange {
+				if err := a.handleChange(ctx, data); err != nil {
+					return err
+				}
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	return lines.Err()
+}
+
+// handleChange syncs the cache unless it already has the revision of the change
+func (a *agent) handleChange(ctx context.Context, data string) error {
+	e := &model.ChangeEvent{}
+	if err := json.Unmarshal([]byte(data), e); err != nil {
+		return err
+	}
+	if e.Op == model.EventPassword {
+		return errPasswordChanged
+	}
+	a.logger.Infof("Server %s %s %d", e.Op, e.Kind, e.ID)
+	if c, err := a.cache.Cache().Get(); err == nil && c != nil && e.Revision > 0 && c.Revision >= e.Revision {
+		return nil
+	}
+	a.syncCache(ctx)
+	return nil
+}
+
+func (a *agent) syncCache(ctx context.Context) {
+	if err := a.updateCache(ctx); err != nil {
+		a.logger.Errorf("Unable to update cache: %s", err.Error())
+	}
+}
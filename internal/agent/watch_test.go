@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_agent_streamEvents(t *testing.T) {
+	var since []string
+	events := []string{
+		`{"op":"update","kind":"secrettext","id":1,"revision":11}`,
+		`{"op":"add","kind":"secrettext","id":2,"revision":12}`,
+		`{"op":"password"}`,
+	}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/"+syncURI {
+			since = append(since, r.URL.Query().Get("since"))
+			rev, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+			changes := model.NewSyncChanges(rev)
+			if rev < 11 {
+				changes.Add(model.SecretTextKind, []model.Secret{&model.SecretText{SecretData: model.SecretData{ID: 1, Revision: 11}}}, nil)
+			}
+			_ = json.NewEncoder(w).Encode(changes)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": connected\n\n")
+		for _, e := range events {
+			fmt.Fprintf(w, "event: change\ndata: %s\n\n", e)
+		}
+	}))
+	defer srv.Close()
+	a := testOnlineAgent(t, srv)
+	if err := a.cache.Cache().Save(&model.SecretCache{Revision: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := a.streamEvents(context.Background())
+	assert.ErrorIs(t, err, errPasswordChanged)
+	// The stream catches up on open, the change it already has is skipped
+	assert.Equal(t, []string{"10", "11"}, since)
+	c, err := a.store.Cache().Get()
+	if assert.NoError(t, err) {
+		assert.Len(t, c.Get(model.SecretTextKind), 1)
+	}
+}
+
+func Test_agent_watch(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	a := testOnlineAgent(t, srv)
+	srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, a.watch(ctx))
+	assert.False(t, a.onlineMode)
+}
@@ -0,0 +1,291 @@
+// Package archive writes and reads portable backups of a vault: plain secrets of every kind and content
+// of files encrypted with a key derived from a passphrase, so they are restored to any account on any server.
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"cenarius/internal/encrypt"
+	"cenarius/internal/model"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Archive format:
+//
+//	header: magic "CNRA" | version | length of parameters (uint32 BE) | parameters of the KDF (JSON)
+//	body:   encrypt stream of a tar of manifest.json, secrets.json and files/<n> with content of files
+//
+// The key of the stream is derived from the passphrase by Argon2id with the salt and parameters of the header,
+// so a wrong passphrase fails to open the first chunk. Secrets are kept in secrets.json as the agent shows them,
+// file secrets name their content in the tar.
+const (
+	Version = 1
+
+	magic         = "CNRA"
+	kdfArgon2id   = "argon2id"
+	kdfTime       = 3
+	kdfMemory     = 64 * 1024
+	kdfThreads    = 4
+	maxKDFTime    = 64
+	maxKDFMemory  = 1024 * 1024
+	saltSize      = 32
+	maxParamsSize = 4096
+	manifestName  = "manifest.json"
+	secretsName   = "secrets.json"
+)
+
+var (
+	ErrNotArchive         = errors.New("not a cenarius archive")
+	ErrUnsupportedVersion = errors.New("archive was made by a newer agent, update the agent to restore it")
+	ErrWrongPassphrase    = errors.New("wrong passphrase or corrupted archive")
+	ErrMissingFile        = errors.New("content of a file is missing in the archive")
+)
+
+// kdfParams derive the key of the archive from the passphrase
+type kdfParams struct {
+	Algorithm string `json:"algorithm"`
+	Time      uint32 `json:"time"`
+	Memory    uint32 `json:"memory"`
+	Threads   uint8  `json:"threads"`
+	Salt      []byte `json:"salt"`
+}
+
+// valid reports whether the parameters read from the header are within limits, so a crafted archive
+// can't make Open allocate unbounded memory or spin before the passphrase is checked
+func (p *kdfParams) valid() bool {
+	return p.Time > 0 && p.Time <= maxKDFTime && p.Memory <= maxKDFMemory && p.Threads > 0
+}
+
+func (p *kdfParams) key(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), p.Salt, p.Time, p.Memory, p.Threads, encrypt.KeySize)
+}
+
+// Manifest describes the archive, Secrets are numbers of secrets by kind
+type Manifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Secrets   map[string]int `json:"secrets"`
+	Files     int            `json:"files"`
+}
+
+// Item is a plain secret of the archive, File names content of a file secret in the archive
+type Item struct {
+	Kind   model.Kind
+	Secret model.Secret
+	File   string
+}
+
+type storedItem struct {
+	Kind   string          `json:"kind"`
+	File   string          `json:"file,omitempty"`
+	Secret json.RawMessage `json:"secret"`
+}
+
+// Writer writes the archive, content of every file secret must be added before it is closed
+type Writer struct {
+	Manifest Manifest
+	stream   *encrypt.StreamWriter
+	tar      *tar.Writer
+	files    map[string]bool
+}
+
+// NewWriter writes the header, the manifest and the secrets of the archive to w. File secrets of items get
+// names of their content, which is added by AddFile. Blob names and keys of files are not kept, content is.
+func NewWriter(w io.Writer, passphrase string, items []Item) (*Writer, error) {
+	params := &kdfParams{Algorithm: kdfArgon2id, Time: kdfTime, Memory: kdfMemory, Threads: kdfThreads, Salt: make([]byte, saltSize)}
+	if _, err := io.ReadFull(rand.Reader, params.Salt); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(magic), Version)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(data)))
+	if _, err := w.Write(append(header, data...)); err != nil {
+		return nil, err
+	}
+	stream, err := encrypt.NewStreamWriter(w, params.key(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	aw := &Writer{stream: stream, tar: tar.NewWriter(stream), files: make(map[string]bool)}
+	manifest := &aw.Manifest
+	*manifest = Manifest{Version: Version, CreatedAt: time.Now().UTC(), Secrets: make(map[string]int)}
+	stored := make([]storedItem, 0, len(items))
+	for i := range items {
+		item := &items[i]
+		if item.Kind.Blob() {
+			item.File = fmt.Sprintf("files/%d", manifest.Files)
+			aw.files[item.File] = false
+			manifest.Files++
+		}
+		data, err := portable(item.Kind, item.Secret)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, storedItem{Kind: item.Kind.Name(), File: item.File, Secret: data})
+		manifest.Secrets[item.Kind.Name()]++
+	}
+	if err := aw.addJSON(manifestName, manifest); err != nil {
+		return nil, err
+	}
+	if err := aw.addJSON(secretsName, stored); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// portable returns the secret without data of the account and the server it is kept on:
+// ids, versions, names of blobs and keys of files, files are encrypted with new keys when restored
+func portable(kind model.Kind, m model.Secret) ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	c := kind.New()
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	d := c.Data()
+	d.ID, d.UserID, d.Version, d.Revision, d.ClientID, d.DeletedAt = 0, 0, 0, 0, "", nil
+	if f, ok := c.(*model.SecretFile); ok {
+		f.Path, f.Key = "", ""
+	}
+	return json.Marshal(c)
+}
+
+func (w *Writer) addJSON(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return w.add(name, int64(len(data)), bytes.NewReader(data))
+}
+
+func (w *Writer) add(name string, size int64, content io.Reader) error {
+	if err := w.tar.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: size, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	_, err := io.CopyN(w.tar, content, size)
+	return err
+}
+
+// AddFile adds size bytes of content of the file secret named by NewWriter
+func (w *Writer) AddFile(name string, size int64, content io.Reader) error {
+	if added, ok := w.files[name]; !ok || added {
+		return fmt.Errorf("archive has no file %s or it is added already", name)
+	}
+	if err := w.add(name, size, content); err != nil {
+		return err
+	}
+	w.files[name] = true
+	return nil
+}
+
+// Close seals the archive, ErrMissingFile is returned if content of a file secret was not added
+func (w *Writer) Close() error {
+	for _, added := range w.files {
+		if !added {
+			return ErrMissingFile
+		}
+	}
+	if err := w.tar.Close(); err != nil {
+		return err
+	}
+	return w.stream.Close()
+}
+
+// Reader reads the archive, its manifest and secrets are read when it is opened, content of files follows
+type Reader struct {
+	Manifest Manifest
+	Items    []Item
+	tar      *tar.Reader
+}
+
+// Open reads the header, the manifest and the secrets of the archive of the size
+func Open(r io.ReaderAt, size int64, passphrase string) (*Reader, error) {
+	header := make([]byte, len(magic)+1+4)
+	if _, err := r.ReadAt(header, 0); err != nil || !bytes.Equal(header[:len(magic)], []byte(magic)) {
+		return nil, ErrNotArchive
+	}
+	if header[len(magic)] > Version {
+		return nil, ErrUnsupportedVersion
+	}
+	n := int64(binary.BigEndian.Uint32(header[len(magic)+1:]))
+	if n > maxParamsSize || int64(len(header))+n > size {
+		return nil, ErrNotArchive
+	}
+	data := make([]byte, n)
+	if _, err := r.ReadAt(data, int64(len(header))); err != nil {
+		return nil, ErrNotArchive
+	}
+	params := &kdfParams{}
+	if err := json.Unmarshal(data, params); err != nil || params.Algorithm != kdfArgon2id || !params.valid() {
+		return nil, ErrNotArchive
+	}
+	offset := int64(len(header)) + n
+	stream, err := encrypt.NewStreamReader(io.NewSectionReader(r, offset, size-offset), size-offset, params.key(passphrase))
+	if err != nil {
+		return nil, ErrNotArchive
+	}
+	ar := &Reader{tar: tar.NewReader(stream)}
+	if err := ar.readJSON(manifestName, &ar.Manifest); err != nil {
+		return nil, err
+	}
+	if ar.Manifest.Version > Version {
+		return nil, ErrUnsupportedVersion
+	}
+	stored := make([]storedItem, 0)
+	if err := ar.readJSON(secretsName, &stored); err != nil {
+		return nil, err
+	}
+	for _, s := range stored {
+		kind, ok := model.KindByName(s.Kind)
+		if !ok {
+			return nil, fmt.Errorf("archive has secrets of kind %s unknown to this agent, update the agent", s.Kind)
+		}
+		m := kind.New()
+		if err := json.Unmarshal(s.Secret, m); err != nil {
+			return nil, err
+		}
+		ar.Items = append(ar.Items, Item{Kind: kind, Secret: m, File: s.File})
+	}
+	return ar, nil
+}
+
+func (r *Reader) readJSON(name string, v any) error {
+	h, err := r.tar.Next()
+	if err != nil {
+		return readError(err)
+	}
+	if h.Name != name {
+		return fmt.Errorf("%w: %s is missing", ErrNotArchive, name)
+	}
+	return readError(json.NewDecoder(r.tar).Decode(v))
+}
+
+// NextFile returns the name and the content of the next file of the archive, io.EOF follows the last one
+func (r *Reader) NextFile() (string, io.Reader, error) {
+	h, err := r.tar.Next()
+	if err != nil {
+		return "", nil, readError(err)
+	}
+	return h.Name, r.tar, nil
+}
+
+// readError tells a wrong passphrase from other errors, the first chunk of the archive fails to open with it
+func readError(err error) error {
+	if errors.Is(err, encrypt.ErrAuthenticationFailed) {
+		return ErrWrongPassphrase
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"bytes"
+	"cenarius/internal/model"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testArchive(t *testing.T, passphrase string) []byte {
+	items := []Item{
+		{Kind: model.LoginWithPasswordKind, Secret: &model.LoginWithPassword{
+			SecretData: model.SecretData{ID: 7, UserID: 2, Version: 3, Name: "GitHub", Labels: model.Labels{Folder: "Work"}},
+			Login:      "octocat", Password: "hunter2"}},
+		{Kind: model.SecretFileKind, Secret: &model.SecretFile{
+			SecretData: model.SecretData{ID: 8, UserID: 2, Name: "codes.txt"}, Path: "blob-of-the-server", Key: "key"}},
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, passphrase, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, w.Close(), ErrMissingFile)
+	assert.Error(t, w.AddFile("files/9", 1, bytes.NewReader([]byte("x"))))
+	if err := w.AddFile(items[1].File, 9, bytes.NewReader([]byte("1234-5678"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchive(t *testing.T) {
+	data := testArchive(t, "correct horse")
+	r, err := Open(bytes.NewReader(data), int64(len(data)), "correct horse")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, Version, r.Manifest.Version)
+	assert.Equal(t, map[string]int{"loginwithpassword": 1, "secretfile": 1}, r.Manifest.Secrets)
+	assert.Equal(t, 1, r.Manifest.Files)
+	if !assert.Len(t, r.Items, 2) {
+		return
+	}
+	login := r.Items[0].Secret.(*model.LoginWithPassword)
+	assert.Equal(t, "hunter2", login.Password)
+	assert.Equal(t, "Work", login.Folder)
+	assert.Zero(t, login.ID)
+	assert.Zero(t, login.UserID)
+	assert.Zero(t, login.Version)
+	file := r.Items[1].Secret.(*model.SecretFile)
+	assert.Equal(t, "codes.txt", file.Name)
+	assert.Empty(t, file.Path)
+	assert.Empty(t, file.Key)
+
+	name, content, err := r.NextFile()
+	if assert.NoError(t, err) {
+		assert.Equal(t, r.Items[1].File, name)
+		b, _ := io.ReadAll(content)
+		assert.Equal(t, "1234-5678", string(b))
+	}
+	_, _, err = r.NextFile()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestOpen_errors(t *testing.T) {
+	data := testArchive(t, "correct horse")
+	_, err := Open(bytes.NewReader(data), int64(len(data)), "wrong horse")
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+
+	newer := append([]byte(nil), data...)
+	newer[len(magic)] = Version + 1
+	_, err = Open(bytes.NewReader(newer), int64(len(newer)), "correct horse")
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	_, err = Open(bytes.NewReader([]byte("PK\x03\x04")), 4, "correct horse")
+	assert.ErrorIs(t, err, ErrNotArchive)
+
+	// Hostile key derivation parameters are refused before the key is derived
+	for _, params := range []string{
+		`{"algorithm": "argon2id", "time": 1, "memory": 4294967295, "threads": 4, "salt": "AAAA"}`,
+		`{"algorithm": "argon2id", "time": 4294967295, "memory": 64, "threads": 4, "salt": "AAAA"}`,
+		`{"algorithm": "argon2id", "time": 0, "memory": 64, "threads": 4, "salt": "AAAA"}`,
+	} {
+		hostile := append([]byte(magic), Version, 0, 0, 0, byte(len(params)))
+		hostile = append(hostile, params...)
+		_, err = Open(bytes.NewReader(hostile), int64(len(hostile)), "correct horse")
+		assert.ErrorIs(t, err, ErrNotArchive, params)
+	}
+
+	truncated := data[:len(data)-10]
+	_, err = Open(bytes.NewReader(truncated), int64(len(truncated)), "correct horse")
+	assert.Error(t, err)
+}
@@ -13,13 +13,8 @@ func TestMCacheRepo(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "Valid",
-			args: &model.SecretCache{
-				LoginWithPasswords: []*model.LoginWithPassword{{Login: "TestLogin", Password: "TestPassword"}, {Login: "TestLogin2", Password: "TestPassword2"}},
-				CreditCards:        []*model.CreditCard{{CVC: "323", Number: "239209355363"}, {CVC: "313", Number: "239376832093"}},
-				SecretTexts:        []*model.SecretText{{Text: "Some Secret text"}, {Text: "another very secret test text"}},
-				SecretFiles:        []*model.SecretFile{{Path: "/Some/Path"}, {Path: "another/path"}},
-			},
+			name:    "Valid",
+			args:    testCache(),
 			wantErr: false,
 		},
 	}
@@ -42,3 +37,12 @@ func TestMCacheRepo(t *testing.T) {
 		})
 	}
 }
+
+func testCache() *model.SecretCache {
+	c := &model.SecretCache{}
+	c.Set(model.LoginWithPasswordKind, []model.Secret{&model.LoginWithPassword{Login: "TestLogin", Password: "TestPassword"}, &model.LoginWithPassword{Login: "TestLogin2", Password: "TestPassword2"}})
+	c.Set(model.CreditCardKind, []model.Secret{&model.CreditCard{CVC: "323", Number: "239209355363"}, &model.CreditCard{CVC: "313", Number: "239376832093"}})
+	c.Set(model.SecretTextKind, []model.Secret{&model.SecretText{Text: "Some Secret text"}, &model.SecretText{Text: "another very secret test text"}})
+	c.Set(model.SecretFileKind, []model.Secret{&model.SecretFile{Path: "/Some/Path"}, &model.SecretFile{Path: "another/path"}})
+	return c
+}
@@ -0,0 +1,77 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Ciphertext envelope versions. Values without a version prefix are
+// legacy unauthenticated AES-CBC ones, Open refuses them and OpenLegacy reads them.
+const (
+	versionGCM = "v2"
+
+	versionSeparator = ":"
+)
+
+// Seal encrypts plaintext with AES-256-GCM using a random nonce and
+// returns versioned envelope "v2:base64(nonce|ciphertext|tag)"
+func Seal(plaintext string, key []byte) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return versionGCM + versionSeparator + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts and verifies the envelope made by Seal
+func Open(envelope string, key []byte) (string, error) {
+	version, data, found := strings.Cut(envelope, versionSeparator)
+	if !found {
+		return "", fmt.Errorf("%w: unversioned legacy value", ErrUnsupportedVersion)
+	}
+	if version != versionGCM {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", ErrMalformedCiphertext
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", ErrMalformedCiphertext
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrAuthenticationFailed
+	}
+	return string(plaintext), nil
+}
+
+// Versioned reports whether the value is an envelope, legacy values have no version
+func Versioned(value string) bool {
+	return strings.Contains(value, versionSeparator)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKeySize
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
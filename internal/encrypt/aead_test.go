@@ -0,0 +1,107 @@
+package encrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := []byte("f1c68defcac1715234f1b9a9906c0a7c")
+	sealed, err := Seal("Valid test", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Seal("Valid test", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed == again {
+		t.Error("Seal() reuses nonce")
+	}
+	badPadding, err := AESEncrypted("0123456789abcd\x02\x03", string(key), string(key[0:16]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, "v2:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 1
+	tampered := "v2:" + base64.StdEncoding.EncodeToString(raw)
+	tests := []struct {
+		name     string
+		envelope string
+		key      []byte
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "Valid",
+			envelope: sealed,
+			key:      key,
+			want:     "Valid test",
+		},
+		{
+			name:     "Legacy",
+			envelope: "YiN/N9QtBJtmjYu6rxL3cA==",
+			key:      key,
+			wantErr:  ErrUnsupportedVersion,
+		},
+		{
+			name:     "WrongKey",
+			envelope: sealed,
+			key:      []byte("f1c68defcac1715234f1b9a9906c0a7d"),
+			wantErr:  ErrAuthenticationFailed,
+		},
+		{
+			name:     "Tampered",
+			envelope: tampered,
+			key:      key,
+			wantErr:  ErrAuthenticationFailed,
+		},
+		{
+			name:     "Truncated",
+			envelope: "v2:AAAA",
+			key:      key,
+			wantErr:  ErrMalformedCiphertext,
+		},
+		{
+			name:     "UnknownVersion",
+			envelope: "v9:" + strings.TrimPrefix(sealed, "v2:"),
+			key:      key,
+			wantErr:  ErrUnsupportedVersion,
+		},
+		{
+			name:     "ShortKey",
+			envelope: sealed,
+			key:      []byte("short"),
+			wantErr:  ErrInvalidKeySize,
+		},
+		{
+			name:     "EmptyLegacy",
+			envelope: "",
+			key:      key,
+			wantErr:  ErrUnsupportedVersion,
+		},
+		{
+			name:     "BadPaddingLegacy",
+			envelope: badPadding,
+			key:      key,
+			wantErr:  ErrUnsupportedVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Open(tt.envelope, tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Open() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Open() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
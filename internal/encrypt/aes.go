@@ -7,7 +7,8 @@ import (
 	"encoding/base64"
 )
 
-// GetAESDecrypted decrypts given text in AES 256 CBC
+// AESDecrypted decrypts given text in AES 256 CBC.
+// It is unauthenticated and is kept for AES-CBC compatibility only, use Open instead.
 func AESDecrypted(encrypted, key, iv string) (string, error) {
 	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
 	if err != nil {
@@ -17,25 +18,54 @@ func AESDecrypted(encrypted, key, iv string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if len(ciphertext) == 0 {
+		return "", ErrMalformedCiphertext
+	}
 	if len(ciphertext)%aes.BlockSize != 0 {
 		return "", ErrZeroBlockSize
 	}
+	if len(iv) != aes.BlockSize {
+		return "", ErrInvalidKeySize
+	}
 	mode := cipher.NewCBCDecrypter(block, []byte(iv))
 	mode.CryptBlocks(ciphertext, ciphertext)
-	ciphertext = PKCS5UnPadding(ciphertext)
+	ciphertext, err = PKCS5UnPadding(ciphertext)
+	if err != nil {
+		return "", err
+	}
 
 	return string(ciphertext[:]), nil
 }
 
-// PKCS5UnPadding  pads a certain blob of data with necessary data to be used in AES block cipher
-func PKCS5UnPadding(src []byte) []byte {
+// OpenLegacy decrypts a value the server sealed before vault keys. Its AES-CBC key and IV were
+// the first 32 and 16 bytes of the bcrypt hash of the master password of the user.
+func OpenLegacy(value, passwordHash string) (string, error) {
+	if len(passwordHash) < 32 {
+		return "", ErrInvalidKeySize
+	}
+	return AESDecrypted(value, passwordHash[:32], passwordHash[:16])
+}
+
+// PKCS5UnPadding removes padding added by AESEncrypted.
+// AESEncrypted doesn't pad block aligned text, so such text is returned as is.
+func PKCS5UnPadding(src []byte) ([]byte, error) {
 	length := len(src)
+	if length == 0 {
+		return nil, ErrInvalidPadding
+	}
 	unpadding := int(src[length-1])
-
-	return src[:(length - unpadding)]
+	if unpadding == 0 || unpadding >= aes.BlockSize || unpadding > length {
+		return src, nil
+	}
+	if !bytes.Equal(src[length-unpadding:], bytes.Repeat([]byte{uint8(unpadding)}, unpadding)) {
+		return nil, ErrInvalidPadding
+	}
+	return src[:(length - unpadding)], nil
 }
 
-// GetAESEncrypted encrypts given text in AES 256 CBC
+// AESEncrypted encrypts given text in AES 256 CBC
+//
+// Deprecated: CBC with a static IV is not authenticated, use Seal.
 func AESEncrypted(plaintext, key, iv string) (string, error) {
 	var plainTextBlock []byte
 	length := len(plaintext)
@@ -97,3 +97,21 @@ func TestAESDecrypted(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenLegacy(t *testing.T) {
+	hash := "$2a$10$f1c68defcac1715234f1b9a9906c0a7c"
+	encrypted, err := AESEncrypted("Valid test", hash[:32], hash[:16])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Versioned(encrypted) {
+		t.Errorf("Versioned(%q) = true, want false", encrypted)
+	}
+	got, err := OpenLegacy(encrypted, hash)
+	if err != nil || got != "Valid test" {
+		t.Errorf("OpenLegacy() = %q, %v, want %q", got, err, "Valid test")
+	}
+	if _, err := OpenLegacy(encrypted, hash[:31]); err != ErrInvalidKeySize {
+		t.Errorf("OpenLegacy() short hash error = %v, want %v", err, ErrInvalidKeySize)
+	}
+}
@@ -3,5 +3,11 @@ package encrypt
 import "errors"
 
 var (
-	ErrZeroBlockSize = errors.New("block size cant be zero")
+	ErrZeroBlockSize           = errors.New("block size cant be zero")
+	ErrInvalidPadding          = errors.New("invalid padding")
+	ErrInvalidKeySize          = errors.New("invalid key size")
+	ErrMalformedCiphertext     = errors.New("malformed ciphertext")
+	ErrUnsupportedVersion      = errors.New("unsupported ciphertext version")
+	ErrAuthenticationFailed    = errors.New("message authentication failed")
+	ErrUnsupportedOTPAlgorithm = errors.New("unsupported OTP algorithm")
 )
@@ -0,0 +1,50 @@
+package encrypt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Argon2id parameters used to derive the vault key from the master password
+const (
+	kdfTime    = 1
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+	KeySize    = 32
+	saltSize   = 16
+	authInfo   = "cenarius auth"
+)
+
+// DeriveKey derives the vault key from the master password and per-user salt with Argon2id
+func DeriveKey(password, salt string) []byte {
+	return argon2.IDKey([]byte(password), []byte(salt), kdfTime, kdfMemory, kdfThreads, KeySize)
+}
+
+// AuthKey derives the key the agent logs in with from the vault key by HKDF-SHA256. The server keeps only
+// a hash of it and never sees the master password, the vault key can't be recovered from the auth key.
+func AuthKey(vaultKey []byte) (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, vaultKey, nil, []byte(authInfo)), key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// NewSalt returns new random salt for DeriveKey
+func NewSalt() (string, error) {
+	return RandomString(saltSize)
+}
+
+// RandomString returns n random bytes encoded in base64
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
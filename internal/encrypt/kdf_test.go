@@ -0,0 +1,51 @@
+package encrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDeriveKey(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSalt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := DeriveKey("master password", salt)
+	if len(key) != KeySize {
+		t.Errorf("DeriveKey() len = %d, want %d", len(key), KeySize)
+	}
+	if !bytes.Equal(key, DeriveKey("master password", salt)) {
+		t.Error("DeriveKey() is not deterministic")
+	}
+	if bytes.Equal(key, DeriveKey("master password", otherSalt)) {
+		t.Error("DeriveKey() returns same key for different salts")
+	}
+	if bytes.Equal(key, DeriveKey("another password", salt)) {
+		t.Error("DeriveKey() returns same key for different passwords")
+	}
+}
+
+func TestAuthKey(t *testing.T) {
+	vaultKey := DeriveKey("master password", "salt")
+	auth, err := AuthKey(vaultKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := AuthKey(vaultKey)
+	if auth != again {
+		t.Error("AuthKey() is not deterministic")
+	}
+	other, _ := AuthKey(DeriveKey("master password", "another salt"))
+	if auth == other {
+		t.Error("AuthKey() returns same key for different vault keys")
+	}
+	if strings.Contains(auth, base64.StdEncoding.EncodeToString(vaultKey)) {
+		t.Error("AuthKey() reveals the vault key")
+	}
+}
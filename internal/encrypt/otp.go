@@ -0,0 +1,61 @@
+package encrypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// OTP hash algorithms of otpauth URIs
+const (
+	OTPAlgorithmSHA1   = "SHA1"
+	OTPAlgorithmSHA256 = "SHA256"
+	OTPAlgorithmSHA512 = "SHA512"
+)
+
+func otpHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToUpper(algorithm) {
+	case OTPAlgorithmSHA1:
+		return sha1.New, nil
+	case OTPAlgorithmSHA256:
+		return sha256.New, nil
+	case OTPAlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedOTPAlgorithm, algorithm)
+}
+
+// HOTP returns the counter based one-time password of RFC 4226
+func HOTP(key []byte, counter uint64, digits int, algorithm string) (string, error) {
+	h, err := otpHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(h, key)
+	_ = binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod), nil
+}
+
+// TOTP returns the time based one-time password of RFC 6238 valid at t and how long it stays valid
+func TOTP(key []byte, t time.Time, period time.Duration, digits int, algorithm string) (string, time.Duration, error) {
+	step := uint64(t.Unix()) / uint64(period/time.Second)
+	code, err := HOTP(key, step, digits, algorithm)
+	if err != nil {
+		return "", 0, err
+	}
+	next := time.Unix(int64(step+1)*int64(period/time.Second), 0)
+	return code, next.Sub(t), nil
+}
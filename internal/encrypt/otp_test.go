@@ -0,0 +1,47 @@
+package encrypt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTOTP(t *testing.T) {
+	// Test vectors of RFC 6238, keys are the ASCII seeds of the reference implementation
+	keys := map[string][]byte{
+		OTPAlgorithmSHA1:   []byte("12345678901234567890"),
+		OTPAlgorithmSHA256: []byte("12345678901234567890123456789012"),
+		OTPAlgorithmSHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		unix      int64
+		algorithm string
+		want      string
+	}{
+		{59, OTPAlgorithmSHA1, "94287082"},
+		{59, OTPAlgorithmSHA256, "46119246"},
+		{59, OTPAlgorithmSHA512, "90693936"},
+		{1111111109, OTPAlgorithmSHA1, "07081804"},
+		{1111111109, OTPAlgorithmSHA256, "68084774"},
+		{1111111109, OTPAlgorithmSHA512, "25091201"},
+		{1234567890, OTPAlgorithmSHA1, "89005924"},
+		{2000000000, OTPAlgorithmSHA256, "90698825"},
+		{20000000000, OTPAlgorithmSHA512, "47863826"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm+"/"+tt.want, func(t *testing.T) {
+			code, valid, err := TOTP(keys[tt.algorithm], time.Unix(tt.unix, 0), 30*time.Second, 8, tt.algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.want {
+				t.Errorf("TOTP() = %s, want %s", code, tt.want)
+			}
+			if want := time.Duration(30-tt.unix%30) * time.Second; valid != want {
+				t.Errorf("TOTP() valid = %v, want %v", valid, want)
+			}
+		})
+	}
+	if _, _, err := TOTP(keys[OTPAlgorithmSHA1], time.Now(), 30*time.Second, 6, "MD5"); err == nil {
+		t.Error("TOTP() accepts unsupported algorithm")
+	}
+}
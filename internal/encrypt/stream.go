@@ -0,0 +1,251 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Stream format:
+//
+//	header: magic "CNRS" | version | chunk size (uint32 BE) | salt
+//	chunks: AES-256-GCM(chunk) sealed with a key derived from the master key and salt by HKDF-SHA256.
+//
+// Every chunk but the last one holds exactly chunk size bytes of plaintext. The nonce is the
+// chunk index with the last chunk flag, so reordered, dropped or truncated chunks fail to open.
+// The header is authenticated as additional data of every chunk.
+const (
+	StreamChunkSize = 64 * 1024
+
+	streamMagic      = "CNRS"
+	streamVersion    = 1
+	streamSaltSize   = 32
+	streamHeaderSize = len(streamMagic) + 1 + 4 + streamSaltSize
+	streamInfo       = "cenarius stream v1"
+	streamLastChunk  = 1
+)
+
+var ErrNotStream = errors.New("not an encrypted stream")
+
+// StreamWriter encrypts everything written to it into the stream format, Close must be called
+// to seal the last chunk
+type StreamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+// NewStreamWriter writes stream header to w and returns writer encrypting data with the key
+func NewStreamWriter(w io.Writer, key []byte) (*StreamWriter, error) {
+	salt := make([]byte, streamSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, streamHeaderSize)
+	header = append(header, streamMagic...)
+	header = append(header, streamVersion)
+	header = binary.BigEndian.AppendUint32(header, StreamChunkSize)
+	header = append(header, salt...)
+	aead, err := newStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &StreamWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n := len(p)
+	for len(p) > 0 {
+		// Full chunk is flushed only when more data comes, otherwise it may be the last one
+		if len(s.buf) == StreamChunkSize {
+			if err := s.flush(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		free := StreamChunkSize - len(s.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		s.buf = append(s.buf, p[:free]...)
+		p = p[free:]
+	}
+	return n, nil
+}
+
+// Close seals the last chunk, it doesn't close the underlying writer
+func (s *StreamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+func (s *StreamWriter) flush(last bool) error {
+	sealed := s.aead.Seal(nil, streamNonce(s.counter, last), s.buf, s.header)
+	if _, err := s.w.Write(sealed); err != nil {
+		return err
+	}
+	s.counter++
+	s.buf = s.buf[:0]
+	return nil
+}
+
+// StreamReader decrypts and verifies the stream format. It reads chunks on demand and
+// implements io.ReadSeeker, so only the requested ranges of a file are decrypted.
+type StreamReader struct {
+	r         io.ReaderAt
+	aead      cipher.AEAD
+	header    []byte
+	chunkSize int64
+	chunks    int64
+	size      int64
+	offset    int64
+	index     int64
+	plain     []byte
+}
+
+// NewStreamReader reads stream header from r holding size bytes of the stream
+func NewStreamReader(r io.ReaderAt, size int64, key []byte) (*StreamReader, error) {
+	header := make([]byte, streamHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNotStream
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(streamMagic)], []byte(streamMagic)) {
+		return nil, ErrNotStream
+	}
+	if header[len(streamMagic)] != streamVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	chunkSize := int64(binary.BigEndian.Uint32(header[len(streamMagic)+1:]))
+	if chunkSize == 0 {
+		return nil, ErrMalformedCiphertext
+	}
+	aead, err := newStreamAEAD(key, header[streamHeaderSize-streamSaltSize:])
+	if err != nil {
+		return nil, err
+	}
+	sealedSize := chunkSize + int64(aead.Overhead())
+	body := size - int64(streamHeaderSize)
+	chunks := (body + sealedSize - 1) / sealedSize
+	if chunks == 0 || body-(chunks-1)*sealedSize < int64(aead.Overhead()) {
+		return nil, ErrMalformedCiphertext
+	}
+	return &StreamReader{
+		r:         r,
+		aead:      aead,
+		header:    header,
+		chunkSize: chunkSize,
+		chunks:    chunks,
+		size:      body - chunks*int64(aead.Overhead()),
+		index:     -1,
+	}, nil
+}
+
+// Size returns plaintext size of the stream
+func (s *StreamReader) Size() int64 {
+	return s.size
+}
+
+func (s *StreamReader) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		// Empty plaintext is still a sealed chunk which must be verified
+		if s.size == 0 && s.index < 0 {
+			if err := s.load(0); err != nil {
+				return 0, err
+			}
+		}
+		return 0, io.EOF
+	}
+	index := s.offset / s.chunkSize
+	if index != s.index {
+		if err := s.load(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.plain[s.offset-index*s.chunkSize:])
+	s.offset += int64(n)
+	return n, nil
+}
+
+func (s *StreamReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("encrypt.StreamReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("encrypt.StreamReader.Seek: negative position")
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *StreamReader) load(index int64) error {
+	sealedSize := s.chunkSize + int64(s.aead.Overhead())
+	start := int64(streamHeaderSize) + index*sealedSize
+	length := sealedSize
+	last := index == s.chunks-1
+	if last {
+		length = s.size - index*s.chunkSize + int64(s.aead.Overhead())
+	}
+	sealed := make([]byte, length)
+	if _, err := s.r.ReadAt(sealed, start); err != nil && !(errors.Is(err, io.EOF) && last) {
+		return err
+	}
+	plain, err := s.aead.Open(sealed[:0], streamNonce(uint64(index), last), sealed, s.header)
+	if err != nil {
+		return ErrAuthenticationFailed
+	}
+	s.index = index
+	s.plain = plain
+	return nil
+}
+
+func newStreamAEAD(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKeySize
+	}
+	subkey := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte(streamInfo)), subkey); err != nil {
+		return nil, err
+	}
+	return newGCM(subkey)
+}
+
+// streamNonce is the chunk index followed by the last chunk flag
+func streamNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], index)
+	if last {
+		nonce[11] = streamLastChunk
+	}
+	return nonce
+}
@@ -0,0 +1,113 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func sealStream(t *testing.T, plaintext, key []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewStreamWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Odd sized writes to cross chunk boundaries
+	for p := plaintext; len(p) > 0; {
+		n := 1000
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStream(t *testing.T) {
+	key := []byte("f1c68defcac1715234f1b9a9906c0a7c")
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 5} {
+		plaintext := make([]byte, size)
+		if _, err := rand.Read(plaintext); err != nil {
+			t.Fatal(err)
+		}
+		sealed := sealStream(t, plaintext, key)
+		r, err := NewStreamReader(bytes.NewReader(sealed), int64(len(sealed)), key)
+		if err != nil {
+			t.Fatalf("size %d: NewStreamReader() error = %v", size, err)
+		}
+		if r.Size() != int64(size) {
+			t.Errorf("size %d: Size() = %d", size, r.Size())
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: ReadAll() error = %v", size, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("size %d: plaintext mismatch", size)
+		}
+		if size > 10 {
+			offset := int64(size - 10)
+			if _, err := r.Seek(offset, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			tail, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("size %d: ReadAll() after Seek error = %v", size, err)
+			}
+			if !bytes.Equal(tail, plaintext[offset:]) {
+				t.Errorf("size %d: plaintext mismatch after Seek", size)
+			}
+		}
+	}
+}
+
+func TestStreamIntegrity(t *testing.T) {
+	key := []byte("f1c68defcac1715234f1b9a9906c0a7c")
+	plaintext := make([]byte, 2*StreamChunkSize+100)
+	sealed := sealStream(t, plaintext, key)
+	sealedChunk := StreamChunkSize + 16
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[streamHeaderSize+10] ^= 1
+	badHeader := append([]byte(nil), sealed...)
+	badHeader[streamHeaderSize-1] ^= 1
+	truncated := sealed[:streamHeaderSize+2*sealedChunk]
+	swapped := append([]byte(nil), sealed...)
+	copy(swapped[streamHeaderSize:], sealed[streamHeaderSize+sealedChunk:streamHeaderSize+2*sealedChunk])
+	copy(swapped[streamHeaderSize+sealedChunk:], sealed[streamHeaderSize:streamHeaderSize+sealedChunk])
+
+	tests := []struct {
+		name    string
+		stream  []byte
+		key     []byte
+		wantErr error
+	}{
+		{name: "TamperedChunk", stream: tampered, key: key, wantErr: ErrAuthenticationFailed},
+		{name: "TamperedHeader", stream: badHeader, key: key, wantErr: ErrAuthenticationFailed},
+		{name: "Truncated", stream: truncated, key: key, wantErr: ErrAuthenticationFailed},
+		{name: "Reordered", stream: swapped, key: key, wantErr: ErrAuthenticationFailed},
+		{name: "WrongKey", stream: sealed, key: []byte("00000000000000000000000000000000"), wantErr: ErrAuthenticationFailed},
+		{name: "ShortKey", stream: sealed, key: []byte("short"), wantErr: ErrInvalidKeySize},
+		{name: "NotStream", stream: []byte("plain old file"), key: key, wantErr: ErrNotStream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewStreamReader(bytes.NewReader(tt.stream), int64(len(tt.stream)), tt.key)
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("StreamReader error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package importer
+
+import (
+	"encoding/binary"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Argon2 variants (RFC 9106). golang.org/x/crypto/argon2 lacks Argon2d, the default KDF of KeePass
+// databases, so the algorithm is implemented here with the secret and associated data KeePass may set.
+const (
+	argon2d  = 0
+	argon2i  = 1
+	argon2id = 2
+)
+
+const (
+	argon2Version    = 0x13
+	argon2BlockWords = 128
+	argon2SyncPoints = 4
+)
+
+type argon2Block [argon2BlockWords]uint64
+
+// argon2Key derives keyLen bytes from the password and the salt, memory is in KiB
+func argon2Key(mode int, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) []byte {
+	h0 := argon2H0(mode, password, salt, secret, data, time, memory, threads, keyLen)
+	memory = memory / (argon2SyncPoints * threads) * (argon2SyncPoints * threads)
+	if memory < 2*argon2SyncPoints*threads {
+		memory = 2 * argon2SyncPoints * threads
+	}
+	b := argon2InitBlocks(&h0, memory, threads)
+	argon2Fill(b, mode, time, memory, threads)
+	return argon2Extract(b, memory, threads, keyLen)
+}
+
+func argon2H0(mode int, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) [blake2b.Size + 8]byte {
+	var h0 [blake2b.Size + 8]byte
+	var params [24]byte
+	var n [4]byte
+	b2, _ := blake2b.New512(nil)
+	binary.LittleEndian.PutUint32(params[0:], threads)
+	binary.LittleEndian.PutUint32(params[4:], keyLen)
+	binary.LittleEndian.PutUint32(params[8:], memory)
+	binary.LittleEndian.PutUint32(params[12:], time)
+	binary.LittleEndian.PutUint32(params[16:], argon2Version)
+	binary.LittleEndian.PutUint32(params[20:], uint32(mode))
+	b2.Write(params[:])
+	for _, v := range [][]byte{password, salt, secret, data} {
+		binary.LittleEndian.PutUint32(n[:], uint32(len(v)))
+		b2.Write(n[:])
+		b2.Write(v)
+	}
+	b2.Sum(h0[:0])
+	return h0
+}
+
+func argon2InitBlocks(h0 *[blake2b.Size + 8]byte, memory, threads uint32) []argon2Block {
+	var block0 [1024]byte
+	b := make([]argon2Block, memory)
+	for lane := uint32(0); lane < threads; lane++ {
+		j := lane * (memory / threads)
+		binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], i)
+			blake2bLong(block0[:], h0[:])
+			for k := range b[j+i] {
+				b[j+i][k] = binary.LittleEndian.Uint64(block0[k*8:])
+			}
+		}
+	}
+	return b
+}
+
+func argon2Fill(b []argon2Block, mode int, time, memory, threads uint32) {
+	laneLength := memory / threads
+	segment := laneLength / argon2SyncPoints
+	for n := uint32(0); n < time; n++ {
+		for slice := uint32(0); slice < argon2SyncPoints; slice++ {
+			for lane := uint32(0); lane < threads; lane++ {
+				argon2Segment(b, mode, n, slice, lane, time, memory, threads, laneLength, segment)
+			}
+		}
+	}
+}
+
+func argon2Segment(b []argon2Block, mode int, n, slice, lane, time, memory, threads, laneLength, segment uint32) {
+	var addresses, in, zero argon2Block
+	independent := mode == argon2i || mode == argon2id && n == 0 && slice < argon2SyncPoints/2
+	if independent {
+		in[0], in[1], in[2] = uint64(n), uint64(lane), uint64(slice)
+		in[3], in[4], in[5] = uint64(memory), uint64(time), uint64(mode)
+	}
+	nextAddresses := func() {
+		in[6]++
+		argon2Compress(&addresses, &in, &zero, false)
+		argon2Compress(&addresses, &addresses, &zero, false)
+	}
+	index := uint32(0)
+	if n == 0 && slice == 0 {
+		// The first two blocks of lanes are set from H0
+		index = 2
+		if independent {
+			nextAddresses()
+		}
+	}
+	offset := lane*laneLength + slice*segment + index
+	for index < segment {
+		prev := offset - 1
+		if index == 0 && slice == 0 {
+			prev += laneLength
+		}
+		var random uint64
+		if independent {
+			if index%argon2BlockWords == 0 {
+				nextAddresses()
+			}
+			random = addresses[index%argon2BlockWords]
+		} else {
+			random = b[prev][0]
+		}
+		ref := argon2RefIndex(random, laneLength, segment, threads, n, slice, lane, index)
+		argon2Compress(&b[offset], &b[prev], &b[ref], true)
+		index, offset = index+1, offset+1
+	}
+}
+
+// argon2RefIndex returns the block referenced by the pseudo-random value
+func argon2RefIndex(random uint64, laneLength, segment, threads, n, slice, lane, index uint32) uint32 {
+	refLane := uint32(random>>32) % threads
+	if n == 0 && slice == 0 {
+		refLane = lane
+	}
+	m, s := 3*segment, ((slice+1)%argon2SyncPoints)*segment
+	if lane == refLane {
+		m += index
+	}
+	if n == 0 {
+		m, s = slice*segment, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		m--
+	}
+	p := random & 0xFFFFFFFF
+	p = p * p >> 32
+	p = p * uint64(m) >> 32
+	return refLane*laneLength + uint32((uint64(s)+uint64(m)-(p+1))%uint64(laneLength))
+}
+
+// argon2Compress is the compression function G of two blocks, xor keeps the previous content of out
+func argon2Compress(out, in1, in2 *argon2Block, xor bool) {
+	var t argon2Block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+	for i := 0; i < argon2BlockWords; i += 16 {
+		blamka(&t[i], &t[i+1], &t[i+2], &t[i+3], &t[i+4], &t[i+5], &t[i+6], &t[i+7],
+			&t[i+8], &t[i+9], &t[i+10], &t[i+11], &t[i+12], &t[i+13], &t[i+14], &t[i+15])
+	}
+	for i := 0; i < argon2BlockWords/8; i += 2 {
+		blamka(&t[i], &t[i+1], &t[16+i], &t[16+i+1], &t[32+i], &t[32+i+1], &t[48+i], &t[48+i+1],
+			&t[64+i], &t[64+i+1], &t[80+i], &t[80+i+1], &t[96+i], &t[96+i+1], &t[112+i], &t[112+i+1])
+	}
+	for i := range t {
+		v := in1[i] ^ in2[i] ^ t[i]
+		if xor {
+			v ^= out[i]
+		}
+		out[i] = v
+	}
+}
+
+// blamka is the BLAKE2b round with multiplications used by the compression function
+func blamka(v0, v1, v2, v3, v4, v5, v6, v7, v8, v9, v10, v11, v12, v13, v14, v15 *uint64) {
+	blamkaG(v0, v4, v8, v12)
+	blamkaG(v1, v5, v9, v13)
+	blamkaG(v2, v6, v10, v14)
+	blamkaG(v3, v7, v11, v15)
+	blamkaG(v0, v5, v10, v15)
+	blamkaG(v1, v6, v11, v12)
+	blamkaG(v2, v7, v8, v13)
+	blamkaG(v3, v4, v9, v14)
+}
+
+func blamkaG(a, b, c, d *uint64) {
+	fBlaMka := func(x, y uint64) uint64 {
+		return x + y + 2*uint64(uint32(x))*uint64(uint32(y))
+	}
+	*a = fBlaMka(*a, *b)
+	*d ^= *a
+	*d = *d>>32 | *d<<32
+	*c = fBlaMka(*c, *d)
+	*b ^= *c
+	*b = *b>>24 | *b<<40
+	*a = fBlaMka(*a, *b)
+	*d ^= *a
+	*d = *d>>16 | *d<<48
+	*c = fBlaMka(*c, *d)
+	*b ^= *c
+	*b = *b>>63 | *b<<1
+}
+
+func argon2Extract(b []argon2Block, memory, threads, keyLen uint32) []byte {
+	laneLength := memory / threads
+	for lane := uint32(0); lane < threads-1; lane++ {
+		for i, v := range b[lane*laneLength+laneLength-1] {
+			b[memory-1][i] ^= v
+		}
+	}
+	var block [1024]byte
+	for i, v := range b[memory-1] {
+		binary.LittleEndian.PutUint64(block[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	blake2bLong(key, block[:])
+	return key
+}
+
+// blake2bLong is the variable length hash H' of Argon2
+func blake2bLong(out []byte, in []byte) {
+	var b2 hash.Hash
+	if n := len(out); n < blake2b.Size {
+		b2, _ = blake2b.New(n, nil)
+	} else {
+		b2, _ = blake2b.New512(nil)
+	}
+	var buffer [blake2b.Size]byte
+	binary.LittleEndian.PutUint32(buffer[:4], uint32(len(out)))
+	b2.Write(buffer[:4])
+	b2.Write(in)
+	if len(out) <= blake2b.Size {
+		b2.Sum(out[:0])
+		return
+	}
+	outLen := len(out)
+	b2.Sum(buffer[:0])
+	b2.Reset()
+	copy(out, buffer[:32])
+	out = out[32:]
+	for len(out) > blake2b.Size {
+		b2.Write(buffer[:])
+		b2.Sum(buffer[:0])
+		copy(out, buffer[:32])
+		out = out[32:]
+		b2.Reset()
+	}
+	if outLen%blake2b.Size > 0 {
+		r := (outLen+31)/32 - 2
+		b2, _ = blake2b.New(outLen-32*r, nil)
+	}
+	b2.Write(buffer[:])
+	b2.Sum(out[:0])
+}
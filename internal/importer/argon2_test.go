@@ -0,0 +1,31 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/argon2"
+)
+
+func Test_argon2Key(t *testing.T) {
+	// Test vectors of RFC 9106
+	password := bytes.Repeat([]byte{1}, 32)
+	salt := bytes.Repeat([]byte{2}, 16)
+	secret := bytes.Repeat([]byte{3}, 8)
+	data := bytes.Repeat([]byte{4}, 12)
+	for mode, tag := range map[int]string{
+		argon2d:  "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb",
+		argon2i:  "c814d9d1dc7f37aa13f0d77f2494bda1c8de6b016dd388d29952a4c4672b6ce8",
+		argon2id: "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659",
+	} {
+		assert.Equal(t, tag, hex.EncodeToString(argon2Key(mode, password, salt, secret, data, 3, 32, 4, 32)), "mode %d", mode)
+	}
+
+	// Without the secret and associated data keys match golang.org/x/crypto/argon2
+	assert.Equal(t, argon2.IDKey([]byte("master"), []byte("salty salt"), 2, 256, 3, 32),
+		argon2Key(argon2id, []byte("master"), []byte("salty salt"), nil, nil, 2, 256, 3, 32))
+	assert.Equal(t, argon2.Key([]byte("master"), []byte("salty salt"), 1, 300, 2, 80),
+		argon2Key(argon2i, []byte("master"), []byte("salty salt"), nil, nil, 1, 300, 2, 80))
+}
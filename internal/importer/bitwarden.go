@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Types of Bitwarden items
+const (
+	bitwardenLogin    = 1
+	bitwardenNote     = 2
+	bitwardenCard     = 3
+	bitwardenIdentity = 4
+	bitwardenSSHKey   = 5
+)
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	FolderID string `json:"folderId"`
+	Favorite bool   `json:"favorite"`
+	Fields   []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"fields"`
+	Login *struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+		URIs     []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+	} `json:"login"`
+	Card *struct {
+		CardholderName string `json:"cardholderName"`
+		Brand          string `json:"brand"`
+		Number         string `json:"number"`
+		ExpMonth       string `json:"expMonth"`
+		ExpYear        string `json:"expYear"`
+		Code           string `json:"code"`
+	} `json:"card"`
+	Identity map[string]any `json:"identity"`
+	SSHKey   *struct {
+		PrivateKey     string `json:"privateKey"`
+		PublicKey      string `json:"publicKey"`
+		KeyFingerprint string `json:"keyFingerprint"`
+	} `json:"sshKey"`
+}
+
+// identityFields are fields of Bitwarden identities in the order they are kept in the notes
+var identityFields = []string{
+	"title", "firstName", "middleName", "lastName", "company", "email", "phone", "username",
+	"address1", "address2", "address3", "city", "state", "postalCode", "country",
+	"ssn", "passportNumber", "licenseNumber",
+}
+
+// readBitwarden reads the unencrypted JSON export of Bitwarden, folders of items become their folders
+func readBitwarden(data []byte) ([]entry, error) {
+	export := &bitwardenExport{}
+	if err := json.Unmarshal(data, export); err != nil {
+		return nil, fmt.Errorf("not a Bitwarden JSON export: %w", err)
+	}
+	if export.Encrypted {
+		return nil, fmt.Errorf("%w, export the vault as unencrypted JSON", ErrPasswordRequired)
+	}
+	folders := make(map[string]string, len(export.Folders))
+	for _, f := range export.Folders {
+		folders[f.ID] = f.Name
+	}
+	entries := make([]entry, 0, len(export.Items))
+	for _, item := range export.Items {
+		e := entry{title: item.Name, notes: item.Notes}
+		e.labels.Folder, e.labels.Favorite = folders[item.FolderID], item.Favorite
+		switch {
+		case item.Type == bitwardenLogin && item.Login != nil:
+			e.username, e.password = item.Login.Username, item.Login.Password
+			for i, u := range item.Login.URIs {
+				if i == 0 {
+					e.url = u.URI
+					continue
+				}
+				e.addField("URL", u.URI)
+			}
+			e.addField("TOTP", item.Login.TOTP)
+		case item.Type == bitwardenCard && item.Card != nil:
+			e.card = &card{holder: item.Card.CardholderName, number: item.Card.Number, cvc: item.Card.Code, brand: item.Card.Brand}
+			if item.Card.ExpMonth != "" || item.Card.ExpYear != "" {
+				e.card.expiry = item.Card.ExpMonth + "/" + item.Card.ExpYear
+			}
+		case item.Type == bitwardenIdentity:
+			for _, name := range identityFields {
+				if v, ok := item.Identity[name].(string); ok {
+					e.addField(name, v)
+				}
+			}
+		case item.Type == bitwardenSSHKey && item.SSHKey != nil:
+			e.addField("Private key", item.SSHKey.PrivateKey)
+			e.addField("Public key", item.SSHKey.PublicKey)
+			e.addField("Fingerprint", item.SSHKey.KeyFingerprint)
+		}
+		for _, f := range item.Fields {
+			e.addField(f.Name, f.Value)
+		}
+		if item.Type == bitwardenNote && strings.TrimSpace(e.notes) == "" && len(e.fields) == 0 {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
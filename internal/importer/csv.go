@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"bytes"
+	"cenarius/internal/model"
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// csvColumns maps names of columns used by CSV exports of password managers to fields of entries.
+// Bitwarden, LastPass, KeePassXC, 1Password, Chrome and Firefox exports are covered.
+var csvColumns = map[string]string{
+	"name": "title", "title": "title", "account": "title",
+	"username": "username", "login_username": "username", "login": "username", "user": "username",
+	"user name": "username", "email": "username",
+	"password": "password", "login_password": "password",
+	"url": "url", "login_uri": "url", "uri": "url", "website": "url", "web site": "url",
+	"notes": "notes", "note": "notes", "extra": "notes", "comments": "notes",
+	"folder": "folder", "group": "folder", "grouping": "folder",
+	"favorite": "favorite", "fav": "favorite",
+	"tags": "tags",
+	"totp": "totp", "login_totp": "totp", "otp": "totp", "otpauth": "totp",
+	"fields":      "fields",
+	"card number": "number", "cardnumber": "number", "card_number": "number",
+	"cardholder": "holder", "cardholder name": "holder", "name on card": "holder",
+	"cvc": "cvc", "cvv": "cvc", "security code": "cvc",
+	"expiry": "expiry", "expiration": "expiry", "expiration date": "expiry",
+}
+
+// readCSV reads a CSV export with a header row, columns are recognized by csvColumns and others are skipped.
+// Rows with a card number are cards, Bitwarden "fields" column of name: value lines is kept in the notes.
+func readCSV(data []byte) ([]entry, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("not a CSV export: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV export has no header row")
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		if f, ok := csvColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, seen := columns[f]; !seen {
+				columns[f] = i
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("CSV export has no known columns, e.g. name, username, password, url, notes")
+	}
+	entries := make([]entry, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		value := func(f string) string {
+			if i, ok := columns[f]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		e := entry{title: value("title"), username: value("username"), password: value("password"), url: value("url"), notes: value("notes")}
+		e.labels = model.Labels{Folder: value("folder"), Tags: model.ParseTags(value("tags")), Favorite: parseBool(value("favorite"))}
+		if number := value("number"); number != "" {
+			e.card = &card{holder: value("holder"), number: number, cvc: value("cvc"), expiry: value("expiry")}
+		}
+		e.addField("TOTP", value("totp"))
+		for _, line := range strings.Split(value("fields"), "\n") {
+			if name, v, ok := strings.Cut(line, ":"); ok {
+				e.addField(strings.TrimSpace(name), v)
+			}
+		}
+		if e.title == "" && e.username == "" && e.password == "" && e.notes == "" && e.card == nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func parseBool(s string) bool {
+	switch strings.ToLower(s) {
+	case "1", "true", "yes", "y":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+// Package importer reads exports of other password managers into plain secrets: Bitwarden JSON,
+// KeePass KDBX 3 and 4 databases, 1Password 1PUX archives and CSV files of most managers.
+package importer
+
+import (
+	"bytes"
+	"cenarius/internal/model"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Formats of exports
+const (
+	FormatBitwarden   = "bitwarden"
+	FormatKeePass     = "keepass"
+	FormatOnePassword = "1password"
+	FormatCSV         = "csv"
+)
+
+var (
+	ErrUnknownFormat    = errors.New("unknown export format, one of: bitwarden, keepass, 1password, csv")
+	ErrPasswordRequired = errors.New("the export is protected by a password")
+	ErrWrongPassword    = errors.New("wrong password or corrupted database")
+)
+
+// Formats returns names of the supported formats
+func Formats() []string {
+	return []string{FormatBitwarden, FormatKeePass, FormatOnePassword, FormatCSV}
+}
+
+// Item is a plain secret read from an export, Content is the content of a file.
+// Source names the entry of the export the secret comes from.
+type Item struct {
+	Kind    model.Kind
+	Secret  model.Secret
+	Content []byte
+	Source  string
+}
+
+// Detect returns the format of the export by the extension of its name or by its content
+func Detect(name string, data []byte) (string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".kdbx":
+		return FormatKeePass, nil
+	case ".1pux":
+		return FormatOnePassword, nil
+	case ".csv":
+		return FormatCSV, nil
+	case ".json":
+		return FormatBitwarden, nil
+	}
+	switch {
+	case bytes.HasPrefix(data, kdbxSignature[:]):
+		return FormatKeePass, nil
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return FormatOnePassword, nil
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		return FormatBitwarden, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// NeedsPassword reports whether exports of the format are opened with a password
+func NeedsPassword(format string) bool {
+	return format == FormatKeePass
+}
+
+// Read returns secrets of the export in the format, the password opens KeePass databases
+func Read(format string, data []byte, password string) ([]Item, error) {
+	var entries []entry
+	var err error
+	switch format {
+	case FormatBitwarden:
+		entries, err = readBitwarden(data)
+	case FormatKeePass:
+		entries, err = readKeePass(data, password)
+	case FormatOnePassword:
+		entries, err = readOnePassword(data)
+	case FormatCSV:
+		entries, err = readCSV(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, e.items()...)
+	}
+	return items, nil
+}
+
+// entry is an entry of an export in terms common to password managers
+type entry struct {
+	title    string
+	username string
+	password string
+	url      string
+	notes    string
+	labels   model.Labels
+	card     *card
+	// fields are other fields of the entry, they are kept in the notes
+	fields []field
+	files  []file
+}
+
+type card struct {
+	holder string
+	number string
+	cvc    string
+	brand  string
+	expiry string
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+type file struct {
+	name    string
+	content []byte
+}
+
+// addField keeps the non-empty field of the entry
+func (e *entry) addField(name, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		e.fields = append(e.fields, field{name: name, value: value})
+	}
+}
+
+// items maps the entry to secrets. Cards become credit cards and entries with credentials logins,
+// their notes and other fields are kept in a text named after the entry. Entries of nothing but notes
+// become texts and attachments become files. Meta gets the URL, notes are encrypted.
+func (e *entry) items() []Item {
+	name := strings.TrimSpace(e.title)
+	if name == "" {
+		name = "Untitled"
+	}
+	e.labels.Normalize()
+	data := model.SecretData{Name: name, Labels: e.labels}
+	items := make([]Item, 0)
+	text := e.text()
+	switch {
+	case e.card != nil:
+		m := &model.CreditCard{SecretData: data, CVC: e.card.cvc, Number: strings.NewReplacer(" ", "", "-", "").Replace(e.card.number)}
+		m.OwnerName, m.OwnerLastName = splitHolder(e.card.holder)
+		m.Meta = e.card.brand
+		items = append(items, Item{Kind: model.CreditCardKind, Secret: m, Source: name})
+	case e.username != "" || e.password != "":
+		m := &model.LoginWithPassword{SecretData: data, Login: e.username, Password: e.password}
+		m.Meta = e.url
+		items = append(items, Item{Kind: model.LoginWithPasswordKind, Secret: m, Source: name})
+	}
+	if text != "" {
+		m := &model.SecretText{SecretData: data, Text: text}
+		if len(items) > 0 {
+			m.Name = name + " notes"
+		} else {
+			m.Meta = e.url
+		}
+		items = append(items, Item{Kind: model.SecretTextKind, Secret: m, Source: name})
+	}
+	for _, f := range e.files {
+		m := &model.SecretFile{SecretData: data}
+		m.Name, m.Meta = f.name, name
+		items = append(items, Item{Kind: model.SecretFileKind, Secret: m, Content: f.content, Source: name})
+	}
+	return items
+}
+
+// text returns the notes of the entry followed by its other fields
+func (e *entry) text() string {
+	lines := make([]string, 0)
+	if notes := strings.TrimSpace(e.notes); notes != "" {
+		lines = append(lines, notes)
+	}
+	if e.card != nil && e.card.expiry != "" {
+		lines = append(lines, "Expiry: "+e.card.expiry)
+	}
+	for _, f := range e.fields {
+		lines = append(lines, fmt.Sprintf("%s: %s", f.name, f.value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitHolder splits the card holder name into the first and the last name
+func splitHolder(holder string) (string, string) {
+	words := strings.Fields(holder)
+	if len(words) < 2 {
+		return holder, ""
+	}
+	return strings.Join(words[:len(words)-1], " "), words[len(words)-1]
+}
@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"cenarius/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRead_Bitwarden(t *testing.T) {
+	data := []byte(`{
+		"encrypted": false,
+		"folders": [{"id": "f1", "name": "Work"}],
+		"items": [
+			{"type": 1, "name": "GitHub", "folderId": "f1", "favorite": true, "notes": "2FA on phone",
+				"fields": [{"name": "PIN", "value": "1234", "type": 1}],
+				"login": {"username": "octocat", "password": "hunter2", "totp": null,
+					"uris": [{"uri": "https://github.com"}, {"uri": "https://gist.github.com"}]}},
+			{"type": 2, "name": "Wifi", "notes": "guest network"},
+			{"type": 3, "name": "Visa", "card": {"cardholderName": "Jane Q Doe", "brand": "Visa",
+				"number": "4111 1111 1111 1111", "expMonth": "12", "expYear": "2027", "code": "123"}},
+			{"type": 4, "name": "Passport", "identity": {"firstName": "Jane", "passportNumber": "X123"}}
+		]
+	}`)
+	format, err := Detect("bitwarden_export.json", data)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatBitwarden, format)
+	items, err := Read(format, data, "")
+	if !assert.NoError(t, err) || !assert.Len(t, items, 6) {
+		return
+	}
+	login := items[0].Secret.(*model.LoginWithPassword)
+	assert.Equal(t, "octocat", login.Login)
+	assert.Equal(t, "https://github.com", login.Meta)
+	assert.Equal(t, "Work", login.Folder)
+	assert.True(t, login.Favorite)
+	notes := items[1].Secret.(*model.SecretText)
+	assert.Equal(t, "GitHub notes", notes.Name)
+	assert.Equal(t, "2FA on phone\nURL: https://gist.github.com\nPIN: 1234", notes.Text)
+	assert.Equal(t, "guest network", items[2].Secret.(*model.SecretText).Text)
+	visa := items[3].Secret.(*model.CreditCard)
+	assert.Equal(t, "4111111111111111", visa.Number)
+	assert.Equal(t, "Jane Q", visa.OwnerName)
+	assert.Equal(t, "Doe", visa.OwnerLastName)
+	assert.NoError(t, visa.Validate())
+	assert.Equal(t, "Expiry: 12/2027", items[4].Secret.(*model.SecretText).Text)
+	assert.Equal(t, "firstName: Jane\npassportNumber: X123", items[5].Secret.(*model.SecretText).Text)
+
+	_, err = Read(FormatBitwarden, []byte(`{"encrypted": true, "items": []}`), "")
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+}
+
+func TestRead_CSV(t *testing.T) {
+	data := []byte("\xef\xbb\xbfurl,username,password,totp,extra,name,grouping,fav\n" +
+		"https://github.com,octocat,hunter2,,,GitHub,Work/Dev,1\n" +
+		"http://sn,,,,guest network,Wifi,,0\n" +
+		",,,,,,,\n")
+	format, err := Detect("lastpass.csv", data)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatCSV, format)
+	items, err := Read(format, data, "")
+	if assert.NoError(t, err) && assert.Len(t, items, 2) {
+		login := items[0].Secret.(*model.LoginWithPassword)
+		assert.Equal(t, "GitHub", login.Name)
+		assert.Equal(t, "hunter2", login.Password)
+		assert.Equal(t, "Work/Dev", login.Folder)
+		assert.True(t, login.Favorite)
+		text := items[1].Secret.(*model.SecretText)
+		assert.Equal(t, "guest network", text.Text)
+		assert.Equal(t, "http://sn", text.Meta)
+	}
+	_, err = Read(FormatCSV, []byte("a,b\n1,2\n"), "")
+	assert.Error(t, err)
+}
+
+func TestRead_OnePassword(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"export.attributes": `{"version": 3}`,
+		"export.data": `{"accounts": [{"vaults": [{"attrs": {"name": "Private"}, "items": [
+			{"favIndex": 1, "state": "active", "categoryUuid": "001",
+				"overview": {"title": "GitHub", "url": "https://github.com", "tags": ["dev"]},
+				"details": {"loginFields": [
+					{"designation": "username", "value": "octocat"},
+					{"designation": "password", "value": "hunter2"}
+				], "notesPlain": "", "sections": [{"fields": [
+					{"title": "recovery", "id": "r", "value": {"file": {"fileName": "codes.txt", "documentId": "d1"}}}
+				]}]}},
+			{"favIndex": 0, "state": "archived", "categoryUuid": "002",
+				"overview": {"title": "Visa"},
+				"details": {"sections": [{"fields": [
+					{"title": "cardholder name", "id": "cardholder", "value": {"string": "Jane Doe"}},
+					{"title": "number", "id": "ccnum", "value": {"creditCardNumber": "4111111111111111"}},
+					{"title": "verification number", "id": "cvv", "value": {"concealed": "123"}},
+					{"title": "expiry date", "id": "expiry", "value": {"monthYear": 202712}}
+				]}]}}
+		]}]}]}`,
+		"files/d1__codes.txt": "1234-5678",
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	format, err := Detect("export", buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, FormatOnePassword, format)
+	items, err := Read(format, buf.Bytes(), "")
+	if !assert.NoError(t, err) || !assert.Len(t, items, 4) {
+		return
+	}
+	login := items[0].Secret.(*model.LoginWithPassword)
+	assert.Equal(t, "hunter2", login.Password)
+	assert.Equal(t, model.Labels{Folder: "Private", Tags: []string{"dev"}, Favorite: true}, login.Labels)
+	assert.Equal(t, []byte("1234-5678"), items[1].Content)
+	visa := items[2].Secret.(*model.CreditCard)
+	assert.Equal(t, "Jane", visa.OwnerName)
+	assert.Equal(t, "123", visa.CVC)
+	assert.Equal(t, []string{"archived"}, visa.Tags)
+	assert.Equal(t, "Expiry: 12/2027", items[3].Secret.(*model.SecretText).Text)
+}
@@ -0,0 +1,668 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20"
+	"golang.org/x/crypto/twofish"
+)
+
+// kdbxSignature starts every KeePass 2 database
+var kdbxSignature = [8]byte{0x03, 0xd9, 0xa2, 0x9a, 0x67, 0xfb, 0x4b, 0xb5}
+
+// Fields of the outer header
+const (
+	kdbxEndOfHeader        = 0
+	kdbxCipherID           = 2
+	kdbxCompression        = 3
+	kdbxMasterSeed         = 4
+	kdbxTransformSeed      = 5
+	kdbxTransformRounds    = 6
+	kdbxEncryptionIV       = 7
+	kdbxProtectedStreamKey = 8
+	kdbxStreamStartBytes   = 9
+	kdbxInnerRandomStream  = 10
+	kdbxKdfParameters      = 11
+)
+
+// Fields of the inner header of KDBX 4
+const (
+	kdbxInnerEnd       = 0
+	kdbxInnerStreamID  = 1
+	kdbxInnerStreamKey = 2
+	kdbxInnerBinary    = 3
+)
+
+// Ciphers of the payload, key derivation functions and streams protecting values
+var (
+	kdbxAES      = mustUUID("31c1f2e6bf714350be5805216afc5aff")
+	kdbxChaCha20 = mustUUID("d6038a2b8b6f4cb5a524339a31dbb59a")
+	kdbxTwofish  = mustUUID("ad68f29f576f4bb9a36ad47af965346c")
+	kdbxAESKDF   = mustUUID("c9d9f39a628a4460bf740d08c18a4fea")
+	kdbxAESKDF4  = mustUUID("7c02bb8279a74ac0927d114a00648238")
+	kdbxArgon2d  = mustUUID("ef636ddf8c29444b91f7a9a403e30a0c")
+	kdbxArgon2id = mustUUID("9e298b1956db4773b23dfc3ec6f0a1e6")
+)
+
+const (
+	kdbxStreamSalsa20  = 2
+	kdbxStreamChaCha20 = 3
+)
+
+var kdbxSalsa20Nonce = []byte{0xe8, 0x30, 0x09, 0x4b, 0x97, 0x20, 0x5d, 0x2a}
+
+// Limits of key derivation parameters, the header is read before the password is checked, so a hostile
+// database could otherwise make the import allocate memory or spin without bounds
+const (
+	kdbxMaxAESRounds        = 1 << 28
+	kdbxMaxArgon2Iterations = 1 << 10
+	kdbxMaxArgon2Memory     = 1 << 30
+	kdbxMaxArgon2Threads    = 255
+)
+
+var errKDBXMalformed = errors.New("malformed KeePass database")
+
+func mustUUID(s string) string {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// kdbxHeader is the outer header of the database, KDBX 3 keeps the key derivation and the inner stream
+// in it, KDBX 4 keeps parameters of the key derivation in a variant dictionary
+type kdbxHeader struct {
+	major              uint16
+	cipher             string
+	compressed         bool
+	masterSeed         []byte
+	iv                 []byte
+	transformSeed      []byte
+	transformRounds    uint64
+	protectedStreamKey []byte
+	streamStartBytes   []byte
+	innerStream        uint32
+	kdf                map[string][]byte
+}
+
+// readKeePass reads KDBX 3.1 and 4 databases protected by the password, key files are not supported.
+// Groups become folders below the root group, the recycle bin and histories of entries are skipped.
+func readKeePass(data []byte, password string) ([]entry, error) {
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+	r := bytes.NewReader(data)
+	h, err := readKDBXHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	headerEnd := len(data) - r.Len()
+	composite := sha256.Sum256([]byte(password))
+	composite = sha256.Sum256(composite[:])
+	transformed, err := h.transformKey(composite[:])
+	if err != nil {
+		return nil, err
+	}
+	master := sha256.Sum256(append(append([]byte{}, h.masterSeed...), transformed...))
+	var content []byte
+	binaries := make([][]byte, 0)
+	var streamID uint32
+	var streamKey []byte
+	if h.major == 3 {
+		content, err = readKDBX3Payload(h, master[:], data[headerEnd:])
+		streamID, streamKey = h.innerStream, h.protectedStreamKey
+	} else {
+		var inner io.Reader
+		inner, err = readKDBX4Payload(h, master[:], transformed, data[:headerEnd], data[headerEnd:])
+		if err == nil {
+			streamID, streamKey, binaries, err = readKDBXInnerHeader(inner)
+		}
+		if err == nil {
+			content, err = io.ReadAll(inner)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	root := &xmlNode{}
+	if err := xml.Unmarshal(content, root); err != nil {
+		return nil, fmt.Errorf("%w: %v", errKDBXMalformed, err)
+	}
+	if err := unprotect(root, streamID, streamKey); err != nil {
+		return nil, err
+	}
+	return keepassEntries(root, binaries)
+}
+
+func readKDBXHeader(r *bytes.Reader) (*kdbxHeader, error) {
+	var signature [8]byte
+	var minor, major uint16
+	if _, err := io.ReadFull(r, signature[:]); err != nil || signature != kdbxSignature {
+		return nil, fmt.Errorf("not a KeePass database")
+	}
+	if binary.Read(r, binary.LittleEndian, &minor) != nil || binary.Read(r, binary.LittleEndian, &major) != nil {
+		return nil, errKDBXMalformed
+	}
+	if major != 3 && major != 4 {
+		return nil, fmt.Errorf("KeePass database version %d.%d is not supported, save it as KDBX 4", major, minor)
+	}
+	h := &kdbxHeader{major: major}
+	for {
+		var id uint8
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return nil, errKDBXMalformed
+		}
+		if major == 3 {
+			var size16 uint16
+			if err := binary.Read(r, binary.LittleEndian, &size16); err != nil {
+				return nil, errKDBXMalformed
+			}
+			size = uint32(size16)
+		} else if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, errKDBXMalformed
+		}
+		if int64(size) > int64(r.Len()) {
+			return nil, errKDBXMalformed
+		}
+		value := make([]byte, size)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, errKDBXMalformed
+		}
+		switch id {
+		case kdbxEndOfHeader:
+			return h, h.check()
+		case kdbxCipherID:
+			h.cipher = string(value)
+		case kdbxCompression:
+			h.compressed = len(value) == 4 && binary.LittleEndian.Uint32(value) == 1
+		case kdbxMasterSeed:
+			h.masterSeed = value
+		case kdbxTransformSeed:
+			h.transformSeed = value
+		case kdbxTransformRounds:
+			if len(value) == 8 {
+				h.transformRounds = binary.LittleEndian.Uint64(value)
+			}
+		case kdbxEncryptionIV:
+			h.iv = value
+		case kdbxProtectedStreamKey:
+			h.protectedStreamKey = value
+		case kdbxStreamStartBytes:
+			h.streamStartBytes = value
+		case kdbxInnerRandomStream:
+			if len(value) == 4 {
+				h.innerStream = binary.LittleEndian.Uint32(value)
+			}
+		case kdbxKdfParameters:
+			kdf, err := readVariantDictionary(value)
+			if err != nil {
+				return nil, err
+			}
+			h.kdf = kdf
+		}
+	}
+}
+
+func (h *kdbxHeader) check() error {
+	if len(h.masterSeed) != 32 || len(h.iv) == 0 {
+		return errKDBXMalformed
+	}
+	if h.cipher != kdbxAES && h.cipher != kdbxChaCha20 && h.cipher != kdbxTwofish {
+		return fmt.Errorf("cipher %x of the KeePass database is not supported", h.cipher)
+	}
+	if h.major == 3 && (len(h.transformSeed) != 32 || len(h.streamStartBytes) != 32) {
+		return errKDBXMalformed
+	}
+	if h.major == 4 && h.kdf == nil {
+		return errKDBXMalformed
+	}
+	return nil
+}
+
+// readVariantDictionary reads values of the dictionary by their names, values are little endian
+func readVariantDictionary(data []byte) (map[string][]byte, error) {
+	if len(data) < 2 || data[1] != 1 {
+		return nil, errKDBXMalformed
+	}
+	dict := make(map[string][]byte)
+	data = data[2:]
+	for len(data) > 0 {
+		if data[0] == 0 {
+			return dict, nil
+		}
+		if len(data) < 5 {
+			return nil, errKDBXMalformed
+		}
+		n := int(binary.LittleEndian.Uint32(data[1:]))
+		if n < 0 || len(data) < 5+n+4 {
+			return nil, errKDBXMalformed
+		}
+		name := string(data[5 : 5+n])
+		data = data[5+n:]
+		m := int(binary.LittleEndian.Uint32(data))
+		if m < 0 || len(data) < 4+m {
+			return nil, errKDBXMalformed
+		}
+		dict[name] = data[4 : 4+m]
+		data = data[4+m:]
+	}
+	return nil, errKDBXMalformed
+}
+
+func dictUint(dict map[string][]byte, name string) uint64 {
+	switch v := dict[name]; len(v) {
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(v))
+	case 8:
+		return binary.LittleEndian.Uint64(v)
+	}
+	return 0
+}
+
+// transformKey derives the key of the database from the composite key with AES-KDF or Argon2
+func (h *kdbxHeader) transformKey(composite []byte) ([]byte, error) {
+	if h.major == 3 {
+		return aesKDF(composite, h.transformSeed, h.transformRounds)
+	}
+	switch string(h.kdf["$UUID"]) {
+	case kdbxAESKDF, kdbxAESKDF4:
+		return aesKDF(composite, h.kdf["S"], dictUint(h.kdf, "R"))
+	case kdbxArgon2d, kdbxArgon2id:
+		mode := argon2d
+		if string(h.kdf["$UUID"]) == kdbxArgon2id {
+			mode = argon2id
+		}
+		if dictUint(h.kdf, "V") != argon2Version {
+			return nil, fmt.Errorf("argon2 parameters of the KeePass database are not supported")
+		}
+		// Limits are checked on the 64-bit values, so nothing wraps around when they are narrowed
+		iterations, memory, parallelism := dictUint(h.kdf, "I"), dictUint(h.kdf, "M"), dictUint(h.kdf, "P")
+		if iterations == 0 || iterations > kdbxMaxArgon2Iterations || memory > kdbxMaxArgon2Memory ||
+			parallelism == 0 || parallelism > kdbxMaxArgon2Threads {
+			return nil, errKDBXMalformed
+		}
+		return argon2Key(mode, composite, h.kdf["S"], h.kdf["K"], h.kdf["A"],
+			uint32(iterations), uint32(memory/1024), uint32(parallelism), 32), nil
+	}
+	return nil, fmt.Errorf("key derivation of the KeePass database is not supported")
+}
+
+// aesKDF encrypts the key with the seed rounds times
+func aesKDF(key, seed []byte, rounds uint64) ([]byte, error) {
+	if rounds > kdbxMaxAESRounds {
+		return nil, errKDBXMalformed
+	}
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, errKDBXMalformed
+	}
+	out := append([]byte{}, key...)
+	for i := uint64(0); i < rounds; i++ {
+		block.Encrypt(out[:16], out[:16])
+		block.Encrypt(out[16:], out[16:])
+	}
+	sum := sha256.Sum256(out)
+	return sum[:], nil
+}
+
+// decrypt decrypts the payload with the cipher of the header, CBC padding is removed
+func (h *kdbxHeader) decrypt(key, data []byte) ([]byte, error) {
+	if h.cipher == kdbxChaCha20 {
+		c, err := chacha20.NewUnauthenticatedCipher(key, h.iv)
+		if err != nil {
+			return nil, errKDBXMalformed
+		}
+		out := make([]byte, len(data))
+		c.XORKeyStream(out, data)
+		return out, nil
+	}
+	var block cipher.Block
+	var err error
+	if h.cipher == kdbxTwofish {
+		block, err = twofish.NewCipher(key)
+	} else {
+		block, err = aes.NewCipher(key)
+	}
+	if err != nil || len(h.iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, errKDBXMalformed
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, h.iv).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > block.BlockSize() {
+		return nil, ErrWrongPassword
+	}
+	for _, b := range out[len(out)-pad:] {
+		if int(b) != pad {
+			return nil, ErrWrongPassword
+		}
+	}
+	return out[:len(out)-pad], nil
+}
+
+// readKDBX3Payload decrypts the payload, checks its start bytes and joins its hashed blocks
+func readKDBX3Payload(h *kdbxHeader, key, data []byte) ([]byte, error) {
+	plain, err := h.decrypt(key, data)
+	if errors.Is(err, ErrWrongPassword) || err == nil && (len(plain) < 32 || !bytes.Equal(plain[:32], h.streamStartBytes)) {
+		return nil, ErrWrongPassword
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain = plain[32:]
+	var joined bytes.Buffer
+	for {
+		if len(plain) < 40 {
+			return nil, errKDBXMalformed
+		}
+		hash, size := plain[4:36], int(binary.LittleEndian.Uint32(plain[36:40]))
+		plain = plain[40:]
+		if size == 0 {
+			break
+		}
+		if size < 0 || size > len(plain) {
+			return nil, errKDBXMalformed
+		}
+		if sum := sha256.Sum256(plain[:size]); !bytes.Equal(sum[:], hash) {
+			return nil, errKDBXMalformed
+		}
+		joined.Write(plain[:size])
+		plain = plain[size:]
+	}
+	if !h.compressed {
+		return joined.Bytes(), nil
+	}
+	return gunzip(joined.Bytes())
+}
+
+// readKDBX4Payload checks the header against its hash and HMAC, checks HMACs of blocks of the payload
+// and returns the reader of the decrypted payload
+func readKDBX4Payload(h *kdbxHeader, key, transformed, header, data []byte) (io.Reader, error) {
+	if len(data) < 64 {
+		return nil, errKDBXMalformed
+	}
+	if sum := sha256.Sum256(header); !bytes.Equal(sum[:], data[:32]) {
+		return nil, errKDBXMalformed
+	}
+	hmacKey := sha512.Sum512(append(append(append([]byte{}, h.masterSeed...), transformed...), 1))
+	if subtle.ConstantTimeCompare(blockHMAC(hmacKey[:], ^uint64(0), header), data[32:64]) != 1 {
+		return nil, ErrWrongPassword
+	}
+	data = data[64:]
+	var joined bytes.Buffer
+	for i := uint64(0); ; i++ {
+		if len(data) < 36 {
+			return nil, errKDBXMalformed
+		}
+		mac, size := data[:32], int(int32(binary.LittleEndian.Uint32(data[32:36])))
+		if size < 0 || size > len(data)-36 {
+			return nil, errKDBXMalformed
+		}
+		if subtle.ConstantTimeCompare(blockHMAC(hmacKey[:], i, data[32:36+size]), mac) != 1 {
+			return nil, errKDBXMalformed
+		}
+		if size == 0 {
+			break
+		}
+		joined.Write(data[36 : 36+size])
+		data = data[36+size:]
+	}
+	plain, err := h.decrypt(key, joined.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if h.compressed {
+		if plain, err = gunzip(plain); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.NewReader(plain), nil
+}
+
+// blockHMAC authenticates the block of the index, the header is authenticated as the block ^0
+func blockHMAC(hmacKey []byte, index uint64, data []byte) []byte {
+	var i [8]byte
+	binary.LittleEndian.PutUint64(i[:], index)
+	key := sha512.Sum512(append(i[:], hmacKey...))
+	mac := hmac.New(sha256.New, key[:])
+	if index != ^uint64(0) {
+		mac.Write(i[:])
+	}
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// readKDBXInnerHeader reads the inner stream and attachments preceding the XML of KDBX 4
+func readKDBXInnerHeader(r io.Reader) (uint32, []byte, [][]byte, error) {
+	var streamID uint32
+	var streamKey []byte
+	binaries := make([][]byte, 0)
+	for {
+		var id uint8
+		var size uint32
+		if binary.Read(r, binary.LittleEndian, &id) != nil || binary.Read(r, binary.LittleEndian, &size) != nil {
+			return 0, nil, nil, errKDBXMalformed
+		}
+		value := make([]byte, size)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return 0, nil, nil, errKDBXMalformed
+		}
+		switch id {
+		case kdbxInnerEnd:
+			return streamID, streamKey, binaries, nil
+		case kdbxInnerStreamID:
+			if len(value) == 4 {
+				streamID = binary.LittleEndian.Uint32(value)
+			}
+		case kdbxInnerStreamKey:
+			streamKey = value
+		case kdbxInnerBinary:
+			// The first byte holds flags of the attachment
+			if len(value) == 0 {
+				return 0, nil, nil, errKDBXMalformed
+			}
+			binaries = append(binaries, value[1:])
+		}
+	}
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errKDBXMalformed, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// xmlNode is an element of the XML of the database, children are kept in the document order
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []*xmlNode `xml:",any"`
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element of the name
+func (n *xmlNode) child(name string) *xmlNode {
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			return c
+		}
+	}
+	return &xmlNode{}
+}
+
+func (n *xmlNode) text() string {
+	return strings.TrimSpace(n.Content)
+}
+
+// unprotect decrypts protected values, they are encrypted by one key stream in the document order
+func unprotect(root *xmlNode, streamID uint32, streamKey []byte) error {
+	protected := make([]*xmlNode, 0)
+	var walk func(n *xmlNode)
+	walk = func(n *xmlNode) {
+		if n.XMLName.Local == "Value" && strings.EqualFold(n.attr("Protected"), "true") {
+			protected = append(protected, n)
+		}
+		for _, c := range n.Nodes {
+			walk(c)
+		}
+	}
+	walk(root)
+	values := make([][]byte, len(protected))
+	total := 0
+	for i, n := range protected {
+		v, err := base64.StdEncoding.DecodeString(n.text())
+		if err != nil {
+			return fmt.Errorf("%w: %v", errKDBXMalformed, err)
+		}
+		values[i] = v
+		total += len(v)
+	}
+	if len(protected) == 0 {
+		return nil
+	}
+	stream := make([]byte, total)
+	switch streamID {
+	case kdbxStreamSalsa20:
+		key := sha256.Sum256(streamKey)
+		salsa20.XORKeyStream(stream, stream, kdbxSalsa20Nonce, &key)
+	case kdbxStreamChaCha20:
+		sum := sha512.Sum512(streamKey)
+		c, err := chacha20.NewUnauthenticatedCipher(sum[:32], sum[32:44])
+		if err != nil {
+			return err
+		}
+		c.XORKeyStream(stream, stream)
+	default:
+		return fmt.Errorf("inner stream %d of the KeePass database is not supported", streamID)
+	}
+	for i, n := range protected {
+		v := values[i]
+		for j := range v {
+			v[j] ^= stream[j]
+		}
+		stream = stream[len(v):]
+		n.Content = string(v)
+	}
+	return nil
+}
+
+// keepassEntries returns entries of groups below the root group of the XML
+func keepassEntries(root *xmlNode, binaries [][]byte) ([]entry, error) {
+	meta := root.child("Meta")
+	recycleBin := ""
+	if meta.child("RecycleBinEnabled").text() != "False" {
+		recycleBin = meta.child("RecycleBinUUID").text()
+	}
+	// KDBX 3 keeps attachments in the meta, they are referenced by ids
+	refs := make(map[string][]byte)
+	for _, b := range meta.child("Binaries").Nodes {
+		content, err := base64.StdEncoding.DecodeString(b.text())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errKDBXMalformed, err)
+		}
+		if strings.EqualFold(b.attr("Compressed"), "true") {
+			if content, err = gunzip(content); err != nil {
+				return nil, err
+			}
+		}
+		refs[b.attr("ID")] = content
+	}
+	for i, b := range binaries {
+		refs[strconv.Itoa(i)] = b
+	}
+	entries := make([]entry, 0)
+	var walk func(g *xmlNode, folder string) error
+	walk = func(g *xmlNode, folder string) error {
+		for _, n := range g.Nodes {
+			switch n.XMLName.Local {
+			case "Group":
+				if recycleBin != "" && n.child("UUID").text() == recycleBin {
+					continue
+				}
+				name := strings.ReplaceAll(n.child("Name").text(), "/", "-")
+				if err := walk(n, strings.TrimPrefix(folder+"/"+name, "/")); err != nil {
+					return err
+				}
+			case "Entry":
+				e, err := keepassEntry(n, folder, refs)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, e)
+			}
+		}
+		return nil
+	}
+	for _, top := range root.child("Root").Nodes {
+		if top.XMLName.Local == "Group" {
+			if err := walk(top, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+func keepassEntry(n *xmlNode, folder string, refs map[string][]byte) (entry, error) {
+	e := entry{}
+	e.labels.Folder = folder
+	e.labels.Tags = strings.FieldsFunc(n.child("Tags").text(), func(r rune) bool { return r == ';' || r == ',' })
+	for _, c := range n.Nodes {
+		key, value := c.child("Key").text(), c.child("Value")
+		switch c.XMLName.Local {
+		case "String":
+			switch key {
+			case "Title":
+				e.title = value.Content
+			case "UserName":
+				e.username = value.Content
+			case "Password":
+				e.password = value.Content
+			case "URL":
+				e.url = value.Content
+			case "Notes":
+				e.notes = value.Content
+			default:
+				e.addField(key, value.Content)
+			}
+		case "Binary":
+			content, ok := refs[value.attr("Ref")]
+			if !ok {
+				return e, fmt.Errorf("%w: attachment %s is missing", errKDBXMalformed, key)
+			}
+			e.files = append(e.files, file{name: key, content: content})
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,310 @@
+package importer
+
+import (
+	"bytes"
+	"cenarius/internal/model"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20"
+)
+
+const testKeePassXML = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<RecycleBinEnabled>True</RecycleBinEnabled>
+		<RecycleBinUUID>YmluYmluYmluYmluYmluYg==</RecycleBinUUID>
+		%s
+	</Meta>
+	<Root>
+		<Group>
+			<UUID>cm9vdHJvb3Ryb290cm9vdA==</UUID>
+			<Name>Passwords</Name>
+			<Entry>
+				<Tags>work;dev</Tags>
+				<String><Key>Title</Key><Value>GitHub</Value></String>
+				<String><Key>UserName</Key><Value>octocat</Value></String>
+				<String><Key>Password</Key><Value Protected="True">hunter2</Value></String>
+				<String><Key>URL</Key><Value>https://github.com</Value></String>
+				<String><Key>otp</Key><Value Protected="True">otpauth://totp/github?secret=JBSWY3DP</Value></String>
+				<Binary><Key>codes.txt</Key><Value Ref="0"/></Binary>
+				<History>
+					<Entry><String><Key>Password</Key><Value Protected="True">hunter1</Value></String></Entry>
+				</History>
+			</Entry>
+			<Group>
+				<UUID>ZW1haWxlbWFpbGVtYWlsZQ==</UUID>
+				<Name>Email</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>Mail recovery</Value></String>
+					<String><Key>Notes</Key><Value Protected="True">ask the admin</Value></String>
+				</Entry>
+			</Group>
+			<Group>
+				<UUID>YmluYmluYmluYmluYmluYg==</UUID>
+				<Name>Recycle Bin</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>deleted</Value></String>
+					<String><Key>Password</Key><Value Protected="True">gone</Value></String>
+				</Entry>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>`
+
+var protectedValue = regexp.MustCompile(`<Value Protected="True">([^<]*)</Value>`)
+
+// protectXML encrypts protected values of the XML with the key stream in the document order
+func protectXML(xml string, stream func(dst, src []byte)) []byte {
+	return protectedValue.ReplaceAllFunc([]byte(xml), func(m []byte) []byte {
+		plain := protectedValue.FindSubmatch(m)[1]
+		sealed := make([]byte, len(plain))
+		stream(sealed, plain)
+		return []byte(`<Value Protected="True">` + base64.StdEncoding.EncodeToString(sealed) + `</Value>`)
+	})
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encryptCBC(t *testing.T, key, iv, data []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(append([]byte{}, data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return data
+}
+
+func headerField(id byte, value []byte, major int) []byte {
+	out := []byte{id}
+	if major == 3 {
+		out = binary.LittleEndian.AppendUint16(out, uint16(len(value)))
+	} else {
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(value)))
+	}
+	return append(out, value...)
+}
+
+func kdbxStart(major uint16) []byte {
+	out := append([]byte{}, kdbxSignature[:]...)
+	out = binary.LittleEndian.AppendUint16(out, 1)
+	return binary.LittleEndian.AppendUint16(out, major)
+}
+
+func compositeKey(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	sum = sha256.Sum256(sum[:])
+	return sum[:]
+}
+
+// writeKDBX3 writes the database of the XML with AES-KDF, AES and Salsa20 like KeePass 2.x did
+func writeKDBX3(t *testing.T, password, xml string) []byte {
+	masterSeed, transformSeed := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	iv, streamKey, startBytes := bytes.Repeat([]byte{3}, 16), bytes.Repeat([]byte{4}, 32), bytes.Repeat([]byte{5}, 32)
+	out := kdbxStart(3)
+	out = append(out, headerField(kdbxCipherID, []byte(kdbxAES), 3)...)
+	out = append(out, headerField(kdbxCompression, binary.LittleEndian.AppendUint32(nil, 1), 3)...)
+	out = append(out, headerField(kdbxMasterSeed, masterSeed, 3)...)
+	out = append(out, headerField(kdbxTransformSeed, transformSeed, 3)...)
+	out = append(out, headerField(kdbxTransformRounds, binary.LittleEndian.AppendUint64(nil, 1000), 3)...)
+	out = append(out, headerField(kdbxEncryptionIV, iv, 3)...)
+	out = append(out, headerField(kdbxProtectedStreamKey, streamKey, 3)...)
+	out = append(out, headerField(kdbxStreamStartBytes, startBytes, 3)...)
+	out = append(out, headerField(kdbxInnerRandomStream, binary.LittleEndian.AppendUint32(nil, kdbxStreamSalsa20), 3)...)
+	out = append(out, headerField(kdbxEndOfHeader, []byte("\r\n\r\n"), 3)...)
+
+	key := sha256.Sum256(streamKey)
+	keystream := make([]byte, 4096)
+	salsa20.XORKeyStream(keystream, keystream, kdbxSalsa20Nonce, &key)
+	content := protectXML(xml, func(dst, src []byte) {
+		for i := range src {
+			dst[i] = src[i] ^ keystream[i]
+		}
+		keystream = keystream[len(src):]
+	})
+	compressed := gzipped(t, content)
+	payload := append([]byte{}, startBytes...)
+	for i, block := range [][]byte{compressed[:len(compressed)/2], compressed[len(compressed)/2:], nil} {
+		sum := sha256.Sum256(block)
+		if block == nil {
+			sum = [32]byte{}
+		}
+		payload = binary.LittleEndian.AppendUint32(payload, uint32(i))
+		payload = append(payload, sum[:]...)
+		payload = binary.LittleEndian.AppendUint32(payload, uint32(len(block)))
+		payload = append(payload, block...)
+	}
+	transformed, err := aesKDF(compositeKey(password), transformSeed, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	master := sha256.Sum256(append(append([]byte{}, masterSeed...), transformed...))
+	return append(out, encryptCBC(t, master[:], iv, payload)...)
+}
+
+// writeKDBX4 writes the database of the XML with Argon2d, AES and ChaCha20 like KeePassXC does
+func writeKDBX4(t *testing.T, password, xml string, attachment []byte) []byte {
+	masterSeed, salt := bytes.Repeat([]byte{6}, 32), bytes.Repeat([]byte{7}, 32)
+	iv, streamKey := bytes.Repeat([]byte{8}, 16), bytes.Repeat([]byte{9}, 64)
+	dict := []byte{0, 1}
+	for _, item := range []struct {
+		kind  byte
+		name  string
+		value []byte
+	}{
+		{0x42, "$UUID", []byte(kdbxArgon2d)},
+		{0x42, "S", salt},
+		{0x04, "P", binary.LittleEndian.AppendUint32(nil, 2)},
+		{0x05, "M", binary.LittleEndian.AppendUint64(nil, 64*1024)},
+		{0x05, "I", binary.LittleEndian.AppendUint64(nil, 2)},
+		{0x04, "V", binary.LittleEndian.AppendUint32(nil, argon2Version)},
+	} {
+		dict = append(dict, item.kind)
+		dict = binary.LittleEndian.AppendUint32(dict, uint32(len(item.name)))
+		dict = append(dict, item.name...)
+		dict = binary.LittleEndian.AppendUint32(dict, uint32(len(item.value)))
+		dict = append(dict, item.value...)
+	}
+	dict = append(dict, 0)
+	header := kdbxStart(4)
+	header = append(header, headerField(kdbxCipherID, []byte(kdbxAES), 4)...)
+	header = append(header, headerField(kdbxCompression, binary.LittleEndian.AppendUint32(nil, 1), 4)...)
+	header = append(header, headerField(kdbxMasterSeed, masterSeed, 4)...)
+	header = append(header, headerField(kdbxEncryptionIV, iv, 4)...)
+	header = append(header, headerField(kdbxKdfParameters, dict, 4)...)
+	header = append(header, headerField(kdbxEndOfHeader, []byte("\r\n\r\n"), 4)...)
+
+	inner := headerField(kdbxInnerStreamID, binary.LittleEndian.AppendUint32(nil, kdbxStreamChaCha20), 4)
+	inner = append(inner, headerField(kdbxInnerStreamKey, streamKey, 4)...)
+	inner = append(inner, headerField(kdbxInnerBinary, append([]byte{1}, attachment...), 4)...)
+	inner = append(inner, headerField(kdbxInnerEnd, nil, 4)...)
+	sum := sha512.Sum512(streamKey)
+	stream, err := chacha20.NewUnauthenticatedCipher(sum[:32], sum[32:44])
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner = append(inner, protectXML(xml, stream.XORKeyStream)...)
+
+	transformed := argon2Key(argon2d, compositeKey(password), salt, nil, nil, 2, 64, 2, 32)
+	master := sha256.Sum256(append(append([]byte{}, masterSeed...), transformed...))
+	hmacKey := sha512.Sum512(append(append(append([]byte{}, masterSeed...), transformed...), 1))
+	encrypted := encryptCBC(t, master[:], iv, gzipped(t, inner))
+
+	headerHash := sha256.Sum256(header)
+	out := append(append(append([]byte{}, header...), headerHash[:]...), blockHMAC(hmacKey[:], ^uint64(0), header)...)
+	for i, block := range [][]byte{encrypted[:32], encrypted[32:], nil} {
+		size := binary.LittleEndian.AppendUint32(nil, uint32(len(block)))
+		out = append(out, blockHMAC(hmacKey[:], uint64(i), append(size, block...))...)
+		out = append(out, size...)
+		out = append(out, block...)
+	}
+	return out
+}
+
+func TestRead_KeePass(t *testing.T) {
+	attachment := []byte("1234-5678\n")
+	binaries := `<Binaries><Binary ID="0" Compressed="True">` + base64.StdEncoding.EncodeToString(gzipped(t, attachment)) + `</Binary></Binaries>`
+	for name, data := range map[string][]byte{
+		"KDBX 3.1": writeKDBX3(t, "correct horse", fmt.Sprintf(testKeePassXML, binaries)),
+		"KDBX 4":   writeKDBX4(t, "correct horse", fmt.Sprintf(testKeePassXML, ""), attachment),
+	} {
+		format, err := Detect("vault", data)
+		assert.NoError(t, err)
+		assert.Equal(t, FormatKeePass, format)
+
+		items, err := Read(FormatKeePass, data, "correct horse")
+		if !assert.NoError(t, err, name) || !assert.Len(t, items, 4, name) {
+			continue
+		}
+		login, ok := items[0].Secret.(*model.LoginWithPassword)
+		if assert.True(t, ok, name) {
+			assert.Equal(t, "GitHub", login.Name)
+			assert.Equal(t, "octocat", login.Login)
+			assert.Equal(t, "hunter2", login.Password, name)
+			assert.Equal(t, "https://github.com", login.Meta)
+			assert.Equal(t, []string{"dev", "work"}, login.Tags)
+		}
+		assert.Equal(t, "otp: otpauth://totp/github?secret=JBSWY3DP", items[1].Secret.(*model.SecretText).Text, name)
+		assert.Equal(t, model.SecretFileKind, items[2].Kind)
+		assert.Equal(t, "codes.txt", items[2].Secret.Data().Name)
+		assert.Equal(t, attachment, items[2].Content, name)
+		// Values after the history of an entry are decrypted with the right part of the key stream
+		note := items[3].Secret.(*model.SecretText)
+		assert.Equal(t, "ask the admin", note.Text, name)
+		assert.Equal(t, "Email", note.Folder)
+
+		_, err = Read(FormatKeePass, data, "wrong horse")
+		assert.ErrorIs(t, err, ErrWrongPassword, name)
+		_, err = Read(FormatKeePass, data, "")
+		assert.ErrorIs(t, err, ErrPasswordRequired, name)
+	}
+}
+
+func Test_kdbxHeader_transformKey(t *testing.T) {
+	u64 := func(v uint64) []byte {
+		return binary.LittleEndian.AppendUint64(nil, v)
+	}
+	argon2 := func(iterations, memory, parallelism uint64) map[string][]byte {
+		return map[string][]byte{
+			"$UUID": []byte(kdbxArgon2d), "V": u64(argon2Version), "S": make([]byte, 32),
+			"I": u64(iterations), "M": u64(memory), "P": u64(parallelism),
+		}
+	}
+	tests := []struct {
+		name    string
+		header  *kdbxHeader
+		wantErr error
+	}{
+		{name: "Argon2", header: &kdbxHeader{major: 4, kdf: argon2(1, 1<<20, 2)}},
+		{name: "Argon2 memory", header: &kdbxHeader{major: 4, kdf: argon2(1, 1<<40, 2)}, wantErr: errKDBXMalformed},
+		{name: "Argon2 memory wraps", header: &kdbxHeader{major: 4, kdf: argon2(1, 1<<42+1<<20, 2)}, wantErr: errKDBXMalformed},
+		{name: "Argon2 threads", header: &kdbxHeader{major: 4, kdf: argon2(1, 1<<20, 1<<31)}, wantErr: errKDBXMalformed},
+		{name: "Argon2 threads wrap", header: &kdbxHeader{major: 4, kdf: argon2(1, 1<<20, 1<<32+2)}, wantErr: errKDBXMalformed},
+		{name: "Argon2 no threads", header: &kdbxHeader{major: 4, kdf: argon2(1, 1<<20, 0)}, wantErr: errKDBXMalformed},
+		{name: "Argon2 iterations", header: &kdbxHeader{major: 4, kdf: argon2(1<<32, 1<<20, 2)}, wantErr: errKDBXMalformed},
+		{
+			name:    "AES-KDF rounds",
+			header:  &kdbxHeader{major: 4, kdf: map[string][]byte{"$UUID": []byte(kdbxAESKDF), "S": make([]byte, 32), "R": u64(1 << 63)}},
+			wantErr: errKDBXMalformed,
+		},
+		{
+			name:    "KDBX 3 rounds",
+			header:  &kdbxHeader{major: 3, transformSeed: make([]byte, 32), transformRounds: 1 << 40},
+			wantErr: errKDBXMalformed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.header.transformKey(compositeKey("correct horse"))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Len(t, key, 32)
+			}
+		})
+	}
+}
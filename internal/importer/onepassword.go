@@ -0,0 +1,201 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Categories of 1Password items
+const (
+	onePasswordLogin    = "001"
+	onePasswordCard     = "002"
+	onePasswordPassword = "005"
+	onePasswordDocument = "006"
+)
+
+// onePasswordExport is export.data of a 1PUX archive
+type onePasswordExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Attrs struct {
+				Name string `json:"name"`
+			} `json:"attrs"`
+			Items []onePasswordItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+type onePasswordItem struct {
+	FavIndex     int    `json:"favIndex"`
+	State        string `json:"state"`
+	CategoryUUID string `json:"categoryUuid"`
+	Overview     struct {
+		Title string   `json:"title"`
+		URL   string   `json:"url"`
+		Tags  []string `json:"tags"`
+	} `json:"overview"`
+	Details struct {
+		LoginFields []struct {
+			Name        string `json:"name"`
+			Value       string `json:"value"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Title  string `json:"title"`
+			Fields []struct {
+				Title string                     `json:"title"`
+				ID    string                     `json:"id"`
+				Value map[string]json.RawMessage `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+		DocumentAttributes *onePasswordDocumentAttributes `json:"documentAttributes"`
+	} `json:"details"`
+}
+
+type onePasswordDocumentAttributes struct {
+	FileName   string `json:"fileName"`
+	DocumentID string `json:"documentId"`
+}
+
+// readOnePassword reads the 1PUX archive of 1Password, vaults become folders and archived items get the
+// archived tag. Fields of sections are kept in the notes, documents and attached files become files.
+func readOnePassword(data []byte) ([]entry, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a 1PUX archive: %w", err)
+	}
+	files := make(map[string]*zip.File)
+	var exportData *zip.File
+	for _, f := range archive.File {
+		switch {
+		case f.Name == "export.data":
+			exportData = f
+		case strings.HasPrefix(f.Name, "files/"):
+			files[strings.TrimPrefix(f.Name, "files/")] = f
+		}
+	}
+	if exportData == nil {
+		return nil, fmt.Errorf("not a 1PUX archive: export.data is missing")
+	}
+	content, err := readZipFile(exportData)
+	if err != nil {
+		return nil, err
+	}
+	export := &onePasswordExport{}
+	if err := json.Unmarshal(content, export); err != nil {
+		return nil, fmt.Errorf("malformed export.data: %w", err)
+	}
+	// Documents are stored as files/<document id>__<file name>
+	attachment := func(doc *onePasswordDocumentAttributes) (file, error) {
+		f, ok := files[doc.DocumentID+"__"+doc.FileName]
+		if !ok {
+			return file{}, fmt.Errorf("file %s of the archive is missing", doc.FileName)
+		}
+		content, err := readZipFile(f)
+		return file{name: doc.FileName, content: content}, err
+	}
+	entries := make([]entry, 0)
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, item := range vault.Items {
+				e := entry{title: item.Overview.Title, url: item.Overview.URL, notes: item.Details.NotesPlain}
+				e.labels.Folder, e.labels.Tags, e.labels.Favorite = vault.Attrs.Name, item.Overview.Tags, item.FavIndex > 0
+				if item.State == "archived" {
+					e.labels.Tags = append(e.labels.Tags, "archived")
+				}
+				for _, f := range item.Details.LoginFields {
+					switch f.Designation {
+					case "username":
+						e.username = f.Value
+					case "password":
+						e.password = f.Value
+					default:
+						e.addField(f.Name, f.Value)
+					}
+				}
+				if item.CategoryUUID == onePasswordPassword {
+					e.password = item.Details.Password
+				}
+				if item.CategoryUUID == onePasswordCard {
+					e.card = &card{}
+				}
+				for _, section := range item.Details.Sections {
+					for _, f := range section.Fields {
+						if raw, ok := f.Value["file"]; ok {
+							doc := &onePasswordDocumentAttributes{}
+							if err := json.Unmarshal(raw, doc); err != nil {
+								return nil, err
+							}
+							a, err := attachment(doc)
+							if err != nil {
+								return nil, err
+							}
+							e.files = append(e.files, a)
+							continue
+						}
+						value := onePasswordValue(f.Value)
+						switch {
+						case e.card != nil && f.ID == "cardholder":
+							e.card.holder = value
+						case e.card != nil && f.ID == "ccnum":
+							e.card.number = value
+						case e.card != nil && f.ID == "cvv":
+							e.card.cvc = value
+						case e.card != nil && f.ID == "type":
+							e.card.brand = value
+						case e.card != nil && f.ID == "expiry":
+							e.card.expiry = value
+						default:
+							e.addField(f.Title, value)
+						}
+					}
+				}
+				if doc := item.Details.DocumentAttributes; item.CategoryUUID == onePasswordDocument && doc != nil {
+					a, err := attachment(doc)
+					if err != nil {
+						return nil, err
+					}
+					e.files = append(e.files, a)
+				}
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// onePasswordValue returns the text of the typed value of a field, e.g. {"concealed": "1234"}.
+// Months of cards are numbers like 202712 and become 12/2027, addresses and other objects are skipped.
+func onePasswordValue(value map[string]json.RawMessage) string {
+	for t, raw := range value {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s
+		}
+		var n int64
+		if json.Unmarshal(raw, &n) != nil {
+			continue
+		}
+		if t == "monthYear" {
+			return fmt.Sprintf("%02d/%d", n%100, n/100)
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	return ""
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
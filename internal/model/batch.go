@@ -0,0 +1,65 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Operations of a batch
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchOp is an operation of a batch on a secret of the kind. Create and update carry the sealed secret,
+// an update is checked against the version of the secret like PUT is. Delete carries the id only.
+type BatchOp struct {
+	Op     string `json:"op"`
+	Kind   string `json:"kind"`
+	ID     int    `json:"id,omitempty"`
+	Secret Secret `json:"secret,omitempty"`
+}
+
+// UnmarshalJSON decodes the secret of the operation by its kind, any name or alias of the kind is accepted
+func (o *BatchOp) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		Op     string          `json:"op"`
+		Kind   string          `json:"kind"`
+		ID     int             `json:"id"`
+		Secret json.RawMessage `json:"secret"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	kind, ok := KindByName(raw.Kind)
+	if !ok {
+		return fmt.Errorf("unknown kind %q", raw.Kind)
+	}
+	o.Op, o.Kind, o.ID, o.Secret = raw.Op, kind.Name(), raw.ID, nil
+	if len(raw.Secret) == 0 || string(raw.Secret) == "null" {
+		return nil
+	}
+	m := kind.New()
+	if err := json.Unmarshal(raw.Secret, m); err != nil {
+		return err
+	}
+	o.Secret = m
+	return nil
+}
+
+// BatchResult is the outcome of an operation of a batch, Status is the HTTP status the single request
+// would get. ID and Version are of the created, updated or deleted secret.
+type BatchResult struct {
+	Status  int    `json:"status"`
+	ID      int    `json:"id,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BatchResults are outcomes of operations of a batch in their order. Operations are applied all or none,
+// Applied tells which happened.
+type BatchResults struct {
+	Applied bool          `json:"applied"`
+	Results []BatchResult `json:"results"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchOp_UnmarshalJSON(t *testing.T) {
+	ops := make([]BatchOp, 0)
+	data := `[
+		{"op": "create", "kind": "login", "secret": {"name": "github", "login": "me"}},
+		{"op": "delete", "kind": "texts", "id": 7}
+	]`
+	if assert.NoError(t, json.Unmarshal([]byte(data), &ops)) && assert.Len(t, ops, 2) {
+		assert.Equal(t, LoginWithPasswordKind.Name(), ops[0].Kind)
+		if assert.IsType(t, &LoginWithPassword{}, ops[0].Secret) {
+			assert.Equal(t, "me", ops[0].Secret.(*LoginWithPassword).Login)
+		}
+		assert.Equal(t, SecretTextKind.Name(), ops[1].Kind)
+		assert.Equal(t, 7, ops[1].ID)
+		assert.Nil(t, ops[1].Secret)
+	}
+	assert.Error(t, json.Unmarshal([]byte(`[{"op": "create", "kind": "ticket"}]`), &ops))
+}
@@ -1,62 +1,152 @@
 package model
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
+// revisionKey holds the revision of the cache next to the kinds
+const revisionKey = "revision"
+
+// SecretCache holds secrets of every registered kind, it is marshaled as an object keyed by plural kind names.
+// Revision is the server revision the cache is synced to, zero means the cache must be fully synced.
 type SecretCache struct {
-	LoginWithPasswords []*LoginWithPassword `json:"login_and_passwords"`
-	CreditCards        []*CreditCard        `json:"credit_cards"`
-	SecretTexts        []*SecretText        `json:"secret_texts"`
-	SecretFiles        []*SecretFile        `json:"secret_files"`
+	secrets  map[string][]Secret
+	Revision int64
 }
 
-func (c *SecretCache) String() string {
-	return fmt.Sprintf("LoginsPasswords:%v, CreditCards: %v, SecretTexts:%v, SecretFiles: %v", c.LoginWithPasswords, c.CreditCards, c.SecretTexts, c.SecretFiles)
+// Get returns cached secrets of the kind
+func (c *SecretCache) Get(kind Kind) []Secret {
+	return c.secrets[kind.Plural()]
 }
 
-func (c *SecretCache) Encrypt(key, iv string) error {
-	for _, l := range c.LoginWithPasswords {
-		if err := l.Encrypt(key, iv); err != nil {
-			return err
-		}
+// Set replaces cached secrets of the kind
+func (c *SecretCache) Set(kind Kind, secrets []Secret) {
+	if c.secrets == nil {
+		c.secrets = make(map[string][]Secret)
 	}
-	for _, c := range c.CreditCards {
-		if err := c.Encrypt(key, iv); err != nil {
-			return err
+	c.secrets[kind.Plural()] = secrets
+}
+
+// Find returns cached secret of the kind by id
+func (c *SecretCache) Find(kind Kind, id int) (Secret, bool) {
+	for _, m := range c.Get(kind) {
+		if m.Data().ID == id {
+			return m, true
 		}
 	}
-	for _, t := range c.SecretTexts {
-		if err := t.Encrypt(key, iv); err != nil {
-			return err
+	return nil, false
+}
+
+// Apply patches the cache with changes of the server, changed secrets replace cached ones
+// with the same id and deleted ones are removed
+func (c *SecretCache) Apply(changes *SyncChanges) {
+	for _, kind := range Kinds() {
+		changed := changes.Secrets.Get(kind)
+		deleted := changes.Deleted[kind.Plural()]
+		if len(changed) == 0 && len(deleted) == 0 {
+			continue
 		}
-	}
-	for _, f := range c.SecretFiles {
-		if err := f.Encrypt(key, iv); err != nil {
-			return err
+		skip := make(map[int]bool, len(changed)+len(deleted))
+		for _, m := range changed {
+			skip[m.Data().ID] = true
+		}
+		for _, t := range deleted {
+			skip[t.ID] = true
 		}
+		secrets := make([]Secret, 0, len(c.Get(kind))+len(changed))
+		for _, m := range c.Get(kind) {
+			if !skip[m.Data().ID] {
+				secrets = append(secrets, m)
+			}
+		}
+		c.Set(kind, append(secrets, changed...))
 	}
-	return nil
+	c.Revision = changes.Revision
 }
 
-func (c *SecretCache) Decrypt(key, iv string) error {
-	for _, l := range c.LoginWithPasswords {
-		if err := l.Decrypt(key, iv); err != nil {
+func (c *SecretCache) String() string {
+	return fmt.Sprintf("%v", c.secrets)
+}
+
+func (c *SecretCache) MarshalJSON() ([]byte, error) {
+	if c.Revision == 0 {
+		return json.Marshal(c.secrets)
+	}
+	v := make(map[string]any, len(c.secrets)+1)
+	for kind, secrets := range c.secrets {
+		v[kind] = secrets
+	}
+	v[revisionKey] = c.Revision
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes secrets of registered kinds, unknown keys are skipped
+func (c *SecretCache) UnmarshalJSON(data []byte) error {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Revision = 0
+	if v, ok := raw[revisionKey]; ok {
+		if err := json.Unmarshal(v, &c.Revision); err != nil {
 			return err
 		}
 	}
-	for _, c := range c.CreditCards {
-		if err := c.Decrypt(key, iv); err != nil {
+	c.secrets = make(map[string][]Secret, len(raw))
+	for _, kind := range Kinds() {
+		v, ok := raw[kind.Plural()]
+		if !ok {
+			continue
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(v, &items); err != nil {
 			return err
 		}
+		secrets := make([]Secret, 0, len(items))
+		for _, item := range items {
+			m := kind.New()
+			if err := json.Unmarshal(item, m); err != nil {
+				return err
+			}
+			secrets = append(secrets, m)
+		}
+		c.secrets[kind.Plural()] = secrets
 	}
-	for _, t := range c.SecretTexts {
-		if err := t.Decrypt(key, iv); err != nil {
-			return err
+	return nil
+}
+
+func (c *SecretCache) Encrypt(key []byte) error {
+	for _, secrets := range c.secrets {
+		for _, m := range secrets {
+			if err := m.Encrypt(key); err != nil {
+				return err
+			}
 		}
 	}
-	for _, f := range c.SecretFiles {
-		if err := f.Decrypt(key, iv); err != nil {
-			return err
+	return nil
+}
+
+func (c *SecretCache) Decrypt(key []byte) error {
+	for _, secrets := range c.secrets {
+		for _, m := range secrets {
+			if err := m.Decrypt(key); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
+
+// Copy returns deep copy of the cache
+func (c *SecretCache) Copy() (*SecretCache, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	cp := &SecretCache{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
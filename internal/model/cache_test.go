@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretCache_Apply(t *testing.T) {
+	c := &SecretCache{Revision: 3}
+	c.Set(SecretTextKind, []Secret{
+		&SecretText{SecretData: SecretData{ID: 1, Name: "kept", Revision: 1}},
+		&SecretText{SecretData: SecretData{ID: 2, Name: "old", Revision: 2}},
+		&SecretText{SecretData: SecretData{ID: 3, Name: "deleted", Revision: 3}},
+	})
+	changes := NewSyncChanges(3)
+	changes.Add(SecretTextKind,
+		[]Secret{
+			&SecretText{SecretData: SecretData{ID: 2, Name: "new", Revision: 5}},
+			&SecretText{SecretData: SecretData{ID: 4, Name: "added", Revision: 4}},
+		},
+		[]Tombstone{{ID: 3, Revision: 6}},
+	)
+	assert.Equal(t, int64(6), changes.Revision)
+
+	c.Apply(changes)
+	var names []string
+	for _, m := range c.Get(SecretTextKind) {
+		names = append(names, m.Data().Name)
+	}
+	assert.Equal(t, []string{"kept", "new", "added"}, names)
+	assert.Equal(t, int64(6), c.Revision)
+}
+
+func TestSecretCache_JSON(t *testing.T) {
+	c := &SecretCache{Revision: 7}
+	c.Set(CreditCardKind, []Secret{&CreditCard{SecretData: SecretData{ID: 1, Revision: 7}}})
+	data, err := json.Marshal(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+	got := &SecretCache{}
+	if assert.NoError(t, json.Unmarshal(data, got)) {
+		assert.Equal(t, int64(7), got.Revision)
+		assert.Len(t, got.Get(CreditCardKind), 1)
+	}
+
+	// Caches saved before revisions are read as not synced
+	old := &SecretCache{}
+	if assert.NoError(t, json.Unmarshal([]byte(`{"creditcards": [{"id": 1}], "unknown": [{}]}`), old)) {
+		assert.Zero(t, old.Revision)
+		assert.Len(t, old.Get(CreditCardKind), 1)
+	}
+}
@@ -1,9 +1,7 @@
 package model
 
 import (
-	"cenarius/internal/encrypt"
 	"fmt"
-	"strings"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
@@ -30,54 +28,27 @@ func (s *CreditCard) Validate() error {
 		validation.Field(&s.OwnerName, validation.Required, is.ASCII),
 		validation.Field(&s.OwnerLastName, validation.Required, is.ASCII),
 		validation.Field(&s.Number, validation.Required, is.CreditCard),
-		validation.Field(&s.CVC, validation.Required),
+		validation.Field(&s.CVC, validation.Required, is.Digit),
 	)
 }
 
-func (s *CreditCard) Encrypt(key, iv string) error {
-	encOwnerName, err := encrypt.AESEncrypted(s.OwnerName, key, iv)
-	if err != nil {
-		return err
+func (s *CreditCard) Fields() []Field {
+	return []Field{
+		{Column: "owner_name", Label: "Owner Name", Value: &s.OwnerName, Encrypted: true},
+		{Column: "owner_last_name", Label: "Owner Last Name", Value: &s.OwnerLastName, Encrypted: true},
+		{Column: "number", Label: "Number", Value: &s.Number, Encrypted: true},
+		{Column: "cvc", Label: "CVC number", Value: &s.CVC, Encrypted: true, Hidden: true},
 	}
-	encOwnerLastName, err := encrypt.AESEncrypted(s.OwnerLastName, key, iv)
-	if err != nil {
-		return err
-	}
-	encNumber, err := encrypt.AESEncrypted(fmt.Sprintf("%s:%s:%s", s.Number, key, iv), key, iv)
-	if err != nil {
-		return err
-	}
-	encCVC, err := encrypt.AESEncrypted(s.CVC, key, iv)
-	if err != nil {
-		return err
-	}
-	s.OwnerName = encOwnerName
-	s.OwnerLastName = encOwnerLastName
-	s.Number = encNumber
-	s.CVC = encCVC
-	return nil
 }
 
-func (s *CreditCard) Decrypt(key, iv string) error {
-	decOwnerName, err := encrypt.AESDecrypted(s.OwnerName, key, iv)
-	if err != nil {
-		return err
-	}
-	decOwnerLastName, err := encrypt.AESDecrypted(s.OwnerLastName, key, iv)
-	if err != nil {
-		return err
-	}
-	decNumber, err := encrypt.AESDecrypted(s.Number, key, iv)
-	if err != nil {
-		return err
-	}
-	decCVC, err := encrypt.AESDecrypted(s.CVC, key, iv)
-	if err != nil {
-		return err
-	}
-	s.OwnerName = decOwnerName
-	s.OwnerLastName = decOwnerLastName
-	s.Number = strings.Split(decNumber, ":")[0]
-	s.CVC = decCVC
-	return nil
+func (s *CreditCard) ValidateEncrypted() error {
+	return validateEncryptedFields(s.Fields())
+}
+
+func (s *CreditCard) Encrypt(key []byte) error {
+	return encryptFields(s.Fields(), key)
+}
+
+func (s *CreditCard) Decrypt(key []byte) error {
+	return decryptFields(s.Fields(), key)
 }
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// SecretVersion describes a prior version of a secret kept in its history. UpdatedAt and ClientID
+// tell when and by which agent the version was saved, Op is the change which replaced it.
+type SecretVersion struct {
+	Version    int       `json:"version"`
+	Name       string    `json:"name"`
+	ClientID   string    `json:"client_id"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+	Op         string    `json:"op"`
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/ssh"
+)
+
+// Key pair types
+const (
+	KeyPairSSH = "ssh"
+	KeyPairGPG = "gpg"
+)
+
+var ErrFingerprintMismatch = errors.New("fingerprint doesn't match the private key")
+
+// KeyPair is an SSH or GPG private key with its passphrase. Type, public key and fingerprint
+// are derived from the private key by Derive.
+type KeyPair struct {
+	SecretData
+	Type        string `json:"type"`
+	PrivateKey  string `json:"private_key"`
+	Passphrase  string `json:"passphrase"`
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// String never shows the private key and the passphrase
+func (s *KeyPair) String() string {
+	return fmt.Sprintf(
+		"ID: %d, Name: %s, Type: %s, Fingerprint: %s, Public key: %s, Meta: %s",
+		s.ID, s.Name, s.Type, s.Fingerprint, strings.TrimSpace(s.PublicKey), s.Meta,
+	)
+}
+
+func (s *KeyPair) Validate() error {
+	if err := validation.ValidateStruct(
+		s,
+		validation.Field(&s.Type, validation.Required, validation.In(KeyPairSSH, KeyPairGPG)),
+		validation.Field(&s.PrivateKey, validation.Required),
+		validation.Field(&s.Fingerprint, validation.Required),
+	); err != nil {
+		return err
+	}
+	_, fingerprint, err := s.parse()
+	if err != nil {
+		return err
+	}
+	if fingerprint != s.Fingerprint {
+		return ErrFingerprintMismatch
+	}
+	return nil
+}
+
+// Derive detects the type of the private key and fills the public key and the fingerprint
+func (s *KeyPair) Derive() error {
+	s.Type = KeyPairSSH
+	if strings.Contains(s.PrivateKey, openpgp.PrivateKeyType) {
+		s.Type = KeyPairGPG
+	}
+	publicKey, fingerprint, err := s.parse()
+	if err != nil {
+		return err
+	}
+	s.PublicKey = publicKey
+	s.Fingerprint = fingerprint
+	return nil
+}
+
+func (s *KeyPair) parse() (string, string, error) {
+	if s.Type == KeyPairGPG {
+		return s.parseGPG()
+	}
+	signer, err := s.SSHSigner()
+	if err != nil {
+		return "", "", err
+	}
+	return string(ssh.MarshalAuthorizedKey(signer.PublicKey())), ssh.FingerprintSHA256(signer.PublicKey()), nil
+}
+
+// SSHKey returns the decrypted SSH private key
+func (s *KeyPair) SSHKey() (any, error) {
+	if s.Passphrase == "" {
+		return ssh.ParseRawPrivateKey([]byte(s.PrivateKey))
+	}
+	return ssh.ParseRawPrivateKeyWithPassphrase([]byte(s.PrivateKey), []byte(s.Passphrase))
+}
+
+func (s *KeyPair) SSHSigner() (ssh.Signer, error) {
+	key, err := s.SSHKey()
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(key)
+}
+
+// parseGPG returns armored public key and fingerprint of the primary key, the private key must decrypt with the passphrase
+func (s *KeyPair) parseGPG() (string, string, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(s.PrivateKey))
+	if err != nil {
+		return "", "", err
+	}
+	if len(entities) != 1 || entities[0].PrivateKey == nil {
+		return "", "", errors.New("exactly one private key is expected")
+	}
+	entity := entities[0]
+	if entity.PrivateKey.Encrypted {
+		if err := entity.PrivateKey.Decrypt([]byte(s.Passphrase)); err != nil {
+			return "", "", err
+		}
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return "", "", err
+	}
+	if err := entity.Serialize(w); err != nil {
+		return "", "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+	return buf.String(), fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint), nil
+}
+
+func (s *KeyPair) Fields() []Field {
+	return []Field{
+		{Column: "key_type", Label: "Type", Value: &s.Type, Encrypted: true, Generated: true},
+		{Column: "private_key", Label: "Private key file", Value: &s.PrivateKey, Encrypted: true, File: true},
+		{Column: "passphrase", Label: "Passphrase (empty if none)", Value: &s.Passphrase, Encrypted: true, Hidden: true},
+		{Column: "public_key", Label: "Public key", Value: &s.PublicKey, Encrypted: true, Generated: true},
+		{Column: "fingerprint", Label: "Fingerprint", Value: &s.Fingerprint, Encrypted: true, Generated: true},
+	}
+}
+
+func (s *KeyPair) ValidateEncrypted() error {
+	return validateEncryptedFields(s.Fields())
+}
+
+func (s *KeyPair) Encrypt(key []byte) error {
+	return encryptFields(s.Fields(), key)
+}
+
+func (s *KeyPair) Decrypt(key []byte) error {
+	return decryptFields(s.Fields(), key)
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/ssh"
+)
+
+func testSSHKey(t *testing.T, passphrase string) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "test")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "test", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(block))
+}
+
+func testGPGKey(t *testing.T) string {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Test", "", "test@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return buf.String()
+}
+
+func TestKeyPair_Derive(t *testing.T) {
+	tests := []struct {
+		name       string
+		m          *KeyPair
+		wantType   string
+		wantPublic string
+		wantErr    bool
+	}{
+		{name: "SSH", m: &KeyPair{PrivateKey: testSSHKey(t, "")}, wantType: KeyPairSSH, wantPublic: "ssh-ed25519 "},
+		{name: "SSHPassphrase", m: &KeyPair{PrivateKey: testSSHKey(t, "secret"), Passphrase: "secret"}, wantType: KeyPairSSH, wantPublic: "ssh-ed25519 "},
+		{name: "SSHWrongPassphrase", m: &KeyPair{PrivateKey: testSSHKey(t, "secret"), Passphrase: "wrong"}, wantErr: true},
+		{name: "SSHMissingPassphrase", m: &KeyPair{PrivateKey: testSSHKey(t, "secret")}, wantErr: true},
+		{name: "GPG", m: &KeyPair{PrivateKey: testGPGKey(t)}, wantType: KeyPairGPG, wantPublic: "-----BEGIN " + openpgp.PublicKeyType},
+		{name: "Garbage", m: &KeyPair{PrivateKey: "not a key"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.m.Derive()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Derive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, tt.wantType, tt.m.Type)
+			assert.True(t, strings.HasPrefix(tt.m.PublicKey, tt.wantPublic), "unexpected public key %s", tt.m.PublicKey)
+			assert.NotEmpty(t, tt.m.Fingerprint)
+			assert.NoError(t, tt.m.Validate())
+			assert.NotContains(t, tt.m.String(), tt.m.PrivateKey)
+
+			tt.m.Fingerprint = "SHA256:forged"
+			assert.ErrorIs(t, tt.m.Validate(), ErrFingerprintMismatch)
+		})
+	}
+}
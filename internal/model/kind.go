@@ -0,0 +1,96 @@
+package model
+
+import "strings"
+
+// Kind describes a registered kind of secrets
+type Kind interface {
+	// Name is a URI path segment of a single secret
+	Name() string
+	// Plural is a URI path segment of the list of secrets and a key of the cache
+	Plural() string
+	// Table is a database table secrets are stored in
+	Table() string
+	// Aliases are names the agent accepts for the kind
+	Aliases() []string
+	// Blob reports whether content of secrets is kept in the blob store
+	Blob() bool
+	New() Secret
+}
+
+// SecretPtr is a constraint satisfied by pointers to secret kinds
+type SecretPtr[T any] interface {
+	*T
+	Secret
+}
+
+// SecretKind is a kind of secrets of type T
+type SecretKind[T any, P SecretPtr[T]] struct {
+	name    string
+	plural  string
+	table   string
+	aliases []string
+}
+
+var kinds []Kind
+
+// RegisterKind registers a kind of secrets, this is the only place a new kind has to be added to
+func RegisterKind[T any, P SecretPtr[T]](name, plural, table string, aliases ...string) *SecretKind[T, P] {
+	k := &SecretKind[T, P]{name: name, plural: plural, table: table, aliases: aliases}
+	kinds = append(kinds, k)
+	return k
+}
+
+func (k *SecretKind[T, P]) Name() string {
+	return k.name
+}
+
+func (k *SecretKind[T, P]) Plural() string {
+	return k.plural
+}
+
+func (k *SecretKind[T, P]) Table() string {
+	return k.table
+}
+
+func (k *SecretKind[T, P]) Aliases() []string {
+	return k.aliases
+}
+
+func (k *SecretKind[T, P]) Blob() bool {
+	_, ok := k.New().(BlobSecret)
+	return ok
+}
+
+func (k *SecretKind[T, P]) New() Secret {
+	return P(new(T))
+}
+
+// Kinds returns registered kinds in order of registration
+func Kinds() []Kind {
+	return kinds
+}
+
+// KindByName finds the kind by its name, plural or alias, an alias may be in plural form too, e.g. cards
+func KindByName(name string) (Kind, bool) {
+	name = strings.ToLower(name)
+	for _, k := range kinds {
+		if k.Name() == name || k.Plural() == name {
+			return k, true
+		}
+		for _, alias := range k.Aliases() {
+			if alias == name || len(alias) > 1 && alias+"s" == name {
+				return k, true
+			}
+		}
+	}
+	return nil, false
+}
+
+var (
+	LoginWithPasswordKind = RegisterKind[LoginWithPassword]("loginwithpassword", "loginwithpasswords", "LoginWithPassword", "l", "login", "password", "lp")
+	CreditCardKind        = RegisterKind[CreditCard]("creditcard", "creditcards", "CreditCard", "c", "credit", "card", "cc")
+	SecretTextKind        = RegisterKind[SecretText]("secrettext", "secrettexts", "SecretText", "t", "text")
+	SecretFileKind        = RegisterKind[SecretFile]("secretfile", "secretfiles", "SecretFile", "f", "file")
+	OTPSecretKind         = RegisterKind[OTPSecret]("otpsecret", "otpsecrets", "OTPSecret", "o", "otp", "totp")
+	KeyPairKind           = RegisterKind[KeyPair]("keypair", "keypairs", "KeyPair", "k", "key", "ssh", "gpg")
+)
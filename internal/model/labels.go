@@ -0,0 +1,133 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// FolderSeparator separates folders of a folder path, e.g. work/databases
+const FolderSeparator = "/"
+
+// Limits of labels of a secret
+const (
+	maxFolderLength = 255
+	maxTagLength    = 64
+	maxTags         = 32
+)
+
+// Labels organize secrets of every kind. They are not encrypted, so the server filters secrets by them.
+// Folder is a path of nested folders, Tags are kept sorted without duplicates.
+type Labels struct {
+	Folder   string   `json:"folder,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Favorite bool     `json:"favorite,omitempty"`
+}
+
+// Normalize drops empty folders of the path and empty or repeated tags
+func (l *Labels) Normalize() {
+	l.Folder = CleanFolder(l.Folder)
+	l.Tags = cleanTags(l.Tags)
+}
+
+func (l *Labels) Validate() error {
+	return validation.ValidateStruct(
+		l,
+		validation.Field(&l.Folder, validation.Length(0, maxFolderLength)),
+		validation.Field(&l.Tags, validation.Length(0, maxTags), validation.Each(
+			validation.Length(1, maxTagLength),
+			validation.By(noComma),
+		)),
+	)
+}
+
+func noComma(value any) error {
+	if s, _ := value.(string); strings.Contains(s, ",") {
+		return errors.New("must not contain commas")
+	}
+	return nil
+}
+
+// CleanFolder trims folders of the path and drops empty ones, "/ work//db " becomes "work/db"
+func CleanFolder(path string) string {
+	folders := make([]string, 0)
+	for _, f := range strings.Split(path, FolderSeparator) {
+		if f = strings.TrimSpace(f); f != "" {
+			folders = append(folders, f)
+		}
+	}
+	return strings.Join(folders, FolderSeparator)
+}
+
+// ParseTags returns tags of the comma separated list
+func ParseTags(list string) []string {
+	return cleanTags(strings.Split(list, ","))
+}
+
+func cleanTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	cleaned := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		cleaned = append(cleaned, t)
+	}
+	sort.Strings(cleaned)
+	if len(cleaned) == 0 {
+		return nil
+	}
+	return cleaned
+}
+
+// InFolder reports whether the path is the folder or one of its subfolders, any path is in the empty folder
+func InFolder(path, folder string) bool {
+	return folder == "" || path == folder || strings.HasPrefix(path, folder+FolderSeparator)
+}
+
+// HasTag reports whether the tag is one of tags
+func (l *Labels) HasTag(tag string) bool {
+	for _, t := range l.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelFilter selects secrets in the folder or its subfolders having every tag, only favorite ones
+// if Favorite is set. The zero filter selects every secret.
+type LabelFilter struct {
+	Folder   string
+	Tags     []string
+	Favorite bool
+}
+
+// Match reports whether the filter selects the secret
+func (f LabelFilter) Match(m Secret) bool {
+	l := &m.Data().Labels
+	if !InFolder(l.Folder, f.Folder) || f.Favorite && !l.Favorite {
+		return false
+	}
+	for _, t := range f.Tags {
+		if !l.HasTag(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// SortByFolder sorts secrets by folder and name, so secrets of a folder follow each other
+func SortByFolder(secrets []Secret) {
+	sort.SliceStable(secrets, func(i, j int) bool {
+		a, b := secrets[i].Data(), secrets[j].Data()
+		if a.Folder != b.Folder {
+			return a.Folder < b.Folder
+		}
+		return a.Name < b.Name
+	})
+}
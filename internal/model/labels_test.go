@@ -0,0 +1,64 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanFolder(t *testing.T) {
+	assert.Equal(t, "work/db", CleanFolder("/ work//db "))
+	assert.Equal(t, "", CleanFolder(" / "))
+}
+
+func TestParseTags(t *testing.T) {
+	assert.Equal(t, []string{"aws", "prod"}, ParseTags(" prod,aws,, prod "))
+	assert.Nil(t, ParseTags(""))
+}
+
+func TestLabels_Validate(t *testing.T) {
+	assert.NoError(t, (&Labels{Folder: "work", Tags: []string{"aws"}}).Validate())
+	assert.Error(t, (&Labels{Tags: []string{"a,b"}}).Validate())
+	assert.Error(t, (&Labels{Tags: []string{strings.Repeat("t", maxTagLength+1)}}).Validate())
+	assert.Error(t, (&Labels{Folder: strings.Repeat("f", maxFolderLength+1)}).Validate())
+}
+
+func TestLabelFilter_Match(t *testing.T) {
+	m := &SecretText{SecretData: SecretData{Labels: Labels{Folder: "work/db", Tags: []string{"aws", "prod"}, Favorite: true}}}
+	plain := &SecretText{}
+	tests := []struct {
+		name   string
+		filter LabelFilter
+		m      Secret
+		want   bool
+	}{
+		{name: "zero", filter: LabelFilter{}, m: plain, want: true},
+		{name: "folder", filter: LabelFilter{Folder: "work/db"}, m: m, want: true},
+		{name: "parent folder", filter: LabelFilter{Folder: "work"}, m: m, want: true},
+		{name: "folder prefix", filter: LabelFilter{Folder: "wo"}, m: m, want: false},
+		{name: "every tag", filter: LabelFilter{Tags: []string{"prod", "aws"}}, m: m, want: true},
+		{name: "missing tag", filter: LabelFilter{Tags: []string{"aws", "dev"}}, m: m, want: false},
+		{name: "favorite", filter: LabelFilter{Favorite: true}, m: m, want: true},
+		{name: "not favorite", filter: LabelFilter{Favorite: true}, m: plain, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Match(tt.m))
+		})
+	}
+}
+
+func TestSortByFolder(t *testing.T) {
+	secrets := []Secret{
+		&SecretText{SecretData: SecretData{Name: "b", Labels: Labels{Folder: "work"}}},
+		&SecretText{SecretData: SecretData{Name: "c"}},
+		&SecretText{SecretData: SecretData{Name: "a", Labels: Labels{Folder: "work"}}},
+	}
+	SortByFolder(secrets)
+	var names []string
+	for _, m := range secrets {
+		names = append(names, m.Data().Name)
+	}
+	assert.Equal(t, []string{"c", "a", "b"}, names)
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"cenarius/internal/encrypt"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// LegacyVault holds secrets of an account made before vault keys, which the server sealed with the hash
+// of the master password. The server hands them out opened, the agent returns them sealed with the vault key
+// together with AuthKey, which replaces the master password.
+type LegacyVault struct {
+	AuthKey string       `json:"auth_key,omitempty"`
+	Secrets *SecretCache `json:"secrets"`
+	Trash   *SecretCache `json:"trash"`
+	History *SecretCache `json:"history"`
+}
+
+func (v *LegacyVault) Validate() error {
+	return validation.ValidateStruct(
+		v,
+		validation.Field(&v.AuthKey, validation.Required, validation.Length(8, 72)),
+	)
+}
+
+// IsLegacy reports whether the secret was sealed by the server before vault keys.
+// Legacy files have no file key, so an empty encrypted field marks a legacy secret too.
+func IsLegacy(m Secret) bool {
+	for _, f := range m.Fields() {
+		if f.Encrypted && !encrypt.Versioned(*f.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenLegacy opens the legacy secret with the hash of the master password, the secret is left untouched on error.
+// Blob names were sealed as well, and legacy card numbers carry the key appended to the number.
+func OpenLegacy(m Secret, passwordHash string) error {
+	values := make([]*string, 0)
+	for _, f := range m.Fields() {
+		if f.Encrypted && *f.Value != "" {
+			values = append(values, f.Value)
+		}
+	}
+	if b, ok := m.(BlobSecret); ok {
+		values = append(values, b.BlobName())
+	}
+	opened := make([]string, len(values))
+	for i, v := range values {
+		o, err := encrypt.OpenLegacy(*v, passwordHash)
+		if err != nil {
+			return err
+		}
+		opened[i] = o
+	}
+	for i, v := range values {
+		*v = opened[i]
+	}
+	if c, ok := m.(*CreditCard); ok {
+		c.Number, _, _ = strings.Cut(c.Number, ":")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"cenarius/internal/encrypt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenLegacy(t *testing.T) {
+	hash, err := HashFromString("master password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seal := func(s string) string {
+		v, err := encrypt.AESEncrypted(s, hash[:32], hash[:16])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	card := &CreditCard{
+		OwnerName:     seal("Alice"),
+		OwnerLastName: seal("Smith"),
+		Number:        seal("4111111111111111:" + hash[:32] + ":" + hash[:16]),
+		CVC:           seal("123"),
+	}
+	if assert.True(t, IsLegacy(card)) && assert.NoError(t, OpenLegacy(card, hash)) {
+		assert.Equal(t, "Alice", card.OwnerName)
+		assert.Equal(t, "4111111111111111", card.Number)
+		assert.Equal(t, "123", card.CVC)
+	}
+
+	// Legacy files have no file key, the blob name is sealed instead
+	file := &SecretFile{Path: seal("/var/lib/cenarius/1/report.pdf")}
+	if assert.True(t, IsLegacy(file)) && assert.NoError(t, OpenLegacy(file, hash)) {
+		assert.Equal(t, "/var/lib/cenarius/1/report.pdf", file.Path)
+		assert.Empty(t, file.Key)
+	}
+
+	text := &SecretText{Text: seal("text")}
+	assert.Error(t, OpenLegacy(text, hash[:31]))
+	assert.Equal(t, seal("text"), text.Text, "secret is left untouched on error")
+
+	assert.NoError(t, text.Encrypt(encrypt.DeriveKey("master password", "salt")))
+	assert.False(t, IsLegacy(text))
+}
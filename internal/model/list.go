@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// Orders of listed secrets, secrets are listed by id without an order
+const (
+	SortName    = "name"
+	SortCreated = "created_at"
+	SortUpdated = "updated_at"
+)
+
+// ValidSort reports whether secrets can be listed in the order
+func ValidSort(sort string) bool {
+	return sort == "" || sort == SortName || sort == SortCreated || sort == SortUpdated
+}
+
+// ListKey is the position of a secret in the order of a list, the id breaks ties
+type ListKey struct {
+	ID   int       `json:"id"`
+	Name string    `json:"name,omitempty"`
+	At   time.Time `json:"at,omitempty"`
+}
+
+// KeyOf returns the position of the secret in the order
+func KeyOf(m Secret, sort string) ListKey {
+	d := m.Data()
+	key := ListKey{ID: d.ID}
+	switch sort {
+	case SortName:
+		key.Name = d.Name
+	case SortCreated:
+		key.At = d.CreatedAt
+	case SortUpdated:
+		key.At = d.UpdatedAt
+	}
+	return key
+}
+
+// ListQuery selects a page of secrets of a kind matching the label filter and the time ranges,
+// zero times leave ranges open. The page follows the secret at After in the order, Desc reverses the order.
+// Zero Limit lists every secret.
+type ListQuery struct {
+	LabelFilter
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	Sort          string
+	Desc          bool
+	Limit         int
+	After         *ListKey
+}
@@ -1,7 +1,6 @@
 package model
 
 import (
-	"cenarius/internal/encrypt"
 	"fmt"
 
 	validation "github.com/go-ozzo/ozzo-validation"
@@ -26,30 +25,21 @@ func (s *LoginWithPassword) Validate() error {
 	)
 }
 
-func (s *LoginWithPassword) Encrypt(key, iv string) error {
-	encLogin, err := encrypt.AESEncrypted(s.Login, key, iv)
-	if err != nil {
-		return err
+func (s *LoginWithPassword) Fields() []Field {
+	return []Field{
+		{Column: "login", Label: "Login", Value: &s.Login, Encrypted: true},
+		{Column: "password", Label: "Password", Value: &s.Password, Encrypted: true, Hidden: true},
 	}
-	encPassword, err := encrypt.AESEncrypted(s.Password, key, iv)
-	if err != nil {
-		return err
-	}
-	s.Login = encLogin
-	s.Password = encPassword
-	return nil
 }
 
-func (s *LoginWithPassword) Decrypt(key, iv string) error {
-	decLogin, err := encrypt.AESDecrypted(s.Login, key, iv)
-	if err != nil {
-		return err
-	}
-	decPassword, err := encrypt.AESDecrypted(s.Password, key, iv)
-	if err != nil {
-		return err
-	}
-	s.Login = decLogin
-	s.Password = decPassword
-	return nil
+func (s *LoginWithPassword) ValidateEncrypted() error {
+	return validateEncryptedFields(s.Fields())
+}
+
+func (s *LoginWithPassword) Encrypt(key []byte) error {
+	return encryptFields(s.Fields(), key)
+}
+
+func (s *LoginWithPassword) Decrypt(key []byte) error {
+	return decryptFields(s.Fields(), key)
 }
@@ -1,12 +1,30 @@
 package model
 
+import "time"
+
+// Encrypter is implemented by secrets which payload is encrypted on the agent side.
+// Server stores the payload as is and never calls these methods.
 type Encrypter interface {
-	Encrypt(string, string) error
-	Decrypt(string, string) error
+	Encrypt([]byte) error
+	Decrypt([]byte) error
+	ValidateEncrypted() error
 }
+
+// SecretData is common data of secrets, Revision and UpdatedAt are set by the server on every change,
+// CreatedAt when the secret is added.
+// Version counts updates of the secret, an update of a stale version is rejected.
+// ClientID names the agent which saved the version. DeletedAt is set for secrets in the trash.
+// Labels place the secret in a folder and tag it.
 type SecretData struct {
-	ID     int    `json:"id"`
-	UserID int    `json:"user_id"`
-	Name   string `json:"name"`
-	Meta   string `json:"meta"`
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Name      string     `json:"name"`
+	Meta      string     `json:"meta"`
+	Version   int        `json:"version"`
+	Revision  int64      `json:"revision"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClientID  string     `json:"client_id,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Labels
 }
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"cenarius/internal/encrypt"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+)
+
+// Defaults of otpauth URI parameters
+const (
+	otpDefaultDigits = "6"
+	otpDefaultPeriod = "30"
+)
+
+var (
+	ErrNotOTPAuthURI  = errors.New("not an otpauth URI")
+	ErrUnsupportedOTP = errors.New("only totp is supported")
+)
+
+// OTPSecret is a TOTP seed. Digits and Period are kept as strings, like every payload field
+// they are encrypted on the agent side. Empty Algorithm, Digits and Period mean defaults.
+type OTPSecret struct {
+	SecretData
+	Issuer    string `json:"issuer"`
+	Account   string `json:"account"`
+	Seed      string `json:"seed"`
+	Algorithm string `json:"algorithm"`
+	Digits    string `json:"digits"`
+	Period    string `json:"period"`
+}
+
+// String never shows the seed
+func (s *OTPSecret) String() string {
+	return fmt.Sprintf(
+		"ID: %d, Name: %s, Issuer: %s, Account: %s, Algorithm: %s, Digits: %s, Period: %s, Meta: %s",
+		s.ID, s.Name, s.Issuer, s.Account, s.Algorithm, s.Digits, s.Period, s.Meta,
+	)
+}
+
+func (s *OTPSecret) Validate() error {
+	return validation.ValidateStruct(
+		s,
+		validation.Field(&s.Seed, validation.Required, validation.By(validateBase32)),
+		validation.Field(&s.Algorithm, validation.In(encrypt.OTPAlgorithmSHA1, encrypt.OTPAlgorithmSHA256, encrypt.OTPAlgorithmSHA512)),
+		validation.Field(&s.Digits, is.Digit, validation.In("6", "7", "8")),
+		validation.Field(&s.Period, is.Digit, validation.By(validatePeriod)),
+	)
+}
+
+func validateBase32(value any) error {
+	_, err := decodeSeed(value.(string))
+	return err
+}
+
+func validatePeriod(value any) error {
+	if p, err := strconv.Atoi(value.(string)); err == nil && p < 1 {
+		return errors.New("must be positive")
+	}
+	return nil
+}
+
+// decodeSeed decodes base32 seed, padding, spaces and case are not significant
+func decodeSeed(seed string) ([]byte, error) {
+	seed = strings.ToUpper(strings.ReplaceAll(seed, " ", ""))
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(seed, "="))
+}
+
+func (s *OTPSecret) Fields() []Field {
+	return []Field{
+		{Column: "issuer", Label: "Issuer", Value: &s.Issuer, Encrypted: true},
+		{Column: "account", Label: "Account", Value: &s.Account, Encrypted: true},
+		{Column: "seed", Label: "Base32 Seed", Value: &s.Seed, Encrypted: true, Hidden: true},
+		{Column: "algorithm", Label: "Algorithm (SHA1|SHA256|SHA512, empty for SHA1)", Value: &s.Algorithm, Encrypted: true},
+		{Column: "digits", Label: "Digits (empty for 6)", Value: &s.Digits, Encrypted: true},
+		{Column: "period", Label: "Period in seconds (empty for 30)", Value: &s.Period, Encrypted: true},
+	}
+}
+
+func (s *OTPSecret) ValidateEncrypted() error {
+	return validateEncryptedFields(s.Fields())
+}
+
+func (s *OTPSecret) Encrypt(key []byte) error {
+	return encryptFields(s.Fields(), key)
+}
+
+func (s *OTPSecret) Decrypt(key []byte) error {
+	return decryptFields(s.Fields(), key)
+}
+
+// ParseURI fills the secret from otpauth://totp/Issuer:account?secret=...&issuer=... URI
+func (s *OTPSecret) ParseURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "otpauth" {
+		return ErrNotOTPAuthURI
+	}
+	if u.Host != "totp" {
+		return fmt.Errorf("%w: %s", ErrUnsupportedOTP, u.Host)
+	}
+	q := u.Query()
+	label := strings.TrimPrefix(u.Path, "/")
+	issuer, account, found := strings.Cut(label, ":")
+	if !found {
+		issuer, account = "", label
+	}
+	s.Issuer = strings.TrimSpace(issuer)
+	s.Account = strings.TrimSpace(account)
+	if q.Has("issuer") {
+		s.Issuer = q.Get("issuer")
+	}
+	s.Seed = q.Get("secret")
+	s.Algorithm = strings.ToUpper(q.Get("algorithm"))
+	if s.Algorithm == "" {
+		s.Algorithm = encrypt.OTPAlgorithmSHA1
+	}
+	s.Digits = q.Get("digits")
+	if s.Digits == "" {
+		s.Digits = otpDefaultDigits
+	}
+	s.Period = q.Get("period")
+	if s.Period == "" {
+		s.Period = otpDefaultPeriod
+	}
+	return s.Validate()
+}
+
+// Code returns the one-time code valid at t and how long it stays valid
+func (s *OTPSecret) Code(t time.Time) (string, time.Duration, error) {
+	key, err := decodeSeed(s.Seed)
+	if err != nil {
+		return "", 0, err
+	}
+	algorithm := s.Algorithm
+	if algorithm == "" {
+		algorithm = encrypt.OTPAlgorithmSHA1
+	}
+	digits, period := s.Digits, s.Period
+	if digits == "" {
+		digits = otpDefaultDigits
+	}
+	if period == "" {
+		period = otpDefaultPeriod
+	}
+	d, err := strconv.Atoi(digits)
+	if err != nil {
+		return "", 0, err
+	}
+	p, err := strconv.Atoi(period)
+	if err != nil {
+		return "", 0, err
+	}
+	return encrypt.TOTP(key, t, time.Duration(p)*time.Second, d, algorithm)
+}
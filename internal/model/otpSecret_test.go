@@ -0,0 +1,57 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOTPSecret_ParseURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    OTPSecret
+		wantErr bool
+	}{
+		{
+			name: "Defaults",
+			uri:  "otpauth://totp/Example:alice@example.org?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+			want: OTPSecret{Issuer: "Example", Account: "alice@example.org", Seed: "JBSWY3DPEHPK3PXP", Algorithm: "SHA1", Digits: "6", Period: "30"},
+		},
+		{
+			name: "Parameters",
+			uri:  "otpauth://totp/ACME%20Co:john?secret=JBSWY3DPEHPK3PXP&algorithm=sha256&digits=8&period=60",
+			want: OTPSecret{Issuer: "ACME Co", Account: "john", Seed: "JBSWY3DPEHPK3PXP", Algorithm: "SHA256", Digits: "8", Period: "60"},
+		},
+		{name: "HOTP", uri: "otpauth://hotp/Example:alice?secret=JBSWY3DPEHPK3PXP&counter=1", wantErr: true},
+		{name: "Scheme", uri: "https://totp/Example:alice?secret=JBSWY3DPEHPK3PXP", wantErr: true},
+		{name: "NoSecret", uri: "otpauth://totp/Example:alice", wantErr: true},
+		{name: "BadSecret", uri: "otpauth://totp/Example:alice?secret=0189", wantErr: true},
+		{name: "BadDigits", uri: "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&digits=12", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &OTPSecret{}
+			err := m.ParseURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseURI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, *m)
+			}
+		})
+	}
+}
+
+func TestOTPSecret_Code(t *testing.T) {
+	// RFC 6238 SHA1 seed "12345678901234567890" in base32, lower case without padding
+	m := &OTPSecret{Seed: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", Digits: "8"}
+	code, valid, err := m.Code(time.Unix(1111111109, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "07081804", code)
+	assert.Equal(t, 1*time.Second, valid)
+	assert.NotContains(t, m.String(), m.Seed)
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// PasswordChange is a request to change the master password.
+// Secrets must hold every secret of the user re-encrypted with the key derived from the new password and KDFSalt,
+// Trash holds secrets of the trash and History every prior version of the secrets re-encrypted the same way.
+// OldPassword and Password are auth keys derived from the old and the new vault key, the master password is never sent.
+type PasswordChange struct {
+	OldPassword string       `json:"old_password"`
+	Password    string       `json:"password"`
+	KDFSalt     string       `json:"kdf_salt"`
+	Secrets     *SecretCache `json:"secrets"`
+	Trash       *SecretCache `json:"trash"`
+	History     *SecretCache `json:"history"`
+}
+
+func (p *PasswordChange) Validate() error {
+	return validation.ValidateStruct(
+		p,
+		validation.Field(&p.OldPassword, validation.Required),
+		validation.Field(&p.Password, validation.Required, validation.Length(8, 72)),
+		validation.Field(&p.KDFSalt, validation.Required),
+		validation.Field(&p.Secrets, validation.NotNil),
+	)
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// maxSearchWords limits words of a search query, the rest of the query is ignored
+const maxSearchWords = 8
+
+// SearchWords returns lowercase words of the search query. Words are runs of letters and digits,
+// so punctuation never reaches the text search query syntax.
+func SearchWords(q string) []string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchWords {
+		words = words[:maxSearchWords]
+	}
+	return words
+}
+
+// SearchHit is a secret of any kind matching a search query, hits with a higher rank match better
+type SearchHit struct {
+	Kind   string  `json:"kind"`
+	Rank   float64 `json:"rank"`
+	Secret Secret  `json:"secret"`
+}
+
+// UnmarshalJSON decodes the secret of the hit by its kind, the secret of an unknown kind is left nil
+func (h *SearchHit) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		Kind   string          `json:"kind"`
+		Rank   float64         `json:"rank"`
+		Secret json.RawMessage `json:"secret"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	h.Kind, h.Rank, h.Secret = raw.Kind, raw.Rank, nil
+	kind, ok := KindByName(raw.Kind)
+	if !ok || raw.Secret == nil {
+		return nil
+	}
+	m := kind.New()
+	if err := json.Unmarshal(raw.Secret, m); err != nil {
+		return err
+	}
+	h.Secret = m
+	return nil
+}
+
+// SearchResults is a page of search hits, best first. NextCursor is passed as cursor to get the next page,
+// it is empty on the last page.
+type SearchResults struct {
+	Hits       []SearchHit `json:"hits"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
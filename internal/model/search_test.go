@@ -0,0 +1,32 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchWords(t *testing.T) {
+	assert.Equal(t, []string{"github", "work", "2fa"}, SearchWords(" GitHub: work-2FA! "))
+	assert.Empty(t, SearchWords(" & | :* "))
+	assert.Len(t, SearchWords("a b c d e f g h i j"), maxSearchWords)
+}
+
+func TestSearchHit_UnmarshalJSON(t *testing.T) {
+	data, err := json.Marshal([]SearchHit{
+		{Kind: SecretTextKind.Name(), Rank: 0.5, Secret: &SecretText{SecretData: SecretData{ID: 3, Name: "note"}, Text: "sealed"}},
+		{Kind: "unknown", Rank: 0.1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits := make([]SearchHit, 0)
+	if assert.NoError(t, json.Unmarshal(data, &hits)) && assert.Len(t, hits, 2) {
+		if assert.IsType(t, &SecretText{}, hits[0].Secret) {
+			assert.Equal(t, "sealed", hits[0].Secret.(*SecretText).Text)
+		}
+		assert.Equal(t, 0.5, hits[0].Rank)
+		assert.Nil(t, hits[1].Secret)
+	}
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"cenarius/internal/encrypt"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// Secret is implemented by pointers to secret kinds. Common data is shared by all kinds,
+// the payload is described by Fields, so stores, the server and the agent handle any kind the same way.
+type Secret interface {
+	Encrypter
+	Validate() error
+	Data() *SecretData
+	Fields() []Field
+	String() string
+}
+
+// BlobSecret is a secret which content is kept in the blob store,
+// BlobName points to the field holding the name of the blob
+type BlobSecret interface {
+	Secret
+	BlobName() *string
+}
+
+// Field is a payload field of a secret
+type Field struct {
+	// Column is a database column the field is stored in
+	Column string
+	// Label is shown to the user when the field is entered
+	Label string
+	Value *string
+	// Encrypted fields are sealed with the vault key by the agent
+	Encrypted bool
+	// Hidden fields are not echoed when entered and are masked when shown
+	Hidden bool
+	// Generated fields are set by the agent or the server and never entered by the user
+	Generated bool
+	// File fields are read from a file the user enters a path of
+	File bool
+}
+
+func (s *SecretData) Data() *SecretData {
+	return s
+}
+
+// encryptFields seals encrypted fields, fields are left untouched on error
+func encryptFields(fields []Field, key []byte) error {
+	sealed := make([]string, len(fields))
+	for i, f := range fields {
+		if !f.Encrypted {
+			continue
+		}
+		v, err := encrypt.Seal(*f.Value, key)
+		if err != nil {
+			return err
+		}
+		sealed[i] = v
+	}
+	for i, f := range fields {
+		if f.Encrypted {
+			*f.Value = sealed[i]
+		}
+	}
+	return nil
+}
+
+// decryptFields opens encrypted fields, fields are left untouched on error
+func decryptFields(fields []Field, key []byte) error {
+	opened := make([]string, len(fields))
+	for i, f := range fields {
+		if !f.Encrypted {
+			continue
+		}
+		v, err := encrypt.Open(*f.Value, key)
+		if err != nil {
+			return err
+		}
+		opened[i] = v
+	}
+	for i, f := range fields {
+		if f.Encrypted {
+			*f.Value = opened[i]
+		}
+	}
+	return nil
+}
+
+// validateEncryptedFields checks that every encrypted field is present
+func validateEncryptedFields(fields []Field) error {
+	errs := validation.Errors{}
+	for _, f := range fields {
+		if f.Encrypted {
+			errs[f.Column] = validation.Validate(*f.Value, validation.Required)
+		}
+	}
+	return errs.Filter()
+}
+
+// URIImporter is implemented by secrets which can be filled from a URI, e.g. otpauth://
+type URIImporter interface {
+	ParseURI(string) error
+}
+
+// Deriver is implemented by secrets with generated fields derived from entered ones,
+// the agent calls Derive before the secret is validated
+type Deriver interface {
+	Derive() error
+}
@@ -1,18 +1,17 @@
 package model
 
 import (
-	"cenarius/internal/encrypt"
 	"fmt"
-	"io/fs"
-	"os"
 
 	validation "github.com/go-ozzo/ozzo-validation"
-	log "github.com/sirupsen/logrus"
 )
 
+// SecretFile is an uploaded file. Path is a blob name in the server storage and
+// Key is a per-file key the agent encrypts the file content with.
 type SecretFile struct {
 	SecretData
 	Path string `json:"path"`
+	Key  string `json:"key"`
 }
 
 func (s *SecretFile) String() string {
@@ -27,40 +26,26 @@ func (s *SecretFile) Validate() error {
 	)
 }
 
-func (s *SecretFile) Encrypt(key, iv string) error {
-	encPath, err := encrypt.AESEncrypted(s.Path, key, iv)
-	if err != nil {
-		return err
+// Fields of the file are generated, the agent asks for a local path and uploads the file instead
+func (s *SecretFile) Fields() []Field {
+	return []Field{
+		{Column: "path", Label: "Path", Value: &s.Path, Generated: true},
+		{Column: "file_key", Label: "Key", Value: &s.Key, Encrypted: true, Hidden: true, Generated: true},
 	}
-	s.Path = encPath
-	return nil
 }
 
-func (s *SecretFile) Decrypt(key, iv string) error {
-	decPath, err := encrypt.AESDecrypted(s.Path, key, iv)
-	if err != nil {
-		return err
-	}
-	s.Path = decPath
-	return nil
+func (s *SecretFile) BlobName() *string {
+	return &s.Path
 }
 
-func (s *SecretFile) Remove() error {
-	if _, err := s.stat(); err != nil {
-		return err
-	}
-	if err := os.Remove(s.Path); err != nil {
-		log.Errorf("Unable to remove file %v: %v", s, err)
-		return err
-	}
-	return nil
+func (s *SecretFile) ValidateEncrypted() error {
+	return validateEncryptedFields(s.Fields())
 }
 
-func (s *SecretFile) stat() (fs.FileInfo, error) {
-	stat, err := os.Stat(s.Path)
-	if err != nil {
-		log.Errorf("Unable to get file stat %v: %v", s, err)
-		return nil, err
-	}
-	return stat, nil
+func (s *SecretFile) Encrypt(key []byte) error {
+	return encryptFields(s.Fields(), key)
+}
+
+func (s *SecretFile) Decrypt(key []byte) error {
+	return decryptFields(s.Fields(), key)
 }
@@ -1,7 +1,6 @@
 package model
 
 import (
-	"cenarius/internal/encrypt"
 	"fmt"
 
 	validation "github.com/go-ozzo/ozzo-validation"
@@ -24,20 +23,20 @@ func (s *SecretText) Validate() error {
 	)
 }
 
-func (s *SecretText) Encrypt(key, iv string) error {
-	encText, err := encrypt.AESEncrypted(s.Text, key, iv)
-	if err != nil {
-		return err
+func (s *SecretText) Fields() []Field {
+	return []Field{
+		{Column: "text", Label: "Secret Text", Value: &s.Text, Encrypted: true},
 	}
-	s.Text = encText
-	return nil
 }
 
-func (s *SecretText) Decrypt(key, iv string) error {
-	decText, err := encrypt.AESDecrypted(s.Text, key, iv)
-	if err != nil {
-		return err
-	}
-	s.Text = decText
-	return nil
+func (s *SecretText) ValidateEncrypted() error {
+	return validateEncryptedFields(s.Fields())
+}
+
+func (s *SecretText) Encrypt(key []byte) error {
+	return encryptFields(s.Fields(), key)
+}
+
+func (s *SecretText) Decrypt(key []byte) error {
+	return decryptFields(s.Fields(), key)
 }
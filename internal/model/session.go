@@ -0,0 +1,20 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+type Session struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	KDFSalt   string    `json:"kdf_salt"`
+}
+
+func (s *Session) String() string {
+	return fmt.Sprintf("ExpiresAt: %s", s.ExpiresAt)
+}
+
+func (s *Session) Expired() bool {
+	return s == nil || !time.Now().Before(s.ExpiresAt)
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// Tombstone is left by a deleted secret, so agents remove it from their caches on sync
+type Tombstone struct {
+	ID        int       `json:"id"`
+	Revision  int64     `json:"revision"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncChanges are secrets changed and deleted after a revision. Revision is the latest revision
+// of the changes, the agent passes it as since to the next sync.
+type SyncChanges struct {
+	Revision int64                  `json:"revision"`
+	Secrets  *SecretCache           `json:"secrets"`
+	Deleted  map[string][]Tombstone `json:"deleted"`
+}
+
+// NewSyncChanges returns empty changes after the revision
+func NewSyncChanges(since int64) *SyncChanges {
+	return &SyncChanges{
+		Revision: since,
+		Secrets:  &SecretCache{},
+		Deleted:  make(map[string][]Tombstone),
+	}
+}
+
+// Add adds changed and deleted secrets of the kind
+func (s *SyncChanges) Add(kind Kind, changed []Secret, deleted []Tombstone) {
+	s.Secrets.Set(kind, changed)
+	s.Deleted[kind.Plural()] = deleted
+	for _, m := range changed {
+		if m.Data().Revision > s.Revision {
+			s.Revision = m.Data().Revision
+		}
+	}
+	for _, t := range deleted {
+		if t.Revision > s.Revision {
+			s.Revision = t.Revision
+		}
+	}
+}
+
+// Operations of change events
+const (
+	EventAdd      = "add"
+	EventUpdate   = "update"
+	EventDelete   = "delete"
+	EventPassword = "password"
+)
+
+// ChangeEvent notifies agents of the user about a change of a secret, agents get the change itself by sync.
+// Password events tell that the vault was re-encrypted with a new master password.
+type ChangeEvent struct {
+	Op       string `json:"op"`
+	Kind     string `json:"kind,omitempty"`
+	ID       int    `json:"id,omitempty"`
+	Revision int64  `json:"revision,omitempty"`
+}
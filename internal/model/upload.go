@@ -0,0 +1,62 @@
+package model
+
+import (
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// Upload is a chunked upload of a secret file in progress. Size is the size of the content
+// the agent uploads, it is split into ChunkSize chunks, Received holds indexes of stored chunks.
+// Labels are given to the secret file created when the upload completes.
+type Upload struct {
+	ID        string `json:"id"`
+	UserID    int    `json:"user_id"`
+	Name      string `json:"name"`
+	Meta      string `json:"meta"`
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	ChunkSize int    `json:"chunk_size"`
+	Received  []int  `json:"received"`
+	Labels
+}
+
+func (u *Upload) String() string {
+	return fmt.Sprintf("ID: %s, User ID: %d, Name: %s, Size: %d, Received: %d/%d", u.ID, u.UserID, u.Name, u.Size, len(u.Received), u.Chunks())
+}
+
+func (u *Upload) Validate() error {
+	return validation.ValidateStruct(
+		u,
+		validation.Field(&u.UserID, validation.Required, validation.Min(1)),
+		validation.Field(&u.Key, validation.Required),
+		validation.Field(&u.Size, validation.Min(0)),
+		validation.Field(&u.ChunkSize, validation.Required, validation.Min(1)),
+	)
+}
+
+// Chunks returns number of chunks the content is split into
+func (u *Upload) Chunks() int {
+	return int((u.Size + int64(u.ChunkSize) - 1) / int64(u.ChunkSize))
+}
+
+// ChunkLen returns expected size of the chunk, all chunks but the last one are ChunkSize long
+func (u *Upload) ChunkLen(index int) int {
+	if index == u.Chunks()-1 {
+		return int(u.Size - int64(index)*int64(u.ChunkSize))
+	}
+	return u.ChunkSize
+}
+
+// Complete reports whether all chunks are received
+func (u *Upload) Complete() bool {
+	return len(u.Received) == u.Chunks()
+}
+
+// UploadChunk is a received chunk of an upload, Checksum is hex encoded sha256 of the chunk
+type UploadChunk struct {
+	UploadID string `json:"upload_id"`
+	Index    int    `json:"index"`
+	Checksum string `json:"checksum"`
+	Size     int    `json:"size"`
+}
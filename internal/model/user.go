@@ -1,6 +1,7 @@
 package model
 
 import (
+	"cenarius/internal/encrypt"
 	"fmt"
 
 	validation "github.com/go-ozzo/ozzo-validation"
@@ -8,15 +9,29 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// User logs in with Password, which is the auth key the agent derives from the vault key, never the master password.
+// Accounts made before auth keys have LegacyAuth set, they log in with the master password
+// until the agent reseals their secrets and the auth key replaces it, see LegacyVault.
 type User struct {
 	ID                int    `json:"id"`
 	Login             string `json:"login"`
 	Password          string `json:"password,omitempty"`
 	EncryptedPassword string `json:"encrypted_password,omitempty"`
+	KDFSalt           string `json:"kdf_salt,omitempty"`
+	LegacyAuth        bool   `json:"-"`
+}
+
+// Prelogin tells the agent the salt of the vault key of the login and whether the account logs in
+// with the master password yet
+type Prelogin struct {
+	Login      string `json:"login"`
+	KDFSalt    string `json:"kdf_salt"`
+	LegacyAuth bool   `json:"legacy_auth"`
 }
 
 func (u *User) String() string {
-	return fmt.Sprintf("ID: %d, Login: %s, Password: %s, EncryptedPassword: %s", u.ID, u.Login, u.Password, u.EncryptedPassword)
+	// Passwords and their hashes are never logged
+	return fmt.Sprintf("ID: %d, Login: %s", u.ID, u.Login)
 }
 
 func (u *User) Sanitaze() {
@@ -34,7 +49,8 @@ func (u *User) Validate() error {
 		validation.Field(
 			&u.Password,
 			validation.By(requiredIf(u.EncryptedPassword == "")),
-			validation.Length(8, 32),
+			// bcrypt hashes the first 72 bytes, auth keys are 44 characters long
+			validation.Length(8, 72),
 		),
 	)
 }
@@ -47,6 +63,13 @@ func (u *User) BeforeCreate() error {
 		}
 		u.EncryptedPassword = enc
 	}
+	if u.KDFSalt == "" {
+		salt, err := encrypt.NewSalt()
+		if err != nil {
+			return err
+		}
+		u.KDFSalt = salt
+	}
 	u.Sanitaze()
 	return nil
 }
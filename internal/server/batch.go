@@ -0,0 +1,203 @@
+package server
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/store"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// maxBatchOps limits operations of a batch
+const maxBatchOps = 1000
+
+var (
+	ErrEmptyBatch     = errors.New("batch has no operations")
+	ErrBatchTooLarge  = fmt.Errorf("batch has more than %d operations", maxBatchOps)
+	ErrUnknownBatchOp = errors.New("op must be create, update or delete")
+	ErrBatchSecret    = errors.New("create and update need the secret, delete needs the id")
+	ErrBatchFile      = errors.New("files are added by the chunked upload, not in a batch")
+	ErrNotApplied     = errors.New("not applied, another operation of the batch failed")
+)
+
+// batchErrorCode returns the status of the failed operation of a batch
+func batchErrorCode(err error) int {
+	var invalid validation.Errors
+	switch {
+	case errors.Is(err, store.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrPreconditionRequired):
+		return http.StatusPreconditionRequired
+	case errors.As(err, &invalid), errors.Is(err, ErrUnknownBatchOp), errors.Is(err, ErrBatchSecret), errors.Is(err, ErrBatchFile):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// checkBatchOp checks the shape of the operation before anything is applied
+func checkBatchOp(op *model.BatchOp) error {
+	if op == nil {
+		return ErrUnknownBatchOp
+	}
+	switch op.Op {
+	case model.BatchCreate, model.BatchUpdate:
+		if op.Secret == nil {
+			return ErrBatchSecret
+		}
+		if kind, _ := model.KindByName(op.Kind); op.Op == model.BatchCreate && kind.Blob() {
+			return ErrBatchFile
+		}
+		// Batches carry no If-Match, an update overwrites only the version it was made from
+		if op.Op == model.BatchUpdate && op.Secret.Data().Version <= 0 {
+			return ErrPreconditionRequired
+		}
+	case model.BatchDelete:
+		if op.ID <= 0 {
+			return ErrBatchSecret
+		}
+	default:
+		return ErrUnknownBatchOp
+	}
+	return nil
+}
+
+// applyBatchOp applies the operation to st bound to the transaction of the batch. Updated and deleted
+// secrets must exist, so a missing secret fails the batch instead of being skipped.
+func (s *server) applyBatchOp(ctx context.Context, st store.Store, op *model.BatchOp, userID int) (model.BatchResult, error) {
+	kind, _ := model.KindByName(op.Kind)
+	switch op.Op {
+	case model.BatchCreate:
+		m := op.Secret
+		m.Data().ID, m.Data().UserID = 0, userID
+		if err := s.addSecretTx(ctx, st, kind, m); err != nil {
+			return model.BatchResult{}, err
+		}
+		return model.BatchResult{Status: http.StatusCreated, ID: m.Data().ID, Version: m.Data().Version}, nil
+	case model.BatchUpdate:
+		m := op.Secret
+		m.Data().UserID = userID
+		stored, err := st.Secrets(kind).GetByID(ctx, m.Data().ID, userID)
+		if err != nil {
+			return model.BatchResult{}, err
+		}
+		if m.Data().Version != stored.Data().Version {
+			return model.BatchResult{}, store.ErrVersionConflict
+		}
+		if err := s.updateSecretTx(ctx, st, kind, m); err != nil {
+			return model.BatchResult{}, err
+		}
+		return model.BatchResult{Status: http.StatusOK, ID: m.Data().ID, Version: m.Data().Version}, nil
+	default:
+		if _, err := st.Secrets(kind).GetByID(ctx, op.ID, userID); err != nil {
+			return model.BatchResult{}, err
+		}
+		if err := st.Secrets(kind).Delete(ctx, op.ID, userID); err != nil {
+			return model.BatchResult{}, err
+		}
+		return model.BatchResult{Status: http.StatusOK, ID: op.ID}, nil
+	}
+}
+
+// runBatch applies operations of the user in one transaction, all of them or none. Results of operations
+// which were not applied because another one failed get 424. Changes are published once they are committed.
+func (s *server) runBatch(ctx context.Context, ops []*model.BatchOp, userID int) *model.BatchResults {
+	results := &model.BatchResults{Results: make([]model.BatchResult, len(ops))}
+	failed := false
+	for i, op := range ops {
+		if err := checkBatchOp(op); err != nil {
+			results.Results[i] = model.BatchResult{Status: batchErrorCode(err), Error: err.Error()}
+			failed = true
+		}
+	}
+	if failed {
+		notApplied(results)
+		return results
+	}
+	err := s.store.WithTx(ctx, func(tx store.Store) error {
+		for i, op := range ops {
+			r, err := s.applyBatchOp(ctx, tx, op, userID)
+			if err != nil {
+				results.Results[i] = model.BatchResult{Status: batchErrorCode(err), ID: op.ID, Error: err.Error()}
+				return err
+			}
+			results.Results[i] = r
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Errorf("server.runBatch of user %d rolled back: %v", userID, err)
+		notApplied(results)
+		return results
+	}
+	results.Applied = true
+	for i, op := range ops {
+		kind, _ := model.KindByName(op.Kind)
+		switch op.Op {
+		case model.BatchCreate:
+			s.publishChange(model.EventAdd, kind, op.Secret)
+		case model.BatchUpdate:
+			s.publishChange(model.EventUpdate, kind, op.Secret)
+		case model.BatchDelete:
+			s.publishChange(model.EventDelete, kind, deletedSecret(kind, results.Results[i].ID, userID))
+		}
+	}
+	return results
+}
+
+// notApplied marks results of a rolled back batch which did not fail themselves, ids of secrets created
+// by them are dropped. A commit which failed leaves every result successful, so they all are marked.
+func notApplied(results *model.BatchResults) {
+	for i, r := range results.Results {
+		if r.Error == "" {
+			results.Results[i] = model.BatchResult{Status: http.StatusFailedDependency, Error: ErrNotApplied.Error()}
+		}
+	}
+}
+
+// batchStatus returns the status of the response to the batch, the status of its first failed operation
+func batchStatus(results *model.BatchResults) int {
+	if results.Applied {
+		return http.StatusOK
+	}
+	for _, r := range results.Results {
+		if r.Status != http.StatusFailedDependency {
+			return r.Status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// handleBatch applies create, update and delete operations on secrets of any kind in one transaction
+// and responds with results of every operation
+func (s *server) handleBatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
+		}
+		ops := make([]*model.BatchOp, 0)
+		if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+			s.logger.Errorf("Unable to parse batch body: %v", err)
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		switch {
+		case len(ops) == 0:
+			s.error(w, r, http.StatusBadRequest, ErrEmptyBatch)
+			return
+		case len(ops) > maxBatchOps:
+			s.error(w, r, http.StatusRequestEntityTooLarge, ErrBatchTooLarge)
+			return
+		}
+		results := s.runBatch(r.Context(), ops, user.ID)
+		s.respond(w, r, batchStatus(results), results)
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"net/http"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_server_runBatch(t *testing.T) {
+	s := &server{logger: log.New()}
+	text := &model.SecretText{SecretData: model.SecretData{Name: "note"}, Text: "sealed"}
+	ops := []*model.BatchOp{
+		{Op: model.BatchCreate, Kind: model.SecretTextKind.Name(), Secret: text},
+		{Op: model.BatchCreate, Kind: model.SecretFileKind.Name(), Secret: &model.SecretFile{}},
+		{Op: model.BatchDelete, Kind: model.SecretTextKind.Name()},
+		{Op: "rename", Kind: model.SecretTextKind.Name()},
+	}
+	// Malformed operations fail the batch before the store is touched
+	results := s.runBatch(context.Background(), ops, 1)
+	assert.False(t, results.Applied)
+	if assert.Len(t, results.Results, 4) {
+		assert.Equal(t, http.StatusFailedDependency, results.Results[0].Status)
+		assert.Equal(t, ErrBatchFile.Error(), results.Results[1].Error)
+		assert.Equal(t, ErrBatchSecret.Error(), results.Results[2].Error)
+		assert.Equal(t, ErrUnknownBatchOp.Error(), results.Results[3].Error)
+	}
+	assert.Equal(t, http.StatusBadRequest, batchStatus(results))
+}
+
+func Test_server_runBatch_versionRequired(t *testing.T) {
+	// The store is never touched, a version-less update refuses the whole batch
+	s := &server{logger: log.New()}
+	ops := []*model.BatchOp{
+		{Op: model.BatchCreate, Kind: model.SecretTextKind.Name(), Secret: &model.SecretText{Text: "sealed"}},
+		{Op: model.BatchUpdate, Kind: model.SecretTextKind.Name(), Secret: &model.SecretText{SecretData: model.SecretData{ID: 7}}},
+	}
+	results := s.runBatch(context.Background(), ops, 1)
+	assert.False(t, results.Applied)
+	if assert.Len(t, results.Results, 2) {
+		assert.Equal(t, http.StatusFailedDependency, results.Results[0].Status)
+		assert.Equal(t, ErrPreconditionRequired.Error(), results.Results[1].Error)
+	}
+	assert.Equal(t, http.StatusPreconditionRequired, batchStatus(results))
+}
+
+func Test_batchStatus(t *testing.T) {
+	assert.Equal(t, http.StatusOK, batchStatus(&model.BatchResults{Applied: true}))
+	assert.Equal(t, http.StatusConflict, batchStatus(&model.BatchResults{Results: []model.BatchResult{
+		{Status: http.StatusFailedDependency}, {Status: http.StatusConflict}, {Status: http.StatusNotFound},
+	}}))
+	// A failed commit fails every operation
+	results := &model.BatchResults{Results: []model.BatchResult{{Status: http.StatusCreated, ID: 3}}}
+	notApplied(results)
+	assert.Equal(t, model.BatchResult{Status: http.StatusFailedDependency, Error: ErrNotApplied.Error()}, results.Results[0])
+	assert.Equal(t, http.StatusInternalServerError, batchStatus(results))
+}
@@ -1,12 +1,33 @@
 package server
 
+import (
+	"fmt"
+	"time"
+)
+
 type Config struct {
-	Bind           string `json:"bind" toml:"bind,omitempty"`
-	LogLevel       string `json:"log_level" toml:"log_level,omitempty"`
-	DatabaseDsn    string `json:"database_url" toml:"database_url,omitempty"`
-	SessionKey     string `json:"session_key" toml:"session_key,omitempty"`
-	SecretFilePath string `json:"secret_file_path" toml:"secret_file_path,omitempty"`
-	MigrationPath  string `json:"migration_path" toml:"migration_path,omitempty"`
+	Bind        string `json:"bind" toml:"bind,omitempty"`
+	LogLevel    string `json:"log_level" toml:"log_level,omitempty"`
+	DatabaseDsn string `json:"database_url" toml:"database_url,omitempty"`
+	// SessionKey signs session tokens, a key generated in KeyDir is used if it is empty
+	SessionKey     string        `json:"session_key" toml:"session_key,omitempty"`
+	SessionTTL     time.Duration `json:"session_ttl" toml:"session_ttl,omitempty"`
+	SecretFilePath string        `json:"secret_file_path" toml:"secret_file_path,omitempty"`
+	// FileKey encrypts secret files at rest, a key generated in KeyDir is used if it is empty
+	FileKey       string `json:"file_key" toml:"file_key,omitempty"`
+	BlobStorage   string `json:"blob_storage" toml:"blob_storage,omitempty"`
+	S3Endpoint    string `json:"s3_endpoint" toml:"s3_endpoint,omitempty"`
+	S3Region      string `json:"s3_region" toml:"s3_region,omitempty"`
+	S3Bucket      string `json:"s3_bucket" toml:"s3_bucket,omitempty"`
+	S3AccessKey   string `json:"s3_access_key" toml:"s3_access_key,omitempty"`
+	S3SecretKey   string `json:"s3_secret_key" toml:"s3_secret_key,omitempty"`
+	MigrationPath string `json:"migration_path" toml:"migration_path,omitempty"`
+	// KeyDir keeps keys generated on the first start for keys which are not configured
+	KeyDir string `json:"key_dir" toml:"key_dir,omitempty"`
+	// TrashRetention is how long deleted secrets are kept in the trash, zero keeps them until the trash is emptied
+	TrashRetention time.Duration `json:"trash_retention" toml:"trash_retention,omitempty"`
+	// UploadTTL is how long unfinished uploads are kept with their chunks, zero keeps them until they are aborted
+	UploadTTL time.Duration `json:"upload_ttl" toml:"upload_ttl,omitempty"`
 }
 
 func NewConfig() *Config {
@@ -14,8 +35,25 @@ func NewConfig() *Config {
 		Bind:           ":8080",
 		LogLevel:       "INFO",
 		DatabaseDsn:    "postgres://localhost:5432/cenarius_test?sslmode=disable",
-		SessionKey:     "cenarius",
+		SessionTTL:     15 * time.Minute,
 		SecretFilePath: "/tmp/cenarius",
+		BlobStorage:    "local",
+		S3Region:       "us-east-1",
 		MigrationPath:  "migrations",
+		KeyDir:         "keys",
+		TrashRetention: 30 * 24 * time.Hour,
+		UploadTTL:      7 * 24 * time.Hour,
+	}
+}
+
+// String hides keys of the configuration, it is logged on start
+func (c Config) String() string {
+	type plain Config
+	p := plain(c)
+	for _, key := range []*string{&p.SessionKey, &p.FileKey, &p.S3SecretKey} {
+		if *key != "" {
+			*key = "***"
+		}
 	}
+	return fmt.Sprintf("%+v", p)
 }
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"cenarius/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// eventsHeartbeat keeps idle event streams open behind proxies
+	eventsHeartbeat = 25 * time.Second
+	// eventsBuffer is how many events a stream may lag behind before it is closed
+	eventsBuffer = 16
+)
+
+// EventChange is the name of server-sent events of changes
+const EventChange = "change"
+
+var ErrStreamingUnsupported = errors.New("streaming is not supported")
+
+// hub delivers change events to event streams of their user. Streams live in this process only,
+// agents connected to other instances of the server catch up by sync when they reconnect.
+type hub struct {
+	mu     sync.Mutex
+	subs   map[int]map[chan *model.ChangeEvent]struct{}
+	closed bool
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[int]map[chan *model.ChangeEvent]struct{})}
+}
+
+// subscribe returns events of the user, the channel is closed by unsubscribe, when the stream
+// lags behind or when the hub is closed
+func (h *hub) subscribe(userID int) chan *model.ChangeEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan *model.ChangeEvent, eventsBuffer)
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[chan *model.ChangeEvent]struct{})
+	}
+	h.subs[userID][ch] = struct{}{}
+	return ch
+}
+
+func (h *hub) unsubscribe(userID int, ch chan *model.ChangeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(userID, ch)
+}
+
+// remove closes the channel if it is still subscribed
+func (h *hub) remove(userID int, ch chan *model.ChangeEvent) {
+	if _, ok := h.subs[userID][ch]; !ok {
+		return
+	}
+	delete(h.subs[userID], ch)
+	if len(h.subs[userID]) == 0 {
+		delete(h.subs, userID)
+	}
+	close(ch)
+}
+
+// publish sends the event to every stream of the user without blocking. Streams which lag behind
+// are closed, their agents sync the missed changes when they reconnect.
+func (h *hub) publish(userID int, e *model.ChangeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[userID] {
+		select {
+		case ch <- e:
+		default:
+			h.remove(userID, ch)
+		}
+	}
+}
+
+// close ends every stream, so the server shuts down without waiting for them
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for userID, subs := range h.subs {
+		for ch := range subs {
+			h.remove(userID, ch)
+		}
+	}
+}
+
+// handleEvents streams change events of the user as server-sent events until the client goes away,
+// the session expires or the server shuts down
+func (s *server) handleEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, ErrStreamingUnsupported)
+			return
+		}
+		claims, err := s.parseSession(r.Header.Get(AuthHeader))
+		if err != nil {
+			s.error(w, r, http.StatusUnauthorized, err)
+			return
+		}
+		events := s.events.subscribe(user.ID)
+		defer s.events.unsubscribe(user.ID, events)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+		expired := time.NewTimer(time.Until(time.Unix(claims.ExpiresAt, 0)))
+		defer expired.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-expired.C:
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					s.logger.Errorf("server.handleEvents marshal %v: %v", e, err)
+					continue
+				}
+				if e.Revision > 0 {
+					fmt.Fprintf(w, "id: %d\n", e.Revision)
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", EventChange, data)
+			}
+			flusher.Flush()
+		}
+	}
+}
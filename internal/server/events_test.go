@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bufio"
+	"cenarius/internal/model"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_hub(t *testing.T) {
+	h := newHub()
+	first := h.subscribe(1)
+	other := h.subscribe(2)
+	h.publish(1, &model.ChangeEvent{Op: model.EventAdd, ID: 7})
+	assert.Equal(t, 7, (<-first).ID)
+	assert.Empty(t, other)
+
+	// A stream lagging behind is closed instead of blocking the publisher
+	for i := 0; i <= eventsBuffer; i++ {
+		h.publish(1, &model.ChangeEvent{Op: model.EventUpdate, ID: i})
+	}
+	for range first {
+	}
+	h.unsubscribe(1, first)
+
+	h.close()
+	_, ok := <-other
+	assert.False(t, ok)
+	_, ok = <-h.subscribe(3)
+	assert.False(t, ok)
+}
+
+func Test_server_handleEvents(t *testing.T) {
+	s := &server{config: NewConfig(), logger: log.New(), events: newHub()}
+	u := &model.User{ID: 5, Login: "user"}
+	session, err := s.newSession(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleEvents()(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, u)))
+	}))
+	defer srv.Close()
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(AuthHeader, session.Token)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(resp.Body)
+	assert.True(t, lines.Scan())
+	assert.Equal(t, ": connected", lines.Text())
+	s.publishChange(model.EventUpdate, model.SecretTextKind, &model.SecretText{SecretData: model.SecretData{ID: 3, UserID: 5, Revision: 42}})
+	var event []string
+	for lines.Scan() && len(event) < 3 {
+		if lines.Text() != "" {
+			event = append(event, lines.Text())
+		}
+	}
+	assert.Equal(t, []string{
+		"id: 42",
+		"event: change",
+		`data: {"op":"update","kind":"secrettext","id":3,"revision":42}`,
+	}, event)
+
+	// Closing the hub ends the stream
+	s.events.close()
+	for lines.Scan() {
+	}
+	assert.NoError(t, lines.Err())
+}
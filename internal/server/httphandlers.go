@@ -2,17 +2,21 @@ package server
 
 import (
 	"cenarius/internal/model"
+	"cenarius/internal/store"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi"
 )
 
+// maxFormValueSize limits text fields of multipart forms
+const maxFormValueSize = 1 << 20
+
 func (s *server) error(w http.ResponseWriter, r *http.Request, code int, err error) {
 	s.respond(w, r, code, map[string]string{"error": err.Error()})
 }
@@ -32,6 +36,8 @@ func (s *server) configureRouter() {
 	s.router.Use(gzipHandle)
 	s.router.Use(s.setContentType)
 	s.router.Post("/api/v1/user/register", s.handleUserRegister())
+	s.router.Post("/api/v1/user/prelogin", s.handleUserPrelogin())
+	s.router.Post("/api/v1/user/login", s.handleUserLogin())
 	s.router.Get("/ping", s.handleHealthCheck())
 
 	s.router.Mount("/api/v1/private", s.privateRouter())
@@ -41,35 +47,42 @@ func (s *server) configureRouter() {
 func (s *server) privateRouter() *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(s.authenticateUser)
+	r.Use(s.setClientID)
 	r.Get("/ping", s.handleHealthCheck())
-
-	r.Get("/loginwithpasswords", s.handleLoginWithPasswordSearch())
-	r.Get("/loginwithpassword/{id}", s.handleLoginWithPasswordWithID())
-	r.Get("/loginwithpassword/search/{name}", s.handleLoginWithPasswordSearch())
-	r.Put("/loginwithpassword", s.handleLoginWithPasswordWithBody())
-	r.Post("/loginwithpassword", s.handleLoginWithPasswordWithBody())
-	r.Delete("/loginwithpassword/{id}", s.handleLoginWithPasswordWithID())
-
-	r.Get("/creditcards", s.handleCreditCardSearch())
-	r.Get("/creditcard/{id}", s.handleCreditCardWithID())
-	r.Get("/creditcard/search/{name}", s.handleCreditCardSearch())
-	r.Put("/creditcard", s.handleCreditCardWithBody())
-	r.Post("/creditcard", s.handleCreditCardWithBody())
-	r.Delete("/creditcard/{id}", s.handleCreditCardWithID())
-
-	r.Get("/secrettexts", s.handleSecretTextSearch())
-	r.Get("/secrettext/{id}", s.handleSecretTextWithID())
-	r.Get("/secrettext/search/{name}", s.handleSecretTextSearch())
-	r.Put("/secrettext", s.handleSecretTextWithBody())
-	r.Post("/secrettext", s.handleSecretTextWithBody())
-	r.Delete("/secrettext/{id}", s.handleSecretTextWithID())
-
-	r.Get("/secretfiles", s.handleSecretFileSearch())
-	r.Get("/secretfile/{id}", s.handleSecretFileWithID())
-	r.Get("/secretfile/search/{name}", s.handleSecretFileSearch())
-	r.Put("/secretfile", s.handleSecretFileWithBody())
-	r.Post("/secretfile", s.handleFileUpload())
-	r.Delete("/secretfile/{id}", s.handleSecretFileWithID())
+	r.Post("/user/refresh", s.handleSessionRefresh())
+	r.Put("/user/password", s.handlePasswordChange())
+	r.Get("/user/legacy", s.handleLegacyVault())
+	r.Put("/user/legacy", s.handleVaultUpgrade())
+	r.Get("/sync", s.handleSync())
+	r.Get("/events", s.handleEvents())
+	r.Get("/trash", s.handleTrash())
+	r.Delete("/trash", s.handleTrash())
+	r.Get("/history", s.handleHistory())
+	r.Get("/search", s.handleSearch())
+	r.Post("/batch", s.handleBatch())
+
+	for _, kind := range model.Kinds() {
+		single := "/" + kind.Name()
+		r.Get("/"+kind.Plural(), s.handleSecretSearch(kind))
+		r.Get(single+"/{id}", s.handleSecretWithID(kind))
+		r.Get(single+"/search/{name}", s.handleSecretSearch(kind))
+		r.Get(single+"/{id}/history", s.handleSecretHistory(kind))
+		r.Get(single+"/{id}/history/{version}", s.handleSecretVersion(kind))
+		r.Put(single, s.handleSecretWithBody(kind))
+		if kind.Blob() {
+			r.Post(single, s.handleFileUpload())
+		} else {
+			r.Post(single, s.handleSecretWithBody(kind))
+		}
+		r.Delete(single+"/{id}", s.handleSecretWithID(kind))
+		r.Post("/trash"+single+"/{id}/restore", s.handleTrashedSecret(kind))
+		r.Delete("/trash"+single+"/{id}", s.handleTrashedSecret(kind))
+	}
+	r.Post("/secretfile/upload", s.handleUploadInit())
+	r.Get("/secretfile/upload/{uploadID}", s.handleUploadWithID())
+	r.Delete("/secretfile/upload/{uploadID}", s.handleUploadWithID())
+	r.Put("/secretfile/upload/{uploadID}/{index}", s.handleUploadChunk())
+	r.Post("/secretfile/upload/{uploadID}/complete", s.handleUploadComplete())
 
 	return r
 }
@@ -91,129 +104,226 @@ func (s *server) handleUserRegister() http.HandlerFunc {
 	}
 }
 
-func (s *server) handleHealthCheck() http.HandlerFunc {
+func (s *server) handleUserPrelogin() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		s.respond(w, r, http.StatusNoContent, nil)
+		u := &model.User{}
+		if err := json.NewDecoder(r.Body).Decode(u); err != nil {
+			s.logger.Errorf("Unable to parse body: %v", err)
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		p, err := s.prelogin(r.Context(), u.Login)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, p)
 	}
 }
 
-func (s *server) handleLoginWithPasswordWithBody() http.HandlerFunc {
+func (s *server) handleUserLogin() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		m := &model.LoginWithPassword{}
-		if err := json.NewDecoder(r.Body).Decode(m); err != nil {
-			s.logger.Errorf("Unable to parse body in handleAddLoginWithPassword: %v", err)
+		u := &model.User{}
+		if err := json.NewDecoder(r.Body).Decode(u); err != nil {
+			s.logger.Errorf("Unable to parse body: %v", err)
 			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
+		u, err := s.userLogin(r.Context(), u)
+		if err != nil {
+			s.error(w, r, http.StatusUnauthorized, store.ErrNotAuthenticated)
+			return
+		}
+		session, err := s.newSession(u)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, session)
+	}
+}
+
+func (s *server) handleSessionRefresh() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
-			s.error(w, r, http.StatusBadRequest, ErrUnableToGetUserFromRequest)
+			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
-		fmt.Println(user)
-		m.UserID = user.ID
-		switch r.Method {
-		case "POST":
-			if _, err := s.addLoginWithPassword(r.Context(), m, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.logger.Error(err)
-				s.error(w, r, http.StatusInternalServerError, err)
-			}
-		case "PUT":
-			if _, err := s.updateLoginWithPassword(r.Context(), m, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
-			}
+		session, err := s.newSession(user)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
 		}
-		s.respond(w, r, http.StatusOK, m)
+		s.respond(w, r, http.StatusOK, session)
 	}
 }
 
-func (s *server) handleLoginWithPasswordWithID() http.HandlerFunc {
+func (s *server) handlePasswordChange() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var err error
+		p := &model.PasswordChange{}
+		if err := json.NewDecoder(r.Body).Decode(p); err != nil {
+			s.logger.Errorf("Unable to parse body in handlePasswordChange: %v", err)
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
-		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		u, code, err := s.changePassword(r.Context(), user.ID, p)
 		if err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+			s.error(w, r, code, err)
 			return
 		}
-		switch r.Method {
-		case "GET":
-			var m *model.LoginWithPassword
-			if m, err = s.getLoginWithPassword(r.Context(), id, user.ID, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
-				return
-			}
-			s.respond(w, r, http.StatusOK, m)
-		case "DELETE":
-			if err := s.deleteLoginWithPassword(r.Context(), id, user.ID); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
-				return
-			}
-			s.respond(w, r, http.StatusOK, nil)
+		session, err := s.newSession(u)
+		if err != nil {
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
 		}
+		s.respond(w, r, http.StatusOK, session)
 	}
 }
 
-func (s *server) handleLoginWithPasswordSearch() http.HandlerFunc {
+// handleLegacyVault returns opened secrets of the legacy account, the agent reseals them with the vault key
+func (s *server) handleLegacyVault() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		s.logger.Info("server.handleLoginWithPasswordSearch is working")
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
 		}
-		name := chi.URLParam(r, "name")
-		s.logger.Infof("server.handleLoginWithPasswordSearch url param: %s", name)
-		result, err := s.searchLoginWithPassword(r.Context(), name, user.ID, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16])
+		v, code, err := s.legacyVault(r.Context(), user.ID)
+		if err != nil {
+			s.error(w, r, code, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, v)
+	}
+}
+
+func (s *server) handleVaultUpgrade() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := &model.LegacyVault{}
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			s.logger.Errorf("Unable to parse body in handleVaultUpgrade: %v", err)
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
+		}
+		u, code, err := s.upgradeVault(r.Context(), user.ID, v)
+		if err != nil {
+			s.error(w, r, code, err)
+			return
+		}
+		session, err := s.newSession(u)
 		if err != nil {
-			s.logger.Errorf("server.handleLoginWithPasswordSearch: %v", err)
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		s.respond(w, r, http.StatusOK, result)
+		s.respond(w, r, http.StatusOK, session)
 	}
 }
 
-func (s *server) handleCreditCardWithBody() http.HandlerFunc {
+func (s *server) handleHealthCheck() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		m := &model.CreditCard{}
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+// versionETag returns the entity tag of the secret version
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion returns the version required by If-Match, zero if any version matches
+func ifMatchVersion(r *http.Request) (int, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(v, "W/"), `"`))
+	if err != nil || version <= 0 {
+		return 0, ErrInvalidIfMatch
+	}
+	return version, nil
+}
+
+// handleSecretWithBody adds or updates a secret of the kind. Updates are checked against If-Match
+// or the version of the body, an update of a stale version gets 409 with the stored secret.
+// An update with neither gets 428, so a secret is overwritten unconditionally only with If-Match: *.
+func (s *server) handleSecretWithBody(kind model.Kind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := kind.New()
 		if err := json.NewDecoder(r.Body).Decode(m); err != nil {
-			s.logger.Errorf("Unable to parse body in handleAddCreditCard: %v", err)
+			s.logger.Errorf("Unable to parse %s body: %v", kind.Table(), err)
 			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
-			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			s.error(w, r, http.StatusBadRequest, ErrUnableToGetUserFromRequest)
 			return
 		}
-		m.UserID = user.ID
+		m.Data().UserID = user.ID
 		switch r.Method {
 		case "POST":
-			if _, err := s.addCreditCard(r.Context(), m, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
+			if err := s.addSecret(r.Context(), kind, m); err != nil {
 				s.error(w, r, http.StatusInternalServerError, err)
 				return
 			}
 		case "PUT":
-			if _, err := s.updateCreditCard(r.Context(), m, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
+			version, err := ifMatchVersion(r)
+			if err != nil {
+				s.error(w, r, http.StatusBadRequest, err)
+				return
+			}
+			if version > 0 {
+				m.Data().Version = version
+			}
+			if m.Data().Version == 0 && r.Header.Get("If-Match") == "" {
+				s.error(w, r, http.StatusPreconditionRequired, ErrPreconditionRequired)
+				return
+			}
+			err = s.updateSecret(r.Context(), kind, m)
+			if errors.Is(err, store.ErrVersionConflict) {
+				s.respondConflict(w, r, kind, m)
+				return
+			}
+			if err != nil {
+				s.error(w, r, lookupErrorCode(err), err)
 				return
 			}
 		}
+		w.Header().Set("ETag", versionETag(m.Data().Version))
 		s.respond(w, r, http.StatusOK, m)
 	}
 }
 
-func (s *server) handleCreditCardWithID() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+// respondConflict responds with the stored secret the update of m conflicts with
+func (s *server) respondConflict(w http.ResponseWriter, r *http.Request, kind model.Kind, m model.Secret) {
+	current, err := s.getSecret(r.Context(), kind, m.Data().ID, m.Data().UserID)
+	if err != nil {
+		s.error(w, r, lookupErrorCode(err), err)
+		return
+	}
+	s.logger.Infof("%s %d update of version %d conflicts with version %d", kind.Table(), m.Data().ID, m.Data().Version, current.Data().Version)
+	w.Header().Set("ETag", versionETag(current.Data().Version))
+	s.respond(w, r, http.StatusConflict, map[string]any{"error": store.ErrVersionConflict.Error(), "current": current})
+}
 
-		var err error
+// handleSecretWithID returns or deletes a secret of the kind, content of blob secrets is served as is
+func (s *server) handleSecretWithID(kind model.Kind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
 		}
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
@@ -222,165 +332,221 @@ func (s *server) handleCreditCardWithID() http.HandlerFunc {
 		}
 		switch r.Method {
 		case "GET":
-			var m *model.CreditCard
-			if m, err = s.getCreditCard(r.Context(), id, user.ID, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
+			m, err := s.getSecret(r.Context(), kind, id, user.ID)
+			if err != nil {
+				s.error(w, r, lookupErrorCode(err), err)
+				return
+			}
+			if b, ok := m.(model.BlobSecret); ok {
+				s.serveSecretFile(w, r, b)
 				return
 			}
+			w.Header().Set("ETag", versionETag(m.Data().Version))
 			s.respond(w, r, http.StatusOK, m)
 		case "DELETE":
-			if err := s.deleteCreditCard(r.Context(), id, user.ID); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
+			if err := s.deleteSecret(r.Context(), kind, id, user.ID); err != nil {
+				s.error(w, r, lookupErrorCode(err), err)
 				return
 			}
 			s.respond(w, r, http.StatusOK, nil)
 		}
-
 	}
 }
 
-func (s *server) handleCreditCardSearch() http.HandlerFunc {
+// handleSecretHistory lists prior versions of a secret of the kind, newest first
+func (s *server) handleSecretHistory(kind model.Kind) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
-		name := chi.URLParam(r, "name")
-		result, err := s.searchCreditCard(r.Context(), name, user.ID, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16])
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
-		s.respond(w, r, http.StatusOK, result)
+		versions, err := s.secretHistory(r.Context(), kind, id, user.ID)
+		if err != nil {
+			s.logger.Errorf("server.handleSecretHistory %s %d: %v", kind.Table(), id, err)
+			s.error(w, r, lookupErrorCode(err), err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, versions)
 	}
 }
 
-func (s *server) handleSecretTextWithBody() http.HandlerFunc {
+// handleHistory returns prior versions of every secret, the agent re-encrypts them on a password change
+func (s *server) handleHistory() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		m := &model.SecretText{}
-		if err := json.NewDecoder(r.Body).Decode(m); err != nil {
-			s.logger.Errorf("Unable to parse body in handleAddSecretText: %v", err)
-			s.error(w, r, http.StatusBadRequest, err)
-			return
-		}
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
 		}
-		m.UserID = user.ID
-		switch r.Method {
-		case "POST":
-			if _, err := s.addSecretText(r.Context(), m, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
-				return
-			}
-		case "PUT":
-			if _, err := s.updateSecretText(r.Context(), m, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
-				return
-			}
+		history, err := s.allHistory(r.Context(), user.ID)
+		if err != nil {
+			s.logger.Errorf("server.handleHistory: %v", err)
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
 		}
-		s.respond(w, r, http.StatusOK, m)
+		s.respond(w, r, http.StatusOK, history)
 	}
 }
 
-func (s *server) handleSecretTextWithID() http.HandlerFunc {
+// handleSecretVersion returns a prior version of a secret of the kind. The agent restores it
+// by saving it as the next version or as a new secret if the secret is deleted.
+func (s *server) handleSecretVersion(kind model.Kind) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
-		var err error
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
-
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
 			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
-		switch r.Method {
-		case "GET":
-			var m *model.SecretText
-			if m, err = s.getSecretText(r.Context(), id, user.ID, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
-				return
-			}
-			s.respond(w, r, http.StatusOK, m)
-		case "DELETE":
-			if err := s.deleteSecretText(r.Context(), id, user.ID); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
-				return
-			}
-			s.respond(w, r, http.StatusOK, nil)
+		version, err := strconv.Atoi(chi.URLParam(r, "version"))
+		if err != nil || version <= 0 {
+			s.error(w, r, http.StatusBadRequest, ErrInvalidVersion)
+			return
 		}
+		m, err := s.getSecretVersion(r.Context(), kind, id, version, user.ID)
+		if err != nil {
+			s.error(w, r, lookupErrorCode(err), err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, m)
 	}
 }
 
-func (s *server) handleSecretTextSearch() http.HandlerFunc {
+// handleSecretSearch lists a page of secrets of the kind, optionally filtered by name, by labels given
+// in folder, tag and favorite query parameters and by time ranges. The cursor of the next page
+// is sent in NextCursorHeader, so the body stays a plain array.
+func (s *server) handleSecretSearch(kind model.Kind) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
+		q, err := listQuery(r)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
 		name := chi.URLParam(r, "name")
-		result, err := s.searchSecretText(r.Context(), name, user.ID, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16])
+		result, next, err := s.listSecrets(r.Context(), kind, name, q, user.ID)
 		if err != nil {
+			s.logger.Errorf("server.handleSecretSearch %s: %v", kind.Table(), err)
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
+		if next != "" {
+			w.Header().Set(NextCursorHeader, next)
+		}
 		s.respond(w, r, http.StatusOK, result)
 	}
 }
 
-// handleFileUpload handle file uploading
-func (s *server) handleFileUpload() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		err := r.ParseMultipartForm(32 << 20)
+// labelFilter returns the filter of folder, tag and favorite query parameters, tag may be repeated
+func labelFilter(r *http.Request) (model.LabelFilter, error) {
+	q := r.URL.Query()
+	filter := model.LabelFilter{Folder: model.CleanFolder(q.Get("folder")), Tags: q["tag"]}
+	if v := q.Get("favorite"); v != "" {
+		favorite, err := strconv.ParseBool(v)
 		if err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+			return filter, ErrInvalidFavorite
 		}
-		file, handler, err := r.FormFile("secretFile")
-		if err != nil {
-			s.logger.Error(err)
-			s.error(w, r, http.StatusInternalServerError, fmt.Errorf("server.handleFileUpload can't read file"))
-			return
-		}
-		defer file.Close()
+		filter.Favorite = favorite
+	}
+	return filter, nil
+}
+
+// handleSync returns secrets changed and deleted after the since revision, all secrets without it
+func (s *server) handleSync() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
-		userSecretFilePath := path.Join(s.config.SecretFilePath, strconv.Itoa(user.ID))
-		err = os.MkdirAll(userSecretFilePath, 0755)
-		if err != nil {
-			s.logger.Errorf("Unable to create dir %s", userSecretFilePath)
+		var since int64
+		if v := r.URL.Query().Get("since"); v != "" {
+			var err error
+			if since, err = strconv.ParseInt(v, 10, 64); err != nil || since < 0 {
+				s.error(w, r, http.StatusBadRequest, ErrInvalidRevision)
+				return
+			}
 		}
-		// Create file locally
-		storageFilePath := path.Join(userSecretFilePath, handler.Filename)
-		dst, err := os.Create(storageFilePath)
+		changes, err := s.syncChanges(r.Context(), since, user.ID)
 		if err != nil {
-			s.logger.Error(err)
-			s.error(w, r, http.StatusInternalServerError, fmt.Errorf("server.handleFileUpload can't create  file in %s", userSecretFilePath))
+			s.logger.Errorf("server.handleSync: %v", err)
+			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		defer dst.Close()
+		s.respond(w, r, http.StatusOK, changes)
+	}
+}
 
-		// Copy the uploaded file data to the newly created file on the filesystem
-		if _, err := io.Copy(dst, file); err != nil {
-			s.logger.Error(err)
-			s.error(w, r, http.StatusInternalServerError, fmt.Errorf("server.handleFileUpload can't copy to  file"))
+// handleFileUpload handle file uploading
+func (s *server) handleFileUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
-		m := &model.SecretFile{
-			Path: storageFilePath,
+		// Multipart body is read part by part, so the file is never buffered in memory or temp files
+		reader, err := r.MultipartReader()
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
 		}
+		m := &model.SecretFile{}
 		m.UserID = user.ID
-		m, err = s.addSecretFile(r.Context(), m, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16])
-		if err != nil {
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				s.error(w, r, http.StatusBadRequest, err)
+				return
+			}
+			switch part.FormName() {
+			case "name", "meta", "key":
+				value, err := io.ReadAll(io.LimitReader(part, maxFormValueSize))
+				if err != nil {
+					s.error(w, r, http.StatusBadRequest, err)
+					return
+				}
+				switch part.FormName() {
+				case "name":
+					m.Name = string(value)
+				case "meta":
+					m.Meta = string(value)
+				case "key":
+					m.Key = string(value)
+				}
+			case "secretFile":
+				if m.Path, err = s.storeSecretFile(r.Context(), user.ID, part); err != nil {
+					s.logger.Error(err)
+					s.error(w, r, http.StatusInternalServerError, fmt.Errorf("server.handleFileUpload can't store file"))
+					return
+				}
+			}
+		}
+		if m.Path == "" {
+			s.error(w, r, http.StatusBadRequest, fmt.Errorf("server.handleFileUpload can't read file"))
+			return
+		}
+		if err := s.addSecret(r.Context(), model.SecretFileKind, m); err != nil {
+			if rmErr := s.blobs.Delete(r.Context(), m.Path); rmErr != nil {
+				s.logger.Errorf("Unable to remove orphan file: %v", rmErr)
+			}
 			s.error(w, r, http.StatusInternalServerError, err)
 			return
 		}
@@ -388,55 +554,48 @@ func (s *server) handleFileUpload() http.HandlerFunc {
 	}
 }
 
-func (s *server) handleSecretFileWithBody() http.HandlerFunc {
+func (s *server) handleUploadInit() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		m := &model.SecretFile{}
-		if err := json.NewDecoder(r.Body).Decode(m); err != nil {
-			s.logger.Errorf("Unable to parse body in handleAddSecretFile: %v", err)
+		u := &model.Upload{}
+		if err := json.NewDecoder(r.Body).Decode(u); err != nil {
+			s.logger.Errorf("Unable to parse body in handleUploadInit: %v", err)
 			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
-		s.logger.Infof("server.handleSecretFileWithBody got secretfile: %v", m)
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
-		m.UserID = user.ID
-
-		if _, err := s.updateSecretFile(r.Context(), m, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+		u.UserID = user.ID
+		u, err := s.initUpload(r.Context(), u)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
-
-		s.respond(w, r, http.StatusOK, m)
+		s.respond(w, r, http.StatusCreated, u)
 	}
 }
 
-func (s *server) handleSecretFileWithID() http.HandlerFunc {
+func (s *server) handleUploadWithID() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var err error
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
-		id, err := strconv.Atoi(chi.URLParam(r, "id"))
-		if err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
-			return
-		}
+		id := chi.URLParam(r, "uploadID")
 		switch r.Method {
 		case "GET":
-			var m *model.SecretFile
-			if m, err = s.getSecretFile(r.Context(), id, user.ID, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
+			u, err := s.getUpload(r.Context(), id, user.ID)
+			if err != nil {
+				s.error(w, r, lookupErrorCode(err), err)
 				return
 			}
-			http.ServeFile(w, r, m.Path)
+			s.respond(w, r, http.StatusOK, u)
 		case "DELETE":
-			if err := s.deleteSecretFile(r.Context(), id, user.ID, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16]); err != nil {
-				s.error(w, r, http.StatusInternalServerError, err)
+			if err := s.abortUpload(r.Context(), id, user.ID); err != nil {
+				s.error(w, r, lookupErrorCode(err), err)
 				return
 			}
 			s.respond(w, r, http.StatusOK, nil)
@@ -444,20 +603,41 @@ func (s *server) handleSecretFileWithID() http.HandlerFunc {
 	}
 }
 
-func (s *server) handleSecretFileSearch() http.HandlerFunc {
+func (s *server) handleUploadChunk() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
 		if !ok {
 			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
 			return
 		}
-		name := chi.URLParam(r, "name")
-		result, err := s.searchSecretFile(r.Context(), name, user.ID, user.EncryptedPassword[0:32], user.EncryptedPassword[0:16])
+		index, err := strconv.Atoi(chi.URLParam(r, "index"))
 		if err != nil {
-			s.logger.Errorf("func handleSecretFileSearch: %s", err.Error())
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, http.StatusBadRequest, err)
 			return
 		}
-		s.respond(w, r, http.StatusOK, result)
+		id := chi.URLParam(r, "uploadID")
+		code, err := s.putUploadChunk(r.Context(), id, user.ID, index, r.Header.Get(ChunkChecksumHeader), r.Body)
+		if err != nil {
+			s.logger.Errorf("Chunk %d of Upload %s rejected: %v", index, id, err)
+			s.error(w, r, code, err)
+			return
+		}
+		s.respond(w, r, code, nil)
+	}
+}
+
+func (s *server) handleUploadComplete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
+		}
+		m, code, err := s.completeUpload(r.Context(), chi.URLParam(r, "uploadID"), user.ID)
+		if err != nil {
+			s.error(w, r, code, err)
+			return
+		}
+		s.respond(w, r, code, m)
 	}
 }
@@ -5,15 +5,20 @@ import (
 	"cenarius/internal/model"
 	"cenarius/internal/store/sqlstore"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -57,6 +62,7 @@ func Test_server_handleUserRegister(t *testing.T) {
 	conf := NewConfig()
 	conf.DatabaseDsn = databaseURL
 	conf.MigrationPath = "../../migrations"
+	conf.KeyDir = t.TempDir()
 	s := NewServer(conf)
 	handler := http.HandlerFunc(s.handleUserRegister())
 
@@ -80,6 +86,7 @@ func Test_server_handleHealthCheck(t *testing.T) {
 	conf := NewConfig()
 	conf.DatabaseDsn = databaseURL
 	conf.MigrationPath = "../../migrations"
+	conf.KeyDir = t.TempDir()
 	s := NewServer(conf)
 	handler := http.HandlerFunc(s.handleHealthCheck())
 	rec := httptest.NewRecorder()
@@ -95,10 +102,11 @@ func Test_server_handleLoginWithPasswordWithBody(t *testing.T) {
 	conf := NewConfig()
 	conf.DatabaseDsn = databaseURL
 	conf.MigrationPath = "../../migrations"
+	conf.KeyDir = t.TempDir()
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 	u := &model.User{Login: "Valid", EncryptedPassword: "testpasswordtestpasswordtestpass", ID: r.Intn(1000-10) + 1}
 	s := NewServer(conf)
-	handler := http.HandlerFunc(s.handleLoginWithPasswordWithBody())
+	handler := http.HandlerFunc(s.handleSecretWithBody(model.LoginWithPasswordKind))
 	tests := []struct {
 		name   string
 		method string
@@ -159,3 +167,169 @@ func Test_server_handleLoginWithPasswordWithBody(t *testing.T) {
 		})
 	}
 }
+
+func Test_server_handleUpload(t *testing.T) {
+	_, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("Upload", "SecretFile")
+	conf := NewConfig()
+	conf.DatabaseDsn = databaseURL
+	conf.MigrationPath = "../../migrations"
+	conf.KeyDir = t.TempDir()
+	conf.SecretFilePath = t.TempDir()
+	s := NewServer(conf)
+	u := &model.User{Login: "Valid", ID: 1}
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, u)))
+		})
+	})
+	router.Post("/secretfile/upload", s.handleUploadInit())
+	router.Get("/secretfile/upload/{uploadID}", s.handleUploadWithID())
+	router.Put("/secretfile/upload/{uploadID}/{index}", s.handleUploadChunk())
+	router.Post("/secretfile/upload/{uploadID}/complete", s.handleUploadComplete())
+
+	content := []byte("chunked secret file content")
+	jsonData, _ := json.Marshal(&model.Upload{Name: "upload", Key: "v2:key", Size: int64(len(content))})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/secretfile/upload", bytes.NewReader(jsonData)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	upload := &model.Upload{}
+	if err := json.NewDecoder(rec.Body).Decode(upload); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	tests := []struct {
+		name     string
+		method   string
+		uri      string
+		body     []byte
+		checksum string
+		want     int
+	}{
+		{name: "Incomplete", method: http.MethodPost, uri: "/complete", want: http.StatusConflict},
+		{name: "BadChecksum", method: http.MethodPut, uri: "/0", body: content, checksum: "00", want: http.StatusBadRequest},
+		{name: "OutOfRange", method: http.MethodPut, uri: "/1", body: content, checksum: hex.EncodeToString(sum[:]), want: http.StatusBadRequest},
+		{name: "Chunk", method: http.MethodPut, uri: "/0", body: content, checksum: hex.EncodeToString(sum[:]), want: http.StatusNoContent},
+		{name: "Status", method: http.MethodGet, want: http.StatusOK},
+		{name: "Complete", method: http.MethodPost, uri: "/complete", want: http.StatusCreated},
+		{name: "Gone", method: http.MethodGet, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/secretfile/upload/"+upload.ID+tt.uri, bytes.NewReader(tt.body))
+			req.Header.Set(ChunkChecksumHeader, tt.checksum)
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func Test_ifMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int
+		wantErr bool
+	}{
+		{header: "", want: 0},
+		{header: "*", want: 0},
+		{header: `"3"`, want: 3},
+		{header: `W/"12"`, want: 12},
+		{header: `"0"`, wantErr: true},
+		{header: `"abc"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/", nil)
+			req.Header.Set("If-Match", tt.header)
+			got, err := ifMatchVersion(req)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_labelFilter(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?folder=/work/db/&tag=aws&tag=prod&favorite=true", nil)
+	filter, err := labelFilter(req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.LabelFilter{Folder: "work/db", Tags: []string{"aws", "prod"}, Favorite: true}, filter)
+	}
+	_, err = labelFilter(httptest.NewRequest(http.MethodGet, "/?favorite=maybe", nil))
+	assert.ErrorIs(t, err, ErrInvalidFavorite)
+}
+
+func Test_server_setClientID(t *testing.T) {
+	s := &server{logger: log.New()}
+	var got string
+	handler := s.setClientID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientID(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodPut, "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "", got)
+
+	req.Header.Set(ClientHeader, " laptop ")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "laptop", got)
+
+	req.Header.Set(ClientHeader, strings.Repeat("x", maxClientIDLength+10))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Len(t, got, maxClientIDLength)
+}
+
+func Test_server_handleSecretVersion(t *testing.T) {
+	s := &server{logger: log.New()}
+	router := chi.NewRouter()
+	router.Get("/secrettext/{id}/history/{version}", s.handleSecretVersion(model.SecretTextKind))
+	u := &model.User{ID: 1}
+	for _, uri := range []string{"/secrettext/x/history/1", "/secrettext/1/history/0", "/secrettext/1/history/v"} {
+		t.Run(uri, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, uri, nil)
+			router.ServeHTTP(rec, req.WithContext(context.WithValue(req.Context(), ctxKeyUser, u)))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+func Test_server_handleSecretWithBody_preconditionRequired(t *testing.T) {
+	s := &server{logger: log.New()}
+	u := &model.User{ID: 1}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/secrettext", strings.NewReader(`{"id": 1, "name": "note", "text": "v2:c2VhbGVk"}`))
+	s.handleSecretWithBody(model.SecretTextKind)(rec, req.WithContext(context.WithValue(req.Context(), ctxKeyUser, u)))
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+}
+
+func Test_listQuery(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?tag=aws&sort=-updated_at&limit=10&updated_after=2024-05-01T10:00:00%2B02:00", nil)
+	q, err := listQuery(req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.SortUpdated, q.Sort)
+		assert.True(t, q.Desc)
+		assert.Equal(t, 10, q.Limit)
+		assert.Equal(t, []string{"aws"}, q.Tags)
+		assert.True(t, q.UpdatedAfter.Equal(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)))
+		assert.Nil(t, q.After)
+	}
+	key := model.ListKey{ID: 7, At: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)}
+	req = httptest.NewRequest(http.MethodGet, "/?sort=-updated_at&cursor="+encodeListCursor(q, key), nil)
+	q, err = listQuery(req)
+	if assert.NoError(t, err) && assert.NotNil(t, q.After) {
+		assert.Equal(t, 7, q.After.ID)
+		assert.True(t, key.At.Equal(q.After.At))
+		assert.Equal(t, defaultListLimit, q.Limit)
+	}
+
+	// A cursor of another order is rejected
+	_, err = listQuery(httptest.NewRequest(http.MethodGet, "/?sort=name&cursor="+encodeListCursor(q, key), nil))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = listQuery(httptest.NewRequest(http.MethodGet, "/?sort=size", nil))
+	assert.ErrorIs(t, err, ErrInvalidSort)
+	_, err = listQuery(httptest.NewRequest(http.MethodGet, "/?created_before=yesterday", nil))
+	assert.ErrorIs(t, err, ErrInvalidTime)
+	_, err = listQuery(httptest.NewRequest(http.MethodGet, "/?limit=-1", nil))
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+}
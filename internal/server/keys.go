@@ -0,0 +1,95 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Server keys are either configured or generated on the first start and kept in files of key_dir
+const (
+	// minKeyLength is the shortest configured key, shorter keys may be guessed
+	minKeyLength = 32
+	// defaultKey was the default of configured keys, it is known to anyone who has read the source
+	defaultKey  = "cenarius"
+	keySize     = 32
+	keyFileMode = 0600
+	keyDirMode  = 0700
+)
+
+var ErrWeakKey = errors.New("key is too short or is the public default")
+
+// loadKey returns the configured key. If none is configured the key is read from <key_dir>/<name>.key,
+// which is generated with a random key on the first start.
+func (s *server) loadKey(name, configured string) ([]byte, error) {
+	if configured != "" {
+		if configured == defaultKey || len(configured) < minKeyLength {
+			return nil, fmt.Errorf("%w: %s key must be at least %d characters long", ErrWeakKey, name, minKeyLength)
+		}
+		return []byte(configured), nil
+	}
+	path := filepath.Join(s.config.KeyDir, name+".key")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s.generateKey(name, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) < keySize {
+		return nil, fmt.Errorf("%w: %s is malformed", ErrWeakKey, path)
+	}
+	return key, nil
+}
+
+// generateKey writes a new random key to the path, an existing file is never replaced
+func (s *server) generateKey(name, path string) ([]byte, error) {
+	if err := os.MkdirAll(s.config.KeyDir, keyDirMode); err != nil {
+		return nil, err
+	}
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, keyFileMode)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	s.logger.Warnf("Generated %s key %s, back it up and share it with every server of the deployment", name, path)
+	return key, nil
+}
+
+// configureSessionKey loads the key session tokens are signed with
+func (s *server) configureSessionKey() error {
+	key, err := s.loadKey("session", s.config.SessionKey)
+	if err != nil {
+		return err
+	}
+	s.sessionKey = key
+	return nil
+}
+
+// configureFileKey derives the key secret files are encrypted with at rest
+func (s *server) configureFileKey() error {
+	key, err := s.loadKey("file", s.config.FileKey)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(key)
+	s.fileKey = sum[:]
+	return nil
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_server_loadKey(t *testing.T) {
+	conf := NewConfig()
+	conf.KeyDir = filepath.Join(t.TempDir(), "keys")
+	s := &server{config: conf, logger: log.New()}
+
+	generated, err := s.loadKey("session", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, generated, keySize)
+	stat, err := os.Stat(filepath.Join(conf.KeyDir, "session.key"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(keyFileMode), stat.Mode().Perm())
+	}
+	// The generated key is kept across restarts
+	again, err := s.loadKey("session", "")
+	assert.NoError(t, err)
+	assert.Equal(t, generated, again)
+
+	configured := strings.Repeat("k", minKeyLength)
+	key, err := s.loadKey("session", configured)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(configured), key)
+
+	_, err = s.loadKey("session", defaultKey)
+	assert.ErrorIs(t, err, ErrWeakKey)
+	_, err = s.loadKey("session", "short")
+	assert.ErrorIs(t, err, ErrWeakKey)
+
+	if err := os.WriteFile(filepath.Join(conf.KeyDir, "broken.key"), []byte("not base64"), keyFileMode); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.loadKey("broken", "")
+	assert.ErrorIs(t, err, ErrWeakKey)
+}
+
+func Test_server_configureFileKey(t *testing.T) {
+	conf := NewConfig()
+	conf.KeyDir = t.TempDir()
+	s := &server{config: conf, logger: log.New()}
+	if assert.NoError(t, s.configureFileKey()) {
+		assert.Len(t, s.fileKey, keySize)
+		assert.FileExists(t, filepath.Join(conf.KeyDir, "file.key"))
+	}
+	conf.FileKey = defaultKey
+	assert.ErrorIs(t, s.configureFileKey(), ErrWeakKey)
+}
+
+func TestConfig_String(t *testing.T) {
+	conf := NewConfig()
+	conf.SessionKey = "a very secret session key"
+	s := conf.String()
+	assert.NotContains(t, s, conf.SessionKey)
+	assert.Contains(t, s, "SessionKey:***")
+}
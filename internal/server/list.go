@@ -0,0 +1,90 @@
+package server
+
+import (
+	"cenarius/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NextCursorHeader carries the cursor of the next page of a list, it is missing on the last page
+const NextCursorHeader = "X-Cenarius-Next-Cursor"
+
+// Page sizes of lists of secrets
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+var (
+	ErrInvalidSort = errors.New("sort must be name, created_at or updated_at, - prefix reverses the order")
+	ErrInvalidTime = errors.New("created_after, created_before, updated_after and updated_before must be RFC 3339 times")
+)
+
+// listCursor is the position of the last secret of a page in the order of the list
+type listCursor struct {
+	Sort string        `json:"sort,omitempty"`
+	Desc bool          `json:"desc,omitempty"`
+	Key  model.ListKey `json:"key"`
+}
+
+// encodeListCursor returns the opaque cursor of the page following the key
+func encodeListCursor(q model.ListQuery, key model.ListKey) string {
+	data, _ := json.Marshal(listCursor{Sort: q.Sort, Desc: q.Desc, Key: key})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeListCursor returns the key the page of the cursor follows, the cursor must be of the same order
+func decodeListCursor(cursor string, q model.ListQuery) (*model.ListKey, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := listCursor{}
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != q.Sort || c.Desc != q.Desc {
+		return nil, ErrInvalidCursor
+	}
+	return &c.Key, nil
+}
+
+// listQuery returns the query of label filters, time ranges, sort, limit and cursor query parameters.
+// sort is name, created_at or updated_at, -updated_at lists recently updated secrets first.
+func listQuery(r *http.Request) (model.ListQuery, error) {
+	filter, err := labelFilter(r)
+	if err != nil {
+		return model.ListQuery{}, err
+	}
+	q := model.ListQuery{LabelFilter: filter}
+	values := r.URL.Query()
+	for param, t := range map[string]*time.Time{
+		"created_after":  &q.CreatedAfter,
+		"created_before": &q.CreatedBefore,
+		"updated_after":  &q.UpdatedAfter,
+		"updated_before": &q.UpdatedBefore,
+	} {
+		if v := values.Get(param); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				return q, ErrInvalidTime
+			}
+		}
+	}
+	q.Sort = values.Get("sort")
+	if strings.HasPrefix(q.Sort, "-") {
+		q.Sort, q.Desc = q.Sort[1:], true
+	}
+	if !model.ValidSort(q.Sort) || q.Sort == "" && q.Desc {
+		return q, ErrInvalidSort
+	}
+	if q.Limit, err = pageLimit(r, defaultListLimit, maxListLimit); err != nil {
+		return q, err
+	}
+	if v := values.Get("cursor"); v != "" {
+		if q.After, err = decodeListCursor(v, q); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
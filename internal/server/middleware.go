@@ -1,10 +1,8 @@
 package server
 
 import (
-	"cenarius/internal/model"
 	"cenarius/internal/store"
 	"context"
-	"encoding/base64"
 	"net/http"
 	"strings"
 	"time"
@@ -30,22 +28,21 @@ func (s *server) authenticateUser(next http.Handler) http.Handler {
 			s.error(w, r, http.StatusUnauthorized, store.ErrNotAuthenticated)
 			return
 		}
-		data, err := base64.StdEncoding.DecodeString(h)
+		claims, err := s.parseSession(h)
 		if err != nil {
-			s.logger.Errorf("Unable to decode header %s: %s", h, err.Error())
-			s.error(w, r, http.StatusUnauthorized, store.ErrNotAuthenticated)
+			s.logger.Errorf("Bad session token: %s", err.Error())
+			s.error(w, r, http.StatusUnauthorized, err)
 			return
 		}
-		loginAndPassword := strings.Fields(string(data))
-		if len(loginAndPassword) != 2 {
-			s.logger.Errorf("Bad header: %s", h)
+		u, err := s.store.User().FindByID(r.Context(), claims.UserID)
+		if err != nil {
+			s.logger.Errorf("Unable to find user %d from session: %s", claims.UserID, err.Error())
 			s.error(w, r, http.StatusUnauthorized, store.ErrNotAuthenticated)
 			return
 		}
-		u := &model.User{Login: loginAndPassword[0], Password: loginAndPassword[1]}
-		u, err = s.userLogin(r.Context(), u)
-		if err != nil {
-			s.error(w, r, http.StatusUnauthorized, store.ErrNotAuthenticated)
+		if err := checkSession(claims, u); err != nil {
+			s.logger.Errorf("Revoked session of user %s: %s", u.Login, err.Error())
+			s.error(w, r, http.StatusUnauthorized, err)
 			return
 		}
 		s.logger.Debugf("server.authenticateUser ok: %s", u.Login)
@@ -53,6 +50,23 @@ func (s *server) authenticateUser(next http.Handler) http.Handler {
 	})
 }
 
+// setClientID keeps the client ID of the agent for secrets saved by the request
+func (s *server) setClientID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get(ClientHeader))
+		if len(id) > maxClientIDLength {
+			id = id[:maxClientIDLength]
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyClientID, id)))
+	})
+}
+
+// clientID returns the client ID of the request, empty if the agent didn't send one
+func clientID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKeyClientID).(string)
+	return id
+}
+
 func (s *server) setRequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		s.logger.Debug("server.setRequestID is working")
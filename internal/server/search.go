@@ -0,0 +1,108 @@
+package server
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// Page sizes of search results
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+var (
+	ErrEmptySearch   = errors.New("q must contain a word to search for")
+	ErrInvalidLimit  = errors.New("limit must be a positive number")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// encodeCursor returns the opaque cursor of the page starting at the offset
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset of the page of the cursor, the empty cursor is the first page
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(string(data))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
+
+// pageLimit returns the limit query parameter capped at maxLimit, def without it
+func pageLimit(r *http.Request, def, maxLimit int) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 {
+		return 0, ErrInvalidLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return limit, nil
+}
+
+// search returns the page of secrets of the user of every kind matching the words, one more hit
+// is asked for to tell whether the page is the last one
+func (s *server) search(ctx context.Context, words []string, limit, offset, userID int) (*model.SearchResults, error) {
+	hits, err := s.store.Search().Find(ctx, words, limit+1, offset, userID)
+	if err != nil {
+		return nil, err
+	}
+	results := &model.SearchResults{Hits: hits}
+	if len(hits) > limit {
+		results.Hits = hits[:limit]
+		results.NextCursor = encodeCursor(offset + limit)
+	}
+	return results, nil
+}
+
+// handleSearch searches secrets of every kind by words of the q query parameter in names, meta and tags.
+// Results are paged by limit and cursor, next_cursor of the results points to the next page.
+func (s *server) handleSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
+		}
+		words := model.SearchWords(r.URL.Query().Get("q"))
+		if len(words) == 0 {
+			s.error(w, r, http.StatusBadRequest, ErrEmptySearch)
+			return
+		}
+		limit, err := pageLimit(r, defaultSearchLimit, maxSearchLimit)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		offset, err := decodeCursor(r.URL.Query().Get("cursor"))
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		results, err := s.search(r.Context(), words, limit, offset, user.ID)
+		if err != nil {
+			s.logger.Errorf("server.handleSearch: %v", err)
+			s.error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		s.respond(w, r, http.StatusOK, results)
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_decodeCursor(t *testing.T) {
+	offset, err := decodeCursor(encodeCursor(40))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 40, offset)
+	}
+	offset, err = decodeCursor("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, offset)
+	}
+	_, err = decodeCursor("not a cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = decodeCursor(encodeCursor(-1))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func Test_pageLimit(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{"", defaultSearchLimit, false},
+		{"limit=5", 5, false},
+		{"limit=1000", maxSearchLimit, false},
+		{"limit=0", 0, true},
+		{"limit=many", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := pageLimit(httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil), defaultSearchLimit, maxSearchLimit)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
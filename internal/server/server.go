@@ -1,30 +1,54 @@
 package server
 
 import (
+	"cenarius/internal/encrypt"
 	"cenarius/internal/model"
 	"cenarius/internal/store"
+	"cenarius/internal/store/blobstore"
 	"cenarius/internal/store/sqlstore"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"os"
+	"path"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/golang-migrate/migrate"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
 type ctxKey int8
 
 const (
-	AuthHeader        = "X-Cenarius-Token"
-	ctxKeyUser ctxKey = iota
+	AuthHeader = "X-Cenarius-Token"
+	// ClientHeader names the agent making the request, it is recorded with every saved version of a secret
+	ClientHeader        = "X-Cenarius-Client"
+	ctxKeyUser   ctxKey = iota
 	ctxKeyRequestID
+	ctxKeyClientID
 )
 
-var ErrUnableToGetUserFromRequest = errors.New("unable to get user from request context")
+// maxClientIDLength limits client IDs recorded with secret versions
+const maxClientIDLength = 64
+
+var (
+	ErrUnableToGetUserFromRequest = errors.New("unable to get user from request context")
+	ErrUnknownBlobStorage         = errors.New("unknown blob storage")
+	ErrInvalidRevision            = errors.New("since must be a non-negative revision")
+	ErrInvalidIfMatch             = errors.New("invalid If-Match, a quoted secret version is expected")
+	ErrPreconditionRequired       = errors.New("update needs If-Match or the version of the secret, If-Match: * overwrites any version")
+	ErrInvalidVersion             = errors.New("version must be a positive secret version")
+	ErrInvalidFavorite            = errors.New("favorite must be true or false")
+	ErrNotLegacy                  = errors.New("secrets of the account are sealed with the vault key already")
+)
 
 // server server main struct
 type server struct {
@@ -33,6 +57,10 @@ type server struct {
 	HTTPServer *http.Server
 	router     *chi.Mux
 	store      store.Store
+	blobs      store.BlobStore
+	fileKey    []byte
+	sessionKey []byte
+	events     *hub
 }
 
 // NewServer returns new server object
@@ -41,13 +69,24 @@ func NewServer(config *Config) *server {
 		config:     config,
 		logger:     log.New(),
 		HTTPServer: &http.Server{Addr: config.Bind},
+		events:     newHub(),
 	}
+	s.HTTPServer.RegisterOnShutdown(s.events.close)
 	if err := s.configureLogger(); err != nil {
 		log.Fatalf("Can't configure logger: %s", err.Error())
 	}
+	if err := s.configureSessionKey(); err != nil {
+		log.Fatalf("Can't configure session key: %s", err.Error())
+	}
+	if err := s.configureFileKey(); err != nil {
+		log.Fatalf("Can't configure file key: %s", err.Error())
+	}
 	if err := s.configureStore(); err != nil {
 		log.Fatalf("Can't configure store: %s", err.Error())
 	}
+	if err := s.configureBlobStore(); err != nil {
+		log.Fatalf("Can't configure blob store: %s", err.Error())
+	}
 
 	return s
 }
@@ -73,8 +112,12 @@ func (s *server) StopHTTPServer() {
 	}
 }
 
-// Start starts the server
+// Start starts the server and purging of the trash, purging stops with the server
 func (s *server) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.HTTPServer.RegisterOnShutdown(cancel)
+	go s.runPurge(ctx)
 	err := s.StartHTTPServer()
 	if err != nil {
 		return err
@@ -100,6 +143,25 @@ func (s *server) configureLogger() error {
 	return nil
 }
 
+// configureBlobStore configures storage of secret file contents
+func (s *server) configureBlobStore() error {
+	switch s.config.BlobStorage {
+	case "local":
+		s.blobs = blobstore.NewLocalStore(s.config.SecretFilePath)
+	case "s3":
+		s.blobs = blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:  s.config.S3Endpoint,
+			Region:    s.config.S3Region,
+			Bucket:    s.config.S3Bucket,
+			AccessKey: s.config.S3AccessKey,
+			SecretKey: s.config.S3SecretKey,
+		})
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownBlobStorage, s.config.BlobStorage)
+	}
+	return nil
+}
+
 // configureStore configures store
 func (s *server) configureStore() error {
 	var conn *sql.DB
@@ -139,6 +201,7 @@ func (s *server) userRegister(ctx context.Context, u *model.User) (*model.User,
 	return u, http.StatusAccepted, nil
 }
 
+// userLogin checks the auth key of the user, legacy accounts log in with the master password until upgradeVault
 func (s *server) userLogin(ctx context.Context, u *model.User) (*model.User, error) {
 	storageUser, err := s.store.User().FindByLogin(ctx, u.Login)
 	if err != nil {
@@ -146,269 +209,539 @@ func (s *server) userLogin(ctx context.Context, u *model.User) (*model.User, err
 		return nil, err
 	}
 	if !storageUser.ComparePassword(u.Password) {
-		s.logger.Errorf("Incorrect Password: %v", u.Password)
+		s.logger.Errorf("Incorrect password of user %s", u.Login)
 		return nil, store.ErrIncorrectPassword
 	}
 	u.ID = storageUser.ID
 	u.EncryptedPassword = storageUser.EncryptedPassword
+	u.KDFSalt = storageUser.KDFSalt
 	u.Sanitaze()
 	return u, nil
 }
 
-func (s *server) addLoginWithPassword(ctx context.Context, m *model.LoginWithPassword, key, iv string) (*model.LoginWithPassword, error) {
-	if err := m.Validate(); err != nil {
-		return nil, err
+// legacyVault opens secrets of the legacy account which the server sealed with the hash of the master password,
+// so the agent reseals them with the vault key
+func (s *server) legacyVault(ctx context.Context, userID int) (*model.LegacyVault, int, error) {
+	u, err := s.store.User().FindByID(ctx, userID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
 	}
-	if err := m.Encrypt(key, iv); err != nil {
-		return nil, err
+	if !u.LegacyAuth {
+		return nil, http.StatusConflict, ErrNotLegacy
 	}
-	if err := s.store.LoginWithPassword().Add(ctx, m); err != nil {
-		s.logger.Errorf("Failed to add LoginWithPassword %v: %v", m, err)
-		return nil, err
+	var v *model.LegacyVault
+	err = s.store.WithTx(ctx, func(tx store.Store) error {
+		v, err = openLegacyVault(ctx, tx, u)
+		return err
+	})
+	if err != nil {
+		s.logger.Errorf("Unable to open legacy secrets of user %d: %v", userID, err)
+		return nil, http.StatusInternalServerError, err
 	}
-	s.logger.Debugf("LoginWithPassword created: %v", m)
-	return m, nil
+	return v, http.StatusOK, nil
 }
 
-func (s *server) addCreditCard(ctx context.Context, m *model.CreditCard, key, iv string) (*model.CreditCard, error) {
-	if err := m.Validate(); err != nil {
-		return nil, err
-	}
-	if err := m.Encrypt(key, iv); err != nil {
-		return nil, err
+// upgradeVault replaces legacy secrets of the user with their copies resealed by the agent and the master password
+// with the auth key in one transaction, the account is no longer legacy from then on
+func (s *server) upgradeVault(ctx context.Context, userID int, v *model.LegacyVault) (*model.User, int, error) {
+	if err := v.Validate(); err != nil {
+		return nil, http.StatusBadRequest, err
 	}
-	if err := s.store.CreditCard().Add(ctx, m); err != nil {
-		s.logger.Errorf("Failed to add CreditCard %v: %v", m, err)
-		return nil, err
+	u, err := s.store.User().FindByID(ctx, userID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
 	}
-	s.logger.Debugf("CreditCard created: %v", m)
-	return m, nil
-}
-
-func (s *server) addSecretText(ctx context.Context, m *model.SecretText, key, iv string) (*model.SecretText, error) {
-	if err := m.Validate(); err != nil {
-		return nil, err
+	if !u.LegacyAuth {
+		return nil, http.StatusConflict, ErrNotLegacy
 	}
-	if err := m.Encrypt(key, iv); err != nil {
-		return nil, err
+	err = s.store.WithTx(ctx, func(tx store.Store) error {
+		stored, err := openLegacyVault(ctx, tx, u)
+		if err != nil {
+			return err
+		}
+		if err := resealLegacy(ctx, tx, u.ID, stored, v); err != nil {
+			return err
+		}
+		u.Password = v.AuthKey
+		return tx.User().UpdatePassword(ctx, u)
+	})
+	if errors.Is(err, store.ErrVaultMismatch) || errors.Is(err, store.ErrVersionConflict) {
+		s.logger.Errorf("Upgrade of user %d rejected: %v", userID, err)
+		return nil, http.StatusConflict, err
 	}
-	if err := s.store.SecretText().Add(ctx, m); err != nil {
-		s.logger.Errorf("Failed to add SecretText %v: %v", m, err)
-		return nil, err
+	if err != nil {
+		s.logger.Errorf("Upgrade of user %d failed: %v", userID, err)
+		return nil, http.StatusBadRequest, err
 	}
-	s.logger.Debugf("SecretText created: %v", m)
-	return m, nil
+	u.Sanitaze()
+	s.logger.Infof("User %s logs in with the auth key from now on", u.Login)
+	s.events.publish(userID, &model.ChangeEvent{Op: model.EventPassword})
+	return u, http.StatusOK, nil
 }
 
-func (s *server) addSecretFile(ctx context.Context, m *model.SecretFile, key, iv string) (*model.SecretFile, error) {
-	if err := m.Validate(); err != nil {
-		return nil, err
-	}
-	if err := m.Encrypt(key, iv); err != nil {
-		return nil, err
-	}
-	if err := s.store.SecretFile().Add(ctx, m); err != nil {
-		s.logger.Errorf("Failed to add SecretFile %v: %v", m, err)
-		return nil, err
+// openLegacyVault locks secrets of the user and returns the legacy ones and their prior versions opened,
+// blob names of opened secrets are the names of the stored blobs
+func openLegacyVault(ctx context.Context, tx store.Store, u *model.User) (*model.LegacyVault, error) {
+	v := &model.LegacyVault{Secrets: &model.SecretCache{}, Trash: &model.SecretCache{}, History: &model.SecretCache{}}
+	for _, kind := range model.Kinds() {
+		repo := tx.Secrets(kind)
+		locked, err := repo.Lock(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		versions, err := repo.Versions(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		locked, err = openLegacy(locked, u.EncryptedPassword)
+		if err != nil {
+			return nil, err
+		}
+		if versions, err = openLegacy(versions, u.EncryptedPassword); err != nil {
+			return nil, err
+		}
+		stored, trashed := splitTrashed(locked)
+		v.Secrets.Set(kind, stored)
+		v.Trash.Set(kind, trashed)
+		v.History.Set(kind, versions)
 	}
-	s.logger.Debugf("SecretFile created: %v", m)
-	return m, nil
+	return v, nil
 }
 
-func (s *server) updateLoginWithPassword(ctx context.Context, m *model.LoginWithPassword, key, iv string) (*model.LoginWithPassword, error) {
-	if err := m.Validate(); err != nil {
-		return nil, err
-	}
-	if err := m.Encrypt(key, iv); err != nil {
-		return nil, err
-	}
-	if err := s.store.LoginWithPassword().Update(ctx, m); err != nil {
-		s.logger.Errorf("Failed to add LoginWithPassword %v: %v", m, err)
-		return nil, err
+// openLegacy returns legacy secrets of mm opened with the hash of the master password
+func openLegacy(mm []model.Secret, passwordHash string) ([]model.Secret, error) {
+	legacy := make([]model.Secret, 0)
+	for _, m := range mm {
+		if !model.IsLegacy(m) {
+			continue
+		}
+		if err := model.OpenLegacy(m, passwordHash); err != nil {
+			return nil, fmt.Errorf("secret %d: %w", m.Data().ID, err)
+		}
+		legacy = append(legacy, m)
 	}
-	s.logger.Debugf("LoginWithPassword updated: %v", m)
-	return m, nil
+	return legacy, nil
 }
 
-func (s *server) updateCreditCard(ctx context.Context, m *model.CreditCard, key, iv string) (*model.CreditCard, error) {
-	if err := m.Validate(); err != nil {
-		return nil, err
+// resealLegacy overwrites the stored legacy secrets and versions with their resealed copies like reencryptSecrets
+func resealLegacy(ctx context.Context, tx store.Store, userID int, stored, resealed *model.LegacyVault) error {
+	if resealed.Secrets == nil {
+		resealed.Secrets = &model.SecretCache{}
 	}
-	if err := m.Encrypt(key, iv); err != nil {
-		return nil, err
-	}
-	if err := s.store.CreditCard().Update(ctx, m); err != nil {
-		s.logger.Errorf("Failed to add CreditCard %v: %v", m, err)
-		return nil, err
+	if resealed.Trash == nil {
+		resealed.Trash = &model.SecretCache{}
 	}
-	s.logger.Debugf("CreditCard updated: %v", m)
-	return m, nil
-}
-
-func (s *server) updateSecretText(ctx context.Context, m *model.SecretText, key, iv string) (*model.SecretText, error) {
-	if err := m.Validate(); err != nil {
-		return nil, err
+	if resealed.History == nil {
+		resealed.History = &model.SecretCache{}
 	}
-	if err := m.Encrypt(key, iv); err != nil {
-		return nil, err
-	}
-	if err := s.store.SecretText().Update(ctx, m); err != nil {
-		s.logger.Errorf("Failed to add SecretText %v: %v", m, err)
-		return nil, err
+	for _, kind := range model.Kinds() {
+		repo := tx.Secrets(kind)
+		if err := reencryptKind(ctx, kind, userID, stored.Secrets.Get(kind), resealed.Secrets.Get(kind), secretKey, repo.Reseal); err != nil {
+			return err
+		}
+		if err := reencryptKind(ctx, kind, userID, stored.Trash.Get(kind), resealed.Trash.Get(kind), secretKey, repo.Reseal); err != nil {
+			return err
+		}
+		if err := reencryptKind(ctx, kind, userID, stored.History.Get(kind), resealed.History.Get(kind), versionKey, repo.UpdateVersion); err != nil {
+			return fmt.Errorf("history: %w", err)
+		}
 	}
-	s.logger.Debugf("SecretText updated: %v", m)
-	return m, nil
+	return nil
 }
 
-func (s *server) updateSecretFile(ctx context.Context, m *model.SecretFile, key, iv string) (*model.SecretFile, error) {
-	storageFile, err := s.store.SecretFile().GetByID(ctx, m.ID, m.UserID)
-	if err != nil {
-		s.logger.Errorf("Unable to find SecretFile in db %v: %v", m, err)
-		return nil, err
+// prelogin returns the salt of the vault key of the login. Unknown logins get a stable salt derived
+// from the session key, so they can't be told from existing ones.
+func (s *server) prelogin(ctx context.Context, login string) (*model.Prelogin, error) {
+	u, err := s.store.User().FindByLogin(ctx, login)
+	if errors.Is(err, sql.ErrNoRows) {
+		mac := hmac.New(sha256.New, s.sessionKey)
+		mac.Write([]byte("prelogin " + login))
+		return &model.Prelogin{Login: login, KDFSalt: base64.StdEncoding.EncodeToString(mac.Sum(nil)[:16])}, nil
 	}
-	m.Path = storageFile.Path
-	if err := m.Validate(); err != nil {
-		s.logger.Errorf("SecretFile validation failed %v: %v", m, err)
-		return nil, err
-	}
-	if err := s.store.SecretFile().Update(ctx, m); err != nil {
-		s.logger.Errorf("Failed to add SecretFile %v: %v", m, err)
+	if err != nil {
 		return nil, err
 	}
-	s.logger.Debugf("SecretFile updated: %v", m)
-	return m, nil
+	return &model.Prelogin{Login: login, KDFSalt: u.KDFSalt, LegacyAuth: u.LegacyAuth}, nil
 }
 
-func (s *server) deleteLoginWithPassword(ctx context.Context, id, userID int) error {
-	if err := s.store.LoginWithPassword().Delete(ctx, id, userID); err != nil {
+func (s *server) addSecret(ctx context.Context, kind model.Kind, m model.Secret) error {
+	if err := s.addSecretTx(ctx, s.store, kind, m); err != nil {
 		return err
 	}
+	s.publishChange(model.EventAdd, kind, m)
 	return nil
 }
 
-func (s *server) deleteCreditCard(ctx context.Context, id, userID int) error {
-	if err := s.store.CreditCard().Delete(ctx, id, userID); err != nil {
+// addSecretTx checks the secret and adds it to st, which may be bound to a transaction.
+// The change is published by the caller once it is committed.
+func (s *server) addSecretTx(ctx context.Context, st store.Store, kind model.Kind, m model.Secret) error {
+	// Blob name of blob secrets is set by the server, so the secret is validated here
+	if _, ok := m.(model.BlobSecret); ok {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := m.ValidateEncrypted(); err != nil {
+		return err
+	}
+	if err := normalizeLabels(&m.Data().Labels); err != nil {
+		return err
+	}
+	m.Data().ClientID = clientID(ctx)
+	if err := st.Secrets(kind).Add(ctx, m); err != nil {
+		s.logger.Errorf("Failed to add %s %v: %v", kind.Table(), m, err)
 		return err
 	}
+	s.logger.Debugf("%s created: %v", kind.Table(), m)
 	return nil
 }
 
-func (s *server) deleteSecretText(ctx context.Context, id, userID int) error {
-	if err := s.store.SecretText().Delete(ctx, id, userID); err != nil {
+func (s *server) updateSecret(ctx context.Context, kind model.Kind, m model.Secret) error {
+	if err := s.updateSecretTx(ctx, s.store, kind, m); err != nil {
 		return err
 	}
+	s.publishChange(model.EventUpdate, kind, m)
 	return nil
 }
 
-func (s *server) deleteSecretFile(ctx context.Context, id, userID int, key, iv string) error {
-	m, err := s.store.SecretFile().GetByID(ctx, id, userID)
-	if err != nil {
-		return err
+// updateSecretTx checks the secret and updates it in st like addSecretTx adds it
+func (s *server) updateSecretTx(ctx context.Context, st store.Store, kind model.Kind, m model.Secret) error {
+	if b, ok := m.(model.BlobSecret); ok {
+		if err := keepBlob(ctx, st, kind, b); err != nil {
+			s.logger.Errorf("%s validation failed %v: %v", kind.Table(), m, err)
+			return err
+		}
 	}
-	if err := m.Decrypt(key, iv); err != nil {
+	if err := m.ValidateEncrypted(); err != nil {
 		return err
 	}
-	if err := s.store.SecretFile().Delete(ctx, id, userID); err != nil {
+	if err := normalizeLabels(&m.Data().Labels); err != nil {
 		return err
 	}
-	if err := os.Remove(m.Path); err != nil {
+	m.Data().ClientID = clientID(ctx)
+	if err := st.Secrets(kind).Update(ctx, m); err != nil {
+		s.logger.Errorf("Failed to update %s %v: %v", kind.Table(), m, err)
 		return err
 	}
+	s.logger.Debugf("%s updated: %v", kind.Table(), m)
 	return nil
 }
 
-func (s *server) getLoginWithPassword(ctx context.Context, id, userID int, key, iv string) (*model.LoginWithPassword, error) {
-	m, err := s.store.LoginWithPassword().GetByID(ctx, id, userID)
+// normalizeLabels drops empty folders and repeated tags and checks the labels
+func normalizeLabels(l *model.Labels) error {
+	l.Normalize()
+	return l.Validate()
+}
+
+// keepBlob takes the blob name and generated fields the agent left empty from the stored secret,
+// an update never replaces the blob
+func keepBlob(ctx context.Context, st store.Store, kind model.Kind, m model.BlobSecret) error {
+	stored, err := st.Secrets(kind).GetByID(ctx, m.Data().ID, m.Data().UserID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err := m.Decrypt(key, iv); err != nil {
-		return nil, err
+	*m.BlobName() = *stored.(model.BlobSecret).BlobName()
+	storedFields := stored.Fields()
+	for i, f := range m.Fields() {
+		if f.Generated && *f.Value == "" {
+			*f.Value = *storedFields[i].Value
+		}
 	}
-	return m, nil
+	return m.Validate()
 }
 
-func (s *server) getCreditCard(ctx context.Context, id, userID int, key, iv string) (*model.CreditCard, error) {
-	m, err := s.store.CreditCard().GetByID(ctx, id, userID)
-	if err != nil {
-		return nil, err
+// deleteSecret moves the secret to the trash, blobs are removed when the secret is purged
+func (s *server) deleteSecret(ctx context.Context, kind model.Kind, id, userID int) error {
+	if err := s.store.Secrets(kind).Delete(ctx, id, userID); err != nil {
+		return err
 	}
-	if err := m.Decrypt(key, iv); err != nil {
-		return nil, err
+	s.publishChange(model.EventDelete, kind, deletedSecret(kind, id, userID))
+	return nil
+}
+
+// deletedSecret returns the secret of the kind carrying only the id and the owner, its deletion is published
+func deletedSecret(kind model.Kind, id, userID int) model.Secret {
+	deleted := kind.New()
+	deleted.Data().ID, deleted.Data().UserID = id, userID
+	return deleted
+}
+
+// publishChange notifies event streams of the owner of the secret
+func (s *server) publishChange(op string, kind model.Kind, m model.Secret) {
+	d := m.Data()
+	s.events.publish(d.UserID, &model.ChangeEvent{Op: op, Kind: kind.Name(), ID: d.ID, Revision: d.Revision})
+}
+
+func (s *server) getSecret(ctx context.Context, kind model.Kind, id, userID int) (model.Secret, error) {
+	return s.store.Secrets(kind).GetByID(ctx, id, userID)
+}
+
+// secretHistory returns prior versions of the secret, the secret may be deleted already
+func (s *server) secretHistory(ctx context.Context, kind model.Kind, id, userID int) ([]model.SecretVersion, error) {
+	return s.store.Secrets(kind).History(ctx, id, userID)
+}
+
+// allHistory returns prior versions of every secret of the user of every kind
+func (s *server) allHistory(ctx context.Context, userID int) (*model.SecretCache, error) {
+	history := &model.SecretCache{}
+	for _, kind := range model.Kinds() {
+		versions, err := s.store.Secrets(kind).Versions(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		history.Set(kind, versions)
 	}
-	return m, nil
+	return history, nil
 }
 
-func (s *server) getSecretText(ctx context.Context, id, userID int, key, iv string) (*model.SecretText, error) {
-	m, err := s.store.SecretText().GetByID(ctx, id, userID)
+func (s *server) getSecretVersion(ctx context.Context, kind model.Kind, id, version, userID int) (model.Secret, error) {
+	return s.store.Secrets(kind).GetVersion(ctx, id, version, userID)
+}
+
+// listSecrets returns the page of secrets of the kind and the cursor of the next page, the cursor is empty
+// on the last page. One more secret is asked for to tell whether the page is the last one.
+func (s *server) listSecrets(ctx context.Context, kind model.Kind, name string, q model.ListQuery, userID int) ([]model.Secret, string, error) {
+	limit := q.Limit
+	q.Limit++
+	secrets, err := s.store.Secrets(kind).List(ctx, name, q, userID)
+	if err != nil || len(secrets) <= limit {
+		return secrets, "", err
+	}
+	secrets = secrets[:limit]
+	return secrets, encodeListCursor(q, model.KeyOf(secrets[limit-1], q.Sort)), nil
+}
+
+// syncChanges collects changes of every kind after the revision, tombstones are skipped on a full sync
+func (s *server) syncChanges(ctx context.Context, since int64, userID int) (*model.SyncChanges, error) {
+	changes := model.NewSyncChanges(since)
+	// Changes in progress hold revisions below the ones already committed, they are waited for
+	// and new ones are kept from committing until all kinds are read
+	err := s.store.WithTx(ctx, func(tx store.Store) error {
+		if err := tx.LockRevisions(ctx, userID); err != nil {
+			return err
+		}
+		for _, kind := range model.Kinds() {
+			changed, err := tx.Secrets(kind).Changed(ctx, since, userID)
+			if err != nil {
+				return err
+			}
+			deleted := make([]model.Tombstone, 0)
+			if since > 0 {
+				if deleted, err = tx.Secrets(kind).Deleted(ctx, since, userID); err != nil {
+					return err
+				}
+			}
+			changes.Add(kind, changed, deleted)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if err := m.Decrypt(key, iv); err != nil {
-		return nil, err
+	return changes, nil
+}
+
+// storeSecretFile encrypts the file with the server file key and stores it under a random name,
+// so neither the content nor the original name leak from the storage. It returns the blob name.
+func (s *server) storeSecretFile(ctx context.Context, userID int, r io.Reader) (string, error) {
+	name := path.Join(strconv.Itoa(userID), uuid.New().String())
+	if err := s.putEncrypted(ctx, name, r); err != nil {
+		return "", err
 	}
-	return m, nil
+	return name, nil
 }
 
-func (s *server) getSecretFile(ctx context.Context, id, userID int, key, iv string) (*model.SecretFile, error) {
-	m, err := s.store.SecretFile().GetByID(ctx, id, userID)
+// putEncrypted streams r encrypted with the server file key to the blob store
+func (s *server) putEncrypted(ctx context.Context, name string, r io.Reader) error {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(s.encryptFile(pw, r))
+	}()
+	err := s.blobs.Put(ctx, name, pr)
+	// Unblocks the encrypting goroutine if Put stopped reading early
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	return err
+}
+
+func (s *server) encryptFile(dst io.Writer, src io.Reader) error {
+	w, err := encrypt.NewStreamWriter(dst, s.fileKey)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err := m.Decrypt(key, iv); err != nil {
-		return nil, err
+	if _, err := io.Copy(w, src); err != nil {
+		return err
 	}
-	return m, nil
+	return w.Close()
 }
 
-func (s *server) searchLoginWithPassword(ctx context.Context, name string, id int, key, iv string) ([]*model.LoginWithPassword, error) {
-	m, err := s.store.LoginWithPassword().SearchByName(ctx, name, id)
+// serveSecretFile decrypts the stored file on the fly, ranges are decrypted chunk by chunk.
+// Files stored before at-rest encryption are served as is.
+func (s *server) serveSecretFile(w http.ResponseWriter, r *http.Request, m model.BlobSecret) {
+	name := *m.BlobName()
+	blob, err := s.blobs.Get(r.Context(), name)
 	if err != nil {
-		return nil, err
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer blob.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	// Stored content never changes, so the blob name is a strong validator for If-Range
+	w.Header().Set("ETag", `"`+path.Base(name)+`"`)
+	content, err := encrypt.NewStreamReader(blob, blob.Size(), s.fileKey)
+	if errors.Is(err, encrypt.ErrNotStream) {
+		s.logger.Warnf("Blob of secret %d is not encrypted at rest", m.Data().ID)
+		http.ServeContent(w, r, "", blob.ModTime(), io.NewSectionReader(blob, 0, blob.Size()))
+		return
 	}
-	for _, i := range m {
-		if err := i.Decrypt(key, iv); err != nil {
-			return nil, err
-		}
+	if err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
 	}
-	return m, nil
+	http.ServeContent(w, r, "", blob.ModTime(), content)
 }
 
-func (s *server) searchCreditCard(ctx context.Context, name string, id int, key, iv string) ([]*model.CreditCard, error) {
-	m, err := s.store.CreditCard().SearchByName(ctx, name, id)
+// changePassword replaces the master password of the user and all of his secrets and their prior versions,
+// re-encrypted by the agent with the new key, in one transaction.
+func (s *server) changePassword(ctx context.Context, userID int, p *model.PasswordChange) (*model.User, int, error) {
+	if err := p.Validate(); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	u, err := s.store.User().FindByID(ctx, userID)
 	if err != nil {
-		return nil, err
+		return nil, http.StatusInternalServerError, err
 	}
-	for _, i := range m {
-		if err := i.Decrypt(key, iv); err != nil {
-			return nil, err
+	if !u.ComparePassword(p.OldPassword) {
+		s.logger.Errorf("Incorrect old password for user %d", userID)
+		return nil, http.StatusForbidden, store.ErrIncorrectPassword
+	}
+	u.Password = p.Password
+	u.KDFSalt = p.KDFSalt
+	err = s.store.WithTx(ctx, func(tx store.Store) error {
+		if err := tx.User().UpdatePassword(ctx, u); err != nil {
+			return err
 		}
+		return reencryptSecrets(ctx, tx, u.ID, p.Secrets, p.Trash, p.History)
+	})
+	if errors.Is(err, store.ErrVaultMismatch) || errors.Is(err, store.ErrVersionConflict) {
+		s.logger.Errorf("Password change for user %d rejected: %v", userID, err)
+		return nil, http.StatusConflict, err
 	}
-	return m, nil
+	if err != nil {
+		s.logger.Errorf("Password change for user %d failed: %v", userID, err)
+		return nil, http.StatusBadRequest, err
+	}
+	u.Sanitaze()
+	s.logger.Debugf("Password changed for user %d", userID)
+	s.events.publish(userID, &model.ChangeEvent{Op: model.EventPassword})
+	return u, http.StatusOK, nil
 }
 
-func (s *server) searchSecretText(ctx context.Context, name string, id int, key, iv string) ([]*model.SecretText, error) {
-	m, err := s.store.SecretText().SearchByName(ctx, name, id)
-	if err != nil {
-		return nil, err
+// reencryptSecrets overwrites every secret of the user, every secret in the trash and every prior version
+// with its re-encrypted copy. Secrets, trash and history must contain exactly the stored secrets and versions,
+// otherwise ErrVaultMismatch is returned.
+func reencryptSecrets(ctx context.Context, tx store.Store, userID int, secrets, trash, history *model.SecretCache) error {
+	if trash == nil {
+		trash = &model.SecretCache{}
+	}
+	if history == nil {
+		history = &model.SecretCache{}
+	}
+	// Blobs are sealed with their own random key, only the wrapped key is re-encrypted
+	for _, kind := range model.Kinds() {
+		repo := tx.Secrets(kind)
+		// Locked secrets can't be changed by requests of prior sessions until the new key is stored
+		locked, err := repo.Lock(ctx, userID)
+		if err != nil {
+			return err
+		}
+		// Versions are only added by updates of the locked secrets, resealing adds none
+		versions, err := repo.Versions(ctx, userID)
+		if err != nil {
+			return err
+		}
+		stored, trashed := splitTrashed(locked)
+		if err := reencryptKind(ctx, kind, userID, stored, secrets.Get(kind), secretKey, repo.Reseal); err != nil {
+			return err
+		}
+		if err := reencryptKind(ctx, kind, userID, trashed, trash.Get(kind), secretKey, repo.Reseal); err != nil {
+			return err
+		}
+		if err := reencryptKind(ctx, kind, userID, versions, history.Get(kind), versionKey, repo.UpdateVersion); err != nil {
+			return fmt.Errorf("history: %w", err)
+		}
 	}
-	for _, i := range m {
-		if err := i.Decrypt(key, iv); err != nil {
-			return nil, err
+	return nil
+}
+
+// splitTrashed splits secrets into stored ones and the ones in the trash
+func splitTrashed(mm []model.Secret) (stored, trashed []model.Secret) {
+	for _, m := range mm {
+		if m.Data().DeletedAt != nil {
+			trashed = append(trashed, m)
+		} else {
+			stored = append(stored, m)
 		}
 	}
-	return m, nil
+	return stored, trashed
 }
 
-func (s *server) searchSecretFile(ctx context.Context, name string, id int, key, iv string) ([]*model.SecretFile, error) {
-	m, err := s.store.SecretFile().SearchByName(ctx, name, id)
-	if err != nil {
-		return nil, err
+// reencryptKind saves submitted copies of stored secrets of the kind with update. Copies must have
+// the version of the stored secrets, blob names are taken from the stored secrets, not from the agent.
+func reencryptKind[K comparable](ctx context.Context, kind model.Kind, userID int, stored, submitted []model.Secret,
+	key func(model.Secret) K, update func(context.Context, model.Secret) error) error {
+	if !sameIDs(stored, submitted, key) {
+		return fmt.Errorf("%s: %w", kind.Table(), store.ErrVaultMismatch)
+	}
+	byKey := make(map[K]model.Secret, len(stored))
+	for _, m := range stored {
+		byKey[key(m)] = m
+	}
+	for _, m := range submitted {
+		s := byKey[key(m)]
+		if m.Data().Version != s.Data().Version {
+			return fmt.Errorf("%s %d: %w", kind.Table(), m.Data().ID, store.ErrVersionConflict)
+		}
+		if b, ok := m.(model.BlobSecret); ok {
+			*b.BlobName() = *s.(model.BlobSecret).BlobName()
+		}
+		m.Data().UserID = userID
+		if err := m.ValidateEncrypted(); err != nil {
+			return err
+		}
+		if err := update(ctx, m); err != nil {
+			return err
+		}
 	}
-	for _, i := range m {
-		if err := i.Decrypt(key, iv); err != nil {
-			return nil, err
+	return nil
+}
+
+// secretKey identifies a secret
+func secretKey(m model.Secret) int {
+	return m.Data().ID
+}
+
+// versionKey identifies the prior version of a secret
+func versionKey(m model.Secret) [2]int {
+	return [2]int{m.Data().ID, m.Data().Version}
+}
+
+// sameIDs reports whether both slices hold the same set of IDs
+func sameIDs[T any, K comparable](stored, submitted []T, id func(T) K) bool {
+	if len(stored) != len(submitted) {
+		return false
+	}
+	ids := make(map[K]bool, len(stored))
+	for _, m := range stored {
+		ids[id(m)] = true
+	}
+	for _, m := range submitted {
+		if !ids[id(m)] {
+			return false
 		}
+		delete(ids, id(m))
 	}
-	return m, nil
+	return true
 }
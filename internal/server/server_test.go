@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bytes"
+	"cenarius/internal/encrypt"
+	"cenarius/internal/model"
+	"cenarius/internal/store/sqlstore"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sameIDs(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    []int
+		submitted []int
+		want      bool
+	}{
+		{name: "Empty", want: true},
+		{name: "Same", stored: []int{1, 2, 3}, submitted: []int{3, 1, 2}, want: true},
+		{name: "Missing", stored: []int{1, 2, 3}, submitted: []int{1, 2}, want: false},
+		{name: "Foreign", stored: []int{1, 2}, submitted: []int{1, 5}, want: false},
+		{name: "Duplicate", stored: []int{1, 2}, submitted: []int{1, 1}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameIDs(tt.stored, tt.submitted, func(id int) int { return id }); got != tt.want {
+				t.Errorf("sameIDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_versionKey(t *testing.T) {
+	v := func(id, version int) model.Secret {
+		return &model.SecretText{SecretData: model.SecretData{ID: id, Version: version}}
+	}
+	stored := []model.Secret{v(1, 1), v(1, 2), v(2, 1)}
+	assert.True(t, sameIDs(stored, []model.Secret{v(2, 1), v(1, 2), v(1, 1)}, versionKey))
+	assert.False(t, sameIDs(stored, []model.Secret{v(1, 1), v(1, 1), v(2, 1)}, versionKey))
+	assert.False(t, sameIDs(stored, []model.Secret{v(1, 1), v(1, 3), v(2, 1)}, versionKey))
+}
+
+func Test_server_changePassword(t *testing.T) {
+	_, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("users", "SecretText", "SecretTombstone", "SecretHistory")
+	conf := NewConfig()
+	conf.DatabaseDsn = databaseURL
+	conf.MigrationPath = "../../migrations"
+	conf.KeyDir = t.TempDir()
+	s := NewServer(conf)
+	ctx := context.Background()
+
+	oldKey, newKey := encrypt.DeriveKey("old password", "old salt"), encrypt.DeriveKey("new password", "new salt")
+	oldAuthKey, _ := encrypt.AuthKey(oldKey)
+	newAuthKey, _ := encrypt.AuthKey(newKey)
+	u := &model.User{Login: "passwd", Password: oldAuthKey}
+	if err := s.store.User().Create(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	text := func(name, text string) *model.SecretText {
+		m := &model.SecretText{SecretData: model.SecretData{UserID: u.ID, Name: name}, Text: text}
+		if err := m.Encrypt(oldKey); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	repo := s.store.Secrets(model.SecretTextKind)
+	live := text("live", "first")
+	assert.NoError(t, repo.Add(ctx, live))
+	second := text("live", "second")
+	second.ID, second.Version = live.ID, live.Version
+	assert.NoError(t, repo.Update(ctx, second))
+	trashed := text("trashed", "deleted")
+	assert.NoError(t, repo.Add(ctx, trashed))
+	assert.NoError(t, repo.Delete(ctx, trashed.ID, u.ID))
+
+	// The agent re-encrypts everything the server returns
+	locked, err := repo.Lock(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, trash := splitTrashed(locked)
+	p := &model.PasswordChange{
+		OldPassword: oldAuthKey, Password: newAuthKey, KDFSalt: "new salt",
+		Secrets: &model.SecretCache{}, Trash: &model.SecretCache{},
+	}
+	p.Secrets.Set(model.SecretTextKind, stored)
+	p.Trash.Set(model.SecretTextKind, trash)
+	if p.History, err = s.allHistory(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*model.SecretCache{p.Secrets, p.Trash, p.History} {
+		assert.NoError(t, c.Decrypt(oldKey))
+		assert.NoError(t, c.Encrypt(newKey))
+	}
+	_, code, err := s.changePassword(ctx, u.ID, p)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	got, err := repo.GetByID(ctx, live.ID, u.ID)
+	if assert.NoError(t, err) && assert.NoError(t, got.Decrypt(newKey)) {
+		assert.Equal(t, "second", got.(*model.SecretText).Text)
+		assert.Equal(t, second.Version, got.Data().Version)
+		assert.Equal(t, second.Revision, got.Data().Revision)
+	}
+	inTrash, err := repo.Trash(ctx, u.ID)
+	if assert.NoError(t, err) && assert.Len(t, inTrash, 1) && assert.NoError(t, inTrash[0].Decrypt(newKey)) {
+		assert.Equal(t, "deleted", inTrash[0].(*model.SecretText).Text)
+	}
+	versions, err := repo.Versions(ctx, u.ID)
+	if assert.NoError(t, err) && assert.Len(t, versions, 1) && assert.NoError(t, versions[0].Decrypt(newKey)) {
+		assert.Equal(t, "first", versions[0].(*model.SecretText).Text)
+	}
+}
+
+func Test_server_upgradeVault(t *testing.T) {
+	_, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("users", "SecretText", "SecretTombstone", "SecretHistory")
+	conf := NewConfig()
+	conf.DatabaseDsn = databaseURL
+	conf.MigrationPath = "../../migrations"
+	conf.KeyDir = t.TempDir()
+	s := NewServer(conf)
+	ctx := context.Background()
+
+	u := &model.User{Login: "legacy", Password: "master password"}
+	if err := s.store.User().Create(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqlstore.NewPGConn(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "UPDATE users SET legacy_auth=true WHERE id=$1", u.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Secrets of the account were sealed by the server before vault keys
+	legacyText, err := encrypt.AESEncrypted("legacy", u.EncryptedPassword[:32], u.EncryptedPassword[:16])
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := s.store.Secrets(model.SecretTextKind)
+	legacy := &model.SecretText{SecretData: model.SecretData{UserID: u.ID, Name: "legacy"}, Text: legacyText}
+	assert.NoError(t, repo.Add(ctx, legacy))
+	key := encrypt.DeriveKey("master password", "salt")
+	authKey, _ := encrypt.AuthKey(key)
+	sealed := &model.SecretText{SecretData: model.SecretData{UserID: u.ID, Name: "sealed"}, Text: "sealed"}
+	assert.NoError(t, sealed.Encrypt(key))
+	assert.NoError(t, repo.Add(ctx, sealed))
+
+	v, code, err := s.legacyVault(ctx, u.ID)
+	if !assert.NoError(t, err) || !assert.Equal(t, http.StatusOK, code) {
+		return
+	}
+	opened := v.Secrets.Get(model.SecretTextKind)
+	if assert.Len(t, opened, 1) {
+		assert.Equal(t, "legacy", opened[0].(*model.SecretText).Text)
+	}
+	for _, c := range []*model.SecretCache{v.Secrets, v.Trash, v.History} {
+		assert.NoError(t, c.Encrypt(key))
+	}
+	v.AuthKey = authKey
+	_, code, err = s.upgradeVault(ctx, u.ID, v)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	got, err := repo.GetByID(ctx, legacy.ID, u.ID)
+	if assert.NoError(t, err) && assert.NoError(t, got.Decrypt(key)) {
+		assert.Equal(t, "legacy", got.(*model.SecretText).Text)
+		assert.Equal(t, legacy.Version, got.Data().Version)
+	}
+	upgraded, err := s.store.User().FindByID(ctx, u.ID)
+	if assert.NoError(t, err) {
+		assert.False(t, upgraded.LegacyAuth)
+		assert.True(t, upgraded.ComparePassword(authKey))
+	}
+	_, code, err = s.legacyVault(ctx, u.ID)
+	assert.ErrorIs(t, err, ErrNotLegacy)
+	assert.Equal(t, http.StatusConflict, code)
+}
+
+func Test_server_storeSecretFile(t *testing.T) {
+	conf := NewConfig()
+	conf.SecretFilePath = t.TempDir()
+	conf.KeyDir = t.TempDir()
+	s := &server{config: conf, logger: log.New()}
+	if err := s.configureFileKey(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.configureBlobStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	content := strings.Repeat("secret file content ", 10000)
+	name, err := s.storeSecretFile(context.Background(), 1, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := os.ReadFile(filepath.Join(conf.SecretFilePath, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, bytes.Contains(stored, []byte("secret file content")), "file is stored in plaintext")
+
+	m := &model.SecretFile{Path: name}
+	tests := []struct {
+		name   string
+		header string
+		code   int
+		want   string
+	}{
+		{name: "Full", code: http.StatusOK, want: content},
+		{name: "Range", header: "bytes=100000-100019", code: http.StatusPartialContent, want: content[100000:100020]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/secretfile/1", nil)
+			if tt.header != "" {
+				r.Header.Set("Range", tt.header)
+			}
+			w := httptest.NewRecorder()
+			s.serveSecretFile(w, r, m)
+			body, err := io.ReadAll(w.Result().Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.want, string(body))
+		})
+	}
+}
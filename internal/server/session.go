@@ -0,0 +1,86 @@
+package server
+
+import (
+	"cenarius/internal/model"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid session token")
+	ErrTokenExpired = errors.New("session token expired")
+	ErrTokenRevoked = errors.New("session token was issued before the password change")
+)
+
+// sessionClaims is a payload of the session token. KDFSalt is the salt of the vault key the session
+// was issued for, a password change replaces it and so revokes prior sessions.
+type sessionClaims struct {
+	UserID    int    `json:"uid"`
+	Login     string `json:"login"`
+	KDFSalt   string `json:"salt"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// newSession issues signed session token for the user
+func (s *server) newSession(u *model.User) (*model.Session, error) {
+	expiresAt := time.Now().Add(s.config.SessionTTL)
+	claims := &sessionClaims{
+		UserID:    u.ID,
+		Login:     u.Login,
+		KDFSalt:   u.KDFSalt,
+		ExpiresAt: expiresAt.Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	encPayload := base64.RawURLEncoding.EncodeToString(payload)
+	token := encPayload + "." + base64.RawURLEncoding.EncodeToString(s.signSession(encPayload))
+	return &model.Session{Token: token, ExpiresAt: expiresAt, KDFSalt: u.KDFSalt}, nil
+}
+
+// parseSession checks token signature and expiration and returns its claims
+func (s *server) parseSession(token string) (*sessionClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+	sign, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal(sign, s.signSession(parts[0])) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims := &sessionClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+// checkSession checks that the session was issued for the current vault key of the user
+func checkSession(claims *sessionClaims, u *model.User) error {
+	if claims.UserID != u.ID || claims.KDFSalt != u.KDFSalt {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+func (s *server) signSession(payload string) []byte {
+	mac := hmac.New(sha256.New, s.sessionKey)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
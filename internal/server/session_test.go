@@ -0,0 +1,82 @@
+package server
+
+import (
+	"cenarius/internal/model"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_server_parseSession(t *testing.T) {
+	s := &server{config: NewConfig(), logger: log.New(), sessionKey: []byte("session key")}
+	u := &model.User{ID: 42, Login: "sessionuser"}
+	valid, err := s.newSession(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &server{config: NewConfig(), logger: log.New(), sessionKey: []byte("another key")}
+	foreign, err := other.newSession(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredServer := &server{config: NewConfig(), logger: log.New(), sessionKey: s.sessionKey}
+	expiredServer.config.SessionTTL = -time.Minute
+	expired, err := expiredServer.newSession(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:    "Valid",
+			token:   valid.Token,
+			wantErr: nil,
+		},
+		{
+			name:    "ForeignKey",
+			token:   foreign.Token,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Expired",
+			token:   expired.Token,
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "Malformed",
+			token:   "not a token",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Tampered",
+			token:   "eyJ1aWQiOjEsImxvZ2luIjoiYWRtaW4iLCJleHAiOjQxMDI0NDQ4MDB9." + valid.Token[len(valid.Token)-43:],
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := s.parseSession(tt.token)
+			assert.Equal(t, tt.wantErr, err)
+			if tt.wantErr == nil {
+				assert.Equal(t, u.ID, claims.UserID)
+				assert.Equal(t, u.Login, claims.Login)
+			}
+		})
+	}
+}
+
+func Test_checkSession(t *testing.T) {
+	u := &model.User{ID: 42, Login: "sessionuser", KDFSalt: "old salt"}
+	claims := &sessionClaims{UserID: u.ID, Login: u.Login, KDFSalt: u.KDFSalt}
+	assert.NoError(t, checkSession(claims, u))
+	// The password change replaced the salt
+	changed := &model.User{ID: 42, Login: "sessionuser", KDFSalt: "new salt"}
+	assert.ErrorIs(t, checkSession(claims, changed), ErrTokenRevoked)
+	other := &model.User{ID: 43, Login: "sessionuser", KDFSalt: "old salt"}
+	assert.ErrorIs(t, checkSession(claims, other), ErrTokenRevoked)
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"cenarius/internal/model"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// purgeInterval is how often secrets kept in the trash longer than the retention period and expired uploads are purged
+const purgeInterval = time.Hour
+
+// trashSecrets returns secrets of the user in the trash of every kind
+func (s *server) trashSecrets(ctx context.Context, userID int) (*model.SecretCache, error) {
+	trash := &model.SecretCache{}
+	for _, kind := range model.Kinds() {
+		secrets, err := s.store.Secrets(kind).Trash(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		trash.Set(kind, secrets)
+	}
+	return trash, nil
+}
+
+// undeleteSecret takes the secret out of the trash, agents of the user add it back on sync
+func (s *server) undeleteSecret(ctx context.Context, kind model.Kind, id, userID int) (model.Secret, error) {
+	m, err := s.store.Secrets(kind).Undelete(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Debugf("%s %d restored from trash", kind.Table(), id)
+	s.publishChange(model.EventAdd, kind, m)
+	return m, nil
+}
+
+// purgeSecret removes the secret from the trash for good
+func (s *server) purgeSecret(ctx context.Context, kind model.Kind, id, userID int) error {
+	m, err := s.store.Secrets(kind).Purge(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	s.removeBlobs(ctx, []model.Secret{m})
+	return nil
+}
+
+// emptyTrash purges every secret of the user in the trash and returns how many were purged
+func (s *server) emptyTrash(ctx context.Context, userID int) (int, error) {
+	count := 0
+	for _, kind := range model.Kinds() {
+		purged, err := s.store.Secrets(kind).EmptyTrash(ctx, userID)
+		if err != nil {
+			return count, err
+		}
+		s.removeBlobs(ctx, purged)
+		count += len(purged)
+	}
+	s.logger.Debugf("Trash of user %d emptied, %d secrets purged", userID, count)
+	return count, nil
+}
+
+// purgeExpired purges secrets of all users kept in the trash longer than the retention period
+func (s *server) purgeExpired(ctx context.Context) {
+	before := time.Now().Add(-s.config.TrashRetention)
+	for _, kind := range model.Kinds() {
+		purged, err := s.store.Secrets(kind).PurgeExpired(ctx, before)
+		if err != nil {
+			s.logger.Errorf("Unable to purge expired %s: %v", kind.Table(), err)
+			continue
+		}
+		s.removeBlobs(ctx, purged)
+		if len(purged) > 0 {
+			s.logger.Infof("Purged %d %s deleted before %s", len(purged), kind.Table(), before.Format(time.RFC3339))
+		}
+	}
+}
+
+// runPurge purges expired secrets and unfinished uploads every purgeInterval until ctx is done,
+// zero retention keeps the trash and zero upload TTL keeps uploads
+func (s *server) runPurge(ctx context.Context) {
+	if s.config.TrashRetention <= 0 && s.config.UploadTTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		if s.config.TrashRetention > 0 {
+			s.purgeExpired(ctx)
+		}
+		if s.config.UploadTTL > 0 {
+			s.purgeUploads(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeUploads removes uploads of all users started longer than the upload TTL ago with their chunks
+func (s *server) purgeUploads(ctx context.Context) {
+	before := time.Now().Add(-s.config.UploadTTL)
+	expired, err := s.store.Upload().DeleteExpired(ctx, before)
+	if err != nil {
+		s.logger.Errorf("Unable to purge expired uploads: %v", err)
+		return
+	}
+	for _, u := range expired {
+		s.deleteChunks(ctx, u)
+	}
+	if len(expired) > 0 {
+		s.logger.Infof("Purged %d uploads started before %s", len(expired), before.Format(time.RFC3339))
+	}
+}
+
+// removeBlobs removes blobs of purged blob secrets, a blob which can't be removed is left orphaned
+func (s *server) removeBlobs(ctx context.Context, purged []model.Secret) {
+	for _, m := range purged {
+		b, ok := m.(model.BlobSecret)
+		if !ok {
+			continue
+		}
+		if err := s.blobs.Delete(ctx, *b.BlobName()); err != nil {
+			s.logger.Errorf("Unable to remove blob of purged secret %d: %v", m.Data().ID, err)
+		}
+	}
+}
+
+// handleTrash lists secrets of the user in the trash or empties the trash
+func (s *server) handleTrash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			trash, err := s.trashSecrets(r.Context(), user.ID)
+			if err != nil {
+				s.logger.Errorf("server.handleTrash: %v", err)
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			s.respond(w, r, http.StatusOK, trash)
+		case "DELETE":
+			count, err := s.emptyTrash(r.Context(), user.ID)
+			if err != nil {
+				s.logger.Errorf("server.handleTrash: %v", err)
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			s.respond(w, r, http.StatusOK, map[string]int{"purged": count})
+		}
+	}
+}
+
+// handleTrashedSecret restores a secret of the kind from the trash or purges it
+func (s *server) handleTrashedSecret(kind model.Kind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(ctxKeyUser).(*model.User)
+		if !ok {
+			s.error(w, r, http.StatusInternalServerError, ErrUnableToGetUserFromRequest)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, err)
+			return
+		}
+		switch r.Method {
+		case "POST":
+			m, err := s.undeleteSecret(r.Context(), kind, id, user.ID)
+			if err != nil {
+				s.error(w, r, lookupErrorCode(err), err)
+				return
+			}
+			w.Header().Set("ETag", versionETag(m.Data().Version))
+			s.respond(w, r, http.StatusOK, m)
+		case "DELETE":
+			if err := s.purgeSecret(r.Context(), kind, id, user.ID); err != nil {
+				s.error(w, r, lookupErrorCode(err), err)
+				return
+			}
+			s.respond(w, r, http.StatusOK, nil)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"cenarius/internal/encrypt"
+	"cenarius/internal/model"
+	"cenarius/internal/store"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ChunkChecksumHeader carries hex encoded sha256 of the uploaded chunk
+const ChunkChecksumHeader = "X-Cenarius-Chunk-Sha256"
+
+// uploadChunkSize is a size of chunks agents split secret files into
+const uploadChunkSize = 4 << 20
+
+var (
+	ErrChunkOutOfRange    = errors.New("chunk index is out of range")
+	ErrChunkSizeMismatch  = errors.New("chunk size mismatch")
+	ErrChecksumMismatch   = errors.New("chunk checksum mismatch")
+	ErrUploadIncomplete   = errors.New("upload is incomplete")
+	ErrUploadChunkMissing = errors.New("upload chunk is missing")
+)
+
+// lookupErrorCode maps error of a record lookup to http status code
+func lookupErrorCode(err error) int {
+	if errors.Is(err, store.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func chunkBlobName(uploadID string, index int) string {
+	return path.Join("uploads", uploadID, strconv.Itoa(index))
+}
+
+// initUpload starts chunked upload of a secret file
+func (s *server) initUpload(ctx context.Context, u *model.Upload) (*model.Upload, error) {
+	u.ID = uuid.New().String()
+	u.ChunkSize = uploadChunkSize
+	u.Received = make([]int, 0)
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+	if err := normalizeLabels(&u.Labels); err != nil {
+		return nil, err
+	}
+	if err := s.store.Upload().Create(ctx, u); err != nil {
+		s.logger.Errorf("Failed to create Upload %v: %v", u, err)
+		return nil, err
+	}
+	s.logger.Debugf("Upload created: %v", u)
+	return u, nil
+}
+
+func (s *server) getUpload(ctx context.Context, id string, userID int) (*model.Upload, error) {
+	return s.store.Upload().GetByID(ctx, id, userID)
+}
+
+// putUploadChunk verifies size and checksum of the chunk and stores it encrypted at rest.
+// Chunks are bounded by uploadChunkSize, so a chunk is checked in memory before it is stored.
+func (s *server) putUploadChunk(ctx context.Context, id string, userID, index int, checksum string, r io.Reader) (int, error) {
+	u, err := s.store.Upload().GetByID(ctx, id, userID)
+	if err != nil {
+		return lookupErrorCode(err), err
+	}
+	if index < 0 || index >= u.Chunks() {
+		return http.StatusBadRequest, ErrChunkOutOfRange
+	}
+	data, err := io.ReadAll(io.LimitReader(r, int64(u.ChunkLen(index))+1))
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(data) != u.ChunkLen(index) {
+		return http.StatusBadRequest, ErrChunkSizeMismatch
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return http.StatusBadRequest, ErrChecksumMismatch
+	}
+	if err := s.putEncrypted(ctx, chunkBlobName(id, index), bytes.NewReader(data)); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	chunk := &model.UploadChunk{UploadID: id, Index: index, Checksum: checksum, Size: len(data)}
+	if err := s.store.Upload().AddChunk(ctx, chunk); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusNoContent, nil
+}
+
+// completeUpload joins received chunks into the secret file and removes the upload
+func (s *server) completeUpload(ctx context.Context, id string, userID int) (*model.SecretFile, int, error) {
+	u, err := s.store.Upload().GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, lookupErrorCode(err), err
+	}
+	if !u.Complete() {
+		return nil, http.StatusConflict, ErrUploadIncomplete
+	}
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(s.copyChunks(ctx, pw, u))
+	}()
+	name, err := s.storeSecretFile(ctx, userID, pr)
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	if err != nil {
+		s.logger.Errorf("Failed to join Upload %v: %v", u, err)
+		return nil, http.StatusInternalServerError, err
+	}
+	m := &model.SecretFile{Path: name, Key: u.Key}
+	m.UserID = userID
+	m.Name = u.Name
+	m.Meta = u.Meta
+	m.Labels = u.Labels
+	m.ClientID = clientID(ctx)
+	err = s.store.WithTx(ctx, func(tx store.Store) error {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		if err := m.ValidateEncrypted(); err != nil {
+			return err
+		}
+		if err := tx.Secrets(model.SecretFileKind).Add(ctx, m); err != nil {
+			return err
+		}
+		return tx.Upload().Delete(ctx, id, userID)
+	})
+	if err != nil {
+		if rmErr := s.blobs.Delete(ctx, name); rmErr != nil {
+			s.logger.Errorf("Unable to remove orphan file %s: %v", name, rmErr)
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	s.deleteChunks(ctx, u)
+	s.logger.Debugf("SecretFile created from Upload %s: %v", id, m)
+	s.publishChange(model.EventAdd, model.SecretFileKind, m)
+	return m, http.StatusCreated, nil
+}
+
+// abortUpload removes the upload with received chunks
+func (s *server) abortUpload(ctx context.Context, id string, userID int) error {
+	u, err := s.store.Upload().GetByID(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Upload().Delete(ctx, id, userID); err != nil {
+		return err
+	}
+	s.deleteChunks(ctx, u)
+	return nil
+}
+
+// copyChunks writes decrypted chunks of the upload to w in order
+func (s *server) copyChunks(ctx context.Context, w io.Writer, u *model.Upload) error {
+	for _, index := range u.Received {
+		blob, err := s.blobs.Get(ctx, chunkBlobName(u.ID, index))
+		if errors.Is(err, store.ErrRecordNotFound) {
+			return ErrUploadChunkMissing
+		}
+		if err != nil {
+			return err
+		}
+		chunk, err := encrypt.NewStreamReader(blob, blob.Size(), s.fileKey)
+		if err == nil {
+			_, err = io.Copy(w, chunk)
+		}
+		blob.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *server) deleteChunks(ctx context.Context, u *model.Upload) {
+	for _, index := range u.Received {
+		if err := s.blobs.Delete(ctx, chunkBlobName(u.ID, index)); err != nil {
+			s.logger.Errorf("Unable to remove chunk %d of Upload %s: %v", index, u.ID, err)
+		}
+	}
+}
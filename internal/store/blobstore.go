@@ -0,0 +1,22 @@
+package store
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// BlobStore keeps contents of secret files, names are relative slash separated paths
+type BlobStore interface {
+	Put(ctx context.Context, name string, r io.Reader) error
+	Get(ctx context.Context, name string) (Blob, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Blob is a stored content opened for random access reads
+type Blob interface {
+	io.ReaderAt
+	io.Closer
+	Size() int64
+	ModTime() time.Time
+}
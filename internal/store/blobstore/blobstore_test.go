@@ -0,0 +1,126 @@
+package blobstore
+
+import (
+	"bytes"
+	"cenarius/internal/store"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory stand-in of S3 API subset used by S3Store
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" Credential=access/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hashHex(body) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	key := r.URL.Path
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID = strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[uploadID] = map[int][]byte{}
+		_, _ = w.Write([]byte("<InitiateMultipartUploadResult><UploadId>" + uploadID + "</UploadId></InitiateMultipartUploadResult>"))
+	case r.Method == http.MethodPut && uploadID != "":
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[uploadID][number] = body
+		w.Header().Set("ETag", `"`+strconv.Itoa(number)+`"`)
+	case r.Method == http.MethodPost && uploadID != "":
+		complete := completeMultipartUpload{}
+		_ = xml.Unmarshal(body, &complete)
+		sort.Slice(complete.Parts, func(i, j int) bool { return complete.Parts[i].PartNumber < complete.Parts[j].PartNumber })
+		var object []byte
+		for _, part := range complete.Parts {
+			object = append(object, f.uploads[uploadID][part.PartNumber]...)
+		}
+		f.objects[key] = object
+		delete(f.uploads, uploadID)
+	case r.Method == http.MethodDelete && uploadID != "":
+		delete(f.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, "", time.Unix(1, 0), bytes.NewReader(object))
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestBlobStore(t *testing.T) {
+	ts := httptest.NewServer(newFakeS3())
+	defer ts.Close()
+	s3 := NewS3Store(S3Config{Endpoint: ts.URL, Region: "us-east-1", Bucket: "cenarius", AccessKey: "access", SecretKey: "secret"})
+	s3.partSize = 1000
+
+	stores := map[string]store.BlobStore{
+		"Local": NewLocalStore(t.TempDir()),
+		"S3":    s3,
+	}
+	ctx := context.Background()
+	for name, blobs := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, size := range []int{0, 10, 1000, 2500} {
+				content := bytes.Repeat([]byte{byte(size)}, size)
+				blobName := "1/blob" + strconv.Itoa(size)
+				if err := blobs.Put(ctx, blobName, bytes.NewReader(content)); err != nil {
+					t.Fatalf("Put(%d) error = %v", size, err)
+				}
+				blob, err := blobs.Get(ctx, blobName)
+				if err != nil {
+					t.Fatalf("Get(%d) error = %v", size, err)
+				}
+				if blob.Size() != int64(size) {
+					t.Errorf("Size() = %d, want %d", blob.Size(), size)
+				}
+				got, err := io.ReadAll(io.NewSectionReader(blob, 0, blob.Size()))
+				if err != nil {
+					t.Fatalf("ReadAt(%d) error = %v", size, err)
+				}
+				if !bytes.Equal(got, content) {
+					t.Errorf("Blob %d content mismatch", size)
+				}
+				blob.Close()
+				if err := blobs.Delete(ctx, blobName); err != nil {
+					t.Fatalf("Delete(%d) error = %v", size, err)
+				}
+				if _, err := blobs.Get(ctx, blobName); !errors.Is(err, store.ErrRecordNotFound) {
+					t.Errorf("Get() after Delete error = %v, want %v", err, store.ErrRecordNotFound)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package blobstore
+
+import (
+	"cenarius/internal/store"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LocalStore keeps blobs in a directory on the local disk
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{root: root}
+}
+
+// path returns location of the blob, absolute names are kept for files stored before BlobStore
+func (s *LocalStore) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.root, filepath.FromSlash(name))
+}
+
+// Put writes the blob to a temporary file and renames it, so a failed upload never leaves a partial blob
+func (s *LocalStore) Put(ctx context.Context, name string, r io.Reader) error {
+	path := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, r); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Get(ctx context.Context, name string) (store.Blob, error) {
+	f, err := os.Open(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &localBlob{File: f, info: info}, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+type localBlob struct {
+	*os.File
+	info fs.FileInfo
+}
+
+func (b *localBlob) Size() int64 {
+	return b.info.Size()
+}
+
+func (b *localBlob) ModTime() time.Time {
+	return b.info.ModTime()
+}
@@ -0,0 +1,264 @@
+package blobstore
+
+import (
+	"bytes"
+	"cenarius/internal/store"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// s3PartSize is a size of multipart upload parts, S3 requires at least 5 MiB
+	s3PartSize = 8 << 20
+	// s3ReadAhead is a minimal size of ranged GET, sequential reads are served from the buffer
+	s3ReadAhead = 4 << 20
+)
+
+var errS3Response = errors.New("unexpected S3 response")
+
+// S3Config is a connection config of S3 compatible storage, objects are addressed path style
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Store keeps blobs in S3 compatible storage
+type S3Store struct {
+	config   S3Config
+	client   *http.Client
+	partSize int
+}
+
+func NewS3Store(config S3Config) *S3Store {
+	return &S3Store{
+		config:   config,
+		client:   &http.Client{},
+		partSize: s3PartSize,
+	}
+}
+
+// Put uploads the blob with a single PUT if it fits in one part, otherwise with multipart upload
+func (s *S3Store) Put(ctx context.Context, name string, r io.Reader) error {
+	buf := make([]byte, s.partSize)
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		_, err := s.do(ctx, http.MethodPut, name, nil, nil, buf[:n], http.StatusOK)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := s.createMultipartUpload(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := s.uploadParts(ctx, name, uploadID, buf, r); err != nil {
+		query := url.Values{"uploadId": {uploadID}}
+		if _, abortErr := s.do(ctx, http.MethodDelete, name, query, nil, nil, http.StatusNoContent); abortErr != nil {
+			return fmt.Errorf("%w, abort failed: %v", err, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+func (s *S3Store) createMultipartUpload(ctx context.Context, name string) (string, error) {
+	resp, err := s.do(ctx, http.MethodPost, name, url.Values{"uploads": {""}}, nil, nil, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	result := struct {
+		UploadID string `xml:"UploadId"`
+	}{}
+	if err := xml.Unmarshal(resp.body, &result); err != nil {
+		return "", err
+	}
+	if result.UploadID == "" {
+		return "", errS3Response
+	}
+	return result.UploadID, nil
+}
+
+// uploadParts uploads the first part from buf and the rest read from r, then completes the upload
+func (s *S3Store) uploadParts(ctx context.Context, name, uploadID string, buf []byte, r io.Reader) error {
+	complete := completeMultipartUpload{}
+	for number := 1; len(buf) > 0; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(ctx, http.MethodPut, name, query, nil, buf, http.StatusOK)
+		if err != nil {
+			return err
+		}
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: number, ETag: resp.header.Get("ETag")})
+
+		buf = buf[:cap(buf)]
+		n, err := io.ReadFull(r, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		buf = buf[:n]
+	}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPost, name, url.Values{"uploadId": {uploadID}}, nil, body, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	// Complete may fail after 200 OK, the error is reported in the body
+	if bytes.Contains(resp.body, []byte("<Error>")) {
+		return fmt.Errorf("%w: %s", errS3Response, resp.body)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, name string) (store.Blob, error) {
+	resp, err := s.do(ctx, http.MethodHead, name, nil, nil, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.ParseInt(resp.header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad Content-Length", errS3Response)
+	}
+	modTime, _ := http.ParseTime(resp.header.Get("Last-Modified"))
+	return &s3Blob{ctx: ctx, store: s, name: name, size: size, modTime: modTime}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, name string) error {
+	_, err := s.do(ctx, http.MethodDelete, name, nil, nil, nil, http.StatusNoContent, http.StatusOK)
+	if errors.Is(err, store.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+type s3Response struct {
+	header http.Header
+	body   []byte
+}
+
+// do sends signed request for the object and checks response status
+func (s *S3Store) do(ctx context.Context, method, name string, query url.Values, header http.Header, body []byte, codes ...int) (*s3Response, error) {
+	u, err := url.Parse(strings.TrimRight(s.config.Endpoint, "/") + "/" + s.config.Bucket + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	signV4(req, hashHex(body), s.config.Region, s.config.AccessKey, s.config.SecretKey, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, store.ErrRecordNotFound
+	}
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			return &s3Response{header: resp.Header, body: respBody}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s %s: %s", errS3Response, method, resp.Status, respBody)
+}
+
+// s3Blob reads the object with ranged GETs, reading ahead to keep the number of requests low
+type s3Blob struct {
+	ctx     context.Context
+	store   *S3Store
+	name    string
+	size    int64
+	modTime time.Time
+
+	mu     sync.Mutex
+	buf    []byte
+	bufOff int64
+}
+
+func (b *s3Blob) ReadAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= b.size {
+			return n, io.EOF
+		}
+		if pos < b.bufOff || pos >= b.bufOff+int64(len(b.buf)) {
+			if err := b.fetch(pos, len(p)-n); err != nil {
+				return n, err
+			}
+		}
+		n += copy(p[n:], b.buf[pos-b.bufOff:])
+	}
+	return n, nil
+}
+
+func (b *s3Blob) fetch(off int64, length int) error {
+	if length < s3ReadAhead {
+		length = s3ReadAhead
+	}
+	end := off + int64(length) - 1
+	if end >= b.size {
+		end = b.size - 1
+	}
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", off, end)}}
+	resp, err := b.store.do(b.ctx, http.MethodGet, b.name, nil, header, nil, http.StatusPartialContent)
+	if err != nil {
+		return err
+	}
+	if int64(len(resp.body)) != end-off+1 {
+		return fmt.Errorf("%w: short range", errS3Response)
+	}
+	b.buf = resp.body
+	b.bufOff = off
+	return nil
+}
+
+func (b *s3Blob) Size() int64 {
+	return b.size
+}
+
+func (b *s3Blob) ModTime() time.Time {
+	return b.modTime
+}
+
+func (b *s3Blob) Close() error {
+	b.buf = nil
+	return nil
+}
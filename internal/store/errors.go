@@ -8,4 +8,6 @@ var (
 	ErrUserAlredyExist   = errors.New("user already exist")
 	ErrRecordNotFound    = errors.New("record not found")
 	ErrUnableToGetRows   = errors.New("unable to get rows")
+	ErrVaultMismatch     = errors.New("secrets don't match the stored ones")
+	ErrVersionConflict   = errors.New("secret was changed since the version")
 )
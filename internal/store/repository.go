@@ -3,6 +3,7 @@ package store
 import (
 	"cenarius/internal/model"
 	"context"
+	"time"
 )
 
 type SecretDataDeleter interface {
@@ -13,36 +14,111 @@ type UserRepository interface {
 	FindByID(context.Context, int) (*model.User, error)
 	FindByLogin(context.Context, string) (*model.User, error)
 	Create(context.Context, *model.User) error
+	UpdatePassword(context.Context, *model.User) error
 }
 
-type LoginWithPasswordRepository interface {
+// SecretRepository stores secrets of one kind
+type SecretRepository interface {
 	SecretDataDeleter
-	SearchByName(context.Context, string, int) ([]*model.LoginWithPassword, error)
-	GetByID(context.Context, int, int) (*model.LoginWithPassword, error)
-	Add(context.Context, *model.LoginWithPassword) error
-	Update(context.Context, *model.LoginWithPassword) error
+	SearchByName(context.Context, string, int) ([]model.Secret, error)
+	// Search returns secrets of the user matching the name pattern and the label filter
+	Search(ctx context.Context, name string, filter model.LabelFilter, userID int) ([]model.Secret, error)
+	// List returns a page of secrets of the user matching the name pattern and the query
+	List(ctx context.Context, name string, q model.ListQuery, userID int) ([]model.Secret, error)
+	GetByID(context.Context, int, int) (model.Secret, error)
+	Add(context.Context, model.Secret) error
+	Update(context.Context, model.Secret) error
+	// Changed returns secrets of the user changed after the revision
+	Changed(context.Context, int64, int) ([]model.Secret, error)
+	// Deleted returns tombstones of secrets of the user deleted after the revision
+	Deleted(context.Context, int64, int) ([]model.Tombstone, error)
+	// History returns prior versions of the secret of the user, newest first, ErrRecordNotFound if there is no such secret
+	History(context.Context, int, int) ([]model.SecretVersion, error)
+	// GetVersion returns the secret of the user as it was at the prior version
+	GetVersion(ctx context.Context, id, version, userID int) (model.Secret, error)
+	// Versions returns prior versions of every secret of the user
+	Versions(context.Context, int) ([]model.Secret, error)
+	// UpdateVersion replaces the prior version of the secret of the user, so it is re-encrypted with the vault
+	UpdateVersion(context.Context, model.Secret) error
+	// Lock returns every secret of the user, the ones in the trash too, locked until the transaction ends
+	Lock(context.Context, int) ([]model.Secret, error)
+	// Reseal replaces the payload of the secret of the user with its re-encrypted copy, keeping its version and revision
+	Reseal(context.Context, model.Secret) error
+	// Trash returns secrets of the user in the trash, Delete moves secrets there
+	Trash(context.Context, int) ([]model.Secret, error)
+	// Undelete takes the secret of the user out of the trash
+	Undelete(ctx context.Context, id, userID int) (model.Secret, error)
+	// Purge removes the secret of the user from the trash for good
+	Purge(ctx context.Context, id, userID int) (model.Secret, error)
+	// EmptyTrash removes every secret of the user in the trash for good
+	EmptyTrash(context.Context, int) ([]model.Secret, error)
+	// PurgeExpired removes secrets of all users deleted before the time for good
+	PurgeExpired(context.Context, time.Time) ([]model.Secret, error)
 }
 
-type CreditCardRepository interface {
+// Repository is a SecretRepository of secrets of type P
+type Repository[P model.Secret] interface {
 	SecretDataDeleter
-	SearchByName(context.Context, string, int) ([]*model.CreditCard, error)
-	GetByID(context.Context, int, int) (*model.CreditCard, error)
-	Add(context.Context, *model.CreditCard) error
-	Update(context.Context, *model.CreditCard) error
+	SearchByName(context.Context, string, int) ([]P, error)
+	GetByID(context.Context, int, int) (P, error)
+	Add(context.Context, P) error
+	Update(context.Context, P) error
 }
 
-type SecretTextRepository interface {
-	SecretDataDeleter
-	SearchByName(context.Context, string, int) ([]*model.SecretText, error)
-	GetByID(context.Context, int, int) (*model.SecretText, error)
-	Add(context.Context, *model.SecretText) error
-	Update(context.Context, *model.SecretText) error
+type LoginWithPasswordRepository = Repository[*model.LoginWithPassword]
+type CreditCardRepository = Repository[*model.CreditCard]
+type SecretTextRepository = Repository[*model.SecretText]
+type SecretFileRepository = Repository[*model.SecretFile]
+
+// Typed returns typed view of the repository of secrets of type P
+func Typed[P model.Secret](r SecretRepository) Repository[P] {
+	return &typedRepository[P]{r}
 }
 
-type SecretFileRepository interface {
-	SecretDataDeleter
-	SearchByName(context.Context, string, int) ([]*model.SecretFile, error)
-	GetByID(context.Context, int, int) (*model.SecretFile, error)
-	Add(context.Context, *model.SecretFile) error
-	Update(context.Context, *model.SecretFile) error
+type typedRepository[P model.Secret] struct {
+	SecretRepository
+}
+
+func (r *typedRepository[P]) SearchByName(ctx context.Context, name string, userID int) ([]P, error) {
+	secrets, err := r.SecretRepository.SearchByName(ctx, name, userID)
+	if err != nil {
+		return nil, err
+	}
+	mm := make([]P, 0, len(secrets))
+	for _, m := range secrets {
+		mm = append(mm, m.(P))
+	}
+	return mm, nil
+}
+
+func (r *typedRepository[P]) GetByID(ctx context.Context, id, userID int) (P, error) {
+	m, err := r.SecretRepository.GetByID(ctx, id, userID)
+	if err != nil {
+		var zero P
+		return zero, err
+	}
+	return m.(P), nil
+}
+
+func (r *typedRepository[P]) Add(ctx context.Context, m P) error {
+	return r.SecretRepository.Add(ctx, m)
+}
+
+func (r *typedRepository[P]) Update(ctx context.Context, m P) error {
+	return r.SecretRepository.Update(ctx, m)
+}
+
+// SearchRepository searches secrets of every kind
+type SearchRepository interface {
+	// Find returns a page of secrets of the user matching the lowercase words, best first
+	Find(ctx context.Context, words []string, limit, offset, userID int) ([]model.SearchHit, error)
+}
+
+type UploadRepository interface {
+	Create(context.Context, *model.Upload) error
+	GetByID(context.Context, string, int) (*model.Upload, error)
+	AddChunk(context.Context, *model.UploadChunk) error
+	Delete(context.Context, string, int) error
+	// DeleteExpired removes uploads of all users started before the time and returns them with received chunks
+	DeleteExpired(context.Context, time.Time) ([]*model.Upload, error)
 }
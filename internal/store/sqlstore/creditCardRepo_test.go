@@ -47,6 +47,7 @@ func TestCreditCardRepository_Update(t *testing.T) {
 
 	for _, tt := range ccTests {
 		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, s.CreditCard().Add(context.Background(), tt.m))
 			if err := s.CreditCard().Update(context.Background(), tt.m); err != nil {
 				t.Errorf("CreditCardRepository.Update() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -1,99 +0,0 @@
-package sqlstore
-
-import (
-	"cenarius/internal/model"
-	"cenarius/internal/store"
-	"context"
-	"database/sql"
-)
-
-type CreditCardRepository struct {
-	store *Store
-}
-
-func (r *CreditCardRepository) Ping() error {
-	return r.store.db.Ping()
-}
-
-func (r *CreditCardRepository) Add(ctx context.Context, m *model.CreditCard) error {
-	if err := r.store.db.QueryRowContext(
-		ctx, "INSERT INTO CreditCard (user_id, name, meta, owner_name, owner_last_name, number, cvc) VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id",
-		m.UserID,
-		m.Name,
-		m.Meta,
-		m.OwnerName,
-		m.OwnerLastName,
-		m.Number,
-		m.CVC,
-	).Scan(&m.ID); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (r *CreditCardRepository) Update(ctx context.Context, m *model.CreditCard) error {
-	if _, err := r.store.db.ExecContext(
-		ctx, "UPDATE CreditCard SET user_id=$1, name=$2, meta=$3, owner_name=$4, owner_last_name=$5, number=$6, cvc=$7  WHERE id=$8",
-		m.UserID,
-		m.Name,
-		m.Meta,
-		m.OwnerName,
-		m.OwnerLastName,
-		m.Number,
-		m.CVC,
-		m.ID,
-	); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (r *CreditCardRepository) Delete(ctx context.Context, id, userID int) error {
-	if _, err := r.store.db.ExecContext(ctx, "DELETE FROM CreditCard WHERE id = $1 AND user_id = $2", id, userID); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (r *CreditCardRepository) SearchByName(ctx context.Context, name string, id int) ([]*model.CreditCard, error) {
-	mm := make([]*model.CreditCard, 0)
-	sqlString := "SELECT id, name, meta, owner_name, owner_last_name, number, cvc FROM CreditCard WHERE user_id=$1"
-	args := []any{id}
-	if name != "" {
-		sqlString += " AND name like $2"
-		args = append(args, name)
-	}
-	rows, err := r.store.db.QueryContext(
-		ctx, sqlString, args...,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	for rows.Next() {
-		m := &model.CreditCard{}
-		m.UserID = id
-		err = rows.Scan(&m.ID, &m.Name, &m.Meta, &m.OwnerName, &m.OwnerLastName, &m.Number, &m.CVC)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				return nil, store.ErrRecordNotFound
-			}
-			return nil, err
-		}
-		mm = append(mm, m)
-	}
-	if rows.Err() != nil {
-		return nil, store.ErrUnableToGetRows
-	}
-	return mm, nil
-}
-
-func (r *CreditCardRepository) GetByID(ctx context.Context, id, userID int) (*model.CreditCard, error) {
-	m := &model.CreditCard{}
-	if err := r.store.db.QueryRowContext(
-		ctx, "SELECT name, meta, owner_name, owner_last_name, number, cvc FROM CreditCard WHERE id = $1 AND user_id = $2", id, userID,
-	).Scan(&m.Name, &m.Meta, &m.OwnerName, &m.OwnerLastName, &m.Number, &m.CVC); err != nil {
-		return nil, err
-	}
-	return m, nil
-}
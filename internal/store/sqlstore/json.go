@@ -0,0 +1,30 @@
+package sqlstore
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonColumn scans a json column into the value v points to, null leaves the value untouched
+type jsonColumn struct {
+	v any
+}
+
+func (c jsonColumn) Scan(src any) error {
+	switch data := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(data, c.v)
+	case string:
+		return json.Unmarshal([]byte(data), c.v)
+	default:
+		return fmt.Errorf("unable to scan %T as json", src)
+	}
+}
+
+// jsonText returns v encoded as a json argument of a query
+func jsonText(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
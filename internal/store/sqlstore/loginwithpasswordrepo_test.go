@@ -2,6 +2,7 @@ package sqlstore_test
 
 import (
 	"cenarius/internal/model"
+	"cenarius/internal/store"
 	"cenarius/internal/store/sqlstore"
 	"context"
 	"testing"
@@ -48,6 +49,7 @@ func TestLoginWithPasswordRepository_Update(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, s.LoginWithPassword().Add(context.Background(), tt.m))
 			if err := s.LoginWithPassword().Update(context.Background(), tt.m); (err != nil) != tt.wantErr {
 				t.Errorf("LoginWithPasswordRepository.Update() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -137,3 +139,27 @@ func TestLoginWithPasswordRepository_GetByID(t *testing.T) {
 		})
 	}
 }
+
+func TestLoginWithPasswordRepository_UpdateVersion(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("LoginWithPassword")
+	ctx := context.Background()
+
+	m := &model.LoginWithPassword{SecretData: model.SecretData{UserID: 1, Name: "github"}, Login: "me", Password: "first"}
+	assert.NoError(t, s.LoginWithPassword().Add(ctx, m))
+	assert.Equal(t, 1, m.Version)
+
+	laptop := *m
+	laptop.Password = "laptop"
+	assert.NoError(t, s.LoginWithPassword().Update(ctx, &laptop))
+	assert.Equal(t, 2, laptop.Version)
+
+	stale := *m
+	stale.Password = "stale"
+	assert.ErrorIs(t, s.LoginWithPassword().Update(ctx, &stale), store.ErrVersionConflict)
+	stored, err := s.LoginWithPassword().GetByID(ctx, m.ID, m.UserID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "laptop", stored.Password)
+		assert.Equal(t, 2, stored.Version)
+	}
+}
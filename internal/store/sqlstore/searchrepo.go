@@ -0,0 +1,111 @@
+package sqlstore
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/store"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// searchDocument is the text of a secret the search matches, text search indexes are built on it
+const searchDocument = "coalesce(name, '') || ' ' || coalesce(meta, '')"
+
+// SearchRepository searches secrets of every kind by their names, meta and tags
+type SearchRepository struct {
+	store *Store
+}
+
+// searchQueries returns text search queries of the words: all of them prefix matched, to match names
+// and meta, and any of them prefix matched, to rank documents and match tags
+func searchQueries(words []string) (string, string) {
+	prefixes := make([]string, 0, len(words))
+	for _, w := range words {
+		prefixes = append(prefixes, w+":*")
+	}
+	return strings.Join(prefixes, " & "), strings.Join(prefixes, " | ")
+}
+
+// kindSearchQuery returns the query of ids and ranks of secrets of the kind of the user matching the words.
+// A secret matches if its document has every word as a prefix, if the text is similar to a word
+// of the document, so typos are forgiven, or if a tag matches a word. Exact names rank first.
+// Arguments are the user id, both text search queries and the lowercase text.
+func kindSearchQuery(kind model.Kind) string {
+	doc := fmt.Sprintf("to_tsvector('simple', %s)", searchDocument)
+	text := fmt.Sprintf("lower(%s)", searchDocument)
+	tagged := fmt.Sprintf(
+		`(SELECT count(*) FROM SecretTag WHERE SecretTag.kind = '%s' AND SecretTag.secret_id = %s.id
+		AND (to_tsvector('simple', tag) @@ to_tsquery('simple', $3::text) OR tag %% $4::text))`,
+		kind.Name(), kind.Table(),
+	)
+	return fmt.Sprintf(
+		`SELECT '%[1]s' AS kind, id, ts_rank(%[3]s, to_tsquery('simple', $3::text)) + word_similarity($4::text, %[4]s)
+			+ 0.2 * %[5]s + CASE WHEN lower(name) = $4::text THEN 1 ELSE 0 END AS rank
+		FROM %[2]s WHERE user_id = $1 AND deleted_at IS NULL
+		AND (%[3]s @@ to_tsquery('simple', $2::text) OR $4::text <%% %[4]s OR %[5]s > 0)`,
+		kind.Name(), kind.Table(), doc, text, tagged,
+	)
+}
+
+// Find returns a page of secrets of the user of every kind matching the words, best first.
+// Hits of equal rank are ordered by kind and id, so pages are stable.
+func (r *SearchRepository) Find(ctx context.Context, words []string, limit, offset, userID int) ([]model.SearchHit, error) {
+	if len(words) == 0 {
+		return []model.SearchHit{}, nil
+	}
+	queries := make([]string, 0, len(model.Kinds()))
+	for _, kind := range model.Kinds() {
+		queries = append(queries, kindSearchQuery(kind))
+	}
+	every, some := searchQueries(words)
+	query := fmt.Sprintf(
+		"SELECT kind, id, rank FROM (%s) AS hits ORDER BY rank DESC, kind, id LIMIT $5 OFFSET $6",
+		strings.Join(queries, " UNION ALL "),
+	)
+	rows, err := r.store.db.QueryContext(ctx, query, userID, every, some, strings.Join(words, " "), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hits := make([]model.SearchHit, 0)
+	ids := make(map[string][]int)
+	hitIDs := make([]int, 0)
+	for rows.Next() {
+		h := model.SearchHit{}
+		var id int
+		if err := rows.Scan(&h.Kind, &id, &h.Rank); err != nil {
+			return nil, err
+		}
+		ids[h.Kind] = append(ids[h.Kind], id)
+		hits = append(hits, h)
+		hitIDs = append(hitIDs, id)
+	}
+	if rows.Err() != nil {
+		return nil, store.ErrUnableToGetRows
+	}
+	rows.Close()
+	secrets := make(map[string]map[int]model.Secret, len(ids))
+	for name, kindIDs := range ids {
+		kind, ok := model.KindByName(name)
+		if !ok {
+			continue
+		}
+		found, err := r.store.secrets(kind).getByIDs(ctx, kindIDs, userID)
+		if err != nil {
+			return nil, err
+		}
+		secrets[name] = make(map[int]model.Secret, len(found))
+		for _, m := range found {
+			secrets[name][m.Data().ID] = m
+		}
+	}
+	// A secret deleted between both queries is dropped from the page
+	page := make([]model.SearchHit, 0, len(hits))
+	for i, h := range hits {
+		if m, ok := secrets[h.Kind][hitIDs[i]]; ok {
+			h.Secret = m
+			page = append(page, h)
+		}
+	}
+	return page, nil
+}
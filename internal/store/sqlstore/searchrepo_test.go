@@ -0,0 +1,48 @@
+package sqlstore_test
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/store/sqlstore"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchRepository_Find(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("SecretText", "LoginWithPassword", "SecretTombstone", "SecretTag", "Folder")
+	ctx := context.Background()
+
+	github := &model.LoginWithPassword{SecretData: model.SecretData{UserID: 1, Name: "github", Meta: "work account"}, Login: "login", Password: "password"}
+	recovery := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "recovery codes", Meta: "github"}, Text: "text"}
+	tagged := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "note", Labels: model.Labels{Tags: []string{"github"}}}, Text: "text"}
+	other := &model.SecretText{SecretData: model.SecretData{UserID: 2, Name: "github"}, Text: "text"}
+	deleted := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "github old"}, Text: "text"}
+	assert.NoError(t, s.Secrets(model.LoginWithPasswordKind).Add(ctx, github))
+	for _, m := range []*model.SecretText{recovery, tagged, other, deleted} {
+		assert.NoError(t, s.Secrets(model.SecretTextKind).Add(ctx, m))
+	}
+	assert.NoError(t, s.Secrets(model.SecretTextKind).Delete(ctx, deleted.ID, 1))
+
+	hits, err := s.Search().Find(ctx, []string{"git"}, 10, 0, 1)
+	if assert.NoError(t, err) && assert.Len(t, hits, 3) {
+		assert.Equal(t, github.ID, hits[0].Secret.Data().ID)
+		assert.Equal(t, model.LoginWithPasswordKind.Name(), hits[0].Kind)
+		assert.Equal(t, "password", hits[0].Secret.(*model.LoginWithPassword).Password)
+	}
+	// Typos are forgiven
+	hits, err = s.Search().Find(ctx, []string{"recovry"}, 10, 0, 1)
+	if assert.NoError(t, err) && assert.Len(t, hits, 1) {
+		assert.Equal(t, recovery.ID, hits[0].Secret.Data().ID)
+	}
+	page, err := s.Search().Find(ctx, []string{"git"}, 2, 2, 1)
+	assert.NoError(t, err)
+	assert.Len(t, page, 1)
+	// Names and meta match every word, tags match any word
+	hits, err = s.Search().Find(ctx, []string{"work", "git"}, 10, 0, 1)
+	if assert.NoError(t, err) && assert.Len(t, hits, 2) {
+		assert.Equal(t, github.ID, hits[0].Secret.Data().ID)
+		assert.Equal(t, tagged.ID, hits[1].Secret.Data().ID)
+	}
+}
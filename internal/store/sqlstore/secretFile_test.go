@@ -42,6 +42,7 @@ func TestSecretFileRepository_Update(t *testing.T) {
 
 	for _, tt := range sFTests {
 		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, s.SecretFile().Add(context.Background(), tt.m))
 			if err := s.SecretFile().Update(context.Background(), tt.m); (err != nil) != tt.wantErr {
 				t.Errorf("SecretFileRepository.Update() error = %v, wantErr %v", err, tt.wantErr)
 			}
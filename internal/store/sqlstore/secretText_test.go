@@ -2,9 +2,11 @@ package sqlstore_test
 
 import (
 	"cenarius/internal/model"
+	"cenarius/internal/store"
 	"cenarius/internal/store/sqlstore"
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -42,6 +44,7 @@ func TestSecretTextRepository_Update(t *testing.T) {
 
 	for _, tt := range stTests {
 		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, s.SecretText().Add(context.Background(), tt.m))
 			if err := s.SecretText().Update(context.Background(), tt.m); (err != nil) != tt.wantErr {
 				t.Errorf("SecretTextRepository.Update() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -62,6 +65,23 @@ func TestSecretTextRepository_Delete(t *testing.T) {
 	}
 }
 
+func TestSecretRepository_Missing(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown()
+	ctx := context.Background()
+	for _, kind := range model.Kinds() {
+		t.Run(kind.Name(), func(t *testing.T) {
+			repo := s.Secrets(kind)
+			m := kind.New()
+			m.Data().ID, m.Data().UserID = 1<<30, 1
+			assert.ErrorIs(t, repo.Update(ctx, m), store.ErrRecordNotFound)
+			m.Data().Version = 1
+			assert.ErrorIs(t, repo.Update(ctx, m), store.ErrRecordNotFound)
+			assert.ErrorIs(t, repo.Delete(ctx, m.Data().ID, 1), store.ErrRecordNotFound)
+		})
+	}
+}
+
 func TestSecretTextRepository_SearchByName(t *testing.T) {
 	s, teardown := sqlstore.TestStore(t, databaseURL)
 	defer teardown("SecretText")
@@ -102,3 +122,272 @@ func TestSecretTextRepository_GetByID(t *testing.T) {
 		})
 	}
 }
+
+func TestSecretTextRepository_Changed(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("SecretText", "SecretTombstone")
+	ctx := context.Background()
+
+	kept := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "kept"}, Text: "text"}
+	deleted := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "deleted"}, Text: "text"}
+	assert.NoError(t, s.SecretText().Add(ctx, kept))
+	assert.NoError(t, s.SecretText().Add(ctx, deleted))
+	since := deleted.Revision
+	assert.Greater(t, since, kept.Revision)
+
+	kept.Text = "changed"
+	assert.NoError(t, s.SecretText().Update(ctx, kept))
+	assert.Greater(t, kept.Revision, since)
+	assert.NoError(t, s.SecretText().Delete(ctx, deleted.ID, deleted.UserID))
+
+	changed, err := s.Secrets(model.SecretTextKind).Changed(ctx, since, 1)
+	if assert.NoError(t, err) && assert.Len(t, changed, 1) {
+		assert.Equal(t, kept.ID, changed[0].Data().ID)
+		assert.Equal(t, kept.Revision, changed[0].Data().Revision)
+	}
+	tombstones, err := s.Secrets(model.SecretTextKind).Deleted(ctx, since, 1)
+	if assert.NoError(t, err) && assert.Len(t, tombstones, 1) {
+		assert.Equal(t, deleted.ID, tombstones[0].ID)
+		assert.Greater(t, tombstones[0].Revision, kept.Revision)
+	}
+	other, err := s.Secrets(model.SecretTextKind).Changed(ctx, 0, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, other)
+}
+
+func TestStore_LockRevisions(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("SecretText", "SecretTombstone", "SecretHistory")
+	ctx := context.Background()
+
+	added := make(chan error, 1)
+	err := s.WithTx(ctx, func(tx store.Store) error {
+		if err := tx.LockRevisions(ctx, 1); err != nil {
+			return err
+		}
+		go func() {
+			added <- s.SecretText().Add(ctx, &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "late"}, Text: "text"})
+		}()
+		// The change waits for the lock, so it can't commit a revision below the ones read meanwhile
+		select {
+		case err := <-added:
+			t.Errorf("Add() committed while revisions are locked, error = %v", err)
+			added <- err
+		case <-time.After(100 * time.Millisecond):
+		}
+		changed, err := tx.Secrets(model.SecretTextKind).Changed(ctx, 0, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, changed)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, <-added)
+}
+
+func TestSecretTextRepository_History(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("SecretText", "SecretTombstone", "SecretHistory")
+	ctx := context.Background()
+	repo := s.Secrets(model.SecretTextKind)
+
+	m := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "note", ClientID: "laptop"}, Text: "first"}
+	assert.NoError(t, repo.Add(ctx, m))
+	m.Text, m.ClientID = "second", "desktop"
+	assert.NoError(t, repo.Update(ctx, m))
+	assert.NoError(t, repo.Delete(ctx, m.ID, m.UserID))
+
+	// Moving to the trash keeps the history and adds no version
+	versions, err := repo.History(ctx, m.ID, 1)
+	if assert.NoError(t, err) && assert.Len(t, versions, 1) {
+		assert.Equal(t, 1, versions[0].Version)
+		assert.Equal(t, model.EventUpdate, versions[0].Op)
+		assert.Equal(t, "laptop", versions[0].ClientID)
+	}
+	first, err := repo.GetVersion(ctx, m.ID, 1, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "first", first.(*model.SecretText).Text)
+		assert.Equal(t, "note", first.Data().Name)
+	}
+	_, err = repo.GetVersion(ctx, m.ID, 1, 2)
+	assert.ErrorIs(t, err, store.ErrRecordNotFound)
+	_, err = repo.History(ctx, m.ID, 2)
+	assert.ErrorIs(t, err, store.ErrRecordNotFound)
+	// A secret without prior versions has an empty history
+	other := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "other"}, Text: "only"}
+	assert.NoError(t, repo.Add(ctx, other))
+	versions, err = repo.History(ctx, other.ID, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+
+	all, err := repo.Versions(ctx, 1)
+	if assert.NoError(t, err) && assert.Len(t, all, 1) {
+		assert.Equal(t, m.ID, all[0].Data().ID)
+		assert.Equal(t, 1, all[0].Data().Version)
+	}
+	first.(*model.SecretText).Text = "resealed"
+	assert.NoError(t, repo.UpdateVersion(ctx, first))
+	first, err = repo.GetVersion(ctx, m.ID, 1, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "resealed", first.(*model.SecretText).Text)
+	}
+	first.Data().Version = 7
+	assert.ErrorIs(t, repo.UpdateVersion(ctx, first), store.ErrRecordNotFound)
+}
+
+func TestSecretTextRepository_Trash(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("SecretText", "SecretTombstone", "SecretHistory")
+	ctx := context.Background()
+	repo := s.Secrets(model.SecretTextKind)
+
+	m := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "note"}, Text: "text"}
+	assert.NoError(t, repo.Add(ctx, m))
+	assert.NoError(t, repo.Delete(ctx, m.ID, m.UserID))
+	_, err := repo.GetByID(ctx, m.ID, m.UserID)
+	assert.ErrorIs(t, err, store.ErrRecordNotFound)
+	assert.ErrorIs(t, repo.Delete(ctx, m.ID, m.UserID), store.ErrRecordNotFound)
+	assert.ErrorIs(t, repo.Update(ctx, m), store.ErrRecordNotFound)
+	trash, err := repo.Trash(ctx, m.UserID)
+	if assert.NoError(t, err) && assert.Len(t, trash, 1) {
+		assert.NotNil(t, trash[0].Data().DeletedAt)
+	}
+	locked, err := repo.Lock(ctx, m.UserID)
+	if assert.NoError(t, err) && assert.Len(t, locked, 1) {
+		assert.Equal(t, m.ID, locked[0].Data().ID)
+	}
+
+	restored, err := repo.Undelete(ctx, m.ID, m.UserID)
+	if assert.NoError(t, err) {
+		assert.Nil(t, restored.Data().DeletedAt)
+		assert.Greater(t, restored.Data().Revision, m.Revision)
+	}
+	_, err = repo.Undelete(ctx, m.ID, m.UserID)
+	assert.ErrorIs(t, err, store.ErrRecordNotFound)
+	_, err = repo.Purge(ctx, m.ID, m.UserID)
+	assert.ErrorIs(t, err, store.ErrRecordNotFound)
+
+	assert.NoError(t, repo.Delete(ctx, m.ID, m.UserID))
+	purged, err := repo.PurgeExpired(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, purged)
+	purged, err = repo.PurgeExpired(ctx, time.Now().Add(time.Hour))
+	if assert.NoError(t, err) && assert.Len(t, purged, 1) {
+		assert.Equal(t, m.ID, purged[0].Data().ID)
+		assert.Equal(t, 1, purged[0].Data().UserID)
+	}
+	trash, err = repo.Trash(ctx, m.UserID)
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+}
+
+func TestSecretTextRepository_Labels(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("SecretText", "SecretTombstone", "SecretHistory", "SecretTag", "Folder")
+	ctx := context.Background()
+	repo := s.Secrets(model.SecretTextKind)
+
+	db := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "db", Labels: model.Labels{
+		Folder: "work/db", Tags: []string{"aws", "prod"}, Favorite: true,
+	}}, Text: "text"}
+	note := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "note", Labels: model.Labels{
+		Folder: "work", Tags: []string{"prod"},
+	}}, Text: "text"}
+	assert.NoError(t, repo.Add(ctx, db))
+	assert.NoError(t, repo.Add(ctx, note))
+
+	found, err := repo.GetByID(ctx, db.ID, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, db.Labels, found.Data().Labels)
+	}
+	work, err := repo.Search(ctx, "", model.LabelFilter{Folder: "work", Tags: []string{"prod"}}, 1)
+	assert.NoError(t, err)
+	assert.Len(t, work, 2)
+	favorites, err := repo.Search(ctx, "", model.LabelFilter{Folder: "work/db", Favorite: true}, 1)
+	if assert.NoError(t, err) && assert.Len(t, favorites, 1) {
+		assert.Equal(t, db.ID, favorites[0].Data().ID)
+	}
+
+	note.Folder, note.Tags, note.Favorite = "", []string{"dev", "prod"}, true
+	assert.NoError(t, repo.Update(ctx, note))
+	found, err = repo.GetByID(ctx, note.ID, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "", found.Data().Folder)
+		assert.Equal(t, []string{"dev", "prod"}, found.Data().Tags)
+		assert.True(t, found.Data().Favorite)
+	}
+	aws, err := repo.Search(ctx, "", model.LabelFilter{Tags: []string{"aws"}}, 1)
+	assert.NoError(t, err)
+	assert.Len(t, aws, 1)
+}
+
+func TestSecretTextRepository_UpdateConflictFolder(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("SecretText", "SecretTombstone", "SecretHistory", "SecretTag", "Folder")
+	ctx := context.Background()
+	repo := s.Secrets(model.SecretTextKind)
+
+	m := &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: "note"}, Text: "text"}
+	assert.NoError(t, repo.Add(ctx, m))
+	stale := &model.SecretText{SecretData: model.SecretData{
+		ID: m.ID, UserID: 1, Version: m.Version + 1, Name: "note", Labels: model.Labels{Folder: "stale"},
+	}, Text: "text"}
+	assert.ErrorIs(t, repo.Update(ctx, stale), store.ErrVersionConflict)
+
+	// The rejected update leaves no folder behind
+	db, err := sqlstore.NewPGConn(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var folders int
+	assert.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM Folder WHERE user_id=1 AND path='stale'").Scan(&folders))
+	assert.Zero(t, folders)
+}
+
+func TestSecretTextRepository_List(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("SecretText", "SecretTombstone", "SecretHistory", "SecretTag", "Folder")
+	ctx := context.Background()
+	repo := s.Secrets(model.SecretTextKind)
+
+	for _, name := range []string{"c", "a", "b", "d"} {
+		assert.NoError(t, repo.Add(ctx, &model.SecretText{SecretData: model.SecretData{UserID: 1, Name: name}, Text: "text"}))
+	}
+	q := model.ListQuery{Sort: model.SortName, Limit: 3}
+	page, err := repo.List(ctx, "", q, 1)
+	if assert.NoError(t, err) && assert.Len(t, page, 3) {
+		assert.Equal(t, "a", page[0].Data().Name)
+		assert.False(t, page[0].Data().CreatedAt.IsZero())
+		key := model.KeyOf(page[2], q.Sort)
+		q.After = &key
+	}
+	page, err = repo.List(ctx, "", q, 1)
+	if assert.NoError(t, err) && assert.Len(t, page, 1) {
+		assert.Equal(t, "d", page[0].Data().Name)
+	}
+
+	b, err := repo.SearchByName(ctx, "b", 1)
+	if err != nil || len(b) != 1 {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, repo.Update(ctx, b[0]))
+	updated, err := repo.GetByID(ctx, b[0].Data().ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = model.ListQuery{Sort: model.SortUpdated, Desc: true, Limit: 1}
+	page, err = repo.List(ctx, "", q, 1)
+	if assert.NoError(t, err) && assert.Len(t, page, 1) {
+		assert.Equal(t, "b", page[0].Data().Name)
+		key := model.KeyOf(page[0], q.Sort)
+		q.After, q.Limit = &key, 0
+	}
+	older, err := repo.List(ctx, "", q, 1)
+	assert.NoError(t, err)
+	assert.Len(t, older, 3)
+	recent, err := repo.List(ctx, "", model.ListQuery{UpdatedAfter: updated.Data().UpdatedAt}, 1)
+	if assert.NoError(t, err) && assert.Len(t, recent, 1) {
+		assert.Equal(t, "b", recent[0].Data().Name)
+	}
+}
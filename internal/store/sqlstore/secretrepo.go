@@ -0,0 +1,668 @@
+package sqlstore
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/store"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SecretRepository stores secrets of one kind, queries are built from the kind table and fields
+type SecretRepository struct {
+	store *Store
+	kind  model.Kind
+}
+
+// columns returns payload columns of the kind
+func (r *SecretRepository) columns() []string {
+	fields := r.kind.New().Fields()
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		columns = append(columns, f.Column)
+	}
+	return columns
+}
+
+// selectColumns returns common, label and payload columns in the order of scanDest,
+// the folder path and tags are taken from their tables
+func (r *SecretRepository) selectColumns() string {
+	labels := fmt.Sprintf(
+		`coalesce((SELECT path FROM Folder WHERE Folder.id = %[1]s.folder_id), '') AS folder,
+		(SELECT coalesce(json_agg(tag ORDER BY tag), '[]') FROM SecretTag
+		WHERE SecretTag.kind = '%[2]s' AND SecretTag.secret_id = %[1]s.id) AS tags, favorite`,
+		r.kind.Table(), r.kind.Name(),
+	)
+	return "id, name, meta, version, revision, created_at, updated_at, client_id, deleted_at, " + labels + ", " + strings.Join(r.columns(), ", ")
+}
+
+// selectQuery returns the query of common and payload columns of secrets matching where,
+// secrets in the trash are matched only if where asks for them
+func (r *SecretRepository) selectQuery(where string) string {
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s", r.selectColumns(), r.kind.Table(), where)
+}
+
+// scanDest returns destinations for common, label and payload columns of m
+func scanDest(m model.Secret) []any {
+	d := m.Data()
+	dest := []any{
+		&d.ID, &d.Name, &d.Meta, &d.Version, &d.Revision, &d.CreatedAt, &d.UpdatedAt, &d.ClientID, &d.DeletedAt,
+		&d.Folder, jsonColumn{&d.Tags}, &d.Favorite,
+	}
+	for _, f := range m.Fields() {
+		dest = append(dest, f.Value)
+	}
+	return dest
+}
+
+// Add inserts the secret with its folder, created if it doesn't exist yet, and its tags
+func (r *SecretRepository) Add(ctx context.Context, m model.Secret) error {
+	d := m.Data()
+	tags, err := tagsText(d.Tags)
+	if err != nil {
+		return err
+	}
+	columns := append([]string{"user_id", "name", "meta", "client_id", "favorite"}, r.columns()...)
+	args := []any{d.UserID, d.Name, d.Meta, d.ClientID, d.Favorite}
+	placeholders := []string{"$1", "$2", "$3", "$4", "$5"}
+	for _, f := range m.Fields() {
+		args = append(args, *f.Value)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+	args = append(args, d.Folder, tags)
+	query := fmt.Sprintf(
+		`WITH %s, s AS (
+			INSERT INTO %s (%s, folder_id) VALUES(%s, (SELECT id FROM folder))
+			RETURNING id, user_id, version, revision, created_at, updated_at
+		), tags AS (
+			INSERT INTO SecretTag (kind, secret_id, user_id, tag)
+			SELECT '%s', s.id, s.user_id, tag FROM s, json_array_elements_text($%d::json) AS tag
+		)
+		SELECT id, version, revision, created_at, updated_at FROM s`,
+		folderCTE(1, len(args)-1, "true"), r.kind.Table(), strings.Join(columns, ", "), strings.Join(placeholders, ", "),
+		r.kind.Name(), len(args),
+	)
+	return r.store.withRevisionLock(ctx, d.UserID, func(db dbtx) error {
+		return db.QueryRowContext(ctx, query, args...).Scan(&d.ID, &d.Version, &d.Revision, &d.CreatedAt, &d.UpdatedAt)
+	})
+}
+
+// folderCTE returns a common table expression named folder returning the id of the folder of the user,
+// the folder is created if it doesn't exist and the condition holds. Nothing is returned for the empty path,
+// so folder_id is null.
+func folderCTE(userArg, pathArg int, cond string) string {
+	return fmt.Sprintf(
+		`folder AS (
+			INSERT INTO Folder (user_id, path) SELECT $%[1]d::int, $%[2]d::varchar WHERE $%[2]d::varchar <> '' AND %[3]s
+			ON CONFLICT (user_id, path) DO UPDATE SET path = EXCLUDED.path RETURNING id
+		)`,
+		userArg, pathArg, cond,
+	)
+}
+
+// retagCTE returns common table expressions replacing tags of secrets locked as old with the json array argument.
+// Kept tags are neither deleted nor inserted, so both statements touch different rows.
+func (r *SecretRepository) retagCTE(tagsArg int) string {
+	return fmt.Sprintf(
+		`untagged AS (
+			DELETE FROM SecretTag AS t USING old WHERE t.kind = '%[1]s' AND t.secret_id = old.id
+			AND t.tag NOT IN (SELECT json_array_elements_text($%[2]d::json))
+		), tagged AS (
+			INSERT INTO SecretTag (kind, secret_id, user_id, tag)
+			SELECT '%[1]s', old.id, old.user_id, tag FROM old, json_array_elements_text($%[2]d::json) AS tag
+			ON CONFLICT DO NOTHING
+		)`,
+		r.kind.Name(), tagsArg,
+	)
+}
+
+// tagsText returns tags as a json array argument, no tags are an empty array
+func tagsText(tags []string) (string, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	return jsonText(tags)
+}
+
+// historyCTE returns common table expressions which lock secrets matching where as old and copy them
+// to the history, so the statement using them replaces exactly the saved versions. The payload is kept
+// as stored, encrypted fields stay sealed.
+func (r *SecretRepository) historyCTE(op, where string) string {
+	data := []string{"'name', name", "'meta', meta"}
+	for _, c := range r.columns() {
+		data = append(data, fmt.Sprintf("'%s', %s::text", c, c))
+	}
+	return fmt.Sprintf(
+		`old AS (
+			SELECT id, user_id, version, client_id, updated_at, json_build_object(%s) AS data
+			FROM %s WHERE %s FOR UPDATE
+		), history AS (
+			INSERT INTO SecretHistory (kind, secret_id, user_id, version, client_id, updated_at, op, data)
+			SELECT '%s', id, user_id, version, client_id, updated_at, '%s', data FROM old
+		)`,
+		strings.Join(data, ", "), r.kind.Table(), where, r.kind.Name(), op,
+	)
+}
+
+// Update replaces name, meta, labels and the payload, the blob name of blob secrets is never changed.
+// Every update takes the next revision and version, the replaced version is kept in the history.
+// If the version of m is set, the secret is updated only if it has not changed since,
+// otherwise ErrVersionConflict is returned. Secrets in the trash are not updated,
+// ErrRecordNotFound is returned for them and for missing secrets.
+func (r *SecretRepository) Update(ctx context.Context, m model.Secret) error {
+	var blobName *string
+	if b, ok := m.(model.BlobSecret); ok {
+		blobName = b.BlobName()
+	}
+	d := m.Data()
+	tags, err := tagsText(d.Tags)
+	if err != nil {
+		return err
+	}
+	sets := []string{
+		"name=$1", "meta=$2", "client_id=$3", "favorite=$4", "folder_id=(SELECT id FROM folder)",
+		"version=s.version+1", "revision=nextval('secret_revision_seq')", "updated_at=NOW()",
+	}
+	args := []any{d.Name, d.Meta, d.ClientID, d.Favorite}
+	for _, f := range m.Fields() {
+		if f.Value == blobName {
+			continue
+		}
+		args = append(args, *f.Value)
+		sets = append(sets, fmt.Sprintf("%s=$%d", f.Column, len(args)))
+	}
+	userArg := len(args) + 2
+	where := fmt.Sprintf("id=$%d AND user_id=$%d AND deleted_at IS NULL", len(args)+1, userArg)
+	args = append(args, d.ID, d.UserID)
+	if d.Version > 0 {
+		args = append(args, d.Version)
+		where += fmt.Sprintf(" AND version=$%d", len(args))
+	}
+	args = append(args, d.Folder, tags)
+	// A rejected update finds no old row, so it creates no folder either
+	query := fmt.Sprintf(
+		"WITH %s, %s, %s UPDATE %s AS s SET %s FROM old WHERE s.id = old.id RETURNING s.version, s.revision, s.updated_at",
+		r.historyCTE(model.EventUpdate, where), folderCTE(userArg, len(args)-1, "EXISTS (SELECT 1 FROM old)"), r.retagCTE(len(args)),
+		r.kind.Table(), strings.Join(sets, ", "),
+	)
+	err = r.store.withRevisionLock(ctx, d.UserID, func(db dbtx) error {
+		return db.QueryRowContext(ctx, query, args...).Scan(&d.Version, &d.Revision, &d.UpdatedAt)
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if d.Version > 0 {
+		if _, err := r.GetByID(ctx, d.ID, d.UserID); err == nil {
+			return store.ErrVersionConflict
+		}
+	}
+	return store.ErrRecordNotFound
+}
+
+// Delete moves the secret to the trash and leaves its tombstone with the next revision, so agents
+// drop it from their caches. The secret keeps its history and blob until it is purged.
+// ErrRecordNotFound is returned if the secret is missing or already in the trash.
+func (r *SecretRepository) Delete(ctx context.Context, id, userID int) error {
+	query := fmt.Sprintf(
+		`WITH trashed AS (
+			UPDATE %s SET deleted_at=NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL RETURNING id, user_id
+		)
+		INSERT INTO SecretTombstone (kind, secret_id, user_id) SELECT $3::varchar, id, user_id FROM trashed
+		ON CONFLICT (kind, secret_id) DO UPDATE SET revision=nextval('secret_revision_seq'), deleted_at=NOW()`,
+		r.kind.Table(),
+	)
+	return r.store.withRevisionLock(ctx, userID, func(db dbtx) error {
+		res, err := db.ExecContext(ctx, query, id, userID, r.kind.Name())
+		if err != nil {
+			return err
+		}
+		// Every trashed secret leaves one tombstone
+		trashed, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if trashed == 0 {
+			return store.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// Trash returns secrets of the user in the trash, recently deleted first
+func (r *SecretRepository) Trash(ctx context.Context, userID int) ([]model.Secret, error) {
+	return r.query(ctx, userID, r.selectQuery("user_id=$1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC"), userID)
+}
+
+// Lock returns every secret of the user, the ones in the trash too, locked FOR UPDATE,
+// so they can't be changed by other transactions until the transaction of the store ends.
+// The revision lock is taken first like by every change, so changes in progress can't deadlock with it.
+func (r *SecretRepository) Lock(ctx context.Context, userID int) ([]model.Secret, error) {
+	var mm []model.Secret
+	err := r.store.withRevisionLock(ctx, userID, func(dbtx) error {
+		var err error
+		mm, err = r.query(ctx, userID, r.selectQuery("user_id=$1 ORDER BY id FOR UPDATE"), userID)
+		return err
+	})
+	return mm, err
+}
+
+// Reseal replaces the payload of the secret of the user, in the trash or not, with its re-encrypted copy.
+// Content of the secret doesn't change, so the version and the revision are kept and no history is written.
+// The caller keeps the blob name of blob secrets. ErrRecordNotFound is returned unless the secret has the version of m.
+func (r *SecretRepository) Reseal(ctx context.Context, m model.Secret) error {
+	d := m.Data()
+	sets := make([]string, 0)
+	args := make([]any, 0)
+	for _, f := range m.Fields() {
+		args = append(args, *f.Value)
+		sets = append(sets, fmt.Sprintf("%s=$%d", f.Column, len(args)))
+	}
+	args = append(args, d.ID, d.UserID, d.Version)
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE id=$%d AND user_id=$%d AND version=$%d",
+		r.kind.Table(), strings.Join(sets, ", "), len(args)-2, len(args)-1, len(args),
+	)
+	res, err := r.store.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Undelete takes the secret out of the trash with the next revision, so agents add it back on sync.
+// ErrRecordNotFound is returned if the secret is not in the trash.
+func (r *SecretRepository) Undelete(ctx context.Context, id, userID int) (model.Secret, error) {
+	query := fmt.Sprintf(
+		`WITH restored AS (
+			UPDATE %s SET deleted_at=NULL, revision=nextval('secret_revision_seq')
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL RETURNING %s
+		), tombstone AS (
+			DELETE FROM SecretTombstone WHERE kind = $3 AND secret_id IN (SELECT id FROM restored)
+		)
+		SELECT * FROM restored`,
+		r.kind.Table(), r.selectColumns(),
+	)
+	m := r.kind.New()
+	err := r.store.withRevisionLock(ctx, userID, func(db dbtx) error {
+		return db.QueryRowContext(ctx, query, id, userID, r.kind.Name()).Scan(scanDest(m)...)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Data().UserID = userID
+	return m, nil
+}
+
+// Purge removes the secret from the trash with its history for good,
+// ErrRecordNotFound is returned if the secret is not in the trash
+func (r *SecretRepository) Purge(ctx context.Context, id, userID int) (model.Secret, error) {
+	purged, err := r.purge(ctx, "id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(purged) == 0 {
+		return nil, store.ErrRecordNotFound
+	}
+	return purged[0], nil
+}
+
+// EmptyTrash purges every secret of the user in the trash
+func (r *SecretRepository) EmptyTrash(ctx context.Context, userID int) ([]model.Secret, error) {
+	return r.purge(ctx, "user_id = $1", userID)
+}
+
+// PurgeExpired purges secrets of all users deleted before the time
+func (r *SecretRepository) PurgeExpired(ctx context.Context, before time.Time) ([]model.Secret, error) {
+	return r.purge(ctx, "deleted_at < $1", before)
+}
+
+// purge removes secrets in the trash matching where with their history and tags and returns them,
+// so blobs of blob secrets can be removed too. Tombstones are kept for sync.
+func (r *SecretRepository) purge(ctx context.Context, where string, args ...any) ([]model.Secret, error) {
+	query := fmt.Sprintf(
+		`WITH purged AS (
+			DELETE FROM %s WHERE deleted_at IS NOT NULL AND %s RETURNING user_id, %s
+		), history AS (
+			DELETE FROM SecretHistory AS h USING purged WHERE h.kind = '%[4]s' AND h.secret_id = purged.id
+		), tags AS (
+			DELETE FROM SecretTag AS t USING purged WHERE t.kind = '%[4]s' AND t.secret_id = purged.id
+		)
+		SELECT * FROM purged`,
+		r.kind.Table(), where, r.selectColumns(), r.kind.Name(),
+	)
+	mm := make([]model.Secret, 0)
+	rows, err := r.store.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		m := r.kind.New()
+		if err := rows.Scan(append([]any{&m.Data().UserID}, scanDest(m)...)...); err != nil {
+			return nil, err
+		}
+		mm = append(mm, m)
+	}
+	if rows.Err() != nil {
+		return nil, store.ErrUnableToGetRows
+	}
+	return mm, nil
+}
+
+// History returns prior versions of the secret, newest first. History of secrets in the trash is kept.
+// ErrRecordNotFound is returned if the user has no such secret, in the trash or not.
+func (r *SecretRepository) History(ctx context.Context, id, userID int) ([]model.SecretVersion, error) {
+	vv := make([]model.SecretVersion, 0)
+	rows, err := r.store.db.QueryContext(
+		ctx,
+		`SELECT version, coalesce(data->>'name', ''), client_id, updated_at, replaced_at, op FROM SecretHistory
+		WHERE kind=$1 AND secret_id=$2 AND user_id=$3 ORDER BY version DESC`,
+		r.kind.Name(), id, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		v := model.SecretVersion{}
+		if err := rows.Scan(&v.Version, &v.Name, &v.ClientID, &v.UpdatedAt, &v.ReplacedAt, &v.Op); err != nil {
+			return nil, err
+		}
+		vv = append(vv, v)
+	}
+	if rows.Err() != nil {
+		return nil, store.ErrUnableToGetRows
+	}
+	if len(vv) == 0 {
+		var exists bool
+		err := r.store.db.QueryRowContext(
+			ctx, fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id=$1 AND user_id=$2)", r.kind.Table()), id, userID,
+		).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, store.ErrRecordNotFound
+		}
+	}
+	return vv, nil
+}
+
+// GetVersion returns the secret as it was at the prior version, sealed like it was stored
+func (r *SecretRepository) GetVersion(ctx context.Context, id, version, userID int) (model.Secret, error) {
+	var clientID string
+	var updatedAt time.Time
+	var data []byte
+	err := r.store.db.QueryRowContext(
+		ctx,
+		"SELECT client_id, updated_at, data FROM SecretHistory WHERE kind=$1 AND secret_id=$2 AND user_id=$3 AND version=$4",
+		r.kind.Name(), id, userID, version,
+	).Scan(&clientID, &updatedAt, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.versionSecret(id, version, userID, clientID, updatedAt, data)
+}
+
+// Versions returns prior versions of every secret of the kind of the user, ordered by id and version
+func (r *SecretRepository) Versions(ctx context.Context, userID int) ([]model.Secret, error) {
+	mm := make([]model.Secret, 0)
+	rows, err := r.store.db.QueryContext(
+		ctx,
+		`SELECT secret_id, version, client_id, updated_at, data FROM SecretHistory
+		WHERE kind=$1 AND user_id=$2 ORDER BY secret_id, version`,
+		r.kind.Name(), userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, version int
+		var clientID string
+		var updatedAt time.Time
+		var data []byte
+		if err := rows.Scan(&id, &version, &clientID, &updatedAt, &data); err != nil {
+			return nil, err
+		}
+		m, err := r.versionSecret(id, version, userID, clientID, updatedAt, data)
+		if err != nil {
+			return nil, err
+		}
+		mm = append(mm, m)
+	}
+	if rows.Err() != nil {
+		return nil, store.ErrUnableToGetRows
+	}
+	return mm, nil
+}
+
+// versionSecret returns the secret kept in the history as data
+func (r *SecretRepository) versionSecret(id, version, userID int, clientID string, updatedAt time.Time, data []byte) (model.Secret, error) {
+	values := make(map[string]string)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	m := r.kind.New()
+	d := m.Data()
+	d.ID, d.UserID, d.Version = id, userID, version
+	d.ClientID, d.UpdatedAt = clientID, updatedAt
+	d.Name, d.Meta = values["name"], values["meta"]
+	for _, f := range m.Fields() {
+		*f.Value = values[f.Column]
+	}
+	return m, nil
+}
+
+// UpdateVersion replaces name, meta and the payload of the prior version of the secret of the user,
+// so it is re-encrypted with the vault. The caller keeps the blob name of blob secrets.
+// ErrRecordNotFound is returned if the version is not in the history.
+func (r *SecretRepository) UpdateVersion(ctx context.Context, m model.Secret) error {
+	d := m.Data()
+	values := map[string]string{"name": d.Name, "meta": d.Meta}
+	for _, f := range m.Fields() {
+		values[f.Column] = *f.Value
+	}
+	data, err := jsonText(values)
+	if err != nil {
+		return err
+	}
+	res, err := r.store.db.ExecContext(
+		ctx,
+		"UPDATE SecretHistory SET data = data || $1::jsonb WHERE kind=$2 AND secret_id=$3 AND user_id=$4 AND version=$5",
+		data, r.kind.Name(), d.ID, d.UserID, d.Version,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *SecretRepository) SearchByName(ctx context.Context, name string, userID int) ([]model.Secret, error) {
+	return r.Search(ctx, name, model.LabelFilter{}, userID)
+}
+
+// Search returns secrets of the user which names match the like pattern and labels match the filter
+func (r *SecretRepository) Search(ctx context.Context, name string, filter model.LabelFilter, userID int) ([]model.Secret, error) {
+	return r.List(ctx, name, model.ListQuery{LabelFilter: filter}, userID)
+}
+
+// sortExpressions are expressions secrets are ordered by, the empty order is the order of ids
+var sortExpressions = map[string]string{
+	model.SortName:    "coalesce(name, '')",
+	model.SortCreated: "created_at",
+	model.SortUpdated: "updated_at",
+}
+
+// List returns secrets of the user which names match the like pattern and which match the query,
+// the empty name matches any name. The folder filter matches subfolders too. Time ranges include
+// their start and exclude their end. Secrets are ordered by the sort expression and id,
+// so the page following a secret is found by comparing both of them.
+func (r *SecretRepository) List(ctx context.Context, name string, q model.ListQuery, userID int) ([]model.Secret, error) {
+	where := "user_id=$1 AND deleted_at IS NULL"
+	args := []any{userID}
+	if name != "" {
+		args = append(args, name)
+		where += fmt.Sprintf(" AND name like $%d", len(args))
+	}
+	if q.Folder != "" {
+		args = append(args, q.Folder)
+		where += fmt.Sprintf(
+			" AND folder_id IN (SELECT id FROM Folder WHERE user_id=$1 AND (path=$%[1]d OR left(path, length($%[1]d)+1) = $%[1]d || '%[2]s'))",
+			len(args), model.FolderSeparator,
+		)
+	}
+	for _, tag := range q.Tags {
+		args = append(args, tag)
+		where += fmt.Sprintf(
+			" AND EXISTS (SELECT 1 FROM SecretTag WHERE kind='%s' AND secret_id=%s.id AND tag=$%d)",
+			r.kind.Name(), r.kind.Table(), len(args),
+		)
+	}
+	if q.Favorite {
+		where += " AND favorite"
+	}
+	ranges := []struct {
+		column, op string
+		t          time.Time
+	}{
+		{"created_at", ">=", q.CreatedAfter},
+		{"created_at", "<", q.CreatedBefore},
+		{"updated_at", ">=", q.UpdatedAfter},
+		{"updated_at", "<", q.UpdatedBefore},
+	}
+	for _, rg := range ranges {
+		if !rg.t.IsZero() {
+			// Timestamps are stored without time zone in UTC
+			args = append(args, rg.t.UTC())
+			where += fmt.Sprintf(" AND %s %s $%d::timestamp", rg.column, rg.op, len(args))
+		}
+	}
+	expr := sortExpressions[q.Sort]
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.After != nil {
+		switch q.Sort {
+		case model.SortName:
+			args = append(args, q.After.Name, q.After.ID)
+			where += fmt.Sprintf(" AND (%s, id) %s ($%d::varchar, $%d::bigint)", expr, cmp, len(args)-1, len(args))
+		case model.SortCreated, model.SortUpdated:
+			args = append(args, q.After.At.UTC(), q.After.ID)
+			where += fmt.Sprintf(" AND (%s, id) %s ($%d::timestamp, $%d::bigint)", expr, cmp, len(args)-1, len(args))
+		default:
+			args = append(args, q.After.ID)
+			where += fmt.Sprintf(" AND id %s $%d", cmp, len(args))
+		}
+	}
+	if expr != "" {
+		where += fmt.Sprintf(" ORDER BY %s %s, id %s", expr, dir, dir)
+	} else {
+		where += " ORDER BY id " + dir
+	}
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		where += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	return r.query(ctx, userID, r.selectQuery(where), args...)
+}
+
+// Changed returns secrets of the user with revisions after since. Revisions of the user are committed in order,
+// see withRevisionLock, so none below the latest one can show up later.
+func (r *SecretRepository) Changed(ctx context.Context, since int64, userID int) ([]model.Secret, error) {
+	return r.query(ctx, userID, r.selectQuery("user_id=$1 AND revision > $2 AND deleted_at IS NULL ORDER BY revision"), userID, since)
+}
+
+func (r *SecretRepository) Deleted(ctx context.Context, since int64, userID int) ([]model.Tombstone, error) {
+	tt := make([]model.Tombstone, 0)
+	rows, err := r.store.db.QueryContext(
+		ctx,
+		"SELECT secret_id, revision, deleted_at FROM SecretTombstone WHERE user_id=$1 AND kind=$2 AND revision > $3 ORDER BY revision",
+		userID, r.kind.Name(), since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t := model.Tombstone{}
+		if err := rows.Scan(&t.ID, &t.Revision, &t.DeletedAt); err != nil {
+			return nil, err
+		}
+		tt = append(tt, t)
+	}
+	if rows.Err() != nil {
+		return nil, store.ErrUnableToGetRows
+	}
+	return tt, nil
+}
+
+// query returns secrets of the user selected by the query
+func (r *SecretRepository) query(ctx context.Context, userID int, query string, args ...any) ([]model.Secret, error) {
+	mm := make([]model.Secret, 0)
+	rows, err := r.store.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		m := r.kind.New()
+		m.Data().UserID = userID
+		if err := rows.Scan(scanDest(m)...); err != nil {
+			return nil, err
+		}
+		mm = append(mm, m)
+	}
+	if rows.Err() != nil {
+		return nil, store.ErrUnableToGetRows
+	}
+	return mm, nil
+}
+
+// getByIDs returns secrets of the user with the ids, missing and deleted secrets are skipped
+func (r *SecretRepository) getByIDs(ctx context.Context, ids []int, userID int) ([]model.Secret, error) {
+	list, err := jsonText(ids)
+	if err != nil {
+		return nil, err
+	}
+	where := "user_id=$1 AND deleted_at IS NULL AND id IN (SELECT json_array_elements_text($2::json)::bigint)"
+	return r.query(ctx, userID, r.selectQuery(where), userID, list)
+}
+
+func (r *SecretRepository) GetByID(ctx context.Context, id, userID int) (model.Secret, error) {
+	m := r.kind.New()
+	query := r.selectQuery("id = $1 AND user_id = $2 AND deleted_at IS NULL")
+	if err := r.store.db.QueryRowContext(ctx, query, id, userID).Scan(scanDest(m)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	m.Data().UserID = userID
+	return m, nil
+}
@@ -1,65 +1,139 @@
 package sqlstore
 
 import (
+	"cenarius/internal/model"
 	"cenarius/internal/store"
+	"context"
 	"database/sql"
 
 	_ "github.com/jackc/pgx"
+	log "github.com/sirupsen/logrus"
 )
 
+// dbtx is implemented by both *sql.DB and *sql.Tx so repositories work inside transactions
+type dbtx interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}
+
+// revisionLockClass is the first key of advisory locks of revisions, the second one is the user ID
+const revisionLockClass = 10006
+
 type Store struct {
-	db                          *sql.DB
-	LoginWithPasswordRepository *LoginWithPasswordRepository
-	CreditCardRepository        *CreditCardRepository
-	SecretTextRepository        *SecretTextRepository
-	SecretFileRepository        *SecretFileRepository
-	UserRepository              *UserRepository
+	conn               *sql.DB
+	db                 dbtx
+	SecretRepositories map[string]*SecretRepository
+	UploadRepository   *UploadRepository
+	SearchRepository   *SearchRepository
+	UserRepository     *UserRepository
 }
 
 func NewStore(db *sql.DB) *Store {
 	return &Store{
-		db: db,
+		conn: db,
+		db:   db,
 	}
 }
 
 func (s *Store) Close() {
-	s.db.Close()
+	s.conn.Close()
 }
 
-func (s *Store) LoginWithPassword() store.LoginWithPasswordRepository {
-	if s.LoginWithPasswordRepository == nil {
-		s.LoginWithPasswordRepository = &LoginWithPasswordRepository{
-			store: s,
+// WithTx runs fn with the store bound to a single transaction.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+func (s *Store) WithTx(ctx context.Context, fn func(store.Store) error) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	txStore := &Store{
+		conn: s.conn,
+		db:   tx,
+	}
+	if err := fn(txStore); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Errorf("sqlstore.WithTx rollback failed: %v", rbErr)
 		}
+		return err
 	}
-	return s.LoginWithPasswordRepository
+	return tx.Commit()
 }
 
-func (s *Store) CreditCard() store.CreditCardRepository {
-	if s.CreditCardRepository == nil {
-		s.CreditCardRepository = &CreditCardRepository{
+// withRevisionLock runs fn in a transaction holding the revision lock of the user, the transaction of the store
+// is used if it is bound to one. Revisions are taken from a sequence shared by concurrent transactions,
+// the lock makes them commit in the order they are taken, so sync never skips a revision committed late.
+func (s *Store) withRevisionLock(ctx context.Context, userID int, fn func(dbtx) error) error {
+	if _, ok := s.db.(*sql.Tx); !ok {
+		return s.WithTx(ctx, func(tx store.Store) error {
+			return tx.(*Store).withRevisionLock(ctx, userID, fn)
+		})
+	}
+	if _, err := s.db.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1::int, $2::int)", revisionLockClass, userID); err != nil {
+		return err
+	}
+	return fn(s.db)
+}
+
+// LockRevisions waits for changes of the user in progress and keeps new ones from committing until
+// the transaction of the store ends, so the transaction reads revisions of the user as a whole
+func (s *Store) LockRevisions(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx, "SELECT pg_advisory_xact_lock_shared($1::int, $2::int)", revisionLockClass, userID)
+	return err
+}
+
+// Secrets returns the repository of secrets of the kind
+func (s *Store) Secrets(kind model.Kind) store.SecretRepository {
+	return s.secrets(kind)
+}
+
+func (s *Store) secrets(kind model.Kind) *SecretRepository {
+	if s.SecretRepositories == nil {
+		s.SecretRepositories = make(map[string]*SecretRepository)
+	}
+	r, ok := s.SecretRepositories[kind.Name()]
+	if !ok {
+		r = &SecretRepository{
 			store: s,
+			kind:  kind,
 		}
+		s.SecretRepositories[kind.Name()] = r
 	}
-	return s.CreditCardRepository
+	return r
+}
+
+func (s *Store) LoginWithPassword() store.LoginWithPasswordRepository {
+	return store.Typed[*model.LoginWithPassword](s.Secrets(model.LoginWithPasswordKind))
+}
+
+func (s *Store) CreditCard() store.CreditCardRepository {
+	return store.Typed[*model.CreditCard](s.Secrets(model.CreditCardKind))
 }
 
 func (s *Store) SecretText() store.SecretTextRepository {
-	if s.SecretTextRepository == nil {
-		s.SecretTextRepository = &SecretTextRepository{
+	return store.Typed[*model.SecretText](s.Secrets(model.SecretTextKind))
+}
+
+func (s *Store) SecretFile() store.SecretFileRepository {
+	return store.Typed[*model.SecretFile](s.Secrets(model.SecretFileKind))
+}
+
+func (s *Store) Upload() store.UploadRepository {
+	if s.UploadRepository == nil {
+		s.UploadRepository = &UploadRepository{
 			store: s,
 		}
 	}
-	return s.SecretTextRepository
+	return s.UploadRepository
 }
 
-func (s *Store) SecretFile() store.SecretFileRepository {
-	if s.SecretFileRepository == nil {
-		s.SecretFileRepository = &SecretFileRepository{
+func (s *Store) Search() store.SearchRepository {
+	if s.SearchRepository == nil {
+		s.SearchRepository = &SearchRepository{
 			store: s,
 		}
 	}
-	return s.SecretFileRepository
+	return s.SearchRepository
 }
 
 func (s *Store) User() store.UserRepository {
@@ -21,6 +21,6 @@ func TestStore(t *testing.T, databaseURL string) (*Store, func(...string)) {
 				t.Fatal(err)
 			}
 		}
-		store.db.Close()
+		store.conn.Close()
 	}
 }
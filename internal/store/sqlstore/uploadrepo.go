@@ -0,0 +1,123 @@
+package sqlstore
+
+import (
+	"cenarius/internal/model"
+	"cenarius/internal/store"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type UploadRepository struct {
+	store *Store
+}
+
+func (r *UploadRepository) Create(ctx context.Context, m *model.Upload) error {
+	labels, err := jsonText(m.Labels)
+	if err != nil {
+		return err
+	}
+	if _, err := r.store.db.ExecContext(
+		ctx, "INSERT INTO Upload (id, user_id, name, meta, file_key, size, chunk_size, labels) VALUES($1, $2, $3, $4, $5, $6, $7, $8)",
+		m.ID,
+		m.UserID,
+		m.Name,
+		m.Meta,
+		m.Key,
+		m.Size,
+		m.ChunkSize,
+		labels,
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetByID returns the upload with indexes of received chunks
+func (r *UploadRepository) GetByID(ctx context.Context, id string, userID int) (*model.Upload, error) {
+	m := &model.Upload{}
+	if err := r.store.db.QueryRowContext(
+		ctx, "SELECT name, meta, file_key, size, chunk_size, labels FROM Upload WHERE id = $1 AND user_id = $2", id, userID,
+	).Scan(&m.Name, &m.Meta, &m.Key, &m.Size, &m.ChunkSize, jsonColumn{&m.Labels}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	m.ID = id
+	m.UserID = userID
+	m.Received = make([]int, 0)
+	rows, err := r.store.db.QueryContext(
+		ctx, "SELECT chunk_index FROM UploadChunk WHERE upload_id = $1 ORDER BY chunk_index", id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var index int
+		if err := rows.Scan(&index); err != nil {
+			return nil, err
+		}
+		m.Received = append(m.Received, index)
+	}
+	if rows.Err() != nil {
+		return nil, store.ErrUnableToGetRows
+	}
+	return m, nil
+}
+
+// AddChunk records received chunk, a chunk sent again replaces the previous one
+func (r *UploadRepository) AddChunk(ctx context.Context, m *model.UploadChunk) error {
+	if _, err := r.store.db.ExecContext(
+		ctx, `INSERT INTO UploadChunk (upload_id, chunk_index, checksum, size) VALUES($1, $2, $3, $4)
+		ON CONFLICT (upload_id, chunk_index) DO UPDATE SET checksum = EXCLUDED.checksum, size = EXCLUDED.size`,
+		m.UploadID,
+		m.Index,
+		m.Checksum,
+		m.Size,
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *UploadRepository) Delete(ctx context.Context, id string, userID int) error {
+	if _, err := r.store.db.ExecContext(ctx, "DELETE FROM Upload WHERE id = $1 AND user_id = $2", id, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired removes uploads started before the time with their chunks and returns them,
+// so blobs of received chunks can be removed too
+func (r *UploadRepository) DeleteExpired(ctx context.Context, before time.Time) ([]*model.Upload, error) {
+	// Chunks deleted by the cascade are still visible to the statement
+	rows, err := r.store.db.QueryContext(
+		ctx, `WITH expired AS (
+			DELETE FROM Upload WHERE created_at < $1::timestamp RETURNING id, user_id
+		)
+		SELECT expired.id, expired.user_id,
+		coalesce(json_agg(c.chunk_index ORDER BY c.chunk_index) FILTER (WHERE c.chunk_index IS NOT NULL), '[]')
+		FROM expired LEFT JOIN UploadChunk AS c ON c.upload_id = expired.id GROUP BY expired.id, expired.user_id`,
+		// Timestamps are stored without time zone in UTC
+		before.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	uu := make([]*model.Upload, 0)
+	for rows.Next() {
+		u := &model.Upload{}
+		if err := rows.Scan(&u.ID, &u.UserID, jsonColumn{&u.Received}); err != nil {
+			return nil, err
+		}
+		uu = append(uu, u)
+	}
+	if rows.Err() != nil {
+		return nil, store.ErrUnableToGetRows
+	}
+	return uu, nil
+}
@@ -2,6 +2,7 @@ package sqlstore
 
 import (
 	"cenarius/internal/model"
+	"cenarius/internal/store"
 	"context"
 )
 
@@ -10,14 +11,14 @@ type UserRepository struct {
 }
 
 func (r *UserRepository) Ping() error {
-	return r.store.db.Ping()
+	return r.store.conn.Ping()
 }
 
 func (r *UserRepository) FindByLogin(ctx context.Context, login string) (*model.User, error) {
 	user := &model.User{}
 	if err := r.store.db.QueryRowContext(
-		ctx, "SELECT id, login, encrypted_password FROM users WHERE login = $1", login,
-	).Scan(&user.ID, &user.Login, &user.EncryptedPassword); err != nil {
+		ctx, "SELECT id, login, encrypted_password, kdf_salt, legacy_auth FROM users WHERE login = $1", login,
+	).Scan(&user.ID, &user.Login, &user.EncryptedPassword, &user.KDFSalt, &user.LegacyAuth); err != nil {
 		return nil, err
 	}
 	return user, nil
@@ -26,8 +27,8 @@ func (r *UserRepository) FindByLogin(ctx context.Context, login string) (*model.
 func (r *UserRepository) FindByID(ctx context.Context, id int) (*model.User, error) {
 	user := &model.User{}
 	if err := r.store.db.QueryRowContext(
-		ctx, "SELECT id, login, encrypted_password FROM users WHERE id = $1", id,
-	).Scan(&user.ID, &user.Login, &user.EncryptedPassword); err != nil {
+		ctx, "SELECT id, login, encrypted_password, kdf_salt, legacy_auth FROM users WHERE id = $1", id,
+	).Scan(&user.ID, &user.Login, &user.EncryptedPassword, &user.KDFSalt, &user.LegacyAuth); err != nil {
 		return nil, err
 	}
 	return user, nil
@@ -41,11 +42,35 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 		return err
 	}
 	if err := r.store.db.QueryRowContext(
-		ctx, "INSERT INTO users (login, encrypted_password) VALUES($1, $2) RETURNING id",
+		ctx, "INSERT INTO users (login, encrypted_password, kdf_salt) VALUES($1, $2, $3) RETURNING id",
 		user.Login,
 		user.EncryptedPassword,
+		user.KDFSalt,
 	).Scan(&user.ID); err != nil {
 		return err
 	}
 	return nil
 }
+
+// UpdatePassword stores new password hash and KDF salt of the user, the password is an auth key from now on
+func (r *UserRepository) UpdatePassword(ctx context.Context, user *model.User) error {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+	if err := user.BeforeCreate(); err != nil {
+		return err
+	}
+	res, err := r.store.db.ExecContext(
+		ctx, "UPDATE users SET encrypted_password=$1, kdf_salt=$2, legacy_auth=false WHERE id=$3",
+		user.EncryptedPassword,
+		user.KDFSalt,
+		user.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
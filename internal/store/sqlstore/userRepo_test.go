@@ -70,6 +70,7 @@ func TestUserRepository_FindByLogin(t *testing.T) {
 			if u != nil {
 				tt.want.ID = u.ID
 				tt.want.EncryptedPassword = u.EncryptedPassword
+				tt.want.KDFSalt = u.KDFSalt
 			}
 			if !reflect.DeepEqual(u, tt.want) {
 				t.Errorf("UserRepository.FindByLogin() = %v, want %v", u, tt.want)
@@ -94,6 +95,7 @@ func TestUserRepository_FindByID(t *testing.T) {
 			if u != nil {
 				tt.want.ID = u.ID
 				tt.want.EncryptedPassword = u.EncryptedPassword
+				tt.want.KDFSalt = u.KDFSalt
 			}
 			if !reflect.DeepEqual(u, tt.want) {
 				t.Errorf("UserRepository.FindByLogin() = %v, want %v", u, tt.want)
@@ -101,3 +103,28 @@ func TestUserRepository_FindByID(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRepository_UpdatePassword(t *testing.T) {
+	s, teardown := sqlstore.TestStore(t, databaseURL)
+	defer teardown("users")
+
+	u := &model.User{Login: "passwdlogin", Password: "old_password"}
+	if err := s.User().Create(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	u.Password = "new_password"
+	u.KDFSalt = "new_salt"
+	if err := s.User().UpdatePassword(context.Background(), u); err != nil {
+		t.Fatalf("UserRepository.UpdatePassword() error = %v", err)
+	}
+	got, err := s.User().FindByID(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.ComparePassword("new_password") || got.ComparePassword("old_password") {
+		t.Errorf("UserRepository.UpdatePassword() password is not changed")
+	}
+	if got.KDFSalt != "new_salt" {
+		t.Errorf("UserRepository.UpdatePassword() kdf_salt = %v, want %v", got.KDFSalt, "new_salt")
+	}
+}
@@ -1,10 +1,17 @@
 package store
 
+import (
+	"cenarius/internal/model"
+	"context"
+)
+
 type Store interface {
-	LoginWithPassword() LoginWithPasswordRepository
-	CreditCard() CreditCardRepository
-	SecretText() SecretTextRepository
-	SecretFile() SecretFileRepository
+	Secrets(model.Kind) SecretRepository
+	Upload() UploadRepository
+	Search() SearchRepository
 	User() UserRepository
+	WithTx(context.Context, func(Store) error) error
+	// LockRevisions keeps changes of the user from committing until the transaction ends, see WithTx
+	LockRevisions(context.Context, int) error
 	Close()
 }
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
 )
 
 func Input(w string) string {
@@ -23,6 +24,29 @@ func Input(w string) string {
 	return strings.Trim(text, "\n")
 }
 
+// InputPassword reads a line without echoing it back when stdin is a terminal
+func InputPassword(w string) string {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		reader := bufio.NewReader(os.Stdin)
+		fmt.Printf("Enter %s: ", w)
+		text, err := reader.ReadString('\n')
+		if err != nil {
+			log.Error(err)
+			return ""
+		}
+		return strings.TrimRight(text, "\r\n")
+	}
+	fmt.Printf("Enter %s: ", w)
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		log.Error(err)
+		return ""
+	}
+	return string(password)
+}
+
 func InputID() int {
 	InputID := Input("Id of secret")
 	id, err := strconv.Atoi(InputID)
@@ -0,0 +1,64 @@
+package userinput
+
+import (
+	"cenarius/internal/model"
+	"errors"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// InputSecret fills name, meta, labels and fields the user enters of the secret, fields of importable secrets
+// may be taken from a URI instead. Blob secrets get a path of the local file if askPath is set.
+// False is returned if the secret can't be filled.
+func InputSecret(m model.Secret, askPath bool) bool {
+	d := m.Data()
+	d.Name = Input("Secret Name")
+	if importer, ok := m.(model.URIImporter); ok {
+		if uri := Input("URI (empty to enter fields)"); uri != "" {
+			if err := importer.ParseURI(uri); err != nil {
+				log.Errorf("Wrong URI: %v", err)
+				return false
+			}
+			d.Meta = Input("Meta")
+			inputLabels(&d.Labels)
+			return true
+		}
+	}
+	for _, f := range m.Fields() {
+		switch {
+		case f.Generated:
+		case f.File:
+			path := Input(f.Label)
+			content, err := os.ReadFile(path)
+			if err != nil {
+				log.Errorf("Unable to read %s: %v", path, err)
+				return false
+			}
+			*f.Value = string(content)
+		case f.Hidden:
+			*f.Value = InputPassword(f.Label)
+		default:
+			*f.Value = Input(f.Label)
+		}
+	}
+	if b, ok := m.(model.BlobSecret); ok && askPath {
+		path := Input("File path")
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			log.Errorf("Path doesn't exist: %s", path)
+			return false
+		}
+		*b.BlobName() = path
+	}
+	d.Meta = Input("Meta")
+	inputLabels(&d.Labels)
+	return true
+}
+
+// inputLabels replaces labels with the entered folder, tags and favorite mark
+func inputLabels(l *model.Labels) {
+	l.Folder = model.CleanFolder(Input("Folder (subfolders are separated by /)"))
+	l.Tags = model.ParseTags(Input("Tags (comma separated)"))
+	l.Favorite = strings.EqualFold(strings.TrimSpace(Input("Favorite (y/n)")), "y")
+}
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS "kdf_salt";
+ALTER TABLE SecretFile DROP COLUMN IF EXISTS "file_key";
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS "kdf_salt" varchar not null default '';
+UPDATE users SET kdf_salt = md5(random()::text || clock_timestamp()::text || id::text) WHERE kdf_salt = '';
+
+ALTER TABLE SecretFile ADD COLUMN IF NOT EXISTS "file_key" varchar not null default '';
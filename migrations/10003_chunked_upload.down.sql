@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS UploadChunk;
+DROP TABLE IF EXISTS Upload;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS Upload(
+    "id" varchar not null primary key,
+    "user_id" int not null,
+    "name" varchar,
+    "meta" text,
+    "file_key" varchar not null,
+    "size" bigint not null,
+    "chunk_size" int not null,
+    "created_at" timestamp default NOW()
+);
+
+CREATE TABLE IF NOT EXISTS UploadChunk(
+    "upload_id" varchar not null references Upload (id) ON DELETE CASCADE,
+    "chunk_index" int not null,
+    "checksum" varchar not null,
+    "size" int not null,
+    primary key ("upload_id", "chunk_index")
+);
+
+CREATE INDEX UploadUserID_idx ON Upload (user_id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS OTPSecret(
+    "id" bigserial not null primary key,
+    "user_id" int not null,
+    "name" varchar,
+    "meta" text,
+    "issuer" varchar not null,
+    "account" varchar not null,
+    "seed" varchar not null,
+    "algorithm" varchar not null,
+    "digits" varchar not null,
+    "period" varchar not null,
+    "created_at" timestamp default NOW()
+);
+
+CREATE INDEX OTPSecretUserID_idx ON OTPSecret (user_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS KeyPair(
+    "id" bigserial not null primary key,
+    "user_id" int not null,
+    "name" varchar,
+    "meta" text,
+    "key_type" varchar not null,
+    "private_key" text not null,
+    "passphrase" varchar not null,
+    "public_key" text not null,
+    "fingerprint" varchar not null,
+    "created_at" timestamp default NOW()
+);
+
+CREATE INDEX KeyPairUserID_idx ON KeyPair (user_id);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS SecretTombstone;
+ALTER TABLE LoginWithPassword DROP COLUMN IF EXISTS "updated_at", DROP COLUMN IF EXISTS "revision";
+ALTER TABLE CreditCard DROP COLUMN IF EXISTS "updated_at", DROP COLUMN IF EXISTS "revision";
+ALTER TABLE SecretText DROP COLUMN IF EXISTS "updated_at", DROP COLUMN IF EXISTS "revision";
+ALTER TABLE SecretFile DROP COLUMN IF EXISTS "updated_at", DROP COLUMN IF EXISTS "revision";
+ALTER TABLE OTPSecret DROP COLUMN IF EXISTS "updated_at", DROP COLUMN IF EXISTS "revision";
+ALTER TABLE KeyPair DROP COLUMN IF EXISTS "updated_at", DROP COLUMN IF EXISTS "revision";
+DROP SEQUENCE IF EXISTS secret_revision_seq;
@@ -0,0 +1,42 @@
+CREATE SEQUENCE IF NOT EXISTS secret_revision_seq;
+
+ALTER TABLE LoginWithPassword
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamp not null default NOW(),
+    ADD COLUMN IF NOT EXISTS "revision" bigint not null default nextval('secret_revision_seq');
+CREATE INDEX IF NOT EXISTS LoginWithPasswordRevision_idx ON LoginWithPassword (user_id, revision);
+
+ALTER TABLE CreditCard
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamp not null default NOW(),
+    ADD COLUMN IF NOT EXISTS "revision" bigint not null default nextval('secret_revision_seq');
+CREATE INDEX IF NOT EXISTS CreditCardRevision_idx ON CreditCard (user_id, revision);
+
+ALTER TABLE SecretText
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamp not null default NOW(),
+    ADD COLUMN IF NOT EXISTS "revision" bigint not null default nextval('secret_revision_seq');
+CREATE INDEX IF NOT EXISTS SecretTextRevision_idx ON SecretText (user_id, revision);
+
+ALTER TABLE SecretFile
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamp not null default NOW(),
+    ADD COLUMN IF NOT EXISTS "revision" bigint not null default nextval('secret_revision_seq');
+CREATE INDEX IF NOT EXISTS SecretFileRevision_idx ON SecretFile (user_id, revision);
+
+ALTER TABLE OTPSecret
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamp not null default NOW(),
+    ADD COLUMN IF NOT EXISTS "revision" bigint not null default nextval('secret_revision_seq');
+CREATE INDEX IF NOT EXISTS OTPSecretRevision_idx ON OTPSecret (user_id, revision);
+
+ALTER TABLE KeyPair
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamp not null default NOW(),
+    ADD COLUMN IF NOT EXISTS "revision" bigint not null default nextval('secret_revision_seq');
+CREATE INDEX IF NOT EXISTS KeyPairRevision_idx ON KeyPair (user_id, revision);
+
+CREATE TABLE IF NOT EXISTS SecretTombstone(
+    "kind" varchar not null,
+    "secret_id" bigint not null,
+    "user_id" int not null,
+    "revision" bigint not null default nextval('secret_revision_seq'),
+    "deleted_at" timestamp not null default NOW(),
+    primary key ("kind", "secret_id")
+);
+
+CREATE INDEX IF NOT EXISTS SecretTombstoneRevision_idx ON SecretTombstone (user_id, revision);
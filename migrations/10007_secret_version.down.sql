@@ -0,0 +1,6 @@
+ALTER TABLE LoginWithPassword DROP COLUMN IF EXISTS "version";
+ALTER TABLE CreditCard DROP COLUMN IF EXISTS "version";
+ALTER TABLE SecretText DROP COLUMN IF EXISTS "version";
+ALTER TABLE SecretFile DROP COLUMN IF EXISTS "version";
+ALTER TABLE OTPSecret DROP COLUMN IF EXISTS "version";
+ALTER TABLE KeyPair DROP COLUMN IF EXISTS "version";
@@ -0,0 +1,6 @@
+ALTER TABLE LoginWithPassword ADD COLUMN IF NOT EXISTS "version" int not null default 1;
+ALTER TABLE CreditCard ADD COLUMN IF NOT EXISTS "version" int not null default 1;
+ALTER TABLE SecretText ADD COLUMN IF NOT EXISTS "version" int not null default 1;
+ALTER TABLE SecretFile ADD COLUMN IF NOT EXISTS "version" int not null default 1;
+ALTER TABLE OTPSecret ADD COLUMN IF NOT EXISTS "version" int not null default 1;
+ALTER TABLE KeyPair ADD COLUMN IF NOT EXISTS "version" int not null default 1;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS SecretHistory;
+ALTER TABLE LoginWithPassword DROP COLUMN IF EXISTS "client_id";
+ALTER TABLE CreditCard DROP COLUMN IF EXISTS "client_id";
+ALTER TABLE SecretText DROP COLUMN IF EXISTS "client_id";
+ALTER TABLE SecretFile DROP COLUMN IF EXISTS "client_id";
+ALTER TABLE OTPSecret DROP COLUMN IF EXISTS "client_id";
+ALTER TABLE KeyPair DROP COLUMN IF EXISTS "client_id";
@@ -0,0 +1,21 @@
+ALTER TABLE LoginWithPassword ADD COLUMN IF NOT EXISTS "client_id" varchar not null default '';
+ALTER TABLE CreditCard ADD COLUMN IF NOT EXISTS "client_id" varchar not null default '';
+ALTER TABLE SecretText ADD COLUMN IF NOT EXISTS "client_id" varchar not null default '';
+ALTER TABLE SecretFile ADD COLUMN IF NOT EXISTS "client_id" varchar not null default '';
+ALTER TABLE OTPSecret ADD COLUMN IF NOT EXISTS "client_id" varchar not null default '';
+ALTER TABLE KeyPair ADD COLUMN IF NOT EXISTS "client_id" varchar not null default '';
+
+CREATE TABLE IF NOT EXISTS SecretHistory(
+    "kind" varchar not null,
+    "secret_id" bigint not null,
+    "user_id" int not null,
+    "version" int not null,
+    "client_id" varchar not null,
+    "updated_at" timestamp not null,
+    "replaced_at" timestamp not null default NOW(),
+    "op" varchar not null,
+    "data" jsonb not null,
+    primary key ("kind", "secret_id", "version")
+);
+
+CREATE INDEX IF NOT EXISTS SecretHistoryUser_idx ON SecretHistory (user_id);
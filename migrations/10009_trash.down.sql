@@ -0,0 +1,12 @@
+DELETE FROM LoginWithPassword WHERE deleted_at IS NOT NULL;
+ALTER TABLE LoginWithPassword DROP COLUMN IF EXISTS "deleted_at";
+DELETE FROM CreditCard WHERE deleted_at IS NOT NULL;
+ALTER TABLE CreditCard DROP COLUMN IF EXISTS "deleted_at";
+DELETE FROM SecretText WHERE deleted_at IS NOT NULL;
+ALTER TABLE SecretText DROP COLUMN IF EXISTS "deleted_at";
+DELETE FROM SecretFile WHERE deleted_at IS NOT NULL;
+ALTER TABLE SecretFile DROP COLUMN IF EXISTS "deleted_at";
+DELETE FROM OTPSecret WHERE deleted_at IS NOT NULL;
+ALTER TABLE OTPSecret DROP COLUMN IF EXISTS "deleted_at";
+DELETE FROM KeyPair WHERE deleted_at IS NOT NULL;
+ALTER TABLE KeyPair DROP COLUMN IF EXISTS "deleted_at";
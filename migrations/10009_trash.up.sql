@@ -0,0 +1,17 @@
+ALTER TABLE LoginWithPassword ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+CREATE INDEX IF NOT EXISTS LoginWithPasswordTrash_idx ON LoginWithPassword (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE CreditCard ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+CREATE INDEX IF NOT EXISTS CreditCardTrash_idx ON CreditCard (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE SecretText ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+CREATE INDEX IF NOT EXISTS SecretTextTrash_idx ON SecretText (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE SecretFile ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+CREATE INDEX IF NOT EXISTS SecretFileTrash_idx ON SecretFile (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE OTPSecret ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+CREATE INDEX IF NOT EXISTS OTPSecretTrash_idx ON OTPSecret (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE KeyPair ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+CREATE INDEX IF NOT EXISTS KeyPairTrash_idx ON KeyPair (deleted_at) WHERE deleted_at IS NOT NULL;
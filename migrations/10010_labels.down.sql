@@ -0,0 +1,15 @@
+ALTER TABLE Upload DROP COLUMN IF EXISTS "labels";
+ALTER TABLE LoginWithPassword DROP COLUMN IF EXISTS "folder_id";
+ALTER TABLE LoginWithPassword DROP COLUMN IF EXISTS "favorite";
+ALTER TABLE CreditCard DROP COLUMN IF EXISTS "folder_id";
+ALTER TABLE CreditCard DROP COLUMN IF EXISTS "favorite";
+ALTER TABLE SecretText DROP COLUMN IF EXISTS "folder_id";
+ALTER TABLE SecretText DROP COLUMN IF EXISTS "favorite";
+ALTER TABLE SecretFile DROP COLUMN IF EXISTS "folder_id";
+ALTER TABLE SecretFile DROP COLUMN IF EXISTS "favorite";
+ALTER TABLE OTPSecret DROP COLUMN IF EXISTS "folder_id";
+ALTER TABLE OTPSecret DROP COLUMN IF EXISTS "favorite";
+ALTER TABLE KeyPair DROP COLUMN IF EXISTS "folder_id";
+ALTER TABLE KeyPair DROP COLUMN IF EXISTS "favorite";
+DROP TABLE IF EXISTS SecretTag;
+DROP TABLE IF EXISTS Folder;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS Folder(
+    "id" bigserial not null primary key,
+    "user_id" int not null,
+    "path" varchar not null,
+    unique ("user_id", "path")
+);
+
+CREATE TABLE IF NOT EXISTS SecretTag(
+    "kind" varchar not null,
+    "secret_id" bigint not null,
+    "user_id" int not null,
+    "tag" varchar not null,
+    primary key ("kind", "secret_id", "tag")
+);
+
+CREATE INDEX IF NOT EXISTS SecretTagUser_idx ON SecretTag (user_id, tag);
+
+ALTER TABLE LoginWithPassword ADD COLUMN IF NOT EXISTS "folder_id" bigint references Folder (id);
+ALTER TABLE LoginWithPassword ADD COLUMN IF NOT EXISTS "favorite" boolean not null default false;
+ALTER TABLE CreditCard ADD COLUMN IF NOT EXISTS "folder_id" bigint references Folder (id);
+ALTER TABLE CreditCard ADD COLUMN IF NOT EXISTS "favorite" boolean not null default false;
+ALTER TABLE SecretText ADD COLUMN IF NOT EXISTS "folder_id" bigint references Folder (id);
+ALTER TABLE SecretText ADD COLUMN IF NOT EXISTS "favorite" boolean not null default false;
+ALTER TABLE SecretFile ADD COLUMN IF NOT EXISTS "folder_id" bigint references Folder (id);
+ALTER TABLE SecretFile ADD COLUMN IF NOT EXISTS "favorite" boolean not null default false;
+ALTER TABLE OTPSecret ADD COLUMN IF NOT EXISTS "folder_id" bigint references Folder (id);
+ALTER TABLE OTPSecret ADD COLUMN IF NOT EXISTS "favorite" boolean not null default false;
+ALTER TABLE KeyPair ADD COLUMN IF NOT EXISTS "folder_id" bigint references Folder (id);
+ALTER TABLE KeyPair ADD COLUMN IF NOT EXISTS "favorite" boolean not null default false;
+
+ALTER TABLE Upload ADD COLUMN IF NOT EXISTS "labels" jsonb not null default '{}';
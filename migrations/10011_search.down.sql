@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS KeyPairTrgm_idx;
+DROP INDEX IF EXISTS KeyPairSearch_idx;
+DROP INDEX IF EXISTS OTPSecretTrgm_idx;
+DROP INDEX IF EXISTS OTPSecretSearch_idx;
+DROP INDEX IF EXISTS SecretFileTrgm_idx;
+DROP INDEX IF EXISTS SecretFileSearch_idx;
+DROP INDEX IF EXISTS SecretTextTrgm_idx;
+DROP INDEX IF EXISTS SecretTextSearch_idx;
+DROP INDEX IF EXISTS CreditCardTrgm_idx;
+DROP INDEX IF EXISTS CreditCardSearch_idx;
+DROP INDEX IF EXISTS LoginWithPasswordTrgm_idx;
+DROP INDEX IF EXISTS LoginWithPasswordSearch_idx;
@@ -0,0 +1,14 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS LoginWithPasswordSearch_idx ON LoginWithPassword USING gin (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(meta, '')));
+CREATE INDEX IF NOT EXISTS LoginWithPasswordTrgm_idx ON LoginWithPassword USING gin (lower(coalesce(name, '') || ' ' || coalesce(meta, '')) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS CreditCardSearch_idx ON CreditCard USING gin (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(meta, '')));
+CREATE INDEX IF NOT EXISTS CreditCardTrgm_idx ON CreditCard USING gin (lower(coalesce(name, '') || ' ' || coalesce(meta, '')) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS SecretTextSearch_idx ON SecretText USING gin (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(meta, '')));
+CREATE INDEX IF NOT EXISTS SecretTextTrgm_idx ON SecretText USING gin (lower(coalesce(name, '') || ' ' || coalesce(meta, '')) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS SecretFileSearch_idx ON SecretFile USING gin (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(meta, '')));
+CREATE INDEX IF NOT EXISTS SecretFileTrgm_idx ON SecretFile USING gin (lower(coalesce(name, '') || ' ' || coalesce(meta, '')) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS OTPSecretSearch_idx ON OTPSecret USING gin (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(meta, '')));
+CREATE INDEX IF NOT EXISTS OTPSecretTrgm_idx ON OTPSecret USING gin (lower(coalesce(name, '') || ' ' || coalesce(meta, '')) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS KeyPairSearch_idx ON KeyPair USING gin (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(meta, '')));
+CREATE INDEX IF NOT EXISTS KeyPairTrgm_idx ON KeyPair USING gin (lower(coalesce(name, '') || ' ' || coalesce(meta, '')) gin_trgm_ops);
@@ -0,0 +1,24 @@
+DROP INDEX IF EXISTS KeyPairUpdatedOrder_idx;
+DROP INDEX IF EXISTS KeyPairCreatedOrder_idx;
+DROP INDEX IF EXISTS KeyPairNameOrder_idx;
+ALTER TABLE KeyPair ALTER COLUMN "created_at" DROP NOT NULL;
+DROP INDEX IF EXISTS OTPSecretUpdatedOrder_idx;
+DROP INDEX IF EXISTS OTPSecretCreatedOrder_idx;
+DROP INDEX IF EXISTS OTPSecretNameOrder_idx;
+ALTER TABLE OTPSecret ALTER COLUMN "created_at" DROP NOT NULL;
+DROP INDEX IF EXISTS SecretFileUpdatedOrder_idx;
+DROP INDEX IF EXISTS SecretFileCreatedOrder_idx;
+DROP INDEX IF EXISTS SecretFileNameOrder_idx;
+ALTER TABLE SecretFile ALTER COLUMN "created_at" DROP NOT NULL;
+DROP INDEX IF EXISTS SecretTextUpdatedOrder_idx;
+DROP INDEX IF EXISTS SecretTextCreatedOrder_idx;
+DROP INDEX IF EXISTS SecretTextNameOrder_idx;
+ALTER TABLE SecretText ALTER COLUMN "created_at" DROP NOT NULL;
+DROP INDEX IF EXISTS CreditCardUpdatedOrder_idx;
+DROP INDEX IF EXISTS CreditCardCreatedOrder_idx;
+DROP INDEX IF EXISTS CreditCardNameOrder_idx;
+ALTER TABLE CreditCard ALTER COLUMN "created_at" DROP NOT NULL;
+DROP INDEX IF EXISTS LoginWithPasswordUpdatedOrder_idx;
+DROP INDEX IF EXISTS LoginWithPasswordCreatedOrder_idx;
+DROP INDEX IF EXISTS LoginWithPasswordNameOrder_idx;
+ALTER TABLE LoginWithPassword ALTER COLUMN "created_at" DROP NOT NULL;
@@ -0,0 +1,35 @@
+UPDATE LoginWithPassword SET created_at = updated_at WHERE created_at IS NULL;
+ALTER TABLE LoginWithPassword ALTER COLUMN "created_at" SET NOT NULL;
+CREATE INDEX IF NOT EXISTS LoginWithPasswordNameOrder_idx ON LoginWithPassword (user_id, coalesce(name, ''), id);
+CREATE INDEX IF NOT EXISTS LoginWithPasswordCreatedOrder_idx ON LoginWithPassword (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS LoginWithPasswordUpdatedOrder_idx ON LoginWithPassword (user_id, updated_at, id);
+
+UPDATE CreditCard SET created_at = updated_at WHERE created_at IS NULL;
+ALTER TABLE CreditCard ALTER COLUMN "created_at" SET NOT NULL;
+CREATE INDEX IF NOT EXISTS CreditCardNameOrder_idx ON CreditCard (user_id, coalesce(name, ''), id);
+CREATE INDEX IF NOT EXISTS CreditCardCreatedOrder_idx ON CreditCard (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS CreditCardUpdatedOrder_idx ON CreditCard (user_id, updated_at, id);
+
+UPDATE SecretText SET created_at = updated_at WHERE created_at IS NULL;
+ALTER TABLE SecretText ALTER COLUMN "created_at" SET NOT NULL;
+CREATE INDEX IF NOT EXISTS SecretTextNameOrder_idx ON SecretText (user_id, coalesce(name, ''), id);
+CREATE INDEX IF NOT EXISTS SecretTextCreatedOrder_idx ON SecretText (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS SecretTextUpdatedOrder_idx ON SecretText (user_id, updated_at, id);
+
+UPDATE SecretFile SET created_at = updated_at WHERE created_at IS NULL;
+ALTER TABLE SecretFile ALTER COLUMN "created_at" SET NOT NULL;
+CREATE INDEX IF NOT EXISTS SecretFileNameOrder_idx ON SecretFile (user_id, coalesce(name, ''), id);
+CREATE INDEX IF NOT EXISTS SecretFileCreatedOrder_idx ON SecretFile (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS SecretFileUpdatedOrder_idx ON SecretFile (user_id, updated_at, id);
+
+UPDATE OTPSecret SET created_at = updated_at WHERE created_at IS NULL;
+ALTER TABLE OTPSecret ALTER COLUMN "created_at" SET NOT NULL;
+CREATE INDEX IF NOT EXISTS OTPSecretNameOrder_idx ON OTPSecret (user_id, coalesce(name, ''), id);
+CREATE INDEX IF NOT EXISTS OTPSecretCreatedOrder_idx ON OTPSecret (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS OTPSecretUpdatedOrder_idx ON OTPSecret (user_id, updated_at, id);
+
+UPDATE KeyPair SET created_at = updated_at WHERE created_at IS NULL;
+ALTER TABLE KeyPair ALTER COLUMN "created_at" SET NOT NULL;
+CREATE INDEX IF NOT EXISTS KeyPairNameOrder_idx ON KeyPair (user_id, coalesce(name, ''), id);
+CREATE INDEX IF NOT EXISTS KeyPairCreatedOrder_idx ON KeyPair (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS KeyPairUpdatedOrder_idx ON KeyPair (user_id, updated_at, id);
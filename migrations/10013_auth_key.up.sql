@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS "legacy_auth" boolean not null default false;
+UPDATE users SET legacy_auth = true;